@@ -0,0 +1,271 @@
+package thunder
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// analyzeSampleRows bounds how many rows Analyze reads.
+	analyzeSampleRows = 10000
+	// analyzeBuckets is the number of buckets of each histogram.
+	analyzeBuckets = 32
+)
+
+// ColumnStats summarises the values of a column, or of a composite index,
+// as of the last Analyze.
+type ColumnStats struct {
+	// Rows is the number of rows in the relation when it was analyzed, and
+	// Sampled how many of them were read.
+	Rows    int
+	Sampled int
+	// Distinct estimates the number of distinct values.
+	Distinct int
+	// Bounds are the upper bounds, as ToKey keys, of an equi-depth
+	// histogram: about Rows/len(Bounds) rows fall in each bucket.
+	Bounds [][]byte
+}
+
+// Analyze samples the rows of the relation to build per-column histograms
+// and distinct counts, which the planner then uses to estimate how many rows
+// each candidate index yields. Statistics are not kept up to date by writes;
+// run Analyze again after the data changes significantly. Encrypted columns
+// are skipped.
+func (pr *Persistent) Analyze() error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if pr.ephemeral {
+		return nil
+	}
+	rows, err := bucketKeyCount(pr.data.bucket)
+	if err != nil {
+		return err
+	}
+	sample, err := pr.sampleRows(rows)
+	if err != nil {
+		return err
+	}
+	stats := make(map[string]ColumnStats)
+	for name, spec := range pr.fields {
+		if spec.EncryptionKey != "" {
+			continue
+		}
+		keys := make([][]byte, 0, len(sample))
+		for _, row := range sample {
+			key, err := pr.computeKey(row, name)
+			if err != nil {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		stats[name] = newColumnStats(keys, rows)
+	}
+	raw, err := pr.maUn.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	root, err := pr.tx.tx.CreateBucketIfNotExists([]byte(statsBucket))
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte("relations"))
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put([]byte(pr.relation), raw); err != nil {
+		return err
+	}
+	pr.analysis, pr.analysisLoaded = stats, true
+	return nil
+}
+
+// sampleRows reads up to analyzeSampleRows rows chosen uniformly at random.
+func (pr *Persistent) sampleRows(rows int) ([]map[string]any, error) {
+	sample := make([]map[string]any, 0, min(rows, analyzeSampleRows))
+	seen := 0
+	c := pr.data.bucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		seen++
+		slot := len(sample)
+		if slot >= analyzeSampleRows {
+			// Reservoir sampling: keep each row with probability n/seen.
+			if slot = rand.IntN(seen); slot >= analyzeSampleRows {
+				continue
+			}
+		}
+		var row map[string]any
+		if err := pr.data.unmarshal(k, v, &row); err != nil {
+			return nil, err
+		}
+		if slot == len(sample) {
+			sample = append(sample, row)
+		} else {
+			sample[slot] = row
+		}
+	}
+	return sample, nil
+}
+
+func newColumnStats(keys [][]byte, rows int) ColumnStats {
+	s := ColumnStats{Rows: rows, Sampled: len(keys)}
+	if len(keys) == 0 {
+		return s
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	distinct, once := 0, 0
+	for i := 0; i < len(keys); {
+		j := i + 1
+		for j < len(keys) && bytes.Equal(keys[i], keys[j]) {
+			j++
+		}
+		distinct++
+		if j-i == 1 {
+			once++
+		}
+		i = j
+	}
+	s.Distinct = distinct
+	if n := len(keys); n < rows {
+		// Haas and Stokes' Duj1 estimator scales the sample's distinct
+		// count by how many of its values were seen only once.
+		estimate := float64(n*distinct) / (float64(n-once) + float64(once*n)/float64(rows))
+		s.Distinct = int(min(float64(rows), estimate))
+	}
+	buckets := min(analyzeBuckets, len(keys))
+	for i := 1; i <= buckets; i++ {
+		s.Bounds = append(s.Bounds, keys[i*len(keys)/buckets-1])
+	}
+	return s
+}
+
+// estimate returns the number of rows expected to fall in kr.
+func (s ColumnStats) estimate(kr *keyRange) float64 {
+	if len(s.Bounds) == 0 {
+		return 0
+	}
+	perBucket := float64(s.Rows) / float64(len(s.Bounds))
+	covered := 0
+	for _, bound := range s.Bounds {
+		if kr.contains(bound) {
+			covered++
+		}
+	}
+	if kr.startKey != nil && kr.includeStart && kr.includeEnd && bytes.Equal(kr.startKey, kr.endKey) {
+		// A value bounding several buckets is frequent enough for the
+		// histogram to tell how frequent.
+		if covered > 1 {
+			return float64(covered) * perBucket
+		}
+		return float64(s.Rows) / float64(max(1, s.Distinct))
+	}
+	// A range between two bounds still holds part of a bucket.
+	return (float64(covered) + 0.5) * perBucket
+}
+
+// ColumnStats returns the statistics gathered by the last Analyze, keyed by
+// column and composite index, or nil if the relation was never analyzed.
+func (pr *Persistent) ColumnStats() (map[string]ColumnStats, error) {
+	if pr.analysisLoaded {
+		return pr.analysis, nil
+	}
+	var stats map[string]ColumnStats
+	if root := pr.tx.tx.Bucket([]byte(statsBucket)); root != nil {
+		if bucket := root.Bucket([]byte("relations")); bucket != nil {
+			if raw := bucket.Get([]byte(pr.relation)); raw != nil {
+				if err := pr.maUn.Unmarshal(raw, &stats); err != nil {
+					return nil, ErrCorruptedMetaDataEntry(statsBucket, pr.relation)
+				}
+			}
+		}
+	}
+	pr.analysis, pr.analysisLoaded = stats, true
+	return stats, nil
+}
+
+// analyzedBestIndex returns the candidate with the fewest estimated rows,
+// provided every candidate has been analyzed.
+func (pr *Persistent) analyzedBestIndex(candidates []string, ranges map[string]*keyRange) (string, bool) {
+	if pr.ephemeral || len(candidates) < 2 {
+		return "", false
+	}
+	stats, err := pr.ColumnStats()
+	if err != nil || stats == nil {
+		return "", false
+	}
+	best := ""
+	bestRows := 0.0
+	for _, name := range candidates {
+		s, ok := stats[name]
+		if !ok {
+			return "", false
+		}
+		rows := s.estimate(ranges[name])
+		if best == "" || rows < bestRows {
+			best, bestRows = name, rows
+		}
+	}
+	return best, true
+}
+
+// forgetAnalysis drops the statistics of a relation that no longer exists.
+func (tx *Tx) forgetAnalysis(relation string) error {
+	root := tx.tx.Bucket([]byte(statsBucket))
+	if root == nil {
+		return nil
+	}
+	bucket := root.Bucket([]byte("relations"))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete([]byte(relation))
+}
+
+// Selectivities EstimateCount assumes for a range on a column without
+// statistics, after the classic System R defaults.
+const (
+	defaultEqSelectivity    = 0.1
+	defaultRangeSelectivity = 1.0 / 3
+)
+
+// EstimateCount estimates how many rows match every op without reading
+// them. Each op is assumed independent of the others and scales the row
+// count by its selectivity, read off the histograms of the last Analyze or,
+// for columns never analyzed, a fixed default. The estimate is meant for
+// pagination and dashboards; use Select to count exactly.
+func (pr *Persistent) EstimateCount(ops ...Op) (int, error) {
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return 0, err
+	}
+	// The policy's ops are estimated like the caller's.
+	if ranges, err = pr.restrict(ranges); err != nil {
+		return 0, err
+	}
+	rows, err := bucketKeyCount(pr.data.bucket)
+	if err != nil || len(ranges) == 0 {
+		return rows, err
+	}
+	stats, err := pr.ColumnStats()
+	if err != nil {
+		return 0, err
+	}
+	estimate := float64(rows)
+	for name, kr := range pr.coerceRanges(ranges) {
+		if _, ok := pr.fields[name]; !ok && !pr.isPath(name) {
+			return 0, ErrFieldNotFound(name)
+		}
+		s, ok := stats[name]
+		switch {
+		case ok && s.Rows > 0 && !kr.elementwise():
+			estimate *= min(1, s.estimate(kr)/float64(s.Rows))
+		case kr.startKey != nil && kr.includeStart && kr.includeEnd && bytes.Equal(kr.startKey, kr.endKey):
+			estimate *= defaultEqSelectivity
+		default:
+			estimate *= defaultRangeSelectivity
+		}
+	}
+	return int(math.Round(estimate)), nil
+}
@@ -0,0 +1,136 @@
+package thunder
+
+import "errors"
+
+// Archive moves the rows of relation matching every op into the relation
+// archive, creating it with the columns of relation when missing, and
+// returns how many rows were moved. The rows, and the blobs they refer to,
+// are written to archive before they are deleted from relation, and a
+// failure undoes both, so a row is either in relation or in archive.
+func (tx *Tx) Archive(relation, archive string, ops ...Op) (int, error) {
+	hot, err := tx.LoadPersistent(relation)
+	if err != nil {
+		return 0, err
+	}
+	if err := hot.checkWritable(); err != nil {
+		return 0, err
+	}
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return 0, err
+	}
+	sp := tx.savepoint()
+	n, err := tx.archive(hot, archive, ranges)
+	if err != nil {
+		return 0, errors.Join(err, tx.RollbackTo(sp), tx.Release(sp))
+	}
+	return n, tx.Release(sp)
+}
+
+func (tx *Tx) archive(hot *Persistent, archive string, ranges map[string]*keyRange) (int, error) {
+	entries, err := hot.archiveEntries(ranges)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	cold, err := tx.archiveRelation(archive, hot.ColumnSpecs())
+	if err != nil {
+		return 0, err
+	}
+	if err := cold.archiveInsert(hot, entries); err != nil {
+		return 0, err
+	}
+	return hot.deleteEntries(entries)
+}
+
+// ArchiveTo is Tx.Archive with the archive relation kept in dst, typically a
+// database in another, colder file. The rows are written to dst before the
+// transaction deleting them from relation commits, and that transaction is
+// rolled back when dst fails, so a crash between the two commits can leave a
+// row in both relations but never in neither. dst must not be d; use
+// Tx.Archive to archive within one database.
+func (d *DB) ArchiveTo(dst *DB, relation, archive string, ops ...Op) (int, error) {
+	if dst == d {
+		return 0, ErrSameDatabase()
+	}
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	err = d.update(func(tx *Tx) error {
+		hot, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		entries, err := hot.archiveEntries(ranges)
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+		err = dst.update(func(dtx *Tx) error {
+			cold, err := dtx.archiveRelation(archive, hot.ColumnSpecs())
+			if err != nil {
+				return err
+			}
+			return cold.archiveInsert(hot, entries)
+		})
+		if err != nil {
+			return err
+		}
+		n, err = hot.deleteEntries(entries)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (tx *Tx) archiveRelation(name string, columns map[string]ColumnSpec) (*Persistent, error) {
+	if isRelationBucket(tx.tx.Bucket([]byte(name))) {
+		return tx.LoadPersistent(name)
+	}
+	return tx.CreatePersistent(name, columns)
+}
+
+// archiveEntries returns the stored rows matching ranges.
+func (pr *Persistent) archiveEntries(ranges map[string]*keyRange) ([]entry, error) {
+	seq, err := pr.iter(ranges)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	for e, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// archiveInsert inserts the rows of entries, stored in src, into pr, copying
+// the blobs they refer to into blobs of pr.
+func (pr *Persistent) archiveInsert(src *Persistent, entries []entry) error {
+	rows := make([]map[string]any, len(entries))
+	for i, e := range entries {
+		row, err := src.rowCopy(e.value)
+		if err != nil {
+			return err
+		}
+		for _, col := range src.columns {
+			if src.fields[col].Type != TypeBlob {
+				continue
+			}
+			id, ok := blobID(row[col])
+			if !ok {
+				continue
+			}
+			if row[col], err = pr.copyBlob(src, id); err != nil {
+				return err
+			}
+		}
+		rows[i] = row
+	}
+	_, err := pr.InsertMany(rows)
+	return err
+}
@@ -0,0 +1,194 @@
+package thunder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	cold, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cold.Close()
+	err = db.update(func(tx *Tx) error {
+		orders, err := tx.CreatePersistent("orders", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"year": {Type: TypeInt, Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, year := range []int{2021, 2022, 2023, 2024, 2025} {
+			if err := orders.Insert(map[string]any{"id": i, "year": year}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(db *DB, relation string) int {
+		t.Helper()
+		rows, err := db.Query("SELECT * FROM " + relation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	var n int
+	err = db.update(func(tx *Tx) (err error) {
+		n, err = tx.Archive("orders", "orders_archive", Lt("year", 2023))
+		return err
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rows archived, got %d, %v", n, err)
+	}
+	if got := count(db, "orders"); got != 3 {
+		t.Fatalf("expected 3 hot rows, got %d", got)
+	}
+	if got := count(db, "orders_archive"); got != 2 {
+		t.Fatalf("expected 2 archived rows, got %d", got)
+	}
+
+	n, err = db.ArchiveTo(cold, "orders", "orders", Lt("year", 2025))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rows moved to the cold database, got %d, %v", n, err)
+	}
+	if got := count(db, "orders"); got != 1 {
+		t.Fatalf("expected 1 hot row, got %d", got)
+	}
+	rows, err := cold.Query("SELECT id FROM orders WHERE year = 2024")
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("expected the 2024 order in the cold database, got %v, %v", rows, err)
+	}
+
+	// A failure in the cold database leaves the hot rows in place.
+	err = cold.update(func(tx *Tx) error {
+		return tx.DropRelation("orders")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cold.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("orders", map[string]ColumnSpec{"id": {Type: TypeString}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ArchiveTo(cold, "orders", "orders"); err == nil {
+		t.Fatal("expected archiving into an incompatible relation to fail")
+	}
+	if got := count(db, "orders"); got != 1 {
+		t.Fatalf("expected the hot row to stay, got %d", got)
+	}
+}
+
+func TestArchive_BlobsAndFailures(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"body": {Type: TypeBlob},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 2 {
+			w, err := docs.CreateBlob()
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte(fmt.Sprintf("body %d", i))); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			if err := docs.Insert(map[string]any{"id": i, "body": w.Blob()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The archived row keeps its blob.
+	err = db.update(func(tx *Tx) error {
+		if n, err := tx.Archive("docs", "docs_old", Eq("id", 0)); err != nil || n != 1 {
+			t.Fatalf("expected 1 row archived, got %d, %v", n, err)
+		}
+		old, err := tx.LoadPersistent("docs_old")
+		if err != nil {
+			return err
+		}
+		seq, err := old.Select(nil)
+		if err != nil {
+			return err
+		}
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			r, err := old.OpenBlob(row["body"])
+			if err != nil {
+				return err
+			}
+			body, err := io.ReadAll(r)
+			if err != nil || string(body) != "body 0" {
+				t.Errorf("expected the archived blob, got %q, %v", body, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed archive insert leaves the hot rows in place.
+	err = db.update(func(tx *Tx) error {
+		old, err := tx.LoadPersistent("docs_old")
+		if err != nil {
+			return err
+		}
+		w, err := old.CreateBlob()
+		if err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if err := old.Insert(map[string]any{"id": 1, "body": w.Blob()}); err != nil {
+			return err
+		}
+		if _, err := tx.Archive("docs", "docs_old", Eq("id", 1)); err == nil {
+			t.Error("expected a unique violation in the archive")
+		}
+		docs, err := tx.LoadPersistent("docs")
+		if err != nil {
+			return err
+		}
+		if n, err := docs.Count(); err != nil || n != 1 {
+			t.Errorf("expected the hot row to stay, got %d, %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var te *ThunderError
+	if _, err := db.ArchiveTo(db, "docs", "docs_old"); !errors.As(err, &te) || te.Code != ErrCodeSameDatabase {
+		t.Fatalf("expected ErrSameDatabase, got %v", err)
+	}
+}
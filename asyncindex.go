@@ -0,0 +1,172 @@
+package thunder
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"slices"
+)
+
+// defaultIndexQueueBatch is the number of queued rows indexed per
+// transaction by DB.ApplyIndexQueues when no batch size is given.
+const defaultIndexQueueBatch = 1000
+
+func (pr *Persistent) asyncIndexes() []string {
+	var names []string
+	for _, name := range pr.indexNames {
+		if pr.fields[name].AsyncIndex {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// queueIndexing queues the row id for its asynchronous index entries.
+func (pr *Persistent) queueIndexing(id []byte) error {
+	if len(pr.asyncIndexes()) == 0 {
+		return nil
+	}
+	queue, err := pr.bucket.CreateBucketIfNotExists([]byte("indexQueue"))
+	if err != nil {
+		return err
+	}
+	return queue.Put(id, nil)
+}
+
+// queuedEntries yields the queued rows matching every range, the ones a read
+// through an asynchronous index cannot find in it. Rows are decoded whole for
+// matching and then cut down to decode, when set.
+func (pr *Persistent) queuedEntries(queue BackendBucket, ranges map[string]*keyRange, decode []string) iter.Seq2[entry, error] {
+	match := pr.matcher(ranges, "")
+	return func(yield func(entry, error) bool) {
+		c := queue.Cursor()
+		for id, _ := c.First(); id != nil; id, _ = c.Next() {
+			value, err := pr.data.getByID(id, nil)
+			if err != nil {
+				if !yield(entry{}, err) {
+					return
+				}
+				continue
+			}
+			if value == nil {
+				continue
+			}
+			if match != nil {
+				ok, err := match(value)
+				if err != nil {
+					if !yield(entry{}, err) {
+						return
+					}
+					continue
+				}
+				if !ok {
+					continue
+				}
+			}
+			if decode != nil {
+				for col := range value {
+					if !slices.Contains(decode, col) {
+						delete(value, col)
+					}
+				}
+			}
+			e := entry{value: value}
+			copy(e.id[:], id)
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// IndexQueueLen returns the number of rows waiting for their asynchronous
+// index entries.
+func (pr *Persistent) IndexQueueLen() (int, error) {
+	queue := pr.bucket.Bucket([]byte("indexQueue"))
+	if queue == nil {
+		return 0, nil
+	}
+	return bucketKeyCount(queue)
+}
+
+// ApplyIndexQueue writes the asynchronous index entries of at most limit
+// queued rows, oldest first, and returns how many rows it indexed.
+func (pr *Persistent) ApplyIndexQueue(limit int) (int, error) {
+	if err := pr.checkWritable(); err != nil {
+		return 0, err
+	}
+	queue := pr.bucket.Bucket([]byte("indexQueue"))
+	if queue == nil {
+		return 0, nil
+	}
+	names := pr.asyncIndexes()
+	var done [][]byte
+	c := queue.Cursor()
+	for id, _ := c.First(); id != nil && len(done) < limit; id, _ = c.Next() {
+		done = append(done, bytes.Clone(id))
+	}
+	for _, id := range done {
+		if raw := pr.data.bucket.Get(id); raw != nil {
+			var value map[string]any
+			if err := pr.data.unmarshal(id, raw, &value); err != nil {
+				return 0, err
+			}
+			for _, name := range names {
+				keys, err := pr.indexKeys(value, name)
+				if err != nil {
+					return 0, err
+				}
+				for _, key := range keys {
+					if err := pr.indexes.insert(name, key, id); err != nil {
+						return 0, err
+					}
+				}
+			}
+		}
+		if err := queue.Delete(id); err != nil {
+			return 0, err
+		}
+	}
+	return len(done), nil
+}
+
+// ApplyIndexQueues drains the index queue of every relation in transactions
+// of batchSize rows, 1000 when zero, and returns how many rows were indexed.
+func (d *DB) ApplyIndexQueues(batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultIndexQueueBatch
+	}
+	names, err := d.Relations()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, relation := range names {
+		for {
+			var n int
+			err := d.update(func(tx *Tx) error {
+				p, err := tx.LoadPersistent(relation)
+				if err != nil {
+					return err
+				}
+				n, err = p.ApplyIndexQueue(batchSize)
+				return err
+			})
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// JobApplyIndexQueues drains the index queues of every relation, the
+// background worker for asynchronous indexes.
+func JobApplyIndexQueues(ctx context.Context, d *DB) error {
+	_, err := d.ApplyIndexQueues(0)
+	return err
+}
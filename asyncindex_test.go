@@ -0,0 +1,113 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPersistent_AsyncIndex(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var te *ThunderError
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("bad", map[string]ColumnSpec{"id": {Unique: true, AsyncIndex: true}})
+		return err
+	})
+	if !errors.As(err, &te) || te.Code != ErrCodeAsyncUniqueIndex {
+		t.Errorf("expected ErrAsyncUniqueIndex, got %v", err)
+	}
+	err = db.update(func(tx *Tx) error {
+		events, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"kind": {Indexed: true, AsyncIndex: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, kind := range []string{"click", "view", "click", "click"} {
+			if err := events.Insert(map[string]any{"id": int64(i), "kind": kind}); err != nil {
+				return err
+			}
+		}
+		return events.Delete(mustRanges(t, Eq("id", int64(3))))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(opts ...QueryOption) int {
+		t.Helper()
+		n := 0
+		err := db.view(func(tx *Tx) error {
+			events, err := tx.LoadPersistent("events")
+			if err != nil {
+				return err
+			}
+			seq, err := events.SelectWith(mustRanges(t, Eq("kind", "click")), opts...)
+			if err != nil {
+				return err
+			}
+			for _, err := range seq {
+				if err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(); n != 2 {
+		t.Errorf("expected 2 clicks found through the queue, got %d", n)
+	}
+	if n := count(StaleIndex()); n != 0 {
+		t.Errorf("expected no clicks in the stale index, got %d", n)
+	}
+	err = db.view(func(tx *Tx) error {
+		events, err := tx.LoadPersistent("events")
+		if err != nil {
+			return err
+		}
+		if n, err := events.IndexQueueLen(); err != nil || n != 3 {
+			t.Errorf("expected 3 queued rows, got %d, %v", n, err)
+		}
+		report, err := events.Verify()
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			t.Errorf("expected queued rows not reported, got %v", report.Issues)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.ApplyIndexQueues(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 rows indexed, got %d", n)
+	}
+	if n := count(StaleIndex()); n != 2 {
+		t.Errorf("expected 2 clicks in the index once applied, got %d", n)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("expected 2 clicks, got %d", n)
+	}
+}
+
+func mustRanges(t *testing.T, ops ...Op) map[string]*keyRange {
+	t.Helper()
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ranges
+}
@@ -0,0 +1,166 @@
+package thunder
+
+import (
+	"io"
+)
+
+// Backend is an ordered key/value store with nested buckets that thunder keeps
+// its relations, indexes and bookkeeping in. OpenDB uses boltdb; other engines
+// can be plugged in with OpenBackend.
+type Backend interface {
+	// Begin starts a transaction. Only one writable transaction may be open
+	// at a time; read-only transactions see a consistent snapshot.
+	Begin(writable bool) (BackendTx, error)
+	// ReadOnly reports whether the store refuses writable transactions.
+	ReadOnly() bool
+	Close() error
+}
+
+// BackendTx is a transaction of a Backend. Bucket lookups return nil when the
+// bucket does not exist.
+type BackendTx interface {
+	ID() int
+	Bucket(name []byte) BackendBucket
+	CreateBucket(name []byte) (BackendBucket, error)
+	CreateBucketIfNotExists(name []byte) (BackendBucket, error)
+	DeleteBucket(name []byte) error
+	// ForEach calls fn for every top-level bucket.
+	ForEach(fn func(name []byte, b BackendBucket) error) error
+	Commit() error
+	Rollback() error
+}
+
+// BackendBucket holds keys in byte order, and possibly nested buckets. Values
+// returned by Get and cursors are only valid for the life of the transaction.
+type BackendBucket interface {
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	Cursor() BackendCursor
+	// ForEach calls fn for every key in order. Nested buckets are reported
+	// with a nil value.
+	ForEach(fn func(k, v []byte) error) error
+	ForEachBucket(fn func(name []byte) error) error
+	Bucket(name []byte) BackendBucket
+	CreateBucket(name []byte) (BackendBucket, error)
+	CreateBucketIfNotExists(name []byte) (BackendBucket, error)
+	DeleteBucket(name []byte) error
+	Sequence() uint64
+	SetSequence(v uint64) error
+	NextSequence() (uint64, error)
+}
+
+// BackendCursor walks the keys of a bucket in order. Methods return a nil key
+// once the cursor moves past either end.
+type BackendCursor interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	Seek(seek []byte) (key, value []byte)
+}
+
+// BackendBucketStats may be implemented by a BackendBucket that can report
+// its size without walking every key.
+type BackendBucketStats interface {
+	// KeyCount returns the number of keys in the bucket and its nested
+	// buckets.
+	KeyCount() int
+	// Bytes returns the storage the bucket and its nested buckets occupy.
+	Bytes() int64
+}
+
+// BackendBucketDepth may be implemented by a BackendBucket stored as a tree
+// to report how many levels the tree has.
+type BackendBucketDepth interface {
+	Depth() int
+}
+
+// BackendSyncer may be implemented by a Backend whose commits can leave the
+// flush to stable storage for later, see DB.SetDurability.
+type BackendSyncer interface {
+	// SetNoSync makes commits skip the flush, or perform it again.
+	SetNoSync(noSync bool) error
+	// Sync flushes every committed write to stable storage.
+	Sync() error
+}
+
+// OpenBackend opens a database stored in backend. The backend is closed
+// together with the DB, or right away if opening fails.
+func OpenBackend(maUn MarshalUnmarshaler, backend Backend) (*DB, error) {
+	d := &DB{backend: backend, maUn: maUn, stats: newPlannerStats(), clock: SystemClock}
+	if err := d.checkEncoding(); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if err := d.loadStats(); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DB) backendView(fn func(tx BackendTx) error) error {
+	tx, err := d.backend.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+func (d *DB) backendUpdate(fn func(tx BackendTx) error) error {
+	tx, err := d.backend.Begin(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeSnapshot writes a restorable copy of the whole store, for backends
+// whose transactions implement io.WriterTo.
+func (tx *Tx) writeSnapshot(w io.Writer) (int64, error) {
+	wt, ok := tx.tx.(io.WriterTo)
+	if !ok {
+		return 0, ErrBackendUnsupported("snapshot backups")
+	}
+	return wt.WriteTo(w)
+}
+
+func bucketKeyCount(bucket BackendBucket) (int, error) {
+	if stats, ok := bucket.(BackendBucketStats); ok {
+		return stats.KeyCount(), nil
+	}
+	n := 0
+	err := bucket.ForEach(func(k, v []byte) error {
+		if child := bucket.Bucket(k); child != nil {
+			m, err := bucketKeyCount(child)
+			n += m
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func bucketBytes(bucket BackendBucket) (int64, error) {
+	if stats, ok := bucket.(BackendBucketStats); ok {
+		return stats.Bytes(), nil
+	}
+	var n int64
+	err := bucket.ForEach(func(k, v []byte) error {
+		n += int64(len(k) + len(v))
+		if child := bucket.Bucket(k); child != nil {
+			m, err := bucketBytes(child)
+			n += m
+			return err
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,79 @@
+package thunder
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/openkvlab/boltdb"
+)
+
+// minimalBackend hides the optional capabilities of the bolt backend.
+type minimalBackend struct {
+	Backend
+}
+
+type minimalTx struct {
+	BackendTx
+}
+
+func (b minimalBackend) Begin(writable bool) (BackendTx, error) {
+	tx, err := b.Backend.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return minimalTx{tx}, nil
+}
+
+func TestOpenBackend_MinimalBackend(t *testing.T) {
+	dir := t.TempDir()
+	bdb, err := boltdb.Open(filepath.Join(dir, "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenBackend(&MsgpackMaUn, minimalBackend{NewBoltBackend(bdb)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"name": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, name := range []string{"ada", "bob", "cy"} {
+			if err := p.Insert(map[string]any{"id": int64(i), "name": name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ranges, err := ToKeyRanges(Eq("name", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Select("users", ranges, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["id"] != int64(1) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	var thErr *ThunderError
+	if _, err := db.Backup(&bytes.Buffer{}); !errors.As(err, &thErr) || thErr.Code != ErrCodeBackendUnsupported {
+		t.Fatalf("expected ErrBackendUnsupported from Backup, got %v", err)
+	}
+	if err := db.Compact(filepath.Join(dir, "compact.db"), false); !errors.As(err, &thErr) || thErr.Code != ErrCodeBackendUnsupported {
+		t.Fatalf("expected ErrBackendUnsupported from Compact, got %v", err)
+	}
+}
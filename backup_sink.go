@@ -0,0 +1,187 @@
+package thunder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BackupSink stores backups written by BackupTo, such as a directory or an
+// object storage bucket.
+type BackupSink interface {
+	// Put stores the contents of r under name.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// List returns the names of all stored backups.
+	List(ctx context.Context) ([]string, error)
+	// Delete removes the backup stored under name.
+	Delete(ctx context.Context, name string) error
+}
+
+// RetentionPolicy decides which backups BackupTo keeps. Backups form sets of
+// a full backup followed by the incrementals taken on top of it, and sets are
+// kept or deleted as a whole. The newest full backup is always kept. Zero
+// values disable the corresponding limit.
+type RetentionPolicy struct {
+	// KeepLast is the number of most recent backup sets to keep.
+	KeepLast int
+	// MaxAge deletes backup sets whose full backup is older than this.
+	MaxAge time.Duration
+}
+
+const backupTimeLayout = "20060102T150405.000000000Z"
+
+// backupName names a backup so that lexical order is chronological.
+func backupName(at time.Time, full bool, seq uint64) string {
+	kind := "incr"
+	if full {
+		kind = "full"
+	}
+	return fmt.Sprintf("thunder-%s-%s-%020d", at.UTC().Format(backupTimeLayout), kind, seq)
+}
+
+func parseBackupName(name string) (at time.Time, full bool, ok bool) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 4 || parts[0] != "thunder" || (parts[2] != "full" && parts[2] != "incr") {
+		return time.Time{}, false, false
+	}
+	at, err := time.Parse(backupTimeLayout, parts[1])
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return at, parts[2] == "full", true
+}
+
+// BackupTo writes a backup of the database to sink and then applies policy to
+// the backups already there. When incremental is true and since is not zero,
+// only the changes after the commit sequence since are written, as with
+// BackupSince; otherwise a full backup is written. It returns the sequence
+// the backup is current up to.
+func (d *DB) BackupTo(ctx context.Context, sink BackupSink, since uint64, incremental bool, policy RetentionPolicy) (uint64, error) {
+	full := !incremental || since == 0
+	var seq uint64
+	err := d.view(func(tx *Tx) error {
+		seq = tx.CommitSequence()
+		pr, pw := io.Pipe()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var err error
+			if full {
+				_, err = tx.writeSnapshot(pw)
+			} else {
+				_, err = tx.backupSince(since, pw)
+			}
+			pw.CloseWithError(err)
+		}()
+		err := sink.Put(ctx, backupName(d.Now(), full, seq), pr)
+		pr.CloseWithError(err)
+		<-done
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return seq, d.applyRetention(ctx, sink, policy)
+}
+
+func (d *DB) applyRetention(ctx context.Context, sink BackupSink, policy RetentionPolicy) error {
+	if policy.KeepLast <= 0 && policy.MaxAge <= 0 {
+		return nil
+	}
+	names, err := sink.List(ctx)
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+	type backupSet struct {
+		at    time.Time
+		names []string
+	}
+	var sets []backupSet
+	var orphans []string
+	for _, name := range names {
+		at, full, ok := parseBackupName(name)
+		switch {
+		case !ok:
+			continue
+		case full:
+			sets = append(sets, backupSet{at: at, names: []string{name}})
+		case len(sets) == 0:
+			orphans = append(orphans, name)
+		default:
+			sets[len(sets)-1].names = append(sets[len(sets)-1].names, name)
+		}
+	}
+	now := d.Now()
+	doomed := orphans
+	for i, set := range sets {
+		newest := i == len(sets)-1
+		tooMany := policy.KeepLast > 0 && i < len(sets)-policy.KeepLast
+		tooOld := policy.MaxAge > 0 && now.Sub(set.at) > policy.MaxAge
+		if !newest && (tooMany || tooOld) {
+			doomed = append(doomed, set.names...)
+		}
+	}
+	for _, name := range doomed {
+		if err := sink.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DirSink is a BackupSink storing each backup as a file in a directory.
+type DirSink struct {
+	Dir string
+}
+
+func (s DirSink) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a failed backup never shows
+	// up under its final name.
+	tmp, err := os.CreateTemp(s.Dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.Dir, name))
+}
+
+func (s DirSink) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func (s DirSink) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.Dir, name))
+}
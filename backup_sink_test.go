@@ -0,0 +1,62 @@
+package thunder
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDB_BackupToDirSinkWithRetention(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	clock := NewManualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	db.SetClock(clock)
+	if err := db.EnableChangeTracking(); err != nil {
+		t.Fatal(err)
+	}
+	sink := DirSink{Dir: t.TempDir()}
+	ctx := context.Background()
+	policy := RetentionPolicy{KeepLast: 2}
+
+	var seq uint64
+	for day := range 3 {
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("events")
+			if err != nil {
+				if p, err = tx.CreatePersistent("events", map[string]ColumnSpec{"day": {}}); err != nil {
+					return err
+				}
+			}
+			return p.Insert(map[string]any{"day": int64(day)})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq, err = db.BackupTo(ctx, sink, seq, false, policy); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Hour)
+		if _, err = db.BackupTo(ctx, sink, seq, true, policy); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(23 * time.Hour)
+	}
+
+	names, err := sink.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	var kinds []string
+	for _, name := range names {
+		kinds = append(kinds, strings.Split(name, "-")[2])
+	}
+	if !slices.Equal(kinds, []string{"full", "incr", "full", "incr"}) {
+		t.Fatalf("expected the two newest backup sets, got %v", names)
+	}
+	if !strings.HasPrefix(names[0], "thunder-20260102T") {
+		t.Fatalf("expected the oldest set to be dropped, got %v", names)
+	}
+}
@@ -0,0 +1,151 @@
+package thunder
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures when a Batcher commits. Zero values pick defaults.
+type BatchOptions struct {
+	// MaxOps commits once this many writes are pending. Defaults to 1000.
+	MaxOps int
+	// MaxDelay commits at most this long after the first pending write.
+	// Defaults to 10ms.
+	MaxDelay time.Duration
+}
+
+// Batcher coalesces writes from many goroutines into shared transactions, so
+// that throughput is not bounded by one commit per write. Every call blocks
+// until the transaction holding its write has committed and returns that
+// write's own error.
+type Batcher struct {
+	db   *DB
+	opts BatchOptions
+
+	mu      sync.Mutex
+	pending []batchOp
+	timer   *time.Timer
+	closed  bool
+}
+
+type batchOp struct {
+	fn   func(tx *Tx) error
+	done chan error
+}
+
+// NewBatcher returns a Batcher writing to d.
+func (d *DB) NewBatcher(opts BatchOptions) *Batcher {
+	if opts.MaxOps <= 0 {
+		opts.MaxOps = 1000
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Millisecond
+	}
+	return &Batcher{db: d, opts: opts}
+}
+
+// Insert adds row to relation in the next batch.
+func (b *Batcher) Insert(relation string, row map[string]any) error {
+	return b.Do(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		return p.Insert(row)
+	})
+}
+
+// Delete removes the rows of relation matching ranges in the next batch.
+func (b *Batcher) Delete(relation string, ranges map[string]*keyRange) error {
+	return b.Do(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		return p.Delete(ranges)
+	})
+}
+
+// Do runs fn in the next batch. fn may run more than once if another write of
+// its batch fails, so it must not have side effects outside tx.
+func (b *Batcher) Do(fn func(tx *Tx) error) error {
+	done := make(chan error, 1)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatcherClosed()
+	}
+	b.pending = append(b.pending, batchOp{fn: fn, done: done})
+	var full []batchOp
+	switch {
+	case len(b.pending) >= b.opts.MaxOps:
+		full = b.take()
+	case b.timer == nil:
+		b.timer = time.AfterFunc(b.opts.MaxDelay, b.flushPending)
+	}
+	b.mu.Unlock()
+	if full != nil {
+		b.commit(full)
+	}
+	return <-done
+}
+
+// Flush commits the pending writes without waiting for a threshold.
+func (b *Batcher) Flush() {
+	b.flushPending()
+}
+
+// Close commits the pending writes. Later calls fail with ErrBatcherClosed.
+func (b *Batcher) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	ops := b.take()
+	b.mu.Unlock()
+	b.commit(ops)
+	return nil
+}
+
+func (b *Batcher) flushPending() {
+	b.mu.Lock()
+	ops := b.take()
+	b.mu.Unlock()
+	b.commit(ops)
+}
+
+// take removes the pending writes. b.mu must be held.
+func (b *Batcher) take() []batchOp {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	ops := b.pending
+	b.pending = nil
+	return ops
+}
+
+// commit runs ops in one transaction. When one of them fails the transaction
+// is rolled back, the failing write gets its error and the others are
+// retried without it.
+func (b *Batcher) commit(ops []batchOp) {
+	for len(ops) > 0 {
+		failed := -1
+		err := b.db.update(func(tx *Tx) error {
+			for i, op := range ops {
+				if err := op.fn(tx); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+		if failed < 0 {
+			for _, op := range ops {
+				op.done <- err
+			}
+			return
+		}
+		ops[failed].done <- err
+		ops = slices.Delete(ops, failed, failed+1)
+	}
+}
@@ -0,0 +1,67 @@
+package thunder
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher_CoalescesAndIsolatesFailures(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+			"id": {Unique: true},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All 41 writes fill a single batch; the duplicate id fails on its own
+	// without taking the rest of the batch down.
+	b := db.NewBatcher(BatchOptions{MaxOps: 41, MaxDelay: time.Hour})
+	var wg sync.WaitGroup
+	errs := make([]error, 41)
+	for i := range 41 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.Insert("events", map[string]any{"id": int64(i % 40)})
+		}()
+	}
+	wg.Wait()
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var thErr *ThunderError
+	failed := 0
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.As(err, &thErr) || thErr.Code != ErrCodeUniqueConstraint {
+			t.Fatalf("expected unique violation, got %v", err)
+		}
+		failed++
+	}
+	if failed != 1 {
+		t.Fatalf("expected exactly one failed insert, got %d", failed)
+	}
+	rows, err := db.Select("events", nil, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 40 {
+		t.Fatalf("expected 40 rows, got %d", len(rows))
+	}
+	if err := b.Insert("events", map[string]any{"id": int64(99)}); !errors.As(err, &thErr) || thErr.Code != ErrCodeBatcherClosed {
+		t.Fatalf("expected ErrBatcherClosed, got %v", err)
+	}
+}
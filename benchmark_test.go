@@ -10,6 +10,10 @@ import (
 
 // setupBenchmarkDB is similar to setupTestDB but uses testing.B and handles errors for benchmarks.
 func setupBenchmarkDB(b *testing.B) (*DB, func()) {
+	return setupBenchmarkDBWithMaUn(b, &MsgpackMaUn)
+}
+
+func setupBenchmarkDBWithMaUn(b *testing.B, maUn MarshalUnmarshaler) (*DB, func()) {
 	tmpfile, err := os.CreateTemp("", "thunder_bench_*.db")
 	if err != nil {
 		b.Fatal(err)
@@ -17,8 +21,7 @@ func setupBenchmarkDB(b *testing.B) (*DB, func()) {
 	dbPath := tmpfile.Name()
 	tmpfile.Close()
 
-	// Using MsgpackMaUn as default
-	db, err := OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+	db, err := OpenDB(maUn, dbPath, 0600, nil)
 	if err != nil {
 		os.Remove(dbPath)
 		b.Fatal(err)
@@ -201,6 +204,109 @@ func BenchmarkSelect(b *testing.B) {
 	})
 }
 
+// BenchmarkSelectAllocs measures the per-row cost of the iteration path:
+// matching the ranges not answered by the index and reading the rows.
+func BenchmarkSelectAllocs(b *testing.B) {
+	db, cleanup := setupBenchmarkDB(b)
+	defer cleanup()
+
+	count := 10000
+	tx, _ := db.Begin(true)
+	defer tx.Rollback()
+	p, _ := tx.CreatePersistent("bench_allocs", map[string]ColumnSpec{
+		"id":    {},
+		"group": {Indexed: true},
+		"val":   {},
+	})
+	for i := range count {
+		p.Insert(map[string]any{
+			"id":    strconv.Itoa(i),
+			"group": int64(i % 10),
+			"val":   float64(i),
+		})
+	}
+	tx.Commit()
+
+	readTx, _ := db.Begin(false)
+	defer readTx.Rollback()
+	pLoad, _ := readTx.LoadPersistent("bench_allocs")
+
+	run := func(b *testing.B, ops ...Op) {
+		f, err := ToKeyRanges(ops...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			seq, _ := pLoad.Select(f)
+			for range seq {
+				// drain
+			}
+		}
+	}
+	b.Run("Scan_Filter", func(b *testing.B) {
+		run(b, Ge("val", float64(count-100)))
+	})
+	b.Run("Index_Filter", func(b *testing.B) {
+		run(b, Eq("group", int64(3)), Lt("val", float64(100)))
+	})
+	b.Run("Index_NoFilter", func(b *testing.B) {
+		run(b, Eq("group", int64(3)))
+	})
+	b.Run("Index_SelectColumns", func(b *testing.B) {
+		f, err := ToKeyRanges(Eq("group", int64(3)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for b.Loop() {
+			seq, _ := pLoad.SelectWith(f, SelectColumns("id"))
+			for range seq {
+				// drain
+			}
+		}
+	})
+}
+
+// BenchmarkScanCodecs compares full scans of string-heavy rows across codecs.
+func BenchmarkScanCodecs(b *testing.B) {
+	for name, maUn := range map[string]MarshalUnmarshaler{
+		"Msgpack":  &MsgpackMaUn,
+		"ZeroCopy": &ZeroCopyMaUn,
+	} {
+		b.Run(name, func(b *testing.B) {
+			db, cleanup := setupBenchmarkDBWithMaUn(b, maUn)
+			defer cleanup()
+			tx, _ := db.Begin(true)
+			defer tx.Rollback()
+			p, _ := tx.CreatePersistent("bench_codecs", map[string]ColumnSpec{
+				"id":    {},
+				"name":  {},
+				"email": {},
+			})
+			for i := range 5000 {
+				p.Insert(map[string]any{
+					"id":    strconv.Itoa(i),
+					"name":  fmt.Sprintf("user %d", i),
+					"email": fmt.Sprintf("user%d@example.com", i),
+				})
+			}
+			tx.Commit()
+
+			readTx, _ := db.Begin(false)
+			defer readTx.Rollback()
+			pLoad, _ := readTx.LoadPersistent("bench_codecs")
+			b.ReportAllocs()
+			for b.Loop() {
+				seq, _ := pLoad.Select(nil)
+				for range seq {
+					// drain
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkDeeplyNestedLargeRows(b *testing.B) {
 	db, cleanup := setupBenchmarkDB(b)
 	defer cleanup()
@@ -0,0 +1,391 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"slices"
+)
+
+// blobChunkSize is the size of the chunks blobs are stored in.
+const blobChunkSize = 64 << 10
+
+// Blob identifies a large value of a TypeBlob column. Its bytes are kept in
+// chunks outside the row, so reading a row does not decode them; use
+// Persistent.OpenBlob to read them.
+type Blob uint64
+
+// blobID converts a stored or caller-supplied blob reference to a Blob.
+// Codecs decode it as any kind of number.
+func blobID(v any) (Blob, bool) {
+	if v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanUint():
+		return Blob(rv.Uint()), rv.Uint() > 0
+	case rv.CanInt():
+		return Blob(rv.Int()), rv.Int() > 0
+	case rv.CanFloat():
+		f := rv.Float()
+		return Blob(f), f > 0 && f == float64(uint64(f))
+	}
+	return 0, false
+}
+
+// BlobWriter writes a new blob. The blob exists once the writer is closed;
+// put Blob in a TypeBlob column to attach it to a row. Several rows may refer
+// to the same blob, which is deleted with the last of them. Blobs travel in
+// incremental backups and change streams with the rows referring to them.
+type BlobWriter struct {
+	pr     *Persistent
+	bucket BackendBucket
+	id     Blob
+	buf    []byte
+	chunk  uint32
+	size   uint64
+	closed bool
+}
+
+// CreateBlob starts a new blob in the relation.
+func (pr *Persistent) CreateBlob() (*BlobWriter, error) {
+	if err := pr.checkWritable(); err != nil {
+		return nil, err
+	}
+	bucket, err := pr.bucket.CreateBucketIfNotExists([]byte("blobs"))
+	if err != nil {
+		return nil, err
+	}
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	return &BlobWriter{pr: pr, bucket: bucket, id: Blob(id), buf: make([]byte, 0, blobChunkSize)}, nil
+}
+
+// Blob returns the reference to store in the row.
+func (w *BlobWriter) Blob() Blob {
+	return w.id
+}
+
+func (w *BlobWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := min(len(p), blobChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:m]...)
+		p = p[m:]
+		if len(w.buf) == blobChunkSize {
+			if err := w.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *BlobWriter) flush() error {
+	if err := w.bucket.Put(blobChunkKey(w.id, w.chunk), w.buf); err != nil {
+		return err
+	}
+	w.chunk++
+	w.size += uint64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close writes the last chunk and the size of the blob.
+func (w *BlobWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	return w.bucket.Put(blobKey(w.id), binary.BigEndian.AppendUint64(nil, w.size))
+}
+
+// BlobReader reads a blob chunk by chunk.
+type BlobReader struct {
+	bucket BackendBucket
+	id     Blob
+	size   int64
+	chunk  uint32
+	buf    []byte
+}
+
+// OpenBlob opens the blob that v, the value of a TypeBlob column, refers to.
+// The blob must be referred to by a row the transaction can read, through a
+// column not masked for its identity, or OpenBlob fails with
+// ErrBlobNotFound or ErrColumnMasked.
+func (pr *Persistent) OpenBlob(v any) (*BlobReader, error) {
+	id, ok := blobID(v)
+	if !ok {
+		return nil, ErrBlobNotFound(pr.relation, v)
+	}
+	if err := pr.checkBlobAccess(id); err != nil {
+		return nil, err
+	}
+	return pr.openBlob(id)
+}
+
+// openBlob opens the blob id whoever may read it.
+func (pr *Persistent) openBlob(id Blob) (*BlobReader, error) {
+	bucket := pr.bucket.Bucket([]byte("blobs"))
+	if bucket == nil {
+		return nil, ErrBlobNotFound(pr.relation, id)
+	}
+	header := bucket.Get(blobKey(id))
+	if len(header) != 8 {
+		return nil, ErrBlobNotFound(pr.relation, id)
+	}
+	return &BlobReader{bucket: bucket, id: id, size: int64(binary.BigEndian.Uint64(header))}, nil
+}
+
+// checkBlobAccess finds a row referring to the blob id that the transaction
+// can read under the relation's policy, with the referring column unmasked.
+func (pr *Persistent) checkBlobAccess(id Blob) error {
+	refs := pr.bucket.Bucket([]byte("blobRefs"))
+	if refs == nil {
+		return ErrBlobNotFound(pr.relation, id)
+	}
+	match, err := pr.policyMatcher()
+	if err != nil {
+		return err
+	}
+	masked := pr.maskedFor()
+	var maskedErr error
+	prefix := blobKey(id)
+	c := refs.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		rowID := k[len(prefix):]
+		raw := pr.data.bucket.Get(rowID)
+		if raw == nil {
+			continue
+		}
+		var value map[string]any
+		if err := pr.data.unmarshal(rowID, raw, &value); err != nil {
+			return err
+		}
+		if match != nil {
+			ok, err := match(value)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		for _, col := range pr.blobColumns() {
+			if ref, ok := blobID(value[col]); !ok || ref != id {
+				continue
+			}
+			if !slices.Contains(masked, col) {
+				return nil
+			}
+			maskedErr = ErrColumnMasked(col)
+		}
+	}
+	if maskedErr != nil {
+		return maskedErr
+	}
+	return ErrBlobNotFound(pr.relation, id)
+}
+
+// Size returns the length of the blob in bytes.
+func (r *BlobReader) Size() int64 {
+	return r.size
+}
+
+func (r *BlobReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		r.buf = r.bucket.Get(blobChunkKey(r.id, r.chunk))
+		if r.buf == nil {
+			return 0, io.EOF
+		}
+		r.chunk++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// copyBlob copies the blob id of src into a new blob of pr and returns it.
+func (pr *Persistent) copyBlob(src *Persistent, id Blob) (Blob, error) {
+	r, err := src.openBlob(id)
+	if err != nil {
+		return 0, err
+	}
+	w, err := pr.CreateBlob()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return w.Blob(), nil
+}
+
+// deleteBlob removes the header and chunks of a blob.
+func (pr *Persistent) deleteBlob(id Blob) error {
+	bucket := pr.bucket.Bucket([]byte("blobs"))
+	if bucket == nil {
+		return nil
+	}
+	header := bucket.Get(blobKey(id))
+	if len(header) != 8 {
+		return nil
+	}
+	chunks := (binary.BigEndian.Uint64(header) + blobChunkSize - 1) / blobChunkSize
+	for i := range uint32(chunks) {
+		if err := bucket.Delete(blobChunkKey(id, i)); err != nil {
+			return err
+		}
+	}
+	return bucket.Delete(blobKey(id))
+}
+
+// dropBlobs deletes the blobs that value, a removed row, refers to and no
+// row refers to any longer. Relations with history keep their blobs for the
+// archived rows.
+func (pr *Persistent) dropBlobs(value map[string]any) error {
+	if pr.versions != nil {
+		return nil
+	}
+	refs := pr.bucket.Bucket([]byte("blobRefs"))
+	for _, col := range pr.blobColumns() {
+		id, ok := blobID(value[col])
+		if !ok {
+			continue
+		}
+		if refs != nil {
+			prefix := blobKey(id)
+			if k, _ := refs.Cursor().Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) {
+				continue
+			}
+		}
+		if err := pr.deleteBlob(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlobs fails with ErrBlobNotFound when obj refers to a blob the
+// relation does not hold.
+func (pr *Persistent) checkBlobs(obj map[string]any) error {
+	for _, col := range pr.blobColumns() {
+		v, ok := obj[col]
+		if !ok || v == nil {
+			continue
+		}
+		id, _ := blobID(v)
+		if _, err := pr.openBlob(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refBlobs records, or with add unset forgets, that the row id refers to the
+// blobs in its value.
+func (pr *Persistent) refBlobs(value map[string]any, id []byte, add bool) error {
+	for _, col := range pr.blobColumns() {
+		blob, ok := blobID(value[col])
+		if !ok {
+			continue
+		}
+		key := append(blobKey(blob), id...)
+		if !add {
+			if refs := pr.bucket.Bucket([]byte("blobRefs")); refs != nil {
+				if err := refs.Delete(key); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		refs, err := pr.bucket.CreateBucketIfNotExists([]byte("blobRefs"))
+		if err != nil {
+			return err
+		}
+		if err := refs.Put(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pr *Persistent) blobColumns() []string {
+	var columns []string
+	for _, col := range pr.columns {
+		if pr.fields[col].Type == TypeBlob {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// blobContents returns the bytes of the blobs value refers to, for a change
+// stream.
+func (pr *Persistent) blobContents(value map[string]any) (map[uint64][]byte, error) {
+	var contents map[uint64][]byte
+	for _, col := range pr.blobColumns() {
+		id, ok := blobID(value[col])
+		if !ok {
+			continue
+		}
+		r, err := pr.openBlob(id)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if contents == nil {
+			contents = make(map[uint64][]byte)
+		}
+		contents[uint64(id)] = data
+	}
+	return contents, nil
+}
+
+// putBlob stores a blob read from a change stream under its original id,
+// unless the relation already holds it.
+func (pr *Persistent) putBlob(id Blob, data []byte) error {
+	bucket, err := pr.bucket.CreateBucketIfNotExists([]byte("blobs"))
+	if err != nil {
+		return err
+	}
+	if bucket.Get(blobKey(id)) != nil {
+		return nil
+	}
+	if uint64(id) > bucket.Sequence() {
+		if err := bucket.SetSequence(uint64(id)); err != nil {
+			return err
+		}
+	}
+	w := &BlobWriter{pr: pr, bucket: bucket, id: id, buf: make([]byte, 0, blobChunkSize)}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func blobKey(id Blob) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+func blobChunkKey(id Blob, chunk uint32) []byte {
+	return binary.BigEndian.AppendUint32(blobKey(id), chunk)
+}
@@ -0,0 +1,190 @@
+package thunder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPersistent_Blob(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	files, err := tx.CreatePersistent("files", map[string]ColumnSpec{
+		"name": {Unique: true},
+		"data": {Type: TypeBlob},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 3*blobChunkSize/16+100)
+	w, err := files.CreateBlob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Insert(map[string]any{"name": "big", "data": w.Blob()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if files, err = tx.LoadPersistent("files"); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("name", "big"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := files.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ref any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref = row["data"]
+	}
+	r, err := files.OpenBlob(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(payload)) {
+		t.Fatalf("expected size %d, got %d", len(payload), r.Size())
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatal("blob content differs")
+	}
+	// Deleting the row deletes its blob.
+	if err := files.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	if _, err := files.OpenBlob(ref); !errors.As(err, &te) || te.Code != ErrCodeBlobNotFound {
+		t.Fatalf("expected the blob deleted, got %v", err)
+	}
+	if k, _ := files.bucket.Bucket([]byte("blobs")).Cursor().First(); k != nil {
+		t.Fatalf("expected no chunks left, found %x", k)
+	}
+}
+
+func TestPersistent_BlobReferences(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	var shared, orphan Blob
+	err := db.update(func(tx *Tx) error {
+		files, err := tx.CreatePersistent("files", map[string]ColumnSpec{
+			"name":  {Unique: true},
+			"owner": {},
+			"data":  {Type: TypeBlob},
+		})
+		if err != nil {
+			return err
+		}
+		var te *ThunderError
+		err = files.Insert(map[string]any{"name": "ghost", "owner": "a", "data": Blob(99)})
+		if !errors.As(err, &te) || te.Code != ErrCodeBlobNotFound {
+			t.Errorf("expected a missing blob rejected, got %v", err)
+		}
+		for _, ref := range []*Blob{&shared, &orphan} {
+			w, err := files.CreateBlob()
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("payload")); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			*ref = w.Blob()
+		}
+		for _, name := range []string{"first", "second"} {
+			if err := files.Insert(map[string]any{"name": name, "owner": "a", "data": shared}); err != nil {
+				return err
+			}
+		}
+		if err := files.MaskColumn("data", "guest"); err != nil {
+			return err
+		}
+		// A blob no row refers to cannot be opened.
+		if _, err := files.OpenBlob(orphan); !errors.As(err, &te) || te.Code != ErrCodeBlobNotFound {
+			t.Errorf("expected an unattached blob hidden, got %v", err)
+		}
+		// The blob outlives the first of the rows sharing it.
+		ranges, err := ToKeyRanges(Eq("name", "first"))
+		if err != nil {
+			return err
+		}
+		if err := files.Delete(ranges); err != nil {
+			return err
+		}
+		r, err := files.OpenBlob(shared)
+		if err != nil {
+			return err
+		}
+		if body, err := io.ReadAll(r); err != nil || string(body) != "payload" {
+			t.Errorf("expected the shared blob kept, got %q, %v", body, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetPolicy("files", func(ctx context.Context) ([]Op, error) {
+		if owner, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []Op{Eq("owner", owner)}, nil
+		}
+		return nil, nil
+	})
+	open := func(ctx context.Context, identity string) error {
+		tx, err := db.BeginContext(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		tx.SetIdentity(identity)
+		files, err := tx.LoadPersistent("files")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = files.OpenBlob(shared)
+		return err
+	}
+	var te *ThunderError
+	if err := open(context.WithValue(context.Background(), tenantKey{}, "b"), ""); !errors.As(err, &te) || te.Code != ErrCodeBlobNotFound {
+		t.Errorf("expected the blob of another owner hidden, got %v", err)
+	}
+	if err := open(context.Background(), "guest"); !errors.As(err, &te) || te.Code != ErrCodeColumnMasked {
+		t.Errorf("expected the blob of a masked column hidden, got %v", err)
+	}
+	if err := open(context.WithValue(context.Background(), tenantKey{}, "a"), ""); err != nil {
+		t.Errorf("expected the owner to open the blob, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package thunder
+
+import (
+	"io"
+
+	"github.com/openkvlab/boltdb"
+)
+
+// boltBackend is the Backend OpenDB stores databases in.
+type boltBackend struct {
+	db *boltdb.DB
+}
+
+// NewBoltBackend wraps an open boltdb database as a Backend.
+func NewBoltBackend(db *boltdb.DB) Backend {
+	return &boltBackend{db: db}
+}
+
+func (b *boltBackend) Begin(writable bool) (BackendTx, error) {
+	tx, err := b.db.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return &boltTx{tx: tx}, nil
+}
+
+func (b *boltBackend) ReadOnly() bool {
+	return b.db.IsReadOnly()
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *boltBackend) SetNoSync(noSync bool) error {
+	// Set within a write transaction so that no commit reads it meanwhile.
+	return b.db.Update(func(*boltdb.Tx) error {
+		b.db.NoSync = noSync
+		return nil
+	})
+}
+
+func (b *boltBackend) Sync() error {
+	return b.db.Sync()
+}
+
+type boltTx struct {
+	tx *boltdb.Tx
+}
+
+var _ io.WriterTo = (*boltTx)(nil)
+
+func (t *boltTx) ID() int {
+	return t.tx.ID()
+}
+
+func (t *boltTx) Bucket(name []byte) BackendBucket {
+	return wrapBoltBucket(t.tx.Bucket(name))
+}
+
+func (t *boltTx) CreateBucket(name []byte) (BackendBucket, error) {
+	bucket, err := t.tx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapBoltBucket(bucket), nil
+}
+
+func (t *boltTx) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	bucket, err := t.tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapBoltBucket(bucket), nil
+}
+
+func (t *boltTx) DeleteBucket(name []byte) error {
+	return t.tx.DeleteBucket(name)
+}
+
+func (t *boltTx) ForEach(fn func(name []byte, b BackendBucket) error) error {
+	return t.tx.ForEach(func(name []byte, b *boltdb.Bucket) error {
+		return fn(name, wrapBoltBucket(b))
+	})
+}
+
+func (t *boltTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *boltTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func (t *boltTx) WriteTo(w io.Writer) (int64, error) {
+	return t.tx.WriteTo(w)
+}
+
+type boltBucket struct {
+	b *boltdb.Bucket
+}
+
+var _ BackendBucketStats = (*boltBucket)(nil)
+
+// wrapBoltBucket keeps a missing bucket an untyped nil so callers can compare
+// the result against nil.
+func wrapBoltBucket(b *boltdb.Bucket) BackendBucket {
+	if b == nil {
+		return nil
+	}
+	return &boltBucket{b: b}
+}
+
+func (b *boltBucket) Get(key []byte) []byte {
+	return b.b.Get(key)
+}
+
+func (b *boltBucket) Put(key, value []byte) error {
+	return b.b.Put(key, value)
+}
+
+func (b *boltBucket) Delete(key []byte) error {
+	return b.b.Delete(key)
+}
+
+func (b *boltBucket) Cursor() BackendCursor {
+	return b.b.Cursor()
+}
+
+func (b *boltBucket) ForEach(fn func(k, v []byte) error) error {
+	return b.b.ForEach(fn)
+}
+
+func (b *boltBucket) ForEachBucket(fn func(name []byte) error) error {
+	return b.b.ForEachBucket(fn)
+}
+
+func (b *boltBucket) Bucket(name []byte) BackendBucket {
+	return wrapBoltBucket(b.b.Bucket(name))
+}
+
+func (b *boltBucket) CreateBucket(name []byte) (BackendBucket, error) {
+	bucket, err := b.b.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapBoltBucket(bucket), nil
+}
+
+func (b *boltBucket) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	bucket, err := b.b.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return wrapBoltBucket(bucket), nil
+}
+
+func (b *boltBucket) DeleteBucket(name []byte) error {
+	return b.b.DeleteBucket(name)
+}
+
+func (b *boltBucket) Sequence() uint64 {
+	return b.b.Sequence()
+}
+
+func (b *boltBucket) SetSequence(v uint64) error {
+	return b.b.SetSequence(v)
+}
+
+func (b *boltBucket) NextSequence() (uint64, error) {
+	return b.b.NextSequence()
+}
+
+func (b *boltBucket) KeyCount() int {
+	return b.b.Stats().KeyN
+}
+
+func (b *boltBucket) Depth() int {
+	return b.b.Stats().Depth
+}
+
+func (b *boltBucket) Bytes() int64 {
+	stats := b.b.Stats()
+	allocated := int64(stats.BranchAlloc + stats.LeafAlloc)
+	if allocated == 0 {
+		return int64(stats.LeafInuse)
+	}
+	return allocated
+}
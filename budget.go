@@ -0,0 +1,144 @@
+package thunder
+
+import (
+	"iter"
+	"time"
+)
+
+// Timeout aborts the query with ErrQueryTimeout once it has run for longer
+// than d, measured on the database Clock from when the query is started.
+func Timeout(d time.Duration) QueryOption {
+	return func(o *queryOptions) {
+		o.timeout = d
+	}
+}
+
+// MaxScanned aborts the query with ErrQueryBudgetExceeded once it has read
+// more than n rows, whether they matched or not.
+func MaxScanned(n int) QueryOption {
+	return func(o *queryOptions) {
+		o.maxScanned = n
+	}
+}
+
+// MaxRows aborts the query with ErrQueryBudgetExceeded once it would return
+// more than n rows.
+func MaxRows(n int) QueryOption {
+	return func(o *queryOptions) {
+		o.maxRows = n
+	}
+}
+
+// queryBudget enforces the limits of a query and, for ExplainAnalyze,
+// counts what it reads into stats. A nil budget does neither.
+type queryBudget struct {
+	stats      *QueryAnalysis
+	relation   string
+	now        func() time.Time
+	timeout    time.Duration
+	deadline   time.Time
+	maxScanned int
+	maxRows    int
+	scanned    int
+	rows       int
+}
+
+// budget returns the queryBudget for o, or nil when o sets no limit and
+// stats is nil.
+func (pr *Persistent) budget(o queryOptions, stats *QueryAnalysis) *queryBudget {
+	if o.timeout <= 0 && o.maxScanned <= 0 && o.maxRows <= 0 && stats == nil {
+		return nil
+	}
+	b := &queryBudget{
+		stats:      stats,
+		relation:   pr.relation,
+		now:        pr.tx.Now,
+		timeout:    o.timeout,
+		maxScanned: o.maxScanned,
+		maxRows:    o.maxRows,
+	}
+	if o.timeout > 0 {
+		b.deadline = b.now().Add(o.timeout)
+	}
+	return b
+}
+
+// scan accounts for one row read by the query.
+func (b *queryBudget) scan() error {
+	if b == nil {
+		return nil
+	}
+	b.scanned++
+	if b.stats != nil {
+		b.stats.RowsFetched++
+	}
+	if b.maxScanned > 0 && b.scanned > b.maxScanned {
+		return ErrQueryBudgetExceeded(b.relation, "rows scanned", b.maxScanned)
+	}
+	return b.check()
+}
+
+// indexEntry accounts for one entry read from the plan's index.
+func (b *queryBudget) indexEntry() {
+	if b != nil && b.stats != nil {
+		b.stats.IndexEntries++
+	}
+}
+
+// startFilter returns the time a row starts being matched against the
+// ranges, when the query is analyzed.
+func (b *queryBudget) startFilter() time.Time {
+	if b == nil || b.stats == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// filtered accounts for a row matched from start on; ok is false when the
+// row was rejected.
+func (b *queryBudget) filtered(start time.Time, ok bool) {
+	if b == nil || b.stats == nil {
+		return
+	}
+	b.stats.FilterTime += time.Since(start)
+	if !ok {
+		b.stats.RowsFiltered++
+	}
+}
+
+func (b *queryBudget) check() error {
+	if !b.deadline.IsZero() && b.now().After(b.deadline) {
+		return ErrQueryTimeout(b.relation, b.timeout)
+	}
+	return nil
+}
+
+// limit ends seq with an error as soon as it returns more rows than allowed
+// or runs past the deadline.
+func (b *queryBudget) limit(seq iter.Seq2[map[string]any, error]) iter.Seq2[map[string]any, error] {
+	if b == nil {
+		return seq
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for row, err := range seq {
+			if err == nil {
+				b.rows++
+				if b.stats != nil {
+					b.stats.RowsReturned++
+				}
+				if b.maxRows > 0 && b.rows > b.maxRows {
+					err = ErrQueryBudgetExceeded(b.relation, "rows returned", b.maxRows)
+				} else {
+					err = b.check()
+				}
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+			if !yield(row, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package thunder
+
+import (
+	"fmt"
+	"iter"
+)
+
+// defaultBulkBatch is the number of rows per transaction of DB.InsertMany
+// and DB.InsertFrom when no batch size is given.
+const defaultBulkBatch = 10000
+
+// RowError reports the row of a bulk insert that was rejected, counted from
+// zero.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// InsertMany inserts rows after checking every one of them against the
+// column types and validators, so that a bad row is reported as a RowError
+// before anything is written. Rows are checked as Insert stores them, with
+// defaults, BeforeInsert hooks and generated columns applied. Constraint
+// violations, such as duplicate unique values, only show up while writing;
+// the transaction should then be rolled back.
+func (pr *Persistent) InsertMany(rows []map[string]any) (int, error) {
+	return pr.insertMany(rows, 0)
+}
+
+// insertMany is InsertMany for rows numbered from offset in errors.
+func (pr *Persistent) insertMany(rows []map[string]any, offset int) (int, error) {
+	if err := pr.checkWritable(); err != nil {
+		return 0, err
+	}
+	complete, err := pr.validateRows(rows, offset, true)
+	if err != nil {
+		return 0, err
+	}
+	return pr.observeWrite(SpanInsert, nil, func() (int, error) {
+		for i, row := range complete {
+			if err := pr.insertComplete(row); err != nil {
+				return i, RowError{Row: offset + i, Err: err}
+			}
+		}
+		return len(rows), nil
+	})
+}
+
+// validateRows completes rows as completeRow does and checks them, returning
+// the completed rows.
+func (pr *Persistent) validateRows(rows []map[string]any, offset int, hooks bool) ([]map[string]any, error) {
+	complete := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		row, err := pr.completeRow(row, hooks)
+		if err != nil {
+			return nil, RowError{Row: offset + i, Err: err}
+		}
+		if err := pr.validateRow(row); err != nil {
+			return nil, RowError{Row: offset + i, Err: err}
+		}
+		if err := pr.runValidators(row); err != nil {
+			return nil, RowError{Row: offset + i, Err: err}
+		}
+		complete[i] = row
+	}
+	return complete, nil
+}
+
+// InsertMany inserts rows into relation, validating all of them first and
+// then writing them in transactions of batchSize rows, 10000 when zero. It
+// returns the number of rows committed; batches committed before an error
+// stay committed. Rows of a relation with a BeforeInsert hook, which needs the
+// write transaction, are only validated batch by batch.
+func (d *DB) InsertMany(relation string, rows []map[string]any, batchSize int) (int, error) {
+	err := d.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		if p.hooks().BeforeInsert != nil {
+			return nil
+		}
+		_, err = p.validateRows(rows, 0, false)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return d.insertBatches(relation, func(yield func(map[string]any) bool) {
+		for _, row := range rows {
+			if !yield(row) {
+				return
+			}
+		}
+	}, batchSize)
+}
+
+// InsertFrom inserts the rows of seq into relation in transactions of
+// batchSize rows, 10000 when zero, validating each batch before writing it.
+// It returns the number of rows committed; batches committed before an
+// error stay committed.
+func (d *DB) InsertFrom(relation string, seq iter.Seq[map[string]any], batchSize int) (int, error) {
+	return d.insertBatches(relation, seq, batchSize)
+}
+
+func (d *DB) insertBatches(relation string, seq iter.Seq[map[string]any], batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultBulkBatch
+	}
+	next, stop := iter.Pull(seq)
+	defer stop()
+	total := 0
+	batch := make([]map[string]any, 0, batchSize)
+	for {
+		batch = batch[:0]
+		for len(batch) < batchSize {
+			row, ok := next()
+			if !ok {
+				break
+			}
+			batch = append(batch, row)
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			_, err = p.insertMany(batch, total)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+		total += len(batch)
+		if len(batch) < batchSize {
+			return total, nil
+		}
+	}
+}
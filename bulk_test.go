@@ -0,0 +1,126 @@
+package thunder
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBulkInsert(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("points", map[string]ColumnSpec{
+			"id": {Unique: true},
+			"x":  {Type: TypeInt},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		t.Helper()
+		rows, err := db.Query("SELECT * FROM points")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	rows := []map[string]any{{"id": "a", "x": 1}, {"id": "b", "x": "two"}, {"id": "c", "x": 3}}
+	n, err := db.InsertMany("points", rows, 0)
+	var rowErr RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 || n != 0 {
+		t.Fatalf("expected row 1 to be rejected up front, got %d, %v", n, err)
+	}
+	if got := count(); got != 0 {
+		t.Fatalf("expected nothing written, got %d rows", got)
+	}
+	rows[1]["x"] = 2
+	if n, err := db.InsertMany("points", rows, 2); err != nil || n != 3 {
+		t.Fatalf("expected 3 rows inserted, got %d, %v", n, err)
+	}
+
+	seq := func(yield func(map[string]any) bool) {
+		for _, id := range []string{"d", "e", "f", "a", "g"} {
+			if !yield(map[string]any{"id": id, "x": 0}) {
+				return
+			}
+		}
+	}
+	// The duplicate a fails the second batch; the first stays committed.
+	n, err = db.InsertFrom("points", seq, 3)
+	if !errors.As(err, &rowErr) || rowErr.Row != 3 || n != 3 {
+		t.Fatalf("expected the duplicate at row 3 to fail after 3 rows, got %d, %v", n, err)
+	}
+	if got := count(); got != 6 {
+		t.Fatalf("expected 6 rows, got %d", got)
+	}
+
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("points")
+		if err != nil {
+			return err
+		}
+		n, err := p.InsertMany([]map[string]any{{"id": "h", "x": 8}, {"id": "i", "x": 9}})
+		if err == nil && n != 2 {
+			t.Errorf("expected 2 rows, got %d", n)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 8 {
+		t.Fatalf("expected 8 rows, got %d", got)
+	}
+}
+
+func TestBulkInsertCompletesRows(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	db.SetGenerator("upper", func(row map[string]any) (any, error) {
+		return strings.ToUpper(row["name"].(string)), nil
+	})
+	db.SetValidators("users", "upper", Match(regexp.MustCompile("^[A-Z]+$")))
+	db.SetValidators("tags", "slug", Match(regexp.MustCompile("^[a-z]+$")))
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"name":    {Unique: true},
+			"upper":   {Type: TypeString, Generated: "upper"},
+			"created": {Type: TypeTime, DefaultNow: true},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.InsertMany("users", []map[string]any{{"name": "ann"}, {"name": "bob"}}, 0); err != nil || n != 2 {
+		t.Fatalf("expected defaults and generated columns applied before validating, got %d, %v", n, err)
+	}
+
+	err = db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("tags", map[string]ColumnSpec{
+			"tag":  {Unique: true},
+			"slug": {Type: TypeString},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fired := 0
+	db.SetHooks("tags", RelationHooks{BeforeInsert: func(tx *Tx, row map[string]any) error {
+		fired++
+		row["slug"] = strings.ToLower(row["tag"].(string))
+		return nil
+	}})
+	if n, err := db.InsertMany("tags", []map[string]any{{"tag": "Go"}, {"tag": "Db"}}, 0); err != nil || n != 2 {
+		t.Fatalf("expected rows completed by BeforeInsert, got %d, %v", n, err)
+	}
+	if fired != 2 {
+		t.Errorf("expected the hook run once per row, got %d", fired)
+	}
+}
@@ -0,0 +1,154 @@
+package thunder
+
+import (
+	"bytes"
+)
+
+// capacity holds the limits of a capped relation together with its current
+// usage, which is maintained on every insert and delete.
+type capacity struct {
+	MaxRows  int
+	MaxBytes int64
+	Rows     int
+	Bytes    int64
+	// Reject makes writes beyond the limits fail instead of evicting, see
+	// SetQuota.
+	Reject bool
+}
+
+// SetCap turns the relation into a capped relation that keeps at most
+// maxRows rows and maxBytes bytes of row data; zero disables a limit.
+// Inserting into a full relation evicts the oldest rows by insertion order.
+// Rows beyond the new limits are evicted immediately. Passing two zeros
+// removes the cap.
+func (pr *Persistent) SetCap(maxRows int, maxBytes int64) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	if maxRows <= 0 && maxBytes <= 0 {
+		pr.capacity = nil
+		return metaBucket.Delete([]byte("capacity"))
+	}
+	c := &capacity{MaxRows: maxRows, MaxBytes: maxBytes}
+	err := pr.data.bucket.ForEach(func(_, v []byte) error {
+		c.Rows++
+		c.Bytes += int64(len(v))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	pr.capacity = c
+	return pr.enforceCap(nil)
+}
+
+// SetQuota limits the relation to maxRows rows and maxBytes bytes of row
+// data; zero disables a limit. Unlike SetCap nothing is evicted: an insert
+// or update that would exceed the quota fails with ErrQuotaExceeded. A
+// relation already over its new quota keeps its rows but accepts no more.
+// Passing two zeros removes the quota.
+func (pr *Persistent) SetQuota(maxRows int, maxBytes int64) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if err := pr.SetCap(0, 0); err != nil || (maxRows <= 0 && maxBytes <= 0) {
+		return err
+	}
+	c := &capacity{MaxRows: maxRows, MaxBytes: maxBytes, Reject: true}
+	err := pr.data.bucket.ForEach(func(_, v []byte) error {
+		c.Rows++
+		c.Bytes += int64(len(v))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	pr.capacity = c
+	return pr.saveCapacity()
+}
+
+func (c *capacity) exceeded() bool {
+	return (c.MaxRows > 0 && c.Rows > c.MaxRows) || (c.MaxBytes > 0 && c.Bytes > c.MaxBytes)
+}
+
+// checkCapacity fails, before anything is written, if inserting the stored
+// row obj would break the relation's limits: under a quota if it exceeds
+// them at all, under a cap if the row alone exceeds the byte limit, as the
+// oldest rows are evicted to make room for anything smaller.
+func (pr *Persistent) checkCapacity(obj map[string]any) error {
+	if pr.capacity == nil {
+		return nil
+	}
+	raw, err := pr.data.maUn.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	after := *pr.capacity
+	if !after.Reject {
+		after.Rows, after.Bytes = 1, int64(len(raw))
+		if after.exceeded() {
+			return ErrCapacityExceeded(pr.relation)
+		}
+		return nil
+	}
+	after.Rows++
+	after.Bytes += int64(len(raw))
+	if after.exceeded() {
+		return ErrQuotaExceeded(pr.relation)
+	}
+	return nil
+}
+
+// enforceCap accounts for the freshly inserted row id, if any, and evicts the
+// oldest rows until the relation fits its cap again. The new row itself is
+// never evicted; checkCapacity has made sure it fits on its own. Under a
+// quota nothing is evicted.
+func (pr *Persistent) enforceCap(id []byte) error {
+	if id != nil {
+		pr.capacity.Rows++
+		pr.capacity.Bytes += int64(len(pr.data.bucket.Get(id)))
+		if pr.capacity.Reject {
+			return pr.saveCapacity()
+		}
+	}
+	for pr.capacity.exceeded() {
+		k, v := pr.data.bucket.Cursor().First()
+		if k == nil || bytes.Equal(k, id) {
+			return ErrCapacityExceeded(pr.relation)
+		}
+		var value map[string]any
+		if err := pr.data.unmarshal(k, v, &value); err != nil {
+			return err
+		}
+		e := entry{value: value}
+		copy(e.id[:], k)
+		if err := pr.deleteEntry(e); err != nil {
+			return err
+		}
+		if hooks := pr.hooks(); hooks.OnEvict != nil {
+			row, err := pr.rowCopy(value)
+			if err != nil {
+				return err
+			}
+			if err := hooks.OnEvict(pr.tx, row); err != nil {
+				return err
+			}
+		}
+	}
+	return pr.saveCapacity()
+}
+
+func (pr *Persistent) releaseUsage(id []byte) error {
+	pr.capacity.Rows--
+	pr.capacity.Bytes -= int64(len(pr.data.bucket.Get(id)))
+	return pr.saveCapacity()
+}
+
+func (pr *Persistent) saveCapacity() error {
+	capBytes, err := pr.maUn.Marshal(pr.capacity)
+	if err != nil {
+		return err
+	}
+	return pr.bucket.Bucket([]byte("meta")).Put([]byte("capacity"), capBytes)
+}
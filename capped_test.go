@@ -0,0 +1,177 @@
+package thunder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPersistent_CappedRelation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{
+		"seq": {Unique: true},
+		"msg": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		if err := logs.Insert(map[string]any{"seq": int64(i), "msg": "boot"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logs.SetCap(3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err = tx.LoadPersistent("logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seqs := func() []int64 {
+		t.Helper()
+		rows, err := logs.Select(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []int64
+		for row, err := range rows {
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, row["seq"].(int64))
+		}
+		return out
+	}
+	if got := seqs(); len(got) != 3 || got[0] != 2 {
+		t.Fatalf("expected the 3 newest rows to survive SetCap, got %v", got)
+	}
+	for i := 5; i < 8; i++ {
+		if err := logs.Insert(map[string]any{"seq": int64(i), "msg": "tick"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := seqs(); len(got) != 3 || got[0] != 5 || got[2] != 7 {
+		t.Fatalf("expected ring buffer of 5..7, got %v", got)
+	}
+	// Evicted rows must also leave the unique index.
+	if err := logs.Insert(map[string]any{"seq": int64(0), "msg": "again"}); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("seq", int64(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if logs.capacity.Rows != 2 {
+		t.Errorf("expected usage to follow deletes, got %d rows", logs.capacity.Rows)
+	}
+
+	if err := logs.SetCap(0, 64); err != nil {
+		t.Fatal(err)
+	}
+	err = logs.Insert(map[string]any{"seq": int64(100), "msg": strings.Repeat("x", 200)})
+	var thunderErr *ThunderError
+	if !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeCapacityExceeded {
+		t.Fatalf("expected oversized row to be rejected, got %v", err)
+	}
+}
+
+func TestPersistent_Quota(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	var evicted []string
+	db.SetHooks("cache", RelationHooks{
+		OnEvict: func(tx *Tx, row map[string]any) error {
+			evicted = append(evicted, row["key"].(string))
+			return nil
+		},
+	})
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tenant, err := tx.CreatePersistent("tenant", map[string]ColumnSpec{"key": {Unique: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.SetQuota(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := tenant.Insert(map[string]any{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var te *ThunderError
+	if err := tenant.Insert(map[string]any{"key": "c"}); !errors.As(err, &te) || te.Code != ErrCodeQuotaExceeded {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	rows, err := tenant.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range rows {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected the rejected row not to be written, got %d rows", n)
+	}
+	if err := tenant.Delete(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tenant.Insert(map[string]any{"key": "c"}); err != nil {
+		t.Fatalf("expected room after deleting, got %v", err)
+	}
+
+	cache, err := tx.CreatePersistent("cache", map[string]ColumnSpec{"key": {Unique: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.SetCap(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := cache.Insert(map[string]any{"key": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(evicted, ",") != "a,b" {
+		t.Fatalf("expected a and b evicted, got %v", evicted)
+	}
+	// A row the cap can never hold is refused before anything is evicted.
+	if err := cache.SetCap(2, 64); err != nil {
+		t.Fatal(err)
+	}
+	evicted = nil
+	err = cache.Insert(map[string]any{"key": strings.Repeat("x", 200)})
+	if !errors.As(err, &te) || te.Code != ErrCodeCapacityExceeded {
+		t.Fatalf("expected ErrCapacityExceeded, got %v", err)
+	}
+	if len(evicted) != 0 {
+		t.Fatalf("expected no evictions for a row that cannot fit, got %v", evicted)
+	}
+}
@@ -0,0 +1,247 @@
+package thunder
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// feedBucket holds the changefeed state. Its sequence numbers change events.
+const feedBucket = "__thunder_feed"
+
+// ChangeOp is the kind of write a ChangeEvent reports.
+type ChangeOp int
+
+const (
+	ChangeInsert ChangeOp = iota + 1
+	ChangeUpdate
+	ChangeDelete
+	// ChangeTruncate reports that every row of the relation was removed.
+	ChangeTruncate
+)
+
+func (op ChangeOp) String() string {
+	switch op {
+	case ChangeInsert:
+		return "insert"
+	case ChangeUpdate:
+		return "update"
+	case ChangeDelete:
+		return "delete"
+	case ChangeTruncate:
+		return "truncate"
+	}
+	return "unknown"
+}
+
+// ChangeEvent is a committed change to a row. Before is nil for inserts and
+// After is nil for deletes. Seq increases with every event of the database,
+// across restarts. Events are shared between watchers and must not be
+// modified.
+type ChangeEvent struct {
+	Seq      uint64
+	Relation string
+	Op       ChangeOp
+	Before   map[string]any
+	After    map[string]any
+}
+
+// changefeed fans committed events out to the watchers of each relation.
+type changefeed struct {
+	mu   sync.Mutex
+	subs map[string]map[*feedSub]struct{}
+}
+
+type feedSub struct {
+	mu     sync.Mutex
+	queue  []ChangeEvent
+	notify chan struct{}
+}
+
+func (f *changefeed) subscribe(relation string) *feedSub {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[string]map[*feedSub]struct{})
+	}
+	if f.subs[relation] == nil {
+		f.subs[relation] = make(map[*feedSub]struct{})
+	}
+	sub := &feedSub{notify: make(chan struct{}, 1)}
+	f.subs[relation][sub] = struct{}{}
+	return sub
+}
+
+func (f *changefeed) unsubscribe(relation string, sub *feedSub) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subs[relation], sub)
+	if len(f.subs[relation]) == 0 {
+		delete(f.subs, relation)
+	}
+}
+
+func (f *changefeed) watched(relation string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs[relation]) > 0
+}
+
+// publish queues events for their watchers. It never blocks on a slow
+// watcher; queues grow until the watcher catches up.
+func (f *changefeed) publish(events []ChangeEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ev := range events {
+		for sub := range f.subs[ev.Relation] {
+			sub.mu.Lock()
+			sub.queue = append(sub.queue, ev)
+			sub.mu.Unlock()
+			select {
+			case sub.notify <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (s *feedSub) drain() []ChangeEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.queue
+	s.queue = nil
+	return events
+}
+
+// Watch streams the changes committed to the relation, from the moment
+// iteration starts until ctx is done, when ctx.Err() is yielded. It does not
+// use the transaction pr belongs to and may outlive it. Under a row policy
+// only the changes to rows visible with ctx are streamed, as by
+// DB.Subscribe. The rows of events are read as the transaction would read
+// them, with the columns it may not see redacted.
+func (pr *Persistent) Watch(ctx context.Context) iter.Seq2[ChangeEvent, error] {
+	feed, relation := &pr.tx.db.feed, pr.relation
+	return func(yield func(ChangeEvent, error) bool) {
+		yield, err := pr.eventFilter(ctx, yield)
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		sub := feed.subscribe(relation)
+		defer feed.unsubscribe(relation, sub)
+		feed.follow(ctx, sub, 0, yield)
+	}
+}
+
+// eventFilter returns yield seen by a watcher of pr: only the events of
+// rows the policy shows under ctx, redacted as by redactEvents.
+func (pr *Persistent) eventFilter(ctx context.Context, yield func(ChangeEvent, error) bool) (func(ChangeEvent, error) bool, error) {
+	ranges, err := pr.restrictFor(ctx, nil)
+	if err != nil {
+		return yield, err
+	}
+	yield = pr.redactEvents(yield)
+	if len(ranges) == 0 {
+		return yield, nil
+	}
+	return matchEvents(pr.matcher(pr.coerceRanges(ranges), ""), yield), nil
+}
+
+// redactEvents returns yield with the columns the transaction of pr cannot
+// read replaced by Redacted in every event: encrypted columns whose key it
+// does not hold and columns masked for its identity.
+func (pr *Persistent) redactEvents(yield func(ChangeEvent, error) bool) func(ChangeEvent, error) bool {
+	hidden := pr.maskedFor()
+	for _, col := range pr.columns {
+		keyID := pr.fields[col].EncryptionKey
+		if _, ok := pr.tx.keys[keyID]; keyID != "" && !ok && !slices.Contains(hidden, col) {
+			hidden = append(hidden, col)
+		}
+	}
+	if len(hidden) == 0 {
+		return yield
+	}
+	redacted := func(row map[string]any) map[string]any {
+		if row == nil {
+			return nil
+		}
+		row = maps.Clone(row)
+		redact(row, hidden)
+		return row
+	}
+	return func(ev ChangeEvent, err error) bool {
+		ev.Before, ev.After = redacted(ev.Before), redacted(ev.After)
+		return yield(ev, err)
+	}
+}
+
+// follow yields the events sub receives with a sequence after after.
+func (f *changefeed) follow(ctx context.Context, sub *feedSub, after uint64, yield func(ChangeEvent, error) bool) {
+	for {
+		for _, ev := range sub.drain() {
+			if ev.Seq <= after {
+				continue
+			}
+			if !yield(ev, nil) {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			yield(ChangeEvent{}, ctx.Err())
+			return
+		case <-sub.notify:
+		}
+	}
+}
+
+// recordChange queues a change event to be published when the transaction
+// commits and appends it to the event log. Events are only numbered while
+// someone watches the relation or the event log is enabled.
+func (pr *Persistent) recordChange(op ChangeOp, before, after map[string]any) error {
+	if !pr.watched() {
+		return nil
+	}
+	bucket, err := pr.tx.tx.CreateBucketIfNotExists([]byte(feedBucket))
+	if err != nil {
+		return err
+	}
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	ev := ChangeEvent{
+		Seq:      seq,
+		Relation: pr.relation,
+		Op:       op,
+		Before:   ownedRow(pr.maUn, before),
+		After:    ownedRow(pr.maUn, maps.Clone(after)),
+	}
+	if err := pr.logEvent(bucket, ev); err != nil {
+		return err
+	}
+	pr.tx.events = append(pr.tx.events, ev)
+	return nil
+}
+
+func (pr *Persistent) watched() bool {
+	if pr.ephemeral || pr.tx.db == nil {
+		return false
+	}
+	return pr.tx.db.feed.watched(pr.relation) || pr.tx.eventLogEnabled()
+}
+
+// decryptedCopy returns a copy of a stored row with its encrypted columns
+// decrypted where the transaction holds the key.
+func (pr *Persistent) decryptedCopy(value map[string]any) (map[string]any, error) {
+	row := maps.Clone(value)
+	if err := pr.decryptRow(row); err != nil {
+		return nil, err
+	}
+	if err := pr.decodeRow(row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
@@ -0,0 +1,179 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPersistent_Watch(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var watcher *Persistent
+	err = db.update(func(tx *Tx) error {
+		watcher, err = tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"name": {},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan ChangeEvent)
+	done := make(chan error, 1)
+	go func() {
+		for ev, err := range watcher.Watch(ctx) {
+			if err != nil {
+				done <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+	for !db.feed.watched("users") {
+		time.Sleep(time.Millisecond)
+	}
+
+	idRange := func(id int64) map[string]*keyRange {
+		ranges, err := ToKeyRanges(Eq("id", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranges
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		if err := p.Insert(map[string]any{"id": int64(1), "name": "ada"}); err != nil {
+			return err
+		}
+		n, err := p.Update(idRange(1), map[string]any{"name": "ada l."})
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			t.Errorf("expected 1 updated row, got %d", n)
+		}
+		return p.Delete(idRange(1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rolled back writes are never reported.
+	_ = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		if err := p.Insert(map[string]any{"id": int64(2), "name": "bob"}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+
+	var got []ChangeEvent
+	for range 3 {
+		got = append(got, <-events)
+	}
+	if got[0].Op != ChangeInsert || got[0].Before != nil || got[0].After["name"] != "ada" {
+		t.Fatalf("unexpected insert event %+v", got[0])
+	}
+	if got[1].Op != ChangeUpdate || got[1].Before["name"] != "ada" || got[1].After["name"] != "ada l." {
+		t.Fatalf("unexpected update event %+v", got[1])
+	}
+	if got[2].Op != ChangeDelete || got[2].Before["name"] != "ada l." || got[2].After != nil {
+		t.Fatalf("unexpected delete event %+v", got[2])
+	}
+	if !(got[0].Seq < got[1].Seq && got[1].Seq < got[2].Seq) {
+		t.Fatalf("sequences not increasing: %d %d %d", got[0].Seq, got[1].Seq, got[2].Seq)
+	}
+
+	cancel()
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	}
+}
+
+func TestPersistent_WatchRedacts(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	key := []byte("0123456789abcdef")
+	err = db.update(func(tx *Tx) error {
+		people, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"ssn":  {},
+			"card": {EncryptionKey: "cards"},
+		})
+		if err != nil {
+			return err
+		}
+		return people.MaskColumn("ssn", "support")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.SetIdentity("support")
+	people, err := tx.LoadPersistent("people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan ChangeEvent, 1)
+	go func() {
+		for ev, err := range people.Watch(ctx) {
+			if err == nil {
+				events <- ev
+			}
+			return
+		}
+	}()
+	for !db.feed.watched("people") {
+		time.Sleep(time.Millisecond)
+	}
+	err = db.update(func(tx *Tx) error {
+		if err := tx.SetKey("cards", key); err != nil {
+			return err
+		}
+		people, err := tx.LoadPersistent("people")
+		if err != nil {
+			return err
+		}
+		return people.Insert(map[string]any{"id": 1, "ssn": "123-45", "card": "4111"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev.After["ssn"] != Redacted || ev.After["card"] != Redacted || ev.After["id"] == Redacted {
+			t.Fatalf("expected ssn and card redacted for support, got %v", ev.After)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the insert")
+	}
+}
@@ -0,0 +1,527 @@
+package thunder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+)
+
+// changesBucket holds the change tracking state. Its sequence is the commit
+// sequence; every relation has a sub-bucket with:
+//   - "created": the sequence at which the relation was (re)created
+//   - "rows": row id -> sequence of the row's last change
+//   - "log": sequence followed by row id -> nil, ordered for BackupSince
+const changesBucket = "__thunder_changes"
+
+// EnableChangeTracking turns on change tracking. From then on every write
+// transaction that modifies a relation is assigned a commit sequence number
+// and the rows it touches are remembered, so BackupSince can export just
+// what changed after a given sequence.
+func (d *DB) EnableChangeTracking() error {
+	return d.update(func(tx *Tx) error {
+		root, err := tx.tx.CreateBucketIfNotExists([]byte(changesBucket))
+		if err != nil {
+			return err
+		}
+		names, err := tx.Relations()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if root.Bucket([]byte(name)) != nil {
+				continue
+			}
+			if err := tx.trackRelationReset(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CommitSequence returns the sequence number of the latest tracked commit,
+// or zero when change tracking is off.
+func (tx *Tx) CommitSequence() uint64 {
+	root := tx.tx.Bucket([]byte(changesBucket))
+	if root == nil {
+		return 0
+	}
+	return root.Sequence()
+}
+
+// changeSeq returns the commit sequence of this transaction, allocating it on
+// first use. It returns nil when change tracking is off.
+func (tx *Tx) changeSeq() (BackendBucket, uint64, error) {
+	root := tx.tx.Bucket([]byte(changesBucket))
+	if root == nil {
+		return nil, 0, nil
+	}
+	if tx.commitSeq == 0 {
+		seq, err := root.NextSequence()
+		if err != nil {
+			return nil, 0, err
+		}
+		tx.commitSeq = seq
+	}
+	return root, tx.commitSeq, nil
+}
+
+// trackRelationReset records that relation was created or replaced as a
+// whole, so incremental backups taken from before now carry it in full.
+func (tx *Tx) trackRelationReset(relation string) error {
+	tx.touch(relation)
+	root, seq, err := tx.changeSeq()
+	if root == nil || err != nil {
+		return err
+	}
+	if err := tx.forgetChanges(relation); err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucket([]byte(relation))
+	if err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket([]byte("rows")); err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket([]byte("log")); err != nil {
+		return err
+	}
+	return bucket.Put([]byte("created"), binary.BigEndian.AppendUint64(nil, seq))
+}
+
+func (tx *Tx) forgetChanges(relation string) error {
+	tx.touch(relation)
+	root := tx.tx.Bucket([]byte(changesBucket))
+	if root == nil || root.Bucket([]byte(relation)) == nil {
+		return nil
+	}
+	return root.DeleteBucket([]byte(relation))
+}
+
+// trackChange records that the row id was inserted or deleted.
+func (pr *Persistent) trackChange(id []byte) error {
+	if pr.ephemeral {
+		return nil
+	}
+	pr.tx.touch(pr.relation)
+	root, seq, err := pr.tx.changeSeq()
+	if root == nil || err != nil {
+		return err
+	}
+	bucket := root.Bucket([]byte(pr.relation))
+	if bucket == nil {
+		if err := pr.tx.trackRelationReset(pr.relation); err != nil {
+			return err
+		}
+		bucket = root.Bucket([]byte(pr.relation))
+	}
+	rows, log := bucket.Bucket([]byte("rows")), bucket.Bucket([]byte("log"))
+	if old := rows.Get(id); old != nil {
+		if err := log.Delete(append(slices.Clone(old), id...)); err != nil {
+			return err
+		}
+	}
+	seqBytes := binary.BigEndian.AppendUint64(nil, seq)
+	if err := rows.Put(id, seqBytes); err != nil {
+		return err
+	}
+	return log.Put(append(seqBytes, id...), nil)
+}
+
+// backupHeader opens an incremental backup stream. It lists every relation
+// with its metadata so that the restore side can create, drop and re-specify
+// relations; Reset marks relations that must be restored from scratch. It
+// also carries every sequence and the log records appended since From.
+type backupHeader struct {
+	From      uint64
+	To        uint64
+	Relations []backupRelation
+	Sequences map[string][]byte
+	Logs      []backupLog
+}
+
+type backupRelation struct {
+	Name  string
+	Meta  map[string][]byte
+	Reset bool
+}
+
+// backupRow is a changed row. A nil Value means the row was deleted.
+// Indexes holds the row's key in every index of the relation, and Blobs the
+// bytes of the blobs the row refers to.
+type backupRow struct {
+	Relation string
+	ID       []byte
+	Value    []byte
+	Indexes  map[string][]byte
+	Blobs    map[uint64][]byte
+}
+
+// BackupSince writes to w every relation row, together with its index
+// entries, that changed after the commit sequence since, along with every
+// sequence and the log records appended since, and returns the sequence the
+// backup is current up to. Pass the returned sequence to the
+// next call to chain incrementals on top of a full backup taken with Backup.
+// Change tracking must be enabled.
+func (d *DB) BackupSince(since uint64, w io.Writer) (uint64, error) {
+	var to uint64
+	err := d.view(func(tx *Tx) error {
+		var err error
+		to, err = tx.backupSince(since, w)
+		return err
+	})
+	return to, err
+}
+
+func (tx *Tx) backupSince(since uint64, w io.Writer) (uint64, error) {
+	root := tx.tx.Bucket([]byte(changesBucket))
+	if root == nil {
+		return 0, ErrChangeTrackingDisabled()
+	}
+	to := root.Sequence()
+	bw := bufio.NewWriter(w)
+	header := backupHeader{From: since, To: to}
+	names, err := tx.Relations()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		rel := backupRelation{Name: name, Meta: make(map[string][]byte)}
+		err := tx.tx.Bucket([]byte(name)).Bucket([]byte("meta")).ForEach(func(k, v []byte) error {
+			rel.Meta[string(k)] = slices.Clone(v)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		changes := root.Bucket([]byte(name))
+		rel.Reset = changes == nil || binary.BigEndian.Uint64(changes.Get([]byte("created"))) > since
+		header.Relations = append(header.Relations, rel)
+	}
+	if header.Sequences, err = tx.backupSequences(); err != nil {
+		return 0, err
+	}
+	if header.Logs, err = tx.backupLogs(since); err != nil {
+		return 0, err
+	}
+	if err := writeFrame(bw, tx.maUn, header); err != nil {
+		return 0, err
+	}
+	for _, rel := range header.Relations {
+		p, err := tx.LoadPersistent(rel.Name)
+		if err != nil {
+			return 0, err
+		}
+		if err := p.backupRows(bw, root.Bucket([]byte(rel.Name)), since, rel.Reset); err != nil {
+			return 0, err
+		}
+	}
+	return to, bw.Flush()
+}
+
+// backupRows writes the rows of the relation changed after since, or all of
+// them when full is set.
+func (pr *Persistent) backupRows(w io.Writer, changes BackendBucket, since uint64, full bool) error {
+	write := func(id []byte) error {
+		row := backupRow{Relation: pr.relation, ID: id}
+		if v := pr.data.bucket.Get(id); v != nil {
+			var value map[string]any
+			if err := pr.data.unmarshal(id, v, &value); err != nil {
+				return err
+			}
+			row.Value = v
+			row.Indexes = make(map[string][]byte, len(pr.indexNames))
+			for _, name := range pr.indexNames {
+				if pr.fields[name].MultiEntry {
+					// Recomputed from the row when applied.
+					continue
+				}
+				key, err := pr.computeKey(value, name)
+				if err != nil {
+					return err
+				}
+				row.Indexes[name] = key
+			}
+			blobs, err := pr.blobContents(value)
+			if err != nil {
+				return err
+			}
+			row.Blobs = blobs
+		}
+		return writeFrame(w, pr.maUn, row)
+	}
+	if full || changes == nil {
+		return pr.data.bucket.ForEach(func(k, _ []byte) error {
+			return write(k)
+		})
+	}
+	c := changes.Bucket([]byte("log")).Cursor()
+	for k, _ := c.Seek(binary.BigEndian.AppendUint64(nil, since+1)); k != nil; k, _ = c.Next() {
+		if err := write(k[8:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the whole database file to w and returns
+// the commit sequence it corresponds to, for use with BackupSince.
+func (d *DB) Backup(w io.Writer) (uint64, error) {
+	var seq uint64
+	err := d.view(func(tx *Tx) error {
+		seq = tx.CommitSequence()
+		_, err := tx.writeSnapshot(w)
+		return err
+	})
+	return seq, err
+}
+
+// ApplyBackup applies an incremental backup written by BackupSince to the
+// database in a single transaction and returns the sequence it brings the
+// database up to. The database is expected to hold the state the backup was
+// taken against, typically a restored full backup plus earlier incrementals.
+func (d *DB) ApplyBackup(r io.Reader) (uint64, error) {
+	br := bufio.NewReader(r)
+	var header backupHeader
+	if err := readFrame(br, d.maUn, &header); err != nil {
+		return 0, err
+	}
+	err := d.update(func(tx *Tx) error {
+		return tx.applyBackup(header, br)
+	})
+	return header.To, err
+}
+
+// applyBackup applies the rows following header. With change tracking on,
+// they are tracked as changes of this transaction.
+func (tx *Tx) applyBackup(header backupHeader, br *bufio.Reader) error {
+	if err := tx.applyBackupHeader(header); err != nil {
+		return err
+	}
+	loaded := make(map[string]*Persistent)
+	for {
+		var row backupRow
+		err := readFrame(br, tx.maUn, &row)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p, ok := loaded[row.Relation]
+		if !ok {
+			if p, err = tx.LoadPersistent(row.Relation); err != nil {
+				return err
+			}
+			loaded[row.Relation] = p
+		}
+		if err := p.applyBackupRow(row); err != nil {
+			return err
+		}
+	}
+}
+
+func (tx *Tx) applyBackupHeader(header backupHeader) error {
+	keep := make(map[string]bool, len(header.Relations))
+	for _, rel := range header.Relations {
+		keep[rel.Name] = true
+		tx.touch(rel.Name)
+		bucket := tx.tx.Bucket([]byte(rel.Name))
+		if bucket != nil && rel.Reset {
+			if err := tx.tx.DeleteBucket([]byte(rel.Name)); err != nil {
+				return err
+			}
+			bucket = nil
+		}
+		if bucket == nil || rel.Reset {
+			if err := tx.trackRelationReset(rel.Name); err != nil {
+				return err
+			}
+		}
+		if bucket == nil {
+			var err error
+			if bucket, err = tx.tx.CreateBucket([]byte(rel.Name)); err != nil {
+				return err
+			}
+		}
+		metaBucket, err := bucket.CreateBucketIfNotExists([]byte("meta"))
+		if err != nil {
+			return err
+		}
+		specsChanged := !bytes.Equal(metaBucket.Get([]byte("columnSpecs")), rel.Meta["columnSpecs"])
+		for k, v := range rel.Meta {
+			if err := metaBucket.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		if _, err := bucket.CreateBucketIfNotExists([]byte("data")); err != nil {
+			return err
+		}
+		if _, err := bucket.CreateBucketIfNotExists([]byte("indexes")); err != nil {
+			return err
+		}
+		if specsChanged {
+			p, err := tx.LoadPersistent(rel.Name)
+			if err != nil {
+				return err
+			}
+			if err := p.rebuildIndexes(); err != nil {
+				return err
+			}
+			if err := p.rebuildColumns(); err != nil {
+				return err
+			}
+		}
+	}
+	names, err := tx.Relations()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !keep[name] {
+			if err := tx.DropRelation(name); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.applySequences(header.Sequences); err != nil {
+		return err
+	}
+	return tx.applyLogs(header.Logs)
+}
+
+// rebuildIndexes recreates every index bucket of the relation from its rows.
+func (pr *Persistent) rebuildIndexes() error {
+	if err := pr.bucket.DeleteBucket([]byte("indexes")); err != nil {
+		return err
+	}
+	indexesStore, err := newIndex(pr.bucket, pr.indexNames, pr.maUn)
+	if err != nil {
+		return err
+	}
+	pr.indexes = indexesStore
+	return pr.data.bucket.ForEach(func(k, v []byte) error {
+		var value map[string]any
+		if err := pr.data.unmarshal(k, v, &value); err != nil {
+			return err
+		}
+		for _, name := range pr.indexNames {
+			keys, err := pr.indexKeys(value, name)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := pr.indexes.insert(name, key, k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (pr *Persistent) applyBackupRow(row backupRow) error {
+	if err := pr.trackChange(row.ID); err != nil {
+		return err
+	}
+	var old map[string]any
+	if raw := pr.data.bucket.Get(row.ID); raw != nil {
+		if err := pr.data.unmarshal(row.ID, raw, &old); err != nil {
+			return err
+		}
+		for _, name := range pr.indexNames {
+			keys, err := pr.indexKeys(old, name)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := pr.indexes.delete(name, key, row.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if err := pr.refBlobs(old, row.ID, false); err != nil {
+			return err
+		}
+	}
+	if row.Value == nil {
+		if err := pr.data.delete(row.ID); err != nil {
+			return err
+		}
+		return pr.dropBlobs(old)
+	}
+	if err := pr.data.bucket.Put(row.ID, row.Value); err != nil {
+		return err
+	}
+	var value map[string]any
+	if err := pr.data.unmarshal(row.ID, row.Value, &value); err != nil {
+		return err
+	}
+	if len(pr.data.columnar) > 0 {
+		if err := pr.data.putColumns(row.ID, value, pr.data.codec(row.ID)); err != nil {
+			return err
+		}
+	}
+	if id := binary.BigEndian.Uint64(row.ID); id > pr.data.bucket.Sequence() {
+		if err := pr.data.bucket.SetSequence(id); err != nil {
+			return err
+		}
+	}
+	for name, key := range row.Indexes {
+		if err := pr.indexes.insert(name, key, row.ID); err != nil {
+			return err
+		}
+	}
+	for _, name := range pr.indexNames {
+		if !pr.fields[name].MultiEntry {
+			continue
+		}
+		keys, err := pr.indexKeys(value, name)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := pr.indexes.insert(name, key, row.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for id, data := range row.Blobs {
+		if err := pr.putBlob(Blob(id), data); err != nil {
+			return err
+		}
+	}
+	if err := pr.refBlobs(value, row.ID, true); err != nil {
+		return err
+	}
+	return pr.dropBlobs(old)
+}
+
+// writeFrame writes v encoded with maUn, prefixed by its length.
+func writeFrame(w io.Writer, maUn MarshalUnmarshaler, v any) error {
+	b, err := maUn.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(binary.AppendUvarint(nil, uint64(len(b)))); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func readFrame(r *bufio.Reader, maUn MarshalUnmarshaler, v any) error {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return err
+	}
+	return maUn.Unmarshal(b, v)
+}
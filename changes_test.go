@@ -0,0 +1,414 @@
+package thunder
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDB_IncrementalBackup(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	if err := db.EnableChangeTracking(); err != nil {
+		t.Fatal(err)
+	}
+	err := db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"name": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreatePersistent("scratch", map[string]ColumnSpec{"x": {}}); err != nil {
+			return err
+		}
+		for i, name := range []string{"alice", "bob", "carol"} {
+			if err := users.Insert(map[string]any{"id": int64(i), "name": name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var full bytes.Buffer
+	seq, err := db.Backup(&full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restorePath := filepath.Join(t.TempDir(), "restore.db")
+	if err := os.WriteFile(restorePath, full.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := OpenDB(&MsgpackMaUn, restorePath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("name", "bob"))
+		if err != nil {
+			return err
+		}
+		if err := users.Delete(ranges); err != nil {
+			return err
+		}
+		if err := users.Insert(map[string]any{"id": int64(3), "name": "dave"}); err != nil {
+			return err
+		}
+		if err := tx.DropRelation("scratch"); err != nil {
+			return err
+		}
+		tags, err := tx.CreatePersistent("tags", map[string]ColumnSpec{"tag": {Indexed: true}})
+		if err != nil {
+			return err
+		}
+		return tags.Insert(map[string]any{"tag": "new"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var incr bytes.Buffer
+	to, err := db.BackupSince(seq, &incr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to <= seq {
+		t.Fatalf("expected sequence to advance past %d, got %d", seq, to)
+	}
+	got, err := restored.ApplyBackup(&incr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != to {
+		t.Fatalf("expected restored sequence %d, got %d", to, got)
+	}
+
+	names, err := restored.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"tags", "users"}) {
+		t.Fatalf("unexpected relations %v", names)
+	}
+	tx, err := restored.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.LoadPersistent("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"alice": 1, "bob": 0, "carol": 1, "dave": 1} {
+		ranges, err := ToKeyRanges(Eq("name", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := users.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		if n != want {
+			t.Fatalf("expected %d rows named %s, got %d", want, name, n)
+		}
+	}
+	tags, err := tx.LoadPersistent("tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tags.Count(); err != nil || n != 1 {
+		t.Fatalf("expected 1 tag, got %d (%v)", n, err)
+	}
+}
+
+func TestDB_IncrementalBackupBlobs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	if err := db.EnableChangeTracking(); err != nil {
+		t.Fatal(err)
+	}
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("files", map[string]ColumnSpec{
+			"name": {Unique: true},
+			"data": {Type: TypeBlob},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var full bytes.Buffer
+	seq, err := db.Backup(&full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restorePath := filepath.Join(t.TempDir(), "restore.db")
+	if err := os.WriteFile(restorePath, full.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := OpenDB(&MsgpackMaUn, restorePath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	apply := func(write func(files *Persistent) error) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			files, err := tx.LoadPersistent("files")
+			if err != nil {
+				return err
+			}
+			return write(files)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var incr bytes.Buffer
+		if seq, err = db.BackupSince(seq, &incr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := restored.ApplyBackup(&incr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ref Blob
+	apply(func(files *Persistent) error {
+		w, err := files.CreateBlob()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("report")); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		ref = w.Blob()
+		return files.Insert(map[string]any{"name": "q1", "data": ref})
+	})
+	err = restored.view(func(tx *Tx) error {
+		files, err := tx.LoadPersistent("files")
+		if err != nil {
+			return err
+		}
+		r, err := files.OpenBlob(ref)
+		if err != nil {
+			return err
+		}
+		if body, err := io.ReadAll(r); err != nil || string(body) != "report" {
+			t.Errorf("expected the blob restored, got %q, %v", body, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apply(func(files *Persistent) error {
+		ranges, err := ToKeyRanges(Eq("name", "q1"))
+		if err != nil {
+			return err
+		}
+		return files.Delete(ranges)
+	})
+	err = restored.view(func(tx *Tx) error {
+		files, err := tx.LoadPersistent("files")
+		if err != nil {
+			return err
+		}
+		if _, err := files.openBlob(ref); err == nil {
+			t.Error("expected the blob deleted with its row")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_ChangesSequencesAndLogs(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	replica, cleanupReplica := setupTestDB(t)
+	defer cleanupReplica()
+	for _, d := range []*DB{db, replica} {
+		if err := d.EnableChangeTracking(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var seq uint64
+	sync := func(write func(tx *Tx) error) {
+		t.Helper()
+		if err := db.update(write); err != nil {
+			t.Fatal(err)
+		}
+		var changes bytes.Buffer
+		var err error
+		if seq, err = db.BackupSince(seq, &changes); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := replica.ApplyChanges(&changes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offsets := func() (first, last uint64, read []uint64) {
+		t.Helper()
+		err := replica.view(func(tx *Tx) error {
+			l, err := tx.LoadLog("events")
+			if err != nil {
+				return err
+			}
+			for record, err := range l.Read(0) {
+				if err != nil {
+					return err
+				}
+				read = append(read, record.Offset)
+			}
+			first, last = l.First(), l.Last()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return first, last, read
+	}
+
+	sync(func(tx *Tx) error {
+		l, err := tx.CreateLog("events", 2)
+		if err != nil {
+			return err
+		}
+		for i := range 3 {
+			if _, err := l.Append(map[string]any{"n": int64(i)}); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Sequence("invoices").Next(); err != nil {
+			return err
+		}
+		_, err = tx.Sequence("invoices").Next()
+		return err
+	})
+	if n, err := replica.Sequence("invoices").Peek(); err != nil || n != 3 {
+		t.Errorf("expected the sequence replicated at 3, got %d, %v", n, err)
+	}
+	if first, last, read := offsets(); first != 1 || last != 3 || !slices.Equal(read, []uint64{1, 2, 3}) {
+		t.Errorf("expected records 1 to 3, got %d to %d: %v", first, last, read)
+	}
+
+	sync(func(tx *Tx) error {
+		l, err := tx.LoadLog("events")
+		if err != nil {
+			return err
+		}
+		for i := range 2 {
+			if _, err := l.Append(map[string]any{"n": int64(i)}); err != nil {
+				return err
+			}
+		}
+		if _, err := l.Trim(3); err != nil {
+			return err
+		}
+		return tx.Sequence("invoices").SetStart(10)
+	})
+	if n, err := replica.Sequence("invoices").Peek(); err != nil || n != 10 {
+		t.Errorf("expected the sequence replicated at 10, got %d, %v", n, err)
+	}
+	if first, last, read := offsets(); first != 3 || last != 5 || !slices.Equal(read, []uint64{3, 4, 5}) {
+		t.Errorf("expected records 3 to 5, got %d to %d: %v", first, last, read)
+	}
+
+	sync(func(tx *Tx) error {
+		return tx.DropLog("events")
+	})
+	err := replica.view(func(tx *Tx) error {
+		_, err := tx.LoadLog("events")
+		return err
+	})
+	var te *ThunderError
+	if !errors.As(err, &te) || te.Code != ErrCodeLogNotFound {
+		t.Errorf("expected the log dropped, got %v", err)
+	}
+}
+
+func TestDB_CreatePersistentExistingKeepsTracking(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	if err := db.EnableChangeTracking(); err != nil {
+		t.Fatal(err)
+	}
+	specs := map[string]ColumnSpec{"id": {Unique: true}}
+	err := db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", specs)
+		if err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"id": "1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := db.BackupSince(0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", specs)
+		if err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"id": "2"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var incr bytes.Buffer
+	if _, err := db.BackupSince(seq, &incr); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(&incr)
+	var header backupHeader
+	if err := readFrame(br, db.maUn, &header); err != nil {
+		t.Fatal(err)
+	}
+	if len(header.Relations) != 1 || header.Relations[0].Reset {
+		t.Fatalf("expected users carried incrementally, got %+v", header.Relations)
+	}
+	rows := 0
+	for {
+		var row backupRow
+		if err := readFrame(br, db.maUn, &row); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows++
+	}
+	if rows != 1 {
+		t.Errorf("expected only the new row, got %d", rows)
+	}
+}
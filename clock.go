@@ -0,0 +1,101 @@
+package thunder
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// Clock tells the database what time it is. It drives TTL expiry and default
+// timestamps; tests can install a ManualClock to control time explicitly.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the default clock, backed by time.Now.
+var SystemClock Clock = systemClock{}
+
+// ManualClock is a Clock that only moves when told to. It is safe for
+// concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock stopped at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetClock replaces the clock used by the database. A nil clock restores
+// SystemClock.
+func (d *DB) SetClock(c Clock) {
+	if c == nil {
+		c = SystemClock
+	}
+	d.clock = c
+}
+
+// Now returns the current time according to the database clock.
+func (d *DB) Now() time.Time {
+	return d.clock.Now()
+}
+
+// Now returns the current time according to the database clock.
+func (tx *Tx) Now() time.Time {
+	if tx.db == nil {
+		return SystemClock.Now()
+	}
+	return tx.db.Now()
+}
+
+// applyDefaults fills columns declared with DefaultNow that obj leaves unset
+// with the current time in Unix nanoseconds (a time.Time for TypeTime
+// columns), and an unset version column with 1. obj itself is not modified.
+func (pr *Persistent) applyDefaults(obj map[string]any) map[string]any {
+	filled, cloned := obj, false
+	for col, spec := range pr.fields {
+		if !spec.DefaultNow && !spec.Version {
+			continue
+		}
+		if _, ok := obj[col]; ok {
+			continue
+		}
+		if !cloned {
+			filled, cloned = make(map[string]any, len(obj)+1), true
+			maps.Copy(filled, obj)
+		}
+		if spec.Version {
+			filled[col] = int64(1)
+		} else if spec.Type == TypeTime {
+			filled[col] = pr.tx.Now()
+		} else {
+			filled[col] = pr.tx.Now().UnixNano()
+		}
+	}
+	return filled
+}
@@ -0,0 +1,423 @@
+// Command thunder inspects and maintains thunder database files.
+//
+// Usage:
+//
+//	thunder [-codec msgpack|json|gob] <file> <command> [arguments]
+//
+// The commands are:
+//
+//	relations                                 list the relations with their sizes
+//	schema <relation>                         show the columns and indexes of a relation
+//	count <relation> [filter...]              count the rows matching the filters
+//	query [-limit n] [-columns a,b] <relation> [filter...]
+//	                                          print the matching rows as NDJSON
+//	export <relation>                         print every row as NDJSON
+//	import [-batch n] <relation> [file]       insert NDJSON rows from file or stdin
+//	compact [-swap] <dst>                     copy the database into a compacted file
+//	sql <query> [arg...]                      run a query and print its rows as NDJSON
+//	repl                                      read queries from stdin, one per line
+//
+// Queries use the SQL subset of DB.Query, for example
+//
+//	SELECT name, age FROM users WHERE age >= 30 LIMIT 10
+//
+// A filter has the form column<op>value where op is one of =, !=, <, <=, >
+// or >=. Values are converted to the declared type of the column; values of
+// untyped columns are read as integers, floats or booleans when they parse as
+// such and as strings otherwise. Quote a value to force a string.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/longlodw/thunder"
+	"github.com/openkvlab/boltdb"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "thunder:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: thunder [-codec msgpack|json|gob] <file> <relations|schema|count|query|export|import|compact|sql|repl> [arguments]")
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("thunder", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	codec := fs.String("codec", "msgpack", "row codec the database was written with: msgpack, json or gob")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errUsage
+	}
+	maUn, err := codecByName(*codec)
+	if err != nil {
+		return err
+	}
+	path, cmd, cmdArgs := fs.Arg(0), fs.Arg(1), fs.Args()[2:]
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	var db *thunder.DB
+	switch cmd {
+	case "relations", "schema", "count", "query", "export":
+		db, err = thunder.OpenReadOnly(maUn, path)
+	default:
+		db, err = thunder.OpenDB(maUn, path, 0600, &boltdb.Options{Timeout: time.Second})
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch cmd {
+	case "relations":
+		return listRelations(db, stdout)
+	case "schema":
+		if len(cmdArgs) != 1 {
+			return fmt.Errorf("usage: schema <relation>")
+		}
+		return showSchema(db, cmdArgs[0], stdout)
+	case "count":
+		if len(cmdArgs) < 1 {
+			return fmt.Errorf("usage: count <relation> [filter...]")
+		}
+		return count(db, cmdArgs[0], cmdArgs[1:], stdout)
+	case "query":
+		return query(db, cmdArgs, stdout)
+	case "export":
+		if len(cmdArgs) != 1 {
+			return fmt.Errorf("usage: export <relation>")
+		}
+		return view(db, cmdArgs[0], func(p *thunder.Persistent) error {
+			return p.Export(stdout)
+		})
+	case "import":
+		return importRows(db, cmdArgs, stdin, stdout)
+	case "compact":
+		return compact(db, cmdArgs)
+	case "sql":
+		if len(cmdArgs) < 1 {
+			return fmt.Errorf("usage: sql <query> [arg...]")
+		}
+		args := make([]any, 0, len(cmdArgs)-1)
+		for _, arg := range cmdArgs[1:] {
+			v, err := parseValue(arg, thunder.TypeAny)
+			if err != nil {
+				return err
+			}
+			args = append(args, v)
+		}
+		return runQuery(db, cmdArgs[0], args, stdout)
+	case "repl":
+		return repl(db, stdin, stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n%v", cmd, errUsage)
+	}
+}
+
+func codecByName(name string) (thunder.MarshalUnmarshaler, error) {
+	switch name {
+	case "msgpack":
+		return &thunder.MsgpackMaUn, nil
+	case "json":
+		return &thunder.JsonMaUn, nil
+	case "gob":
+		return &thunder.GobMaUn, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+// view runs fn on relation in a read-only transaction.
+func view(db *thunder.DB, relation string, fn func(*thunder.Persistent) error) error {
+	tx, err := db.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(relation)
+	if err != nil {
+		return err
+	}
+	return fn(p)
+}
+
+func listRelations(db *thunder.DB, stdout io.Writer) error {
+	infos, err := db.Catalog()
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(infos, func(a, b thunder.RelationInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "RELATION\tROWS\tBYTES")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", info.Name, info.Rows, info.Bytes)
+	}
+	return w.Flush()
+}
+
+func showSchema(db *thunder.DB, relation string, stdout io.Writer) error {
+	return view(db, relation, func(p *thunder.Persistent) error {
+		specs := p.ColumnSpecs()
+		indexes, uniques := p.Indexes(), p.Uniques()
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "COLUMN\tTYPE\tATTRIBUTES")
+		var composite []string
+		for _, name := range slices.Sorted(maps.Keys(specs)) {
+			spec := specs[name]
+			if len(spec.ReferenceCols) > 0 {
+				composite = append(composite, name)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, spec.Type, strings.Join(columnAttributes(name, spec, indexes, uniques), " "))
+		}
+		if len(composite) > 0 {
+			fmt.Fprintln(w, "\nINDEX\tCOLUMNS\tATTRIBUTES")
+			for _, name := range composite {
+				spec := specs[name]
+				fmt.Fprintf(w, "%s\t%s\t%s\n", name, strings.Join(spec.ReferenceCols, ","), strings.Join(columnAttributes(name, spec, indexes, uniques), " "))
+			}
+		}
+		return w.Flush()
+	})
+}
+
+func columnAttributes(name string, spec thunder.ColumnSpec, indexes, uniques []string) []string {
+	var attrs []string
+	switch {
+	case slices.Contains(uniques, name):
+		attrs = append(attrs, "unique")
+	case slices.Contains(indexes, name):
+		attrs = append(attrs, "indexed")
+	}
+	if spec.Version {
+		attrs = append(attrs, "version")
+	}
+	if spec.DefaultNow {
+		attrs = append(attrs, "default-now")
+	}
+	if spec.EncryptionKey != "" {
+		attrs = append(attrs, "encrypted:"+spec.EncryptionKey)
+	}
+	return attrs
+}
+
+func count(db *thunder.DB, relation string, filters []string, stdout io.Writer) error {
+	return view(db, relation, func(p *thunder.Persistent) error {
+		n := 0
+		if len(filters) == 0 {
+			var err error
+			if n, err = p.Count(); err != nil {
+				return err
+			}
+		} else {
+			seq, err := selectFiltered(p, filters)
+			if err != nil {
+				return err
+			}
+			for _, err := range seq {
+				if err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		_, err := fmt.Fprintln(stdout, n)
+		return err
+	})
+}
+
+func query(db *thunder.DB, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	limit := fs.Int("limit", 0, "maximum number of rows to print; 0 prints all")
+	columns := fs.String("columns", "", "comma-separated columns to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: query [-limit n] [-columns a,b] <relation> [filter...]")
+	}
+	var opts []thunder.QueryOption
+	if *columns != "" {
+		opts = append(opts, thunder.SelectColumns(strings.Split(*columns, ",")...))
+	}
+	return view(db, fs.Arg(0), func(p *thunder.Persistent) error {
+		seq, err := selectFiltered(p, fs.Args()[1:], opts...)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(stdout)
+		n := 0
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+			if n++; *limit > 0 && n >= *limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func selectFiltered(p *thunder.Persistent, filters []string, opts ...thunder.QueryOption) (iter.Seq2[map[string]any, error], error) {
+	specs := p.ColumnSpecs()
+	ops := make([]thunder.Op, 0, len(filters))
+	for _, filter := range filters {
+		op, err := parseFilter(filter, specs)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	ranges, err := thunder.ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	return p.SelectWith(ranges, opts...)
+}
+
+var filterOps = []struct {
+	token string
+	op    func(string, ...any) thunder.Op
+}{
+	// Two-character operators come first so that "<=" is not read as "<".
+	{"!=", thunder.Ne},
+	{"<=", thunder.Le},
+	{">=", thunder.Ge},
+	{"=", thunder.Eq},
+	{"<", thunder.Lt},
+	{">", thunder.Gt},
+}
+
+// parseFilter parses a column<op>value filter.
+func parseFilter(filter string, specs map[string]thunder.ColumnSpec) (thunder.Op, error) {
+	at := strings.IndexAny(filter, "=!<>")
+	if at <= 0 {
+		return thunder.Op{}, fmt.Errorf("invalid filter %q: expected column<op>value", filter)
+	}
+	column, rest := filter[:at], filter[at:]
+	for _, fo := range filterOps {
+		if !strings.HasPrefix(rest, fo.token) {
+			continue
+		}
+		spec, ok := specs[column]
+		if !ok {
+			return thunder.Op{}, fmt.Errorf("invalid filter %q: unknown column %q", filter, column)
+		}
+		value, err := parseValue(strings.TrimPrefix(rest, fo.token), spec.Type)
+		if err != nil {
+			return thunder.Op{}, fmt.Errorf("invalid filter %q: %w", filter, err)
+		}
+		return fo.op(column, value), nil
+	}
+	return thunder.Op{}, fmt.Errorf("invalid filter %q: unknown operator", filter)
+}
+
+// parseValue converts s to a value of type t.
+func parseValue(s string, t thunder.ColumnType) (any, error) {
+	if unquoted, err := strconv.Unquote(s); err == nil && len(s) > 0 && s[0] == '"' {
+		if t != thunder.TypeAny && t != thunder.TypeString {
+			return nil, fmt.Errorf("%s is not a %s", s, t)
+		}
+		return unquoted, nil
+	}
+	return t.Parse(s)
+}
+
+func runQuery(db *thunder.DB, query string, args []any, stdout io.Writer) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repl runs the queries read from stdin, one per line, until EOF or "exit".
+// Errors are printed and do not end the session.
+func repl(db *thunder.DB, stdin io.Reader, stdout io.Writer) error {
+	sc := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "thunder> ")
+		if !sc.Scan() {
+			fmt.Fprintln(stdout)
+			return sc.Err()
+		}
+		line := strings.TrimSpace(sc.Text())
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		if err := runQuery(db, line, nil, stdout); err != nil {
+			fmt.Fprintln(stdout, "error:", err)
+		}
+	}
+}
+
+func importRows(db *thunder.DB, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	batch := fs.Int("batch", 1024, "rows committed per transaction")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("usage: import [-batch n] <relation> [file]")
+	}
+	r := stdin
+	if fs.NArg() == 2 {
+		f, err := os.Open(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := db.Import(fs.Arg(0), r, *batch)
+	fmt.Fprintf(stdout, "imported %d rows\n", n)
+	return err
+}
+
+func compact(db *thunder.DB, args []string) error {
+	fs := flag.NewFlagSet("compact", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	swap := fs.Bool("swap", false, "replace the database file with the compacted copy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: compact [-swap] <dst>")
+	}
+	return db.Compact(fs.Arg(0), *swap)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/longlodw/thunder"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{
+		"email": {Unique: true, Type: thunder.TypeString},
+		"age":   {Indexed: true, Type: thunder.TypeInt},
+		"name":  {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	thunderCmd := func(stdin string, args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := run(append([]string{path}, args...), strings.NewReader(stdin), &out); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+	rows := `{"email":"ada@example.com","age":36,"name":"ada"}
+{"email":"bob@example.com","age":41,"name":"bob"}
+{"email":"eve@example.com","age":29,"name":"eve"}
+`
+	if out := thunderCmd(rows, "import", "users"); out != "imported 3 rows\n" {
+		t.Fatalf("unexpected import output %q", out)
+	}
+	if out := thunderCmd("", "relations"); !strings.Contains(out, "users") {
+		t.Fatalf("expected users in %q", out)
+	}
+	out := thunderCmd("", "schema", "users")
+	for _, want := range []string{"email", "unique", "age", "indexed", "int"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in schema %q", want, out)
+		}
+	}
+	if out := thunderCmd("", "count", "users"); out != "3\n" {
+		t.Fatalf("unexpected count %q", out)
+	}
+	if out := thunderCmd("", "count", "users", "age>=30"); out != "2\n" {
+		t.Fatalf("unexpected filtered count %q", out)
+	}
+	if out := thunderCmd("", "query", "-columns", "name", "users", "email=bob@example.com"); out != "{\"name\":\"bob\"}\n" {
+		t.Fatalf("unexpected query output %q", out)
+	}
+	if out := thunderCmd("", "query", "-limit", "1", "users", `name!="ada"`); strings.Count(out, "\n") != 1 {
+		t.Fatalf("expected one row, got %q", out)
+	}
+	if out := thunderCmd("", "export", "users"); strings.Count(out, "\n") != 3 {
+		t.Fatalf("expected three exported rows, got %q", out)
+	}
+	if out := thunderCmd("", "sql", "SELECT name FROM users WHERE age < ?", "30"); out != "{\"name\":\"eve\"}\n" {
+		t.Fatalf("unexpected sql output %q", out)
+	}
+	out = thunderCmd("SELECT name FROM users WHERE name = 'ada'\nSELECT * FROM nowhere\nexit\n", "repl")
+	if !strings.Contains(out, `{"name":"ada"}`) || !strings.Contains(out, "error:") {
+		t.Fatalf("unexpected repl output %q", out)
+	}
+	thunderCmd("", "compact", "-swap", filepath.Join(filepath.Dir(path), "compact.db"))
+	if out := thunderCmd("", "count", "users"); out != "3\n" {
+		t.Fatalf("unexpected count after compaction %q", out)
+	}
+
+	if err := run([]string{path, "query", "users", "age~3"}, nil, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an invalid filter to fail")
+	}
+}
+
+func TestRunReadsShareTheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{"name": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := thunder.OpenReadOnly(&thunder.MsgpackMaUn, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var out bytes.Buffer
+	if err := run([]string{path, "relations"}, strings.NewReader(""), &out); err != nil || !strings.Contains(out.String(), "users") {
+		t.Fatalf("expected relations listed next to another reader, got %q, %v", out.String(), err)
+	}
+}
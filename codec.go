@@ -0,0 +1,121 @@
+package thunder
+
+import (
+	"encoding/base64"
+	"maps"
+)
+
+// SetCodec registers the codec that columns with Codec set to name are stored
+// with, replacing an earlier one. A nil maUn removes it. A codec decodes a
+// column value by unmarshaling into a *any.
+func (d *DB) SetCodec(name string, maUn MarshalUnmarshaler) {
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	if maUn == nil {
+		delete(d.codecs, name)
+		return
+	}
+	if d.codecs == nil {
+		d.codecs = make(map[string]MarshalUnmarshaler)
+	}
+	d.codecs[name] = maUn
+}
+
+func (pr *Persistent) codec(column string) (MarshalUnmarshaler, error) {
+	name := pr.fields[column].Codec
+	var maUn MarshalUnmarshaler
+	if pr.tx.db != nil {
+		pr.tx.db.hooksMu.RLock()
+		maUn = pr.tx.db.codecs[name]
+		pr.tx.db.hooksMu.RUnlock()
+	}
+	if maUn == nil {
+		return nil, ErrCodecNotFound(column, name)
+	}
+	return maUn, nil
+}
+
+// encodeRow returns obj with the values of its columns with a codec replaced
+// by their encoding. obj itself is not modified.
+func (pr *Persistent) encodeRow(obj map[string]any) (map[string]any, error) {
+	if !pr.encoded {
+		return obj, nil
+	}
+	stored := maps.Clone(obj)
+	for _, col := range pr.columns {
+		v, ok := obj[col]
+		if pr.fields[col].Codec == "" || !ok || v == nil {
+			continue
+		}
+		maUn, err := pr.codec(col)
+		if err != nil {
+			return nil, err
+		}
+		if stored[col], err = maUn.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// decodeRow decodes the columns with a codec of a stored row in place.
+func (pr *Persistent) decodeRow(value map[string]any) error {
+	if !pr.encoded {
+		return nil
+	}
+	for _, col := range pr.columns {
+		if pr.fields[col].Codec == "" {
+			continue
+		}
+		var data []byte
+		switch v := value[col].(type) {
+		case []byte:
+			data = v
+		case string:
+			// Codecs such as JSON keep bytes as base64 text.
+			if v == Redacted {
+				continue
+			}
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return err
+			}
+			data = b
+		default:
+			continue
+		}
+		maUn, err := pr.codec(col)
+		if err != nil {
+			return err
+		}
+		var decoded any
+		if err := maUn.Unmarshal(data, &decoded); err != nil {
+			return err
+		}
+		value[col] = decoded
+	}
+	return nil
+}
+
+func hasCodecColumns(specs map[string]ColumnSpec) bool {
+	for _, spec := range specs {
+		if spec.Codec != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEncodedRanges rejects filters on columns with a codec, whose stored
+// bytes do not order like their values.
+func (pr *Persistent) checkEncodedRanges(ranges map[string]*keyRange) error {
+	if !pr.encoded {
+		return nil
+	}
+	for name := range ranges {
+		if pr.fields[name].Codec != "" {
+			return ErrColumnEncoded(name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package thunder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPersistent_ColumnCodec(t *testing.T) {
+	for _, rowCodec := range []MarshalUnmarshaler{&MsgpackMaUn, &JsonMaUn, &CborMaUn} {
+		db, err := OpenMemory(rowCodec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		db.SetCodec("json", &JsonMaUn)
+		err = db.update(func(tx *Tx) error {
+			var te *ThunderError
+			if _, err := tx.CreatePersistent("bad", map[string]ColumnSpec{
+				"payload": {Codec: "json", Indexed: true},
+			}); !errors.As(err, &te) || te.Code != ErrCodeColumnEncoded {
+				t.Errorf("expected ErrColumnEncoded for an indexed codec column, got %v", err)
+			}
+			events, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+				"id":      {Unique: true},
+				"payload": {Codec: "json"},
+			})
+			if err != nil {
+				return err
+			}
+			return events.Insert(map[string]any{"id": "e1", "payload": map[string]any{"n": 1, "tags": []string{"a"}}})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.view(func(tx *Tx) error {
+			events, err := tx.LoadPersistent("events")
+			if err != nil {
+				return err
+			}
+			ranges, err := ToKeyRanges(Eq("id", "e1"))
+			if err != nil {
+				return err
+			}
+			seq, err := events.Select(ranges)
+			if err != nil {
+				return err
+			}
+			for row, err := range seq {
+				if err != nil {
+					return err
+				}
+				payload, ok := row["payload"].(map[string]any)
+				if !ok {
+					t.Fatalf("expected the payload decoded as a map, got %T", row["payload"])
+				}
+				// The JSON column codec decodes numbers as float64 whatever
+				// the row codec.
+				if n, ok := payload["n"].(float64); !ok || n != 1 || fmt.Sprint(payload["tags"]) != "[a]" {
+					t.Errorf("unexpected payload %v", payload)
+				}
+			}
+			ranges, err = ToKeyRanges(Eq("payload", "x"))
+			if err != nil {
+				return err
+			}
+			var te *ThunderError
+			if _, err := events.Select(ranges); !errors.As(err, &te) || te.Code != ErrCodeColumnEncoded {
+				t.Errorf("expected filtering on a codec column to fail, got %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCborMaUn(t *testing.T) {
+	row := map[string]any{
+		"n":      int64(-3),
+		"big":    uint64(1 << 63),
+		"nested": map[string]any{"k": []any{uint64(1), "two"}},
+		"raw":    []byte{0, 1},
+		"at":     time.Date(2024, 1, 1, 0, 0, 0, 5, time.UTC),
+	}
+	data, err := CborMaUn.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := CborMaUn.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, row) {
+		t.Fatalf("expected %#v, got %#v", row, got)
+	}
+}
@@ -0,0 +1,125 @@
+package thunder
+
+import (
+	"maps"
+	"math"
+)
+
+// SetNumericCoercion sets whether Op values on TypeInt and TypeFloat columns
+// are converted to the column's kind of number before comparing, so that
+// Eq("qty", 5.0) finds the row holding 5 and Gt("price", 3) works on a float
+// column. A fractional bound on an integer column is rounded towards the
+// inside of the range. Coercion is off by default.
+func (d *DB) SetNumericCoercion(on bool) {
+	d.coerce = on
+}
+
+// coerceRanges returns ranges with the string bounds on normalized columns
+// normalized and, when the database coerces numbers, the numeric bounds on
+// typed columns converted to the column's type. ranges itself is not
+// modified.
+func (pr *Persistent) coerceRanges(ranges map[string]*keyRange) map[string]*keyRange {
+	numbers := pr.tx.db != nil && pr.tx.db.coerce
+	coerced, cloned := ranges, false
+	for name, kr := range ranges {
+		spec, ok := pr.fields[name]
+		if !ok || len(spec.ReferenceCols) != 0 {
+			continue
+		}
+		c, changed := kr, false
+		if numbers && (spec.Type == TypeInt || spec.Type == TypeFloat) {
+			c, changed = c.coerceNumbers(spec.Type)
+		}
+		if spec.Normalize != NormNone {
+			var normalized bool
+			c, normalized = c.normalizeStrings(spec.Normalize)
+			changed = changed || normalized
+		}
+		if !changed {
+			continue
+		}
+		if !cloned {
+			coerced, cloned = maps.Clone(ranges), true
+		}
+		coerced[name] = c
+	}
+	return coerced
+}
+
+// coerceNumbers returns a copy of kr with its bounds and excludes converted
+// to numbers of type t, and whether anything changed.
+func (kr *keyRange) coerceNumbers(t ColumnType) (*keyRange, bool) {
+	c := *kr
+	var changed, ok bool
+	if kr.startKey != nil {
+		c.startKey, c.includeStart, ok = numberBound(kr.startKey, kr.includeStart, t, math.Ceil)
+		changed = changed || ok
+	}
+	if kr.endKey != nil {
+		c.endKey, c.includeEnd, ok = numberBound(kr.endKey, kr.includeEnd, t, math.Floor)
+		changed = changed || ok
+	}
+	c.excludes = make([][]byte, 0, len(kr.excludes))
+	for _, key := range kr.excludes {
+		excl, fractional, ok := numberBound(key, false, t, math.Trunc)
+		changed = changed || ok
+		// An integer column never holds a fractional value, so there is
+		// nothing to exclude.
+		if !fractional {
+			c.excludes = append(c.excludes, excl)
+		}
+	}
+	if !changed {
+		return kr, false
+	}
+	c.distance = c.computeDistance()
+	return &c, true
+}
+
+// numberBound converts the single number key encodes to type t. It returns
+// the new key and whether it is included; a fractional value converted to an
+// integer is rounded with round and then included. ok is false when key was
+// left as is.
+func numberBound(key []byte, include bool, t ColumnType, round func(float64) float64) ([]byte, bool, bool) {
+	var values []any
+	if err := orderedMa.Unmarshal(key, &values); err != nil || len(values) != 1 {
+		return key, include, false
+	}
+	var v any
+	switch n := values[0].(type) {
+	case float32:
+		v, include = floatAs(float64(n), include, t, round)
+	case float64:
+		if t == TypeFloat {
+			return key, include, false
+		}
+		v, include = floatAs(n, include, t, round)
+	case int64:
+		if t == TypeInt {
+			return key, include, false
+		}
+		v = float64(n)
+	case uint64:
+		if t == TypeInt {
+			return key, include, false
+		}
+		v = float64(n)
+	default:
+		return key, include, false
+	}
+	coerced, err := ToKey(v)
+	if err != nil {
+		return key, include, false
+	}
+	return coerced, include, true
+}
+
+func floatAs(f float64, include bool, t ColumnType, round func(float64) float64) (any, bool) {
+	if t == TypeFloat {
+		return f, include
+	}
+	if r := round(f); r != f {
+		return int64(r), true
+	}
+	return int64(f), include
+}
@@ -0,0 +1,88 @@
+package thunder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPersistent_NumericCoercion(t *testing.T) {
+	db, err := OpenMemory(&JsonMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	items, err := tx.CreatePersistent("items", map[string]ColumnSpec{
+		"id":    {Unique: true},
+		"qty":   {Indexed: true, Type: TypeInt},
+		"price": {Type: TypeFloat},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"id": "a", "qty": 2, "price": 1.5},
+		{"id": "b", "qty": int64(3), "price": 3.0},
+		{"id": "c", "qty": 5.0, "price": 4.25},
+	} {
+		if err := items.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(ops ...Op) []string {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := items.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, row["id"].(string))
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	if got := ids(Eq("qty", 5.0)); len(got) != 0 {
+		t.Fatalf("expected no match without coercion, got %v", got)
+	}
+	db.SetNumericCoercion(true)
+	for _, tc := range []struct {
+		ops  []Op
+		want []string
+	}{
+		{[]Op{Eq("qty", 5.0)}, []string{"c"}},
+		{[]Op{Gt("qty", 2.5)}, []string{"b", "c"}},
+		{[]Op{Le("qty", 2.9)}, []string{"a"}},
+		{[]Op{Eq("qty", 2.5)}, nil},
+		{[]Op{Ne("qty", 2.5)}, []string{"a", "b", "c"}},
+		{[]Op{Gt("price", 3)}, []string{"c"}},
+		{[]Op{Eq("price", 3)}, []string{"b"}},
+	} {
+		if got := ids(tc.ops...); !slices.Equal(got, tc.want) {
+			t.Fatalf("%v: expected %v, got %v", tc.ops, tc.want, got)
+		}
+	}
+
+	ranges, err := ToKeyRanges(Eq("qty", 3.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := items.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(Ge("qty", 0)); !slices.Equal(got, []string{"a", "c"}) {
+		t.Fatalf("expected [a c] after delete, got %v", got)
+	}
+}
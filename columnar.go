@@ -0,0 +1,157 @@
+package thunder
+
+import (
+	"iter"
+	"reflect"
+)
+
+func columnarNames(specs map[string]ColumnSpec) []string {
+	var names []string
+	for name, spec := range specs {
+		if spec.Columnar && len(spec.ReferenceCols) == 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// createColumns creates the bucket of every columnar column under parent.
+func (d *dataStorage) createColumns(parent BackendBucket) error {
+	if len(d.columnar) == 0 {
+		return nil
+	}
+	columns, err := parent.CreateBucketIfNotExists([]byte("columns"))
+	if err != nil {
+		return err
+	}
+	for _, col := range d.columnar {
+		if _, err := columns.CreateBucketIfNotExists([]byte(col)); err != nil {
+			return err
+		}
+	}
+	d.columns = columns
+	return nil
+}
+
+// resetColumns empties the buckets of the columnar columns.
+func (d *dataStorage) resetColumns(parent BackendBucket) error {
+	if parent.Bucket([]byte("columns")) != nil {
+		if err := parent.DeleteBucket([]byte("columns")); err != nil {
+			return err
+		}
+	}
+	return d.createColumns(parent)
+}
+
+// putColumns stores the values of the columnar columns of the row stored
+// under id, each as a row of one column encoded with maUn.
+func (d *dataStorage) putColumns(id []byte, value map[string]any, maUn MarshalUnmarshaler) error {
+	for _, col := range d.columnar {
+		raw, err := maUn.Marshal(map[string]any{col: value[col]})
+		if err != nil {
+			return err
+		}
+		if err := d.columns.Bucket([]byte(col)).Put(id, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildColumns refills the buckets of the columnar columns from the rows.
+func (pr *Persistent) rebuildColumns() error {
+	if err := pr.data.resetColumns(pr.bucket); err != nil || len(pr.data.columnar) == 0 {
+		return err
+	}
+	return pr.data.bucket.ForEach(func(k, v []byte) error {
+		var value map[string]any
+		if err := pr.data.unmarshal(k, v, &value); err != nil {
+			return err
+		}
+		return pr.data.putColumns(k, value, pr.data.codec(k))
+	})
+}
+
+// ScanColumn returns the values of column in the rows matching every op, in
+// insertion order. Without ops, a Columnar column is read from its own
+// bucket, leaving the rest of each row undecoded; otherwise, or under a row
+// policy, the matching rows are selected as usual.
+func (pr *Persistent) ScanColumn(column string, ops ...Op) (iter.Seq2[any, error], error) {
+	spec, ok := pr.fields[column]
+	if !ok || len(spec.ReferenceCols) > 0 {
+		return nil, ErrFieldNotFound(column)
+	}
+	if !spec.Columnar || len(ops) > 0 || (pr.tx.db != nil && pr.tx.db.policy(pr.access) != nil) {
+		seq, err := pr.SelectColumns([]string{column}, ops...)
+		if err != nil {
+			return nil, err
+		}
+		return func(yield func(any, error) bool) {
+			for row, err := range seq {
+				if !yield(row[column], err) {
+					return
+				}
+			}
+		}, nil
+	}
+	masked := pr.maskedFor()
+	columns := []string{column}
+	return func(yield func(any, error) bool) {
+		c := pr.data.columns.Bucket([]byte(column)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var value map[string]any
+			if err := pr.data.unmarshal(k, v, &value); err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if err := pr.readRow(value, masked, columns); err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if !yield(value[column], nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// SumOf returns the sum of the numeric values of column in the rows matching
+// every op, skipping nil values. It reads the column as ScanColumn does.
+func (pr *Persistent) SumOf(column string, ops ...Op) (float64, error) {
+	seq, err := pr.ScanColumn(column, ops...)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for v, err := range seq {
+		if err != nil {
+			return 0, err
+		}
+		if v == nil {
+			continue
+		}
+		f, ok := floatValue(v)
+		if !ok {
+			return 0, ErrTypeMismatch(column, TypeFloat, v)
+		}
+		sum += f
+	}
+	return sum, nil
+}
+
+func floatValue(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), true
+	case rv.CanUint():
+		return float64(rv.Uint()), true
+	case rv.CanFloat():
+		return rv.Float(), true
+	}
+	return 0, false
+}
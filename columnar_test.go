@@ -0,0 +1,93 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPersistent_Columnar(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var te *ThunderError
+	if _, err := tx.CreatePersistent("secret", map[string]ColumnSpec{
+		"x": {Columnar: true, EncryptionKey: "k"},
+	}); !errors.As(err, &te) || te.Code != ErrCodeColumnEncrypted {
+		t.Fatalf("expected an encrypted columnar column to be rejected, got %v", err)
+	}
+	sales, err := tx.CreatePersistent("sales", map[string]ColumnSpec{
+		"region": {Indexed: true},
+		"amount": {Type: TypeInt, Columnar: true},
+		"note":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, region := range []string{"eu", "us", "eu", "us"} {
+		if err := sales.Insert(map[string]any{"region": region, "amount": int64(10 * (i + 1)), "note": "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := ToKeyRanges(Eq("region", "us"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// us rows hold 20 and 40; 40 is deleted and 20 raised to 25.
+	if _, err := sales.Update(ranges, map[string]any{"amount": int64(25)}); err != nil {
+		t.Fatal(err)
+	}
+	high, err := ToKeyRanges(Eq("amount", int64(25)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sales.Delete(high); err != nil {
+		t.Fatal(err)
+	}
+	if err := sales.Insert(map[string]any{"region": "us", "amount": int64(5), "note": "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	seq, err := sales.ScanColumn("amount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var amounts []int64
+	for v, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		amounts = append(amounts, v.(int64))
+	}
+	if len(amounts) != 3 || amounts[0] != 10 || amounts[1] != 30 || amounts[2] != 5 {
+		t.Fatalf("expected amounts [10 30 5], got %v", amounts)
+	}
+	sum, err := sales.SumOf("amount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 45 {
+		t.Errorf("expected sum 45, got %v", sum)
+	}
+	sum, err = sales.SumOf("amount", Eq("region", "eu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 40 {
+		t.Errorf("expected sum 40 for eu, got %v", sum)
+	}
+	if _, err := sales.SumOf("note"); !errors.As(err, &te) || te.Code != ErrCodeTypeMismatch {
+		t.Errorf("expected a type mismatch summing text, got %v", err)
+	}
+
+	if err := sales.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := sales.SumOf("amount"); err != nil || sum != 0 {
+		t.Errorf("expected an empty column after truncate, got %v, %v", sum, err)
+	}
+}
@@ -1,7 +1,248 @@
 package thunder
 
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
 type ColumnSpec struct {
 	ReferenceCols []string
 	Unique        bool
 	Indexed       bool
+	Type          ColumnType
+	// DefaultNow fills the column with the current time, in Unix
+	// nanoseconds or as a time.Time for TypeTime, when an inserted row
+	// leaves it unset.
+	DefaultNow bool
+	// EncryptionKey names the key the column's values are encrypted with.
+	// Encrypted columns cannot be indexed or filtered on.
+	EncryptionKey string
+	// Version marks the column holding the row version for optimistic
+	// concurrency. Insert sets it to 1 when unset; Update requires the
+	// version the caller read among its changes, fails with
+	// ErrVersionConflict if the row has moved on and increments it. A
+	// relation has at most one version column.
+	Version bool
+	// Generated names the Generator, registered with DB.SetGenerator, that
+	// computes the column from the rest of the row on every insert and
+	// update. The value is stored, so the column can be indexed.
+	Generated string
+	// MultiEntry makes the index of a slice-valued column hold one entry per
+	// element, so that Contains and ranges over the elements are index
+	// lookups. Ranges on a MultiEntry column match rows with an element in
+	// range.
+	MultiEntry bool
+	// Normalize puts string values in a Unicode normalization form before
+	// they are indexed or compared, for Eq and unique constraints alike.
+	// Stored rows keep the text as written.
+	Normalize Normalization
+	// Codec names the MarshalUnmarshaler, registered with DB.SetCodec, the
+	// column's values are stored with, as bytes within the row encoded by
+	// the relation's codec. Such columns cannot be indexed or filtered on.
+	Codec string
+	// Columnar also stores the column's values in a bucket of their own,
+	// keyed by row id, so that ScanColumn and SumOf read the column without
+	// decoding whole rows. The values stay in the rows as well, so every row
+	// write puts each columnar value twice and the column takes about twice
+	// its space; it pays off for columns scanned far more often than written.
+	// Encrypted columns and columns with a Codec cannot be columnar.
+	Columnar bool
+	// AsyncIndex defers the index entries of rows written to the column's
+	// index to ApplyIndexQueue, queueing the rows instead, for write-heavy
+	// relations. Reads through the index still find queued rows by checking
+	// them one by one, unless StaleIndex is given. Unique indexes cannot be
+	// asynchronous.
+	AsyncIndex bool
+}
+
+// ColumnType declares the kind of values a column is expected to hold.
+// TypeAny disables type checking for the column.
+type ColumnType uint8
+
+const (
+	TypeAny ColumnType = iota
+	TypeString
+	TypeInt
+	// TypeFloat holds a float64. Keys order NaN before -Inf and treat -0
+	// and 0 as equal.
+	TypeFloat
+	TypeBool
+	TypeBytes
+	// TypeBlob holds a Blob, whose bytes are stored apart from the row.
+	TypeBlob
+	// TypeTime holds a time.Time, keyed by its UTC nanosecond instant.
+	TypeTime
+	// TypeBigInt holds a *big.Int and TypeDecimal a *big.Rat with a finite
+	// decimal expansion. Both are stored as decimal text, so values keep
+	// their precision under every codec, and are keyed in numeric order.
+	TypeBigInt
+	TypeDecimal
+)
+
+func (t ColumnType) String() string {
+	switch t {
+	case TypeAny:
+		return "any"
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeBytes:
+		return "bytes"
+	case TypeBlob:
+		return "blob"
+	case TypeTime:
+		return "time"
+	case TypeBigInt:
+		return "bigint"
+	case TypeDecimal:
+		return "decimal"
+	default:
+		if ct := t.custom(); ct != nil {
+			return ct.name
+		}
+		return "unknown"
+	}
+}
+
+// accepts reports whether v is a valid value for a column of type t.
+// Integral floats are accepted for TypeInt because codecs such as JSON
+// decode every number as float64.
+func (t ColumnType) accepts(v any) bool {
+	switch t {
+	case TypeAny:
+		return true
+	case TypeString:
+		_, ok := v.(string)
+		return ok
+	case TypeInt:
+		switch n := v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		case float32:
+			return n == float32(int64(n))
+		}
+		return false
+	case TypeFloat:
+		switch v.(type) {
+		case float32, float64:
+			return true
+		}
+		return false
+	case TypeBool:
+		_, ok := v.(bool)
+		return ok
+	case TypeBytes:
+		_, ok := v.([]byte)
+		return ok
+	case TypeBlob:
+		_, ok := blobID(v)
+		return ok
+	case TypeTime:
+		_, ok := timeValue(v)
+		return ok
+	case TypeBigInt:
+		x, ok := decimalValue(v)
+		return ok && x.IsInt()
+	case TypeDecimal:
+		x, ok := decimalValue(v)
+		if !ok {
+			return false
+		}
+		_, _, ok = decimalParts(x)
+		return ok
+	default:
+		if ct := t.custom(); ct != nil {
+			_, ok := ct.value(v)
+			return ok
+		}
+		return false
+	}
+}
+
+// Parse converts the text form of a value, such as a command-line argument
+// or URL query parameter, to type t. For TypeAny, s is read as an int64,
+// float64 or bool when it parses as one and kept as a string otherwise.
+func (t ColumnType) Parse(s string) (any, error) {
+	var v any
+	var err error
+	switch t {
+	case TypeString:
+		return s, nil
+	case TypeInt:
+		v, err = strconv.ParseInt(s, 10, 64)
+	case TypeFloat:
+		v, err = strconv.ParseFloat(s, 64)
+	case TypeBool:
+		v, err = strconv.ParseBool(s)
+	case TypeBytes:
+		return []byte(s), nil
+	case TypeBlob:
+		var id uint64
+		id, err = strconv.ParseUint(s, 10, 64)
+		v = Blob(id)
+	case TypeTime:
+		v, err = time.Parse(time.RFC3339Nano, s)
+	case TypeBigInt:
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			err = &strconv.NumError{Func: "Parse", Num: s, Err: strconv.ErrSyntax}
+		}
+		v = n
+	case TypeDecimal:
+		x, ok := new(big.Rat).SetString(s)
+		if !ok || !t.accepts(x) {
+			err = &strconv.NumError{Func: "Parse", Num: s, Err: strconv.ErrSyntax}
+		}
+		v = x
+	default:
+		if ct := t.custom(); ct != nil {
+			x, ok := ct.value(s)
+			if !ok {
+				return nil, fmt.Errorf("cannot parse %q as %s", s, t)
+			}
+			return x, nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// versionNumber converts a stored or caller-supplied row version to int64.
+func versionNumber(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float64:
+		return int64(n), n == float64(int64(n))
+	}
+	return 0, false
 }
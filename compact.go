@@ -0,0 +1,110 @@
+package thunder
+
+import (
+	"errors"
+	"os"
+
+	"github.com/openkvlab/boltdb"
+)
+
+// compactTxSize bounds how many bytes Compact copies per transaction into the
+// destination file.
+const compactTxSize = 1 << 24
+
+// Compact copies every relation, index and internal bucket into a fresh
+// database file at dstPath, leaving behind the free pages that accumulate
+// after heavy deletes. With swap set, the compacted file then atomically
+// replaces the database file and the DB continues on it; dstPath must be on
+// the same filesystem for the rename to be atomic. Swapping waits for open
+// transactions to finish and the DB must not be used concurrently until
+// Compact returns.
+func (d *DB) Compact(dstPath string, swap bool) error {
+	src, ok := d.backend.(*boltBackend)
+	if !ok {
+		return ErrBackendUnsupported("compaction")
+	}
+	if err := d.saveStats(); err != nil {
+		return err
+	}
+	dst, err := boltdb.Open(dstPath, d.mode, d.options)
+	if err != nil {
+		return err
+	}
+	if err := boltdb.Compact(dst, src.db, compactTxSize); err != nil {
+		dst.Close()
+		os.Remove(dstPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if !swap {
+		return nil
+	}
+	path := src.db.Path()
+	if err := errors.Join(d.readPool.drain(), src.db.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(dstPath, path); err != nil {
+		// Keep serving from the original file.
+		return errors.Join(err, d.reopen(path))
+	}
+	return d.reopen(path)
+}
+
+func (d *DB) reopen(path string) error {
+	bdb, err := boltdb.Open(path, d.mode, d.options)
+	if err != nil {
+		return err
+	}
+	// The durability mode is a setting of the handle, not of the file.
+	d.durability.mu.Lock()
+	bdb.NoSync = !d.durability.mode.always()
+	d.durability.mu.Unlock()
+	d.backend = NewBoltBackend(bdb)
+	return nil
+}
+
+// CloneTo writes a copy of the database, as of a consistent snapshot, to a
+// new bolt file at path, which must not exist yet. It works with any backend,
+// so an in-memory database can be saved this way.
+func (d *DB) CloneTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return os.ErrExist
+	}
+	if err := d.saveStats(); err != nil {
+		return err
+	}
+	mode := d.mode
+	if mode == 0 {
+		mode = 0600
+	}
+	bdb, err := boltdb.Open(path, mode, d.options)
+	if err != nil {
+		return err
+	}
+	dst := NewBoltBackend(bdb)
+	err = d.backendView(func(src BackendTx) error {
+		tx, err := dst.Begin(true)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		err = src.ForEach(func(name []byte, b BackendBucket) error {
+			bucket, err := tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			return copyBucket(bucket, b)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err := errors.Join(err, dst.Close()); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package thunder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDB_CompactSwap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.db")
+	db, err := OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("blobs", map[string]ColumnSpec{
+			"n":    {Indexed: true},
+			"body": {},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 2000 {
+			if err := p.Insert(map[string]any{"n": int64(i), "body": strings.Repeat("x", 512)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("blobs")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Ge("n", int64(10)))
+		if err != nil {
+			return err
+		}
+		return p.Delete(ranges)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Compact(filepath.Join(dir, "compact.db"), true); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatalf("expected file to shrink, %d -> %d", before.Size(), after.Size())
+	}
+	ranges, err := ToKeyRanges(Lt("n", int64(5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Select("blobs", ranges, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows after compaction, got %d", len(rows))
+	}
+}
+
+func TestDB_CopyRelationAndCloneTo(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"team": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, team := range []string{"red", "blue", "red"} {
+			if err := users.Insert(map[string]any{"id": i, "team": team}); err != nil {
+				return err
+			}
+		}
+		if err := tx.CopyRelation("users", "staging"); err != nil {
+			return err
+		}
+		if err := tx.CopyRelation("users", "staging"); err == nil {
+			t.Error("expected error copying onto an existing relation")
+		}
+		staging, err := tx.LoadPersistent("staging")
+		if err != nil {
+			return err
+		}
+		// The copy is independent of its source.
+		return staging.Insert(map[string]any{"id": 3, "team": "red"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "clone.db")
+	if err := db.CloneTo(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CloneTo(path); err == nil {
+		t.Fatal("expected error cloning onto an existing file")
+	}
+	clone, err := OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Close()
+	for relation, want := range map[string]int{"users": 2, "staging": 3} {
+		rows, err := clone.Query("SELECT id FROM " + relation + " WHERE team = 'red'")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != want {
+			t.Fatalf("expected %d red rows in %s, got %d", want, relation, len(rows))
+		}
+	}
+	err = clone.view(func(tx *Tx) error {
+		staging, err := tx.LoadPersistent("staging")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("team", "blue"))
+		if err != nil {
+			return err
+		}
+		plan, err := staging.Explain(ranges)
+		if err != nil {
+			return err
+		}
+		if plan.Index != "team" {
+			t.Errorf("expected the copied index to be used, got %v", plan)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,212 @@
+package thunder
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Consistency states how fresh the data seen by a read must be.
+type Consistency struct {
+	maxStaleness time.Duration
+	snapshot     *Snapshot
+}
+
+// ConsistencyLatest reads the latest committed state. It is the default.
+var ConsistencyLatest = Consistency{}
+
+// AllowStale lets a read reuse a read transaction opened up to d ago instead
+// of starting a new one, trading freshness for latency. A write that needs to
+// grow the database file waits for such transactions to expire; setting
+// boltdb.Options.InitialMmapSize generously avoids the wait.
+func AllowStale(d time.Duration) Consistency {
+	return Consistency{maxStaleness: d}
+}
+
+// AtSnapshot reads the state pinned by s.
+func AtSnapshot(s *Snapshot) Consistency {
+	return Consistency{snapshot: s}
+}
+
+// Snapshot pins a consistent view of the database that several reads can
+// share, such as the queries of a long export, while writers keep
+// committing. It holds a read transaction open until Release is called, which
+// keeps the pages it references from being reused and, with boltdb, blocks
+// writes that need to grow the database file.
+type Snapshot struct {
+	mu  sync.Mutex
+	db  *DB
+	tx  *Tx
+	seq uint64
+}
+
+// Snapshot pins the current committed state of the database.
+func (d *DB) Snapshot() (*Snapshot, error) {
+	tx, err := d.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{db: d, tx: tx, seq: tx.CommitSequence()}, nil
+}
+
+// Read runs fn in the snapshot's read-only transaction. Reads through the
+// same snapshot are serialized.
+func (s *Snapshot) Read(fn func(tx *Tx) error) error {
+	return s.read(fn)
+}
+
+// Select returns the rows of relation matching ranges as of the snapshot.
+func (s *Snapshot) Select(relation string, ranges map[string]*keyRange) ([]map[string]any, error) {
+	return s.db.Select(relation, ranges, AtSnapshot(s))
+}
+
+// CommitSequence returns the commit sequence the snapshot observes, which is
+// zero when change tracking is off. An incremental backup taken since it
+// picks up exactly the changes the snapshot does not see.
+func (s *Snapshot) CommitSequence() uint64 {
+	return s.seq
+}
+
+// Release closes the snapshot. Reads through it fail afterwards.
+func (s *Snapshot) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Rollback()
+	s.tx = nil
+	return err
+}
+
+func (s *Snapshot) read(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tx == nil {
+		return ErrSnapshotReleased()
+	}
+	return fn(s.tx)
+}
+
+// pooledTx is an idle read transaction kept for AllowStale reads.
+type pooledTx struct {
+	tx     *Tx
+	opened time.Time
+}
+
+// readPool keeps idle read transactions so that stale-tolerant reads can skip
+// the cost of beginning a new one. Idle transactions are rolled back once they
+// are too old to serve the read that returned them, because bolt cannot grow
+// its memory map while any read transaction is open.
+type readPool struct {
+	mu   sync.Mutex
+	idle []pooledTx
+}
+
+// take returns an idle transaction opened no earlier than maxStaleness before
+// now, discarding older ones.
+func (p *readPool) take(now time.Time, maxStaleness time.Duration) (pooledTx, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for len(p.idle) > 0 {
+		last := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if now.Sub(last.opened) <= maxStaleness {
+			return last, true, errors.Join(errs...)
+		}
+		errs = append(errs, last.tx.Rollback())
+	}
+	return pooledTx{}, false, errors.Join(errs...)
+}
+
+// put returns ptx to the pool and schedules its eviction after ttl.
+func (p *readPool) put(ptx pooledTx, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle = append(p.idle, ptx)
+	time.AfterFunc(ttl, func() { p.evict(ptx.tx) })
+}
+
+func (p *readPool) evict(tx *Tx) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.IndexFunc(p.idle, func(ptx pooledTx) bool { return ptx.tx == tx })
+	if i < 0 {
+		return
+	}
+	p.idle = slices.Delete(p.idle, i, i+1)
+	tx.Rollback()
+}
+
+func (p *readPool) drain() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for _, ptx := range p.idle {
+		errs = append(errs, ptx.tx.Rollback())
+	}
+	p.idle = nil
+	return errors.Join(errs...)
+}
+
+// Read runs fn in a read-only transaction chosen according to c. fn must not
+// keep the transaction, or anything derived from it, after returning.
+func (d *DB) Read(c Consistency, fn func(tx *Tx) error) error {
+	switch {
+	case c.snapshot != nil:
+		return c.snapshot.read(fn)
+	case c.maxStaleness > 0:
+		now := d.Now()
+		ptx, ok, err := d.readPool.take(now, c.maxStaleness)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			tx, err := d.Begin(false)
+			if err != nil {
+				return err
+			}
+			ptx = pooledTx{tx: tx, opened: now}
+		}
+		defer d.readPool.put(ptx, c.maxStaleness-now.Sub(ptx.opened))
+		return fn(ptx.tx)
+	default:
+		return d.view(fn)
+	}
+}
+
+// Select returns the rows of relation matching ranges, read with the given
+// consistency. Reads at ConsistencyLatest are served from the result cache
+// when it is enabled.
+func (d *DB) Select(relation string, ranges map[string]*keyRange, c Consistency) ([]map[string]any, error) {
+	if cache := d.resultCache(); cache != nil && c == ConsistencyLatest {
+		return cache.cachedSelect(relation, ranges, func() ([]map[string]any, error) {
+			return d.selectRows(relation, ranges, c)
+		})
+	}
+	return d.selectRows(relation, ranges, c)
+}
+
+func (d *DB) selectRows(relation string, ranges map[string]*keyRange, c Consistency) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := d.Read(c, func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		seq, err := p.Select(ranges)
+		if err != nil {
+			return err
+		}
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			rows = append(rows, ownedRow(tx.maUn, row))
+		}
+		return nil
+	})
+	return rows, err
+}
@@ -0,0 +1,154 @@
+package thunder
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openkvlab/boltdb"
+)
+
+func TestDB_ReadConsistency(t *testing.T) {
+	// Pinned readers block remapping, so give the file room to grow.
+	db, err := OpenDB(&MsgpackMaUn, filepath.Join(t.TempDir(), "read.db"), 0600, &boltdb.Options{InitialMmapSize: 1 << 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := NewManualClock(time.Unix(0, 0))
+	db.SetClock(clock)
+	insert := func(n int64) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("counters")
+			if err != nil {
+				p, err = tx.CreatePersistent("counters", map[string]ColumnSpec{"n": {}})
+				if err != nil {
+					return err
+				}
+			}
+			return p.Insert(map[string]any{"n": n})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(c Consistency) int {
+		t.Helper()
+		rows, err := db.Select("counters", nil, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	insert(1)
+	snap, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := count(AllowStale(time.Minute)); got != 1 {
+		t.Fatalf("expected 1 row, got %d", got)
+	}
+	insert(2)
+	if got := count(ConsistencyLatest); got != 2 {
+		t.Fatalf("latest: expected 2 rows, got %d", got)
+	}
+	if got := count(AtSnapshot(snap)); got != 1 {
+		t.Fatalf("snapshot: expected 1 row, got %d", got)
+	}
+	if got := count(AllowStale(time.Minute)); got != 1 {
+		t.Fatalf("stale within bound: expected 1 row, got %d", got)
+	}
+	clock.Advance(2 * time.Minute)
+	if got := count(AllowStale(time.Minute)); got != 2 {
+		t.Fatalf("stale past bound: expected 2 rows, got %d", got)
+	}
+	if err := snap.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Select("counters", nil, AtSnapshot(snap)); err == nil {
+		t.Fatal("expected error reading a released snapshot")
+	}
+}
+
+func TestSnapshot_ConsistentAcrossRelations(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	move := func(from, to string, n int64) error {
+		return db.update(func(tx *Tx) error {
+			src, err := tx.LoadPersistent(from)
+			if err != nil {
+				return err
+			}
+			dst, err := tx.LoadPersistent(to)
+			if err != nil {
+				return err
+			}
+			ranges, err := ToKeyRanges(Eq("n", n))
+			if err != nil {
+				return err
+			}
+			if err := src.Delete(ranges); err != nil {
+				return err
+			}
+			return dst.Insert(map[string]any{"n": n})
+		})
+	}
+	err = db.update(func(tx *Tx) error {
+		for _, name := range []string{"todo", "done"} {
+			if _, err := tx.CreatePersistent(name, map[string]ColumnSpec{"n": {Indexed: true}}); err != nil {
+				return err
+			}
+		}
+		todo, err := tx.LoadPersistent("todo")
+		if err != nil {
+			return err
+		}
+		for n := range int64(4) {
+			if err := todo.Insert(map[string]any{"n": n}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	todo, err := snap.Select("todo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A writer moves rows between the relations halfway through the export.
+	for n := range int64(3) {
+		if err := move("todo", "done", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done, err := snap.Select("done", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todo) != 4 || len(done) != 0 {
+		t.Fatalf("torn snapshot: %d todo, %d done", len(todo), len(done))
+	}
+	err = snap.Read(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("done")
+		if err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"n": int64(9)})
+	})
+	if err == nil {
+		t.Fatal("expected writes through a snapshot to fail")
+	}
+}
@@ -0,0 +1,204 @@
+package thunder
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVOptions configures a CSV import.
+type CSVOptions struct {
+	// Mapping maps CSV header names to relation columns. Headers that are
+	// not mapped are ignored. When nil, headers map to columns of the same name.
+	Mapping map[string]string
+	// BatchSize is the number of lines committed per transaction by
+	// DB.ImportCSV. Defaults to 1024.
+	BatchSize int
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+}
+
+// LineError reports a CSV line that could not be imported.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e LineError) Unwrap() error {
+	return e.Err
+}
+
+// CSVResult summarises a CSV import. Lines listed in Errors were skipped.
+type CSVResult struct {
+	Inserted int
+	Errors   []LineError
+}
+
+// ImportCSV inserts the rows of a CSV file with a header line into the
+// relation, coercing fields to the declared column types. Lines that fail to
+// parse, coerce or insert are skipped and reported in the result.
+func (pr *Persistent) ImportCSV(r io.Reader, opts CSVOptions) (CSVResult, error) {
+	if err := pr.checkWritable(); err != nil {
+		return CSVResult{}, err
+	}
+	var result CSVResult
+	imp, err := newCSVImporter(r, opts, pr)
+	if err != nil {
+		return result, err
+	}
+	for {
+		done, err := imp.importBatch(pr, opts.batchSize(), &result)
+		if err != nil || done {
+			return result, err
+		}
+	}
+}
+
+// ImportCSV imports a CSV file into relation like Persistent.ImportCSV,
+// committing a transaction every opts.BatchSize lines.
+func (d *DB) ImportCSV(relation string, r io.Reader, opts CSVOptions) (CSVResult, error) {
+	var result CSVResult
+	var imp *csvImporter
+	for done := false; !done; {
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			if imp == nil {
+				if imp, err = newCSVImporter(r, opts, p); err != nil {
+					return err
+				}
+			}
+			batch := CSVResult{}
+			done, err = imp.importBatch(p, opts.batchSize(), &batch)
+			if err != nil {
+				return err
+			}
+			result.Inserted += batch.Inserted
+			result.Errors = append(result.Errors, batch.Errors...)
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (o CSVOptions) batchSize() int {
+	if o.BatchSize <= 0 {
+		return 1024
+	}
+	return o.BatchSize
+}
+
+type csvImporter struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVImporter(r io.Reader, opts CSVOptions, pr *Persistent) (*csvImporter, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		col := name
+		if opts.Mapping != nil {
+			col = opts.Mapping[name]
+		}
+		if col == "" {
+			continue
+		}
+		if spec, ok := pr.fields[col]; !ok || len(spec.ReferenceCols) > 0 {
+			return nil, ErrFieldNotFound(col)
+		}
+		columns[i] = col
+	}
+	return &csvImporter{reader: reader, columns: columns}, nil
+}
+
+func (imp *csvImporter) importBatch(pr *Persistent, batchSize int, result *CSVResult) (bool, error) {
+	for range batchSize {
+		record, err := imp.reader.Read()
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Errors = append(result.Errors, LineError{Line: parseErr.Line, Err: err})
+				continue
+			}
+			return false, err
+		}
+		line, _ := imp.reader.FieldPos(0)
+		row, err := imp.row(pr, record)
+		if err == nil {
+			err = pr.Insert(row)
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, LineError{Line: line, Err: err})
+			continue
+		}
+		result.Inserted++
+	}
+	return false, nil
+}
+
+func (imp *csvImporter) row(pr *Persistent, record []string) (map[string]any, error) {
+	if len(record) != len(imp.columns) {
+		return nil, ErrFieldCountMismatch(len(imp.columns), len(record))
+	}
+	row := make(map[string]any, len(record))
+	for i, field := range record {
+		col := imp.columns[i]
+		if col == "" {
+			continue
+		}
+		v, err := coerceString(col, pr.fields[col].Type, field)
+		if err != nil {
+			return nil, err
+		}
+		row[col] = v
+	}
+	return row, nil
+}
+
+// coerceString parses a textual field into the declared column type.
+func coerceString(column string, t ColumnType, s string) (any, error) {
+	var v any
+	var err error
+	switch t {
+	case TypeInt:
+		v, err = strconv.ParseInt(s, 10, 64)
+	case TypeFloat:
+		v, err = strconv.ParseFloat(s, 64)
+	case TypeBool:
+		v, err = strconv.ParseBool(s)
+	case TypeBytes:
+		v = []byte(s)
+	case TypeTime:
+		v, err = time.Parse(time.RFC3339Nano, s)
+	default:
+		v = s
+	}
+	if err != nil {
+		return nil, ErrTypeMismatch(column, t, s)
+	}
+	return v, nil
+}
@@ -0,0 +1,123 @@
+package thunder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDB_ImportCSV(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.CreatePersistent("products", map[string]ColumnSpec{
+		"sku":    {Unique: true, Type: TypeString},
+		"price":  {Type: TypeFloat},
+		"stock":  {Type: TypeInt},
+		"active": {Type: TypeBool},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"SKU,Price,Qty,Active,Notes",
+		"a-1,9.99,3,true,first",
+		"a-2,abc,1,false,bad price",
+		"a-3,1.5,7,true,",
+		"a-1,2.0,1,true,duplicate sku",
+		"a-4,3.25,2",
+		"a-5,4,0,false,last",
+	}, "\n")
+	result, err := db.ImportCSV("products", strings.NewReader(input), CSVOptions{
+		Mapping: map[string]string{
+			"SKU":    "sku",
+			"Price":  "price",
+			"Qty":    "stock",
+			"Active": "active",
+		},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 3 {
+		t.Errorf("expected 3 inserted rows, got %d", result.Inserted)
+	}
+	var lines []int
+	for _, lineErr := range result.Errors {
+		lines = append(lines, lineErr.Line)
+	}
+	if len(lines) != 3 || lines[0] != 3 || lines[1] != 5 || lines[2] != 6 {
+		t.Errorf("unexpected line errors %v", result.Errors)
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent("products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("sku", "a-3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := p.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["price"] != 1.5 || row["stock"] != int64(7) || row["active"] != true {
+			t.Errorf("unexpected coerced row %v", row)
+		}
+	}
+
+	if _, err := db.ImportCSV("products", strings.NewReader("nope\nx"), CSVOptions{}); err == nil {
+		t.Error("expected unknown header column to fail")
+	}
+}
+
+func TestDB_ImportCSVMalformed(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("products", map[string]ColumnSpec{
+			"sku":   {Unique: true, Type: TypeString},
+			"stock": {Type: TypeInt},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"sku,stock",
+		"a-1,1",
+		`"a-2"x,2`,
+		"a-3,3",
+	}, "\n")
+	result, err := db.ImportCSV("products", strings.NewReader(input), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inserted != 2 {
+		t.Errorf("expected 2 inserted rows, got %d", result.Inserted)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 3 {
+		t.Errorf("expected a parse error on line 3, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,103 @@
+package thunder
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// firstCustomType is the ColumnType given to the first type registered with
+// RegisterType.
+const firstCustomType ColumnType = 128
+
+type customType struct {
+	name  string
+	key   func(any) any
+	value func(any) (any, bool)
+}
+
+var customTypes struct {
+	sync.RWMutex
+	byType map[reflect.Type]*customType
+	list   []*customType
+}
+
+// RegisterType makes values of type T usable as column values, index keys
+// and Op values. key returns a string, []byte, integer or float whose order
+// matches the order of the values. decode converts the form a codec reads T
+// back as, such as the text of an encoding.TextMarshaler, to a T.
+//
+// The returned ColumnType declares columns of type T. It is recorded in
+// relation schemas, so types must be registered in the same order, before
+// any database using them is opened. RegisterType panics if T is already
+// registered or too many types are.
+func RegisterType[T any](name string, key func(T) any, decode func(any) (T, bool)) ColumnType {
+	typ := reflect.TypeFor[T]()
+	customTypes.Lock()
+	defer customTypes.Unlock()
+	if _, ok := customTypes.byType[typ]; ok {
+		panic(fmt.Sprintf("thunder: type %v registered twice", typ))
+	}
+	if len(customTypes.list) > int(^ColumnType(0)-firstCustomType) {
+		panic("thunder: too many registered types")
+	}
+	if customTypes.byType == nil {
+		customTypes.byType = make(map[reflect.Type]*customType)
+	}
+	ct := &customType{
+		name: name,
+		key:  func(v any) any { return key(v.(T)) },
+		value: func(v any) (any, bool) {
+			if x, ok := v.(T); ok {
+				return x, true
+			}
+			return decode(v)
+		},
+	}
+	customTypes.byType[typ] = ct
+	customTypes.list = append(customTypes.list, ct)
+	return firstCustomType + ColumnType(len(customTypes.list)-1)
+}
+
+// custom returns the registered type t declares, or nil.
+func (t ColumnType) custom() *customType {
+	if t < firstCustomType {
+		return nil
+	}
+	customTypes.RLock()
+	defer customTypes.RUnlock()
+	if i := int(t - firstCustomType); i < len(customTypes.list) {
+		return customTypes.list[i]
+	}
+	return nil
+}
+
+// customKey returns the key form of v when its type is registered.
+func customKey(v any) (any, bool) {
+	if v == nil {
+		return nil, false
+	}
+	customTypes.RLock()
+	ct, ok := customTypes.byType[reflect.TypeOf(v)]
+	customTypes.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return ct.key(v), true
+}
+
+// loadCustom converts the stored values of columns with a registered type
+// in value back to that type.
+func (pr *Persistent) loadCustom(value map[string]any) {
+	for col, spec := range pr.fields {
+		ct := spec.Type.custom()
+		if ct == nil {
+			continue
+		}
+		if v, ok := value[col]; ok {
+			if x, ok := ct.value(v); ok {
+				value[col] = x
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package thunder
+
+import (
+	"net/netip"
+	"testing"
+)
+
+var typeAddr = RegisterType("addr",
+	func(a netip.Addr) any {
+		b := a.As16()
+		return b[:]
+	},
+	func(v any) (netip.Addr, bool) {
+		var a netip.Addr
+		var err error
+		switch v := v.(type) {
+		case string: // JSON uses the text form
+			a, err = netip.ParseAddr(v)
+		case []byte: // msgpack the binary one
+			err = a.UnmarshalBinary(v)
+		default:
+			return a, false
+		}
+		return a, err == nil
+	},
+)
+
+func TestPersistent_RegisteredType(t *testing.T) {
+	for name, maUn := range map[string]MarshalUnmarshaler{"msgpack": &MsgpackMaUn, "json": &JsonMaUn, "cbor": &CborMaUn} {
+		t.Run(name, func(t *testing.T) {
+			db, err := OpenMemory(maUn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tx, err := db.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			hosts, err := tx.CreatePersistent("hosts", map[string]ColumnSpec{
+				"name": {Unique: true},
+				"addr": {Unique: true, Type: typeAddr},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, addr := range map[string]string{"a": "10.0.0.2", "b": "10.0.0.10", "c": "192.168.1.1"} {
+				if err := hosts.Insert(map[string]any{"name": name, "addr": netip.MustParseAddr(addr)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := hosts.Insert(map[string]any{"name": "d", "addr": "not an address"}); err == nil {
+				t.Fatal("expected a type mismatch")
+			}
+			if err := hosts.Insert(map[string]any{"name": "d", "addr": netip.MustParseAddr("10.0.0.10")}); err == nil {
+				t.Fatal("expected a unique violation")
+			}
+
+			ranges, err := ToKeyRanges(Ge("addr", netip.MustParseAddr("10.0.0.3")), Lt("addr", netip.MustParseAddr("11.0.0.0")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			seq, err := hosts.Select(ranges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []map[string]any
+			for row, err := range seq {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, row)
+			}
+			if len(got) != 1 || got[0]["name"] != "b" {
+				t.Fatalf("expected only b, got %v", got)
+			}
+			if addr, ok := got[0]["addr"].(netip.Addr); !ok || addr != netip.MustParseAddr("10.0.0.10") {
+				t.Fatalf("expected a netip.Addr, got %#v", got[0]["addr"])
+			}
+			if typeAddr.String() != "addr" {
+				t.Fatalf("unexpected type name %q", typeAddr)
+			}
+		})
+	}
+}
@@ -4,35 +4,49 @@ import (
 	"bytes"
 	"encoding/binary"
 	"iter"
-
-	"github.com/openkvlab/boltdb"
+	"slices"
 )
 
 type dataStorage struct {
-	bucket *boltdb.Bucket
+	bucket BackendBucket
 	fields []string
 	maUn   MarshalUnmarshaler
+	// recoded is the codec of the rows with ids up to recodedUntil while
+	// the relation is being recoded, see Persistent.Recode.
+	recoded      MarshalUnmarshaler
+	recodedUntil []byte
+	// columns holds a bucket per columnar column, mapping row ids to the
+	// column's value, see ColumnSpec.Columnar.
+	columns  BackendBucket
+	columnar []string
 }
 
 func newData(
-	parentBucket *boltdb.Bucket,
+	parentBucket BackendBucket,
 	fields []string,
+	columnar []string,
 	maUn MarshalUnmarshaler,
 ) (*dataStorage, error) {
 	bucket, err := parentBucket.CreateBucketIfNotExists([]byte("data"))
 	if err != nil {
 		return nil, err
 	}
-	return &dataStorage{
-		bucket: bucket,
-		fields: fields,
-		maUn:   maUn,
-	}, nil
+	d := &dataStorage{
+		bucket:   bucket,
+		fields:   fields,
+		maUn:     maUn,
+		columnar: columnar,
+	}
+	if err := d.createColumns(parentBucket); err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
 func loadData(
-	parentBucket *boltdb.Bucket,
+	parentBucket BackendBucket,
 	fields []string,
+	columnar []string,
 	maUn MarshalUnmarshaler,
 ) (*dataStorage, error) {
 	bucket := parentBucket.Bucket([]byte("data"))
@@ -40,9 +54,11 @@ func loadData(
 		return nil, nil
 	}
 	return &dataStorage{
-		bucket: bucket,
-		fields: fields,
-		maUn:   maUn,
+		bucket:   bucket,
+		fields:   fields,
+		maUn:     maUn,
+		columns:  parentBucket.Bucket([]byte("columns")),
+		columnar: columnar,
 	}, nil
 }
 
@@ -60,52 +76,144 @@ func (d *dataStorage) insert(value map[string]any) ([8]byte, error) {
 	if err != nil {
 		return idBytes, err
 	}
-	return idBytes, d.bucket.Put(idBytes[:], valueBytes)
+	if err := d.bucket.Put(idBytes[:], valueBytes); err != nil {
+		return idBytes, err
+	}
+	return idBytes, d.putColumns(idBytes[:], value, d.maUn)
 }
 
-func (d *dataStorage) get(kr *keyRange) (iter.Seq2[entry, error], error) {
+// get yields the rows with ids in kr for which match, if set, returns true,
+// decoded with only columns, or every column when nil. Rows that do not match
+// are decoded into the same map, which is cleared and reused, so only the
+// rows yielded are allocated. With desc the rows come in descending id order.
+func (d *dataStorage) get(kr *keyRange, columns []string, match func(map[string]any) (bool, error), desc bool) (iter.Seq2[entry, error], error) {
 	return func(yield func(entry, error) bool) {
 		c := d.bucket.Cursor()
-		lessThan := func(k []byte) bool {
+		inBounds := func(k []byte) bool {
 			if kr.endKey == nil {
 				return true
 			}
 			cmp := bytes.Compare(k, kr.endKey)
 			return cmp < 0 || (cmp == 0 && kr.includeEnd)
 		}
+		next := c.Next
 		var k, v []byte
-		if kr.startKey != nil {
+		switch {
+		case desc:
+			next = c.Prev
+			inBounds = func(k []byte) bool {
+				if kr.startKey == nil {
+					return true
+				}
+				cmp := bytes.Compare(k, kr.startKey)
+				return cmp > 0 || (cmp == 0 && kr.includeStart)
+			}
+			if kr.endKey == nil {
+				k, v = c.Last()
+				break
+			}
+			if k, v = c.Seek(kr.endKey); k == nil {
+				k, v = c.Last()
+			} else if cmp := bytes.Compare(k, kr.endKey); cmp > 0 || (cmp == 0 && !kr.includeEnd) {
+				k, v = c.Prev()
+			}
+		case kr.startKey != nil:
 			k, v = c.Seek(kr.startKey)
-		} else {
+		default:
 			k, v = c.First()
 		}
-		if !kr.includeStart {
+		if !desc && !kr.includeStart {
 			k, v = c.Next()
 		}
-		for ; k != nil && lessThan(k); k, v = c.Next() {
+		var value map[string]any
+		for ; k != nil && inBounds(k); k, v = next() {
 			if !kr.contains(k) {
 				continue
 			}
-			var value map[string]any
-			if err := d.maUn.Unmarshal(v, &value); err != nil {
+			clear(value)
+			if err := d.decode(k, v, &value, columns); err != nil {
+				value = nil
 				if !yield(entry{}, err) {
 					return
 				}
 				continue
 			}
-			idFixed := [8]byte{}
-			copy(idFixed[:], k)
-			if !yield(entry{
-				value: value,
-				id:    idFixed,
-			}, nil) {
+			if match != nil {
+				ok, err := match(value)
+				if err != nil {
+					if !yield(entry{}, err) {
+						return
+					}
+					continue
+				}
+				if !ok {
+					continue
+				}
+			}
+			e := entry{value: value}
+			copy(e.id[:], k)
+			value = nil
+			if !yield(e, nil) {
 				return
 			}
 		}
 	}, nil
 }
 
+// getByID returns the row stored under id, or nil if there is none.
+func (d *dataStorage) getByID(id []byte, columns []string) (map[string]any, error) {
+	v := d.bucket.Get(id)
+	if v == nil {
+		return nil, nil
+	}
+	var value map[string]any
+	if err := d.decode(id, v, &value, columns); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// codec returns the codec the row stored under id is encoded with.
+func (d *dataStorage) codec(id []byte) MarshalUnmarshaler {
+	if d.recoded != nil && bytes.Compare(id, d.recodedUntil) <= 0 {
+		return d.recoded
+	}
+	return d.maUn
+}
+
+// unmarshal decodes the row stored under id.
+func (d *dataStorage) unmarshal(id, raw []byte, value *map[string]any) error {
+	return d.codec(id).Unmarshal(raw, value)
+}
+
+// decode unmarshals the columns of the row stored under id, or all of them
+// when nil. Codecs that are not a ColumnUnmarshaler decode the whole row
+// first.
+func (d *dataStorage) decode(id, raw []byte, value *map[string]any, columns []string) error {
+	maUn := d.codec(id)
+	if columns == nil {
+		return maUn.Unmarshal(raw, value)
+	}
+	if cu, ok := maUn.(ColumnUnmarshaler); ok {
+		return cu.UnmarshalColumns(raw, columns, value)
+	}
+	if err := maUn.Unmarshal(raw, value); err != nil {
+		return err
+	}
+	for col := range *value {
+		if !slices.Contains(columns, col) {
+			delete(*value, col)
+		}
+	}
+	return nil
+}
+
 func (d *dataStorage) delete(id []byte) error {
+	for _, col := range d.columnar {
+		if err := d.columns.Bucket([]byte(col)).Delete(id); err != nil {
+			return err
+		}
+	}
 	return d.bucket.Delete(id)
 }
 
@@ -1,14 +1,59 @@
 package thunder
 
 import (
+	"context"
+	"errors"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/openkvlab/boltdb"
 )
 
 type DB struct {
-	db   *boltdb.DB
-	maUn MarshalUnmarshaler
+	backend        Backend
+	maUn           MarshalUnmarshaler
+	validationMode ValidationMode
+	coerce         bool
+	warningHandler func(Warning)
+	plannerHook    func(PlannerEvent)
+	metrics        MetricsSink
+	tracer         Tracer
+	logger         Logger
+	slowQuery      time.Duration
+	stats          *plannerStats
+	clock          Clock
+	readPool       readPool
+	mode           os.FileMode
+	options        *boltdb.Options
+	remotesMu      sync.Mutex
+	remotes        map[string]RemoteSource
+	feed           changefeed
+	hooksMu        sync.RWMutex
+	hooks          map[string]RelationHooks
+	generators     map[string]Generator
+	codecs         map[string]MarshalUnmarshaler
+	validators     map[string]map[string][]Validator
+	retentionMu    sync.Mutex
+	retention      map[string]Retention
+	horizons       map[string]time.Duration
+	maintenance    maintenance
+	durability     durabilityState
+	policyMu       sync.RWMutex
+	policies       map[string]Policy
+	cacheMu        sync.Mutex
+	cache          *resultCache
+	commits        commitSignal
+	following      atomic.Bool
+}
+
+// OpenReadOnly opens the database file at path for reading only, sharing the
+// file with other readers, such as reporting jobs running against a backup
+// copy. Writable transactions, and writes through the handles of read-only
+// ones, fail with ErrReadOnly.
+func OpenReadOnly(maUn MarshalUnmarshaler, path string) (*DB, error) {
+	return OpenDB(maUn, path, 0, &boltdb.Options{ReadOnly: true})
 }
 
 func OpenDB(maUn MarshalUnmarshaler, path string, mode os.FileMode, options *boltdb.Options) (*DB, error) {
@@ -16,45 +61,161 @@ func OpenDB(maUn MarshalUnmarshaler, path string, mode os.FileMode, options *bol
 	if err != nil {
 		return nil, err
 	}
-	return &DB{db: bdb, maUn: maUn}, nil
+	d, err := OpenBackend(maUn, NewBoltBackend(bdb))
+	if err != nil {
+		return nil, err
+	}
+	d.mode, d.options = mode, options
+	if options != nil && options.NoSync {
+		d.durability.mode = NoSync()
+	}
+	return d, nil
+}
+
+// OpenDBDurable is OpenDB with commits flushed as durability says; see
+// SetDurability.
+func OpenDBDurable(maUn MarshalUnmarshaler, path string, mode os.FileMode, options *boltdb.Options, durability Durability) (*DB, error) {
+	d, err := OpenDB(maUn, path, mode, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.SetDurability(durability); err != nil {
+		return nil, errors.Join(err, d.Close())
+	}
+	return d, nil
 }
 
+// Close releases pooled read transactions, persists the planner statistics
+// gathered by this process and closes the database file.
 func (d *DB) Close() error {
-	return d.db.Close()
+	return errors.Join(d.readPool.drain(), d.saveStats(), d.stopSyncing(), d.backend.Close())
 }
 
 func (d *DB) Begin(writable bool) (*Tx, error) {
-	tx, err := d.db.Begin(writable)
-	if err != nil {
-		return nil, err
+	if writable && d.following.Load() {
+		return nil, ErrReplicaReadOnly()
 	}
-	tempFile, err := os.CreateTemp("", "thunder_tempdb_*.db")
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+	if writable && d.backend.ReadOnly() {
+		return nil, ErrReadOnly()
 	}
-	tempFilePath := tempFile.Name()
-	tempFile.Close()
+	return d.begin(writable)
+}
 
-	tempDb, err := boltdb.Open(tempFilePath, 0600, nil)
+func (d *DB) begin(writable bool) (*Tx, error) {
+	tx, err := d.backend.Begin(writable)
 	if err != nil {
-		tx.Rollback()
-		os.Remove(tempFilePath)
 		return nil, err
 	}
-	tempTx, err := tempDb.Begin(true)
+	// Ephemeral relations live in a private in-memory store that is
+	// discarded with the transaction.
+	tempTx, err := NewMemoryBackend().Begin(true)
 	if err != nil {
 		tx.Rollback()
-		tempDb.Close()
-		os.Remove(tempFilePath)
 		return nil, err
 	}
 
+	// Writes are journaled so that savepoints can undo them; the journal
+	// stays empty until the first savepoint.
+	j := &journal{}
+	tempTx = &journalTx{BackendTx: tempTx, j: j}
+	if writable {
+		tx = &journalTx{BackendTx: tx, j: j}
+	}
 	return &Tx{
-		tx:           tx,
-		tempTx:       tempTx,
-		tempDb:       tempDb,
-		tempFilePath: tempFilePath,
-		maUn:         d.maUn,
+		tx:       tx,
+		tempTx:   tempTx,
+		maUn:     d.maUn,
+		db:       d,
+		writable: writable,
+		started:  time.Now(),
+		journal:  j,
 	}, nil
 }
+
+// ForEachRelation calls fn for every persistent relation inside a single
+// read-write transaction, committing only if every call succeeds.
+func (d *DB) ForEachRelation(fn func(*Persistent) error) error {
+	return d.update(func(tx *Tx) error {
+		return tx.ForEachRelation(fn)
+	})
+}
+
+// CreateIndex builds an index on relation without holding the write lock for
+// the whole backfill. The index is registered first so that concurrent
+// inserts maintain it, then existing rows are indexed in transactions of at
+// most batchSize rows. The planner ignores the index until the backfill
+// completes; if the backfill fails the index is dropped again.
+func (d *DB) CreateIndex(relation, name string, columns []string, unique bool, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = 1024
+	}
+	if err := d.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		return p.registerIndex(name, columns, unique)
+	}); err != nil {
+		return err
+	}
+	var after []byte
+	for done := false; !done; {
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			last, _, finished, err := p.backfillIndex(name, after, batchSize)
+			if err != nil {
+				return err
+			}
+			after, done = last, finished
+			if finished {
+				return p.finishIndex(name)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Join(err, d.update(func(tx *Tx) error {
+				p, err := tx.LoadPersistent(relation)
+				if err != nil {
+					return err
+				}
+				return p.DropIndex(name)
+			}))
+		}
+	}
+	return nil
+}
+
+func (d *DB) update(fn func(tx *Tx) error) error {
+	tx, err := d.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// viewContext is view in a transaction begun with ctx, so that row policies
+// apply as they would to the caller.
+func (d *DB) viewContext(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := d.BeginContext(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+func (d *DB) view(fn func(tx *Tx) error) error {
+	tx, err := d.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
@@ -0,0 +1,159 @@
+package thunder
+
+import (
+	"encoding/binary"
+	"maps"
+	"math/big"
+	"strings"
+)
+
+// decimalParts returns the digits and exponent of x such that
+// |x| = 0.digits × 10^exp, with no trailing zeros in digits. ok is false
+// when x has no finite decimal expansion, such as 1/3.
+func decimalParts(x *big.Rat) (digits string, exp int, ok bool) {
+	if x.Sign() == 0 {
+		return "", 0, true
+	}
+	den := new(big.Int).Set(x.Denom())
+	twos, fives := 0, 0
+	for den.Bit(0) == 0 {
+		den.Rsh(den, 1)
+		twos++
+	}
+	five, mod := big.NewInt(5), new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(den, five, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		den = q
+		fives++
+	}
+	if den.Cmp(big.NewInt(1)) != 0 {
+		return "", 0, false
+	}
+	scale := max(twos, fives)
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	n.Mul(n, x.Num())
+	n.Quo(n, x.Denom())
+	s := n.Abs(n).String()
+	digits = strings.TrimRight(s, "0")
+	scale -= len(s) - len(digits)
+	return digits, len(digits) - scale, true
+}
+
+// decimalKey encodes x so that byte order matches numeric order: a sign
+// byte, then the exponent and digits, both inverted for negative numbers.
+func decimalKey(x *big.Rat) ([]byte, bool) {
+	digits, exp, ok := decimalParts(x)
+	if !ok {
+		return nil, false
+	}
+	switch x.Sign() {
+	case 0:
+		return []byte{0x02}, true
+	case 1:
+		key := binary.BigEndian.AppendUint64([]byte{0x03}, uint64(exp)^1<<63)
+		return append(append(key, digits...), 0x00), true
+	}
+	key := binary.BigEndian.AppendUint64([]byte{0x01}, uint64(exp)^1<<63)
+	key = append(key, digits...)
+	for i := 1; i < len(key); i++ {
+		key[i] = ^key[i]
+	}
+	return append(key, 0xff), true
+}
+
+// decimalText formats x, which must have a finite decimal expansion, with
+// no more fractional digits than it needs.
+func decimalText(x *big.Rat) string {
+	if x.IsInt() {
+		return x.Num().String()
+	}
+	digits, exp, _ := decimalParts(x)
+	return x.FloatString(len(digits) - exp)
+}
+
+// decimalValue converts v to a *big.Rat. Integers and decimal strings, the
+// form TypeBigInt and TypeDecimal columns are stored in, are accepted; floats
+// are not, as they rarely hold the decimal they were meant to.
+func decimalValue(v any) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case *big.Rat:
+		if n == nil {
+			return nil, false
+		}
+		return n, true
+	case *big.Int:
+		if n == nil {
+			return nil, false
+		}
+		return new(big.Rat).SetInt(n), true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int8:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int16:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case uint:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint8:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint16:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint32:
+		return new(big.Rat).SetUint64(uint64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case string:
+		return new(big.Rat).SetString(n)
+	case []byte:
+		return new(big.Rat).SetString(string(n))
+	}
+	return nil, false
+}
+
+// storeDecimals returns obj with the values of its TypeBigInt and
+// TypeDecimal columns replaced by their decimal text, which every codec
+// stores exactly. obj itself is not modified.
+func (pr *Persistent) storeDecimals(obj map[string]any) map[string]any {
+	stored, cloned := obj, false
+	for col, spec := range pr.fields {
+		if spec.Type != TypeBigInt && spec.Type != TypeDecimal {
+			continue
+		}
+		x, ok := decimalValue(obj[col])
+		if !ok {
+			continue
+		}
+		if !cloned {
+			stored, cloned = make(map[string]any, len(obj)), true
+			maps.Copy(stored, obj)
+		}
+		stored[col] = decimalText(x)
+	}
+	return stored
+}
+
+// loadDecimals converts the stored text of TypeBigInt and TypeDecimal
+// columns in value back to a *big.Int or *big.Rat.
+func (pr *Persistent) loadDecimals(value map[string]any) {
+	for col, spec := range pr.fields {
+		if spec.Type != TypeBigInt && spec.Type != TypeDecimal {
+			continue
+		}
+		x, ok := decimalValue(value[col])
+		if !ok {
+			continue
+		}
+		if spec.Type == TypeBigInt && x.IsInt() {
+			value[col] = new(big.Int).Set(x.Num())
+		} else {
+			value[col] = x
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package thunder
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestDecimalKeyOrder(t *testing.T) {
+	values := []string{"-1000", "-12.5", "-12.25", "-0.5", "-0.001", "0", "0.001", "0.1", "0.12", "1", "10", "12.25", "12.5", "100"}
+	var prev []byte
+	for i, s := range values {
+		x, _ := new(big.Rat).SetString(s)
+		key, ok := decimalKey(x)
+		if !ok {
+			t.Fatalf("cannot encode %s", s)
+		}
+		if i > 0 && bytes.Compare(prev, key) >= 0 {
+			t.Fatalf("%s does not sort after %s", s, values[i-1])
+		}
+		prev = key
+	}
+	if _, ok := decimalKey(big.NewRat(1, 3)); ok {
+		t.Fatal("expected 1/3 to have no decimal key")
+	}
+}
+
+func TestPersistent_DecimalColumn(t *testing.T) {
+	for name, maUn := range map[string]MarshalUnmarshaler{"msgpack": &MsgpackMaUn, "json": &JsonMaUn, "cbor": &CborMaUn} {
+		t.Run(name, func(t *testing.T) {
+			db, err := OpenMemory(maUn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tx, err := db.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			payments, err := tx.CreatePersistent("payments", map[string]ColumnSpec{
+				"id":     {Unique: true},
+				"amount": {Indexed: true, Type: TypeDecimal},
+				"units":  {Type: TypeBigInt},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+			exact, _ := new(big.Rat).SetString("90071992547409931.01")
+			rows := []map[string]any{
+				{"id": "a", "amount": big.NewRat(-3, 2), "units": 1},
+				{"id": "b", "amount": "0.10", "units": huge},
+				{"id": "c", "amount": exact, "units": int64(3)},
+				{"id": "d", "amount": 7, "units": "4"},
+			}
+			for _, row := range rows {
+				if err := payments.Insert(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := payments.Insert(map[string]any{"id": "e", "amount": big.NewRat(1, 3), "units": 1}); err == nil {
+				t.Fatal("expected 1/3 to be rejected")
+			}
+			if err := payments.Insert(map[string]any{"id": "e", "amount": 1.5, "units": 1}); err == nil {
+				t.Fatal("expected a float to be rejected")
+			}
+
+			ranges, err := ToKeyRanges(Gt("amount", big.NewRat(0, 1)), Le("amount", big.NewInt(7)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			seq, err := payments.Select(ranges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for row, err := range seq {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, row["id"].(string))
+				if row["id"] == "b" {
+					if units, ok := row["units"].(*big.Int); !ok || units.Cmp(huge) != 0 {
+						t.Fatalf("expected units %v, got %#v", huge, row["units"])
+					}
+				}
+			}
+			if len(got) != 2 || got[0] != "b" || got[1] != "d" {
+				t.Fatalf("expected [b d] in amount order, got %v", got)
+			}
+
+			ranges, err = ToKeyRanges(Eq("amount", exact))
+			if err != nil {
+				t.Fatal(err)
+			}
+			seq, err = payments.Select(ranges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := 0
+			for row, err := range seq {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if amount, ok := row["amount"].(*big.Rat); !ok || amount.Cmp(exact) != 0 {
+					t.Fatalf("expected amount %v, got %#v", exact, row["amount"])
+				}
+				n++
+			}
+			if n != 1 {
+				t.Fatalf("expected 1 row, got %d", n)
+			}
+		})
+	}
+}
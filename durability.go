@@ -0,0 +1,158 @@
+package thunder
+
+import (
+	"sync"
+	"time"
+)
+
+// Durability is when committed transactions are flushed to stable storage.
+// The zero Durability flushes every commit before Commit returns. The others
+// trade the commits since the last flush, which a crash of the machine can
+// lose, for write throughput, typically during a bulk load.
+type Durability struct {
+	never    bool
+	every    int
+	interval time.Duration
+}
+
+// SyncAlways flushes every commit, the default.
+func SyncAlways() Durability {
+	return Durability{}
+}
+
+// NoSync never flushes by itself; only Flush and Close do.
+func NoSync() Durability {
+	return Durability{never: true}
+}
+
+// SyncEveryN flushes after every n write commits.
+func SyncEveryN(n int) Durability {
+	if n <= 1 {
+		return Durability{}
+	}
+	return Durability{every: n}
+}
+
+// SyncInterval flushes the commits made since the last flush every interval.
+func SyncInterval(interval time.Duration) Durability {
+	if interval <= 0 {
+		return Durability{}
+	}
+	return Durability{interval: interval}
+}
+
+func (d Durability) always() bool {
+	return d == Durability{}
+}
+
+type durabilityState struct {
+	mu       sync.Mutex
+	mode     Durability
+	unsynced int
+	// stop ends the goroutine of SyncInterval.
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SetDurability sets when commits are flushed, usually right after opening
+// the database, as OpenDBDurable does. It fails with ErrBackendUnsupported unless the backend is a
+// BackendSyncer, as the one OpenDB uses is. Changing it flushes the commits
+// not yet flushed.
+func (d *DB) SetDurability(mode Durability) error {
+	syncer, ok := d.backend.(BackendSyncer)
+	if !ok {
+		return ErrBackendUnsupported("durability modes")
+	}
+	if d.backend.ReadOnly() {
+		return ErrReadOnly()
+	}
+	if err := d.stopSyncing(); err != nil {
+		return err
+	}
+	if err := syncer.SetNoSync(!mode.always()); err != nil {
+		return err
+	}
+	s := &d.durability
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mode = mode
+	if mode.interval > 0 {
+		s.stop, s.done = make(chan struct{}), make(chan struct{})
+		go d.syncEvery(mode.interval, s.stop, s.done)
+	}
+	return nil
+}
+
+// Flush writes every commit to stable storage. It is a no-op unless the
+// durability mode leaves commits unflushed.
+func (d *DB) Flush() error {
+	s := &d.durability
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return d.flushLocked()
+}
+
+func (d *DB) flushLocked() error {
+	s := &d.durability
+	if s.unsynced == 0 {
+		return nil
+	}
+	syncer, ok := d.backend.(BackendSyncer)
+	if !ok {
+		return nil
+	}
+	if err := syncer.Sync(); err != nil {
+		return err
+	}
+	s.unsynced = 0
+	return nil
+}
+
+// committed counts a write commit and flushes when SyncEveryN says so.
+// A failed flush is logged: the transaction has committed either way.
+func (d *DB) committed() {
+	s := &d.durability
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode.always() {
+		return
+	}
+	s.unsynced++
+	if s.mode.every == 0 || s.unsynced < s.mode.every {
+		return
+	}
+	if err := d.flushLocked(); err != nil && d.logger != nil {
+		d.logger.Error("thunder: flushing commits failed", "error", err)
+	}
+}
+
+func (d *DB) syncEvery(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := d.Flush(); err != nil && d.logger != nil {
+				d.logger.Error("thunder: flushing commits failed", "error", err)
+			}
+		}
+	}
+}
+
+// stopSyncing stops the goroutine of SyncInterval, if any, and flushes what
+// is left.
+func (d *DB) stopSyncing() error {
+	s := &d.durability
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return d.Flush()
+}
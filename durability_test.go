@@ -0,0 +1,125 @@
+package thunder
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDB_Durability(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	unsynced := func() int {
+		db.durability.mu.Lock()
+		defer db.durability.mu.Unlock()
+		return db.durability.unsynced
+	}
+	commit := func() {
+		t.Helper()
+		if err := db.update(func(tx *Tx) error {
+			_, err := tx.Sequence("n").Next()
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.SetDurability(SyncEveryN(3)); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+	commit()
+	if n := unsynced(); n != 2 {
+		t.Errorf("expected 2 unflushed commits, got %d", n)
+	}
+	commit()
+	if n := unsynced(); n != 0 {
+		t.Errorf("expected the third commit to flush, got %d unflushed", n)
+	}
+
+	if err := db.SetDurability(NoSync()); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		commit()
+	}
+	if n := unsynced(); n != 5 {
+		t.Errorf("expected 5 unflushed commits, got %d", n)
+	}
+	if err := db.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := unsynced(); n != 0 {
+		t.Errorf("expected Flush to flush, got %d unflushed", n)
+	}
+
+	if err := db.SetDurability(SyncInterval(5 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+	deadline := time.Now().Add(5 * time.Second)
+	for unsynced() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the interval to flush the commit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := db.SetDurability(SyncAlways()); err != nil {
+		t.Fatal(err)
+	}
+	commit()
+	if n := unsynced(); n != 0 {
+		t.Errorf("expected no unflushed commits, got %d", n)
+	}
+
+	mem, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mem.Close()
+	var te *ThunderError
+	if err := mem.SetDurability(NoSync()); !errors.As(err, &te) || te.Code != ErrCodeBackendUnsupported {
+		t.Errorf("expected ErrBackendUnsupported, got %v", err)
+	}
+}
+
+func TestDB_DurabilityAtOpenSurvivesCompact(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenDBDurable(&MsgpackMaUn, filepath.Join(dir, "bulk.db"), 0600, nil, NoSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	noSync := func() bool {
+		return db.backend.(*boltBackend).db.NoSync
+	}
+	if !noSync() {
+		t.Fatal("expected the open-time mode to skip flushes")
+	}
+	if err := db.update(func(tx *Tx) error {
+		_, err := tx.Sequence("n").Next()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Compact(filepath.Join(dir, "compact.db"), true); err != nil {
+		t.Fatal(err)
+	}
+	if !noSync() {
+		t.Error("expected the mode to still skip flushes after compacting")
+	}
+	if err := db.update(func(tx *Tx) error {
+		_, err := tx.Sequence("n").Next()
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.durability.mu.Lock()
+	n := db.durability.unsynced
+	db.durability.mu.Unlock()
+	if n == 0 {
+		t.Error("expected the commit after compacting to be left unflushed")
+	}
+}
@@ -0,0 +1,193 @@
+package thunder
+
+import (
+	"bytes"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// Element ops match slice-valued columns by their elements.
+const (
+	OpContains    = OpType(0b1000)
+	OpContainsAny = OpType(0b1001)
+	OpContainsAll = OpType(0b1010)
+)
+
+// Contains matches rows whose slice in field has value as an element.
+func Contains(field string, value any) Op {
+	return Op{
+		field:  field,
+		value:  []any{value},
+		opType: OpContains,
+	}
+}
+
+// ContainsAny matches rows whose slice in field has at least one of values as
+// an element.
+func ContainsAny(field string, values ...any) Op {
+	return Op{
+		field:  field,
+		value:  values,
+		opType: OpContainsAny,
+	}
+}
+
+// ContainsAll matches rows whose slice in field has every one of values as an
+// element. Several Contains on a field are combined the same way.
+func ContainsAll(field string, values ...any) Op {
+	return Op{
+		field:  field,
+		value:  values,
+		opType: OpContainsAll,
+	}
+}
+
+// addElements adds the element conditions of op to kr.
+func (kr *keyRange) addElements(op Op) error {
+	keys := make([][]byte, len(op.value))
+	for i, v := range op.value {
+		key, err := ToKey(v)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+	if op.opType == OpContainsAny {
+		kr.anyOf = append(kr.anyOf, keys)
+	} else {
+		kr.all = append(kr.all, keys...)
+	}
+	return nil
+}
+
+// elementwise reports whether kr has element conditions.
+func (kr *keyRange) elementwise() bool {
+	return len(kr.all) > 0 || len(kr.anyOf) > 0
+}
+
+func (kr *keyRange) bounded() bool {
+	return kr.startKey != nil || kr.endKey != nil || len(kr.excludes) > 0
+}
+
+// matchElements reports whether the element keys satisfy kr: one of them
+// falls in its bounds, if it has any, each key of all is among them, and so
+// is a key of every anyOf group.
+func (kr *keyRange) matchElements(keys [][]byte) bool {
+	has := func(key []byte) bool {
+		return slices.ContainsFunc(keys, func(k []byte) bool { return bytes.Equal(k, key) })
+	}
+	if kr.bounded() && !slices.ContainsFunc(keys, kr.contains) {
+		return false
+	}
+	for _, key := range kr.all {
+		if !has(key) {
+			return false
+		}
+	}
+	for _, group := range kr.anyOf {
+		if !slices.ContainsFunc(group, has) {
+			return false
+		}
+	}
+	return true
+}
+
+// elementKeys returns the distinct keys of the elements of v, or of v itself
+// when it is not a slice. A nil v has no elements.
+func elementKeys(v any) ([][]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		key, err := ToKey(v)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{key}, nil
+	}
+	keys := make([][]byte, 0, rv.Len())
+	for i := range rv.Len() {
+		key, err := ToKey(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(keys, func(k []byte) bool { return bytes.Equal(k, key) }) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// elementMatch reports whether the range on the column name of row is
+// checked element by element: the column is MultiEntry or the range has
+// element conditions.
+func (pr *Persistent) elementMatch(name string, kr *keyRange) bool {
+	spec := pr.fields[name]
+	return len(spec.ReferenceCols) == 0 && (spec.MultiEntry || kr.elementwise())
+}
+
+// indexKeys returns the keys obj is stored under in the index name: one per
+// element for MultiEntry columns, otherwise a single one.
+func (pr *Persistent) indexKeys(obj map[string]any, name string) ([][]byte, error) {
+	if spec := pr.fields[name]; spec.MultiEntry && len(spec.ReferenceCols) == 0 {
+		v, ok := obj[name]
+		if !ok {
+			return nil, ErrFieldNotFound(name)
+		}
+		return elementKeys(v)
+	}
+	key, err := pr.computeKey(obj, name)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{key}, nil
+}
+
+// lookupIndex yields the ids the index name holds for kr. MultiEntry indexes
+// hold a row once per element, so their rows are yielded once, and element
+// conditions without bounds are looked up by their first elements.
+func (pr *Persistent) lookupIndex(name string, kr *keyRange, desc bool) (iter.Seq2[[8]byte, error], error) {
+	if !pr.fields[name].MultiEntry {
+		return pr.indexes.get(name, kr, desc)
+	}
+	lookups := []*keyRange{kr}
+	switch {
+	case kr.bounded():
+	case len(kr.all) > 0:
+		lookups = []*keyRange{KeyRange(kr.all[0], kr.all[0], true, true, nil)}
+	case len(kr.anyOf) > 0:
+		lookups = lookups[:0]
+		for _, key := range kr.anyOf[0] {
+			lookups = append(lookups, KeyRange(key, key, true, true, nil))
+		}
+	}
+	if desc {
+		slices.Reverse(lookups)
+	}
+	seqs := make([]iter.Seq2[[8]byte, error], len(lookups))
+	for i, lookup := range lookups {
+		seq, err := pr.indexes.get(name, lookup, desc)
+		if err != nil {
+			return nil, err
+		}
+		seqs[i] = seq
+	}
+	return func(yield func([8]byte, error) bool) {
+		seen := make(map[[8]byte]struct{})
+		for _, seq := range seqs {
+			for id, err := range seq {
+				if err == nil {
+					if _, dup := seen[id]; dup {
+						continue
+					}
+					seen[id] = struct{}{}
+				}
+				if !yield(id, err) {
+					return
+				}
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,87 @@
+package thunder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPersistent_ArrayOps(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	posts, err := tx.CreatePersistent("posts", map[string]ColumnSpec{
+		"id":     {Unique: true},
+		"tags":   {Indexed: true, MultiEntry: true},
+		"scores": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"id": "1", "tags": []string{"go", "db"}, "scores": []any{int64(1), int64(5)}},
+		{"id": "2", "tags": []string{"go", "web", "go"}, "scores": []any{int64(2)}},
+		{"id": "3", "tags": []string{"rust"}, "scores": []any{}},
+	} {
+		if err := posts.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(ops ...Op) []string {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := posts.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, row["id"].(string))
+		}
+		slices.Sort(out)
+		return out
+	}
+	for _, tc := range []struct {
+		name string
+		ops  []Op
+		want []string
+	}{
+		{"contains", []Op{Contains("tags", "go")}, []string{"1", "2"}},
+		{"contains all", []Op{ContainsAll("tags", "go", "db")}, []string{"1"}},
+		{"contains twice", []Op{Contains("tags", "go"), Contains("tags", "web")}, []string{"2"}},
+		{"contains any", []Op{ContainsAny("tags", "db", "web", "rust")}, []string{"1", "2", "3"}},
+		{"element range", []Op{Ge("tags", "r")}, []string{"2", "3"}},
+		{"unindexed", []Op{Contains("scores", int64(5))}, []string{"1"}},
+	} {
+		if got := ids(tc.ops...); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+	ranges, err := ToKeyRanges(Contains("tags", "go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := posts.Explain(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Index != "tags" {
+		t.Fatalf("expected the multi-entry index to be used, got %v", plan)
+	}
+	if err := posts.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(ContainsAny("tags", "go", "db", "web")); got != nil {
+		t.Fatalf("expected every entry of the deleted rows removed, got %v", got)
+	}
+}
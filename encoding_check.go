@@ -0,0 +1,111 @@
+package thunder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"os"
+
+	"github.com/openkvlab/boltdb"
+)
+
+const encodingBucket = "__thunder_encoding"
+
+// encodingVersion is bumped whenever thunder itself changes how it lays out
+// index keys, independently of the ordered package.
+const encodingVersion = 2
+
+// encodingCanaries are encoded with ToKey at open time. Their fingerprint is
+// recorded in the database, so an upgrade of the ordered package that changes
+// its byte layout, and with it the order of every index, is caught before
+// any index is read.
+var encodingCanaries = [][]any{
+	{int64(0)}, {int64(-1)}, {int64(1)}, {int64(math.MinInt64)}, {int64(math.MaxInt64)},
+	{uint64(math.MaxUint64)},
+	{0.0}, {math.Copysign(0, -1)}, {-1.5}, {math.Inf(1)}, {math.Inf(-1)}, {math.NaN()},
+	{math.SmallestNonzeroFloat64},
+	{""}, {"a"}, {"a\x00b"}, {"\xff"},
+	{[]byte{}}, {[]byte{0, 1, 0xff}},
+	{"a", int64(1)}, {int64(1), "a", 2.5},
+}
+
+func encodingFingerprint() ([]byte, error) {
+	h := sha256.New()
+	for _, parts := range encodingCanaries {
+		key, err := ToKey(parts...)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(binary.AppendUvarint(nil, uint64(len(key))))
+		h.Write(key)
+	}
+	return binary.BigEndian.AppendUint32(h.Sum(nil), encodingVersion), nil
+}
+
+// checkEncoding compares the key encoding fingerprint stored in the database
+// with the one of the running binary. A new database records its fingerprint;
+// a mismatch means every index may be out of order and is reported as
+// ErrEncodingChanged. Reindex repairs such a database.
+func (d *DB) checkEncoding() error {
+	fingerprint, err := encodingFingerprint()
+	if err != nil {
+		return err
+	}
+	var stored []byte
+	err = d.backendView(func(tx BackendTx) error {
+		if bucket := tx.Bucket([]byte(encodingBucket)); bucket != nil {
+			stored = bytes.Clone(bucket.Get([]byte("fingerprint")))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case stored == nil && !d.backend.ReadOnly():
+		return d.saveEncoding(fingerprint)
+	case stored != nil && !bytes.Equal(stored, fingerprint):
+		return ErrEncodingChanged()
+	}
+	return nil
+}
+
+func (d *DB) saveEncoding(fingerprint []byte) error {
+	return d.backendUpdate(func(tx BackendTx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(encodingBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("fingerprint"), fingerprint)
+	})
+}
+
+// Reindex opens the database at path without checking its key encoding,
+// rebuilds every index and cross-relation uniqueness constraint with the
+// current encoding, records the new fingerprint and closes it again. Use it
+// when OpenDB fails with ErrEncodingChanged.
+func Reindex(maUn MarshalUnmarshaler, path string, mode os.FileMode, options *boltdb.Options) error {
+	bdb, err := boltdb.Open(path, mode, options)
+	if err != nil {
+		return err
+	}
+	defer bdb.Close()
+	d := &DB{backend: NewBoltBackend(bdb), maUn: maUn, stats: newPlannerStats(), clock: SystemClock}
+	err = d.update(func(tx *Tx) error {
+		if err := tx.ForEachRelation(func(p *Persistent) error {
+			return p.rebuildIndexes()
+		}); err != nil {
+			return err
+		}
+		return tx.rebuildSharedUniques()
+	})
+	if err != nil {
+		return err
+	}
+	fingerprint, err := encodingFingerprint()
+	if err != nil {
+		return err
+	}
+	return d.saveEncoding(fingerprint)
+}
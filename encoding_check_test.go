@@ -0,0 +1,89 @@
+package thunder
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/openkvlab/boltdb"
+)
+
+func TestOpenDB_EncodingChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enc.db")
+	db, err := OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("items", map[string]ColumnSpec{"n": {Indexed: true}})
+		if err != nil {
+			return err
+		}
+		for i := range 4 {
+			if err := p.Insert(map[string]any{"n": int64(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a database written by a binary with a different key layout.
+	bdb, err := boltdb.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bdb.Update(func(tx *boltdb.Tx) error {
+		return tx.Bucket([]byte(encodingBucket)).Put([]byte("fingerprint"), []byte("stale"))
+	})
+	bdb.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenDB(&MsgpackMaUn, path, 0600, nil)
+	var thunderErr *ThunderError
+	if !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeEncodingChanged {
+		t.Fatalf("expected ErrCodeEncodingChanged, got %v", err)
+	}
+	if err := Reindex(&MsgpackMaUn, path, 0600, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err = OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent("items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Ge("n", int64(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := p.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows after reindex, got %d", n)
+	}
+}
@@ -0,0 +1,149 @@
+package thunder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"maps"
+)
+
+// SetKey makes the encryption key with the given id available to the
+// transaction. Columns declared with that EncryptionKey can then be written,
+// and are decrypted on Select; without the key they read as Redacted. Keys
+// must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+func (tx *Tx) SetKey(id string, key []byte) error {
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+	if tx.keys == nil {
+		tx.keys = make(map[string][]byte)
+	}
+	tx.keys[id] = key
+	return nil
+}
+
+func (pr *Persistent) columnCipher(column string) (cipher.AEAD, bool, error) {
+	key, ok := pr.tx.keys[pr.fields[column].EncryptionKey]
+	if !ok {
+		return nil, false, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, false, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, false, err
+	}
+	return aead, true, nil
+}
+
+// encryptRow returns a copy of obj with its encrypted columns sealed. The
+// relation and column name are bound to the ciphertext so that values cannot
+// be moved between columns.
+func (pr *Persistent) encryptRow(obj map[string]any) (map[string]any, error) {
+	if !pr.encrypted {
+		return obj, nil
+	}
+	sealed := maps.Clone(obj)
+	for _, col := range pr.columns {
+		keyID := pr.fields[col].EncryptionKey
+		v, ok := obj[col]
+		if keyID == "" || !ok {
+			continue
+		}
+		aead, ok, err := pr.columnCipher(col)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrKeyNotFound(keyID)
+		}
+		plain, err := pr.maUn.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed[col] = aead.Seal(nonce, nonce, plain, []byte(pr.relation+"\x00"+col))
+	}
+	return sealed, nil
+}
+
+// decryptRow opens the encrypted columns of a stored row in place, replacing
+// those whose key the transaction does not hold with Redacted.
+func (pr *Persistent) decryptRow(value map[string]any) error {
+	if !pr.encrypted {
+		return nil
+	}
+	for _, col := range pr.columns {
+		if pr.fields[col].EncryptionKey == "" {
+			continue
+		}
+		var sealed []byte
+		switch v := value[col].(type) {
+		case []byte:
+			sealed = v
+		case string:
+			// Codecs without a binary type, such as JSON, store bytes as
+			// base64 text.
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return ErrDecryptionFailed(col)
+			}
+			sealed = b
+		default:
+			continue
+		}
+		aead, ok, err := pr.columnCipher(col)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			value[col] = Redacted
+			continue
+		}
+		if len(sealed) < aead.NonceSize() {
+			return ErrDecryptionFailed(col)
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, ciphertext, []byte(pr.relation+"\x00"+col))
+		if err != nil {
+			return ErrDecryptionFailed(col)
+		}
+		var v any
+		if err := pr.maUn.Unmarshal(plain, &v); err != nil {
+			return err
+		}
+		value[col] = v
+	}
+	return nil
+}
+
+func hasEncryptedColumns(specs map[string]ColumnSpec) bool {
+	for _, spec := range specs {
+		if spec.EncryptionKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEncryptedRanges rejects filters on encrypted columns, paths into them
+// included, whose stored ciphertexts have no meaningful order.
+func (pr *Persistent) checkEncryptedRanges(ranges map[string]*keyRange) error {
+	if !pr.encrypted {
+		return nil
+	}
+	for name := range ranges {
+		for _, col := range pr.filteredColumns(name) {
+			if pr.fields[col].EncryptionKey != "" {
+				return ErrColumnEncrypted(col)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package thunder
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPersistent_EncryptedColumn(t *testing.T) {
+	for name, maUn := range map[string]MarshalUnmarshaler{"msgpack": &MsgpackMaUn, "json": &JsonMaUn, "cbor": &CborMaUn} {
+		t.Run(name, func(t *testing.T) {
+			db, cleanup := setupTestDBWithMaUn(t, maUn)
+			defer cleanup()
+			key := bytes.Repeat([]byte{7}, 32)
+
+			tx, err := db.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			people, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+				"name": {Indexed: true},
+				"ssn":  {EncryptionKey: "pii", Type: TypeString},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			row := map[string]any{"name": "alice", "ssn": "123-45-6789"}
+			var thunderErr *ThunderError
+			if err := people.Insert(row); !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeKeyNotFound {
+				t.Fatalf("expected ErrCodeKeyNotFound without the key, got %v", err)
+			}
+			if err := tx.SetKey("pii", key); err != nil {
+				t.Fatal(err)
+			}
+			if err := people.Insert(row); err != nil {
+				t.Fatal(err)
+			}
+			if row["ssn"] != "123-45-6789" {
+				t.Fatalf("Insert modified the caller's row: %v", row)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			read := func(withKey bool) map[string]any {
+				t.Helper()
+				tx, err := db.Begin(false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer tx.Rollback()
+				if withKey {
+					if err := tx.SetKey("pii", key); err != nil {
+						t.Fatal(err)
+					}
+				}
+				people, err := tx.LoadPersistent("people")
+				if err != nil {
+					t.Fatal(err)
+				}
+				seq, err := people.Select(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for row, err := range seq {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return row
+				}
+				t.Fatal("no rows")
+				return nil
+			}
+			if got := read(true)["ssn"]; got != "123-45-6789" {
+				t.Fatalf("expected decrypted ssn, got %v", got)
+			}
+			if got := read(false)["ssn"]; got != Redacted {
+				t.Fatalf("expected redacted ssn without the key, got %v", got)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package thunder
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 const (
 	ErrCodeFieldCountMismatch = iota
@@ -14,6 +18,50 @@ const (
 	ErrCodeMetaDataNotFound
 	ErrCodeCorruptedIndexEntry
 	ErrCodeCorruptedMetaDataEntry
+	ErrCodeIndexAlreadyExists
+	ErrCodeRelationNotFound
+	ErrCodeRelationAlreadyExists
+	ErrCodeTypeMismatch
+	ErrCodeInvalidMigration
+	ErrCodeCapacityExceeded
+	ErrCodeChangeTrackingDisabled
+	ErrCodeEncodingChanged
+	ErrCodeSnapshotReleased
+	ErrCodeColumnMasked
+	ErrCodeColumnEncrypted
+	ErrCodeKeyNotFound
+	ErrCodeDecryptionFailed
+	ErrCodeBackendUnsupported
+	ErrCodeBatcherClosed
+	ErrCodeEventLogDisabled
+	ErrCodeEventsExpired
+	ErrCodeHistoryDisabled
+	ErrCodeVersionConflict
+	ErrCodeInvalidVersionColumn
+	ErrCodeQuerySyntax
+	ErrCodeReplicaReadOnly
+	ErrCodeReplicationGap
+	ErrCodeSavepointReleased
+	ErrCodeGeneratorNotFound
+	ErrCodeValidationFailed
+	ErrCodeBlobNotFound
+	ErrCodeQueryTimeout
+	ErrCodeQueryBudgetExceeded
+	ErrCodeQuotaExceeded
+	ErrCodeCodecNotFound
+	ErrCodeColumnEncoded
+	ErrCodeUnknownCodec
+	ErrCodeRecodeInProgress
+	ErrCodeLogNotFound
+	ErrCodeLogAlreadyExists
+	ErrCodeHistoryExpired
+	ErrCodeJobNotFound
+	ErrCodeAsyncUniqueIndex
+	ErrCodeReadOnly
+	ErrCodeSameDatabase
+	ErrCodePolicyContext
+	ErrCodePolicyViolation
+	ErrCodeNoShards
 )
 
 type ThunderError struct {
@@ -94,3 +142,319 @@ func ErrCorruptedMetaDataEntry(relation, metaName string) error {
 		Message: fmt.Sprintf("corrupted meta data entry %s in relation %s", metaName, relation),
 	}
 }
+
+func ErrIndexAlreadyExists(indexName string) error {
+	return &ThunderError{
+		Code:    ErrCodeIndexAlreadyExists,
+		Message: fmt.Sprintf("index already exists: %s", indexName),
+	}
+}
+
+func ErrRelationNotFound(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodeRelationNotFound,
+		Message: fmt.Sprintf("relation not found: %s", relation),
+	}
+}
+
+func ErrRelationAlreadyExists(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodeRelationAlreadyExists,
+		Message: fmt.Sprintf("relation already exists: %s", relation),
+	}
+}
+
+func ErrTypeMismatch(column string, expected ColumnType, got any) error {
+	return &ThunderError{
+		Code:    ErrCodeTypeMismatch,
+		Message: fmt.Sprintf("type mismatch on column %s: expected %s, got %T", column, expected, got),
+	}
+}
+
+func ErrInvalidMigration(relation string, version uint64) error {
+	return &ThunderError{
+		Code:    ErrCodeInvalidMigration,
+		Message: fmt.Sprintf("invalid or duplicate migration version %d for relation %s", version, relation),
+	}
+}
+
+func ErrCapacityExceeded(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodeCapacityExceeded,
+		Message: fmt.Sprintf("capacity exceeded for relation %s", relation),
+	}
+}
+
+func ErrChangeTrackingDisabled() error {
+	return &ThunderError{
+		Code:    ErrCodeChangeTrackingDisabled,
+		Message: "change tracking is not enabled",
+	}
+}
+
+func ErrEncodingChanged() error {
+	return &ThunderError{
+		Code:    ErrCodeEncodingChanged,
+		Message: "index key encoding differs from the one the database was written with; run Reindex",
+	}
+}
+
+func ErrSnapshotReleased() error {
+	return &ThunderError{
+		Code:    ErrCodeSnapshotReleased,
+		Message: "snapshot has been released",
+	}
+}
+
+func ErrColumnMasked(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeColumnMasked,
+		Message: fmt.Sprintf("column %s is masked", column),
+	}
+}
+
+func ErrColumnEncrypted(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeColumnEncrypted,
+		Message: fmt.Sprintf("column %s is encrypted and cannot be indexed or filtered on", column),
+	}
+}
+
+func ErrKeyNotFound(keyID string) error {
+	return &ThunderError{
+		Code:    ErrCodeKeyNotFound,
+		Message: fmt.Sprintf("encryption key %s not available", keyID),
+	}
+}
+
+func ErrDecryptionFailed(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeDecryptionFailed,
+		Message: fmt.Sprintf("failed to decrypt column %s", column),
+	}
+}
+
+func ErrBackendUnsupported(feature string) error {
+	return &ThunderError{
+		Code:    ErrCodeBackendUnsupported,
+		Message: fmt.Sprintf("storage backend does not support %s", feature),
+	}
+}
+
+func ErrBatcherClosed() error {
+	return &ThunderError{
+		Code:    ErrCodeBatcherClosed,
+		Message: "batcher is closed",
+	}
+}
+
+func ErrEventLogDisabled() error {
+	return &ThunderError{
+		Code:    ErrCodeEventLogDisabled,
+		Message: "event log is not enabled",
+	}
+}
+
+func ErrEventsExpired(after uint64) error {
+	return &ThunderError{
+		Code:    ErrCodeEventsExpired,
+		Message: fmt.Sprintf("events after %d are no longer in the event log", after),
+	}
+}
+
+func ErrHistoryDisabled(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodeHistoryDisabled,
+		Message: fmt.Sprintf("history is not enabled for relation %s", relation),
+	}
+}
+
+func ErrVersionConflict(relation string, expected, actual any) error {
+	return &ThunderError{
+		Code:    ErrCodeVersionConflict,
+		Message: fmt.Sprintf("version conflict in relation %s: expected version %v, found %v", relation, expected, actual),
+	}
+}
+
+func ErrInvalidVersionColumn(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeInvalidVersionColumn,
+		Message: fmt.Sprintf("column %s cannot be the version column", column),
+	}
+}
+
+func ErrQuerySyntax(pos int, msg string) error {
+	return &ThunderError{
+		Code:    ErrCodeQuerySyntax,
+		Message: fmt.Sprintf("query syntax error at offset %d: %s", pos, msg),
+	}
+}
+
+func ErrReplicaReadOnly() error {
+	return &ThunderError{
+		Code:    ErrCodeReplicaReadOnly,
+		Message: "the database is following a leader and cannot be written to",
+	}
+}
+
+func ErrReplicationGap(from, seq uint64) error {
+	return &ThunderError{
+		Code:    ErrCodeReplicationGap,
+		Message: fmt.Sprintf("replication batch starts at sequence %d but the follower is at %d", from, seq),
+	}
+}
+
+func ErrSavepointReleased() error {
+	return &ThunderError{
+		Code:    ErrCodeSavepointReleased,
+		Message: "savepoint has been released or rolled back past",
+	}
+}
+
+func ErrGeneratorNotFound(column, name string) error {
+	return &ThunderError{
+		Code:    ErrCodeGeneratorNotFound,
+		Message: fmt.Sprintf("generator %s of column %s is not registered", name, column),
+	}
+}
+
+func ErrValidationFailed(relation string, violations []Violation) error {
+	msgs := make([]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = fmt.Sprintf("%s: %v", v.Column, v.Err)
+	}
+	return &ValidationError{
+		ThunderError: ThunderError{
+			Code:    ErrCodeValidationFailed,
+			Message: fmt.Sprintf("validation failed for relation %s: %s", relation, strings.Join(msgs, "; ")),
+		},
+		Relation:   relation,
+		Violations: violations,
+	}
+}
+
+func ErrBlobNotFound(relation string, ref any) error {
+	return &ThunderError{
+		Code:    ErrCodeBlobNotFound,
+		Message: fmt.Sprintf("blob %v not found in relation %s", ref, relation),
+	}
+}
+
+func ErrQueryTimeout(relation string, timeout time.Duration) error {
+	return &ThunderError{
+		Code:    ErrCodeQueryTimeout,
+		Message: fmt.Sprintf("query on relation %s ran longer than %v", relation, timeout),
+	}
+}
+
+func ErrQueryBudgetExceeded(relation, what string, limit int) error {
+	return &ThunderError{
+		Code:    ErrCodeQueryBudgetExceeded,
+		Message: fmt.Sprintf("query on relation %s exceeded %d %s", relation, limit, what),
+	}
+}
+
+func ErrQuotaExceeded(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodeQuotaExceeded,
+		Message: fmt.Sprintf("quota exceeded for relation %s", relation),
+	}
+}
+
+func ErrCodecNotFound(column, name string) error {
+	return &ThunderError{
+		Code:    ErrCodeCodecNotFound,
+		Message: fmt.Sprintf("codec %s of column %s is not registered", name, column),
+	}
+}
+
+func ErrColumnEncoded(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeColumnEncoded,
+		Message: fmt.Sprintf("column %s is stored with a codec and cannot be indexed or filtered on", column),
+	}
+}
+
+func ErrUnknownCodec(codec string) error {
+	return &ThunderError{
+		Code:    ErrCodeUnknownCodec,
+		Message: fmt.Sprintf("codec %s is neither built in nor registered with SetCodec", codec),
+	}
+}
+
+func ErrRecodeInProgress(relation, codec string) error {
+	return &ThunderError{
+		Code:    ErrCodeRecodeInProgress,
+		Message: fmt.Sprintf("relation %s is being recoded to %s", relation, codec),
+	}
+}
+
+func ErrLogNotFound(name string) error {
+	return &ThunderError{
+		Code:    ErrCodeLogNotFound,
+		Message: fmt.Sprintf("log not found: %s", name),
+	}
+}
+
+func ErrLogAlreadyExists(name string) error {
+	return &ThunderError{
+		Code:    ErrCodeLogAlreadyExists,
+		Message: fmt.Sprintf("log already exists: %s", name),
+	}
+}
+
+func ErrHistoryExpired(relation string, at time.Time) error {
+	return &ThunderError{
+		Code:    ErrCodeHistoryExpired,
+		Message: fmt.Sprintf("history of relation %s before %s has been collected", relation, at.Format(time.RFC3339Nano)),
+	}
+}
+
+func ErrJobNotFound(name string) error {
+	return &ThunderError{
+		Code:    ErrCodeJobNotFound,
+		Message: fmt.Sprintf("maintenance job not found: %s", name),
+	}
+}
+
+func ErrAsyncUniqueIndex(column string) error {
+	return &ThunderError{
+		Code:    ErrCodeAsyncUniqueIndex,
+		Message: fmt.Sprintf("unique index %s cannot be maintained asynchronously", column),
+	}
+}
+
+func ErrReadOnly() error {
+	return &ThunderError{
+		Code:    ErrCodeReadOnly,
+		Message: "cannot write: the database or transaction is read-only",
+	}
+}
+
+func ErrSameDatabase() error {
+	return &ThunderError{
+		Code:    ErrCodeSameDatabase,
+		Message: "source and destination are the same database",
+	}
+}
+
+func ErrPolicyContext(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodePolicyContext,
+		Message: fmt.Sprintf("relation %s has a row policy and the transaction has no context", relation),
+	}
+}
+
+func ErrPolicyViolation(relation string) error {
+	return &ThunderError{
+		Code:    ErrCodePolicyViolation,
+		Message: fmt.Sprintf("row would fall outside the row policy of relation %s", relation),
+	}
+}
+
+func ErrNoShards() error {
+	return &ThunderError{
+		Code:    ErrCodeNoShards,
+		Message: "a sharded relation needs at least one shard",
+	}
+}
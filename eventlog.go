@@ -0,0 +1,210 @@
+package thunder
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"iter"
+	"maps"
+)
+
+// The event log lives in feedBucket:
+//   - "log": event sequence -> encoded ChangeEvent, oldest first
+//   - "limit", "count": the retention bound and the number of logged events
+//   - "trimmed": the highest sequence no longer in the log
+//   - "consumers": consumer name -> last acknowledged sequence
+
+// EnableEventLog keeps the change events of every relation in a durable log
+// holding at most maxEvents events, dropping the oldest beyond that, so that
+// WatchFrom can replay what a consumer missed while it was offline. Calling
+// it again changes the bound. Encrypted columns are logged as Redacted.
+func (d *DB) EnableEventLog(maxEvents int) error {
+	if maxEvents <= 0 {
+		maxEvents = 1
+	}
+	return d.update(func(tx *Tx) error {
+		feed, err := tx.tx.CreateBucketIfNotExists([]byte(feedBucket))
+		if err != nil {
+			return err
+		}
+		if feed.Bucket([]byte("log")) == nil {
+			if _, err := feed.CreateBucket([]byte("log")); err != nil {
+				return err
+			}
+			// Events numbered before the log existed cannot be replayed.
+			if err := putUint64(feed, "trimmed", feed.Sequence()); err != nil {
+				return err
+			}
+		}
+		if err := putUint64(feed, "limit", uint64(maxEvents)); err != nil {
+			return err
+		}
+		return trimEventLog(feed)
+	})
+}
+
+// AckEvents records that consumer has processed every event up to seq.
+func (d *DB) AckEvents(consumer string, seq uint64) error {
+	return d.update(func(tx *Tx) error {
+		feed := tx.tx.Bucket([]byte(feedBucket))
+		if feed == nil || feed.Bucket([]byte("log")) == nil {
+			return ErrEventLogDisabled()
+		}
+		consumers, err := feed.CreateBucketIfNotExists([]byte("consumers"))
+		if err != nil {
+			return err
+		}
+		return putUint64(consumers, consumer, seq)
+	})
+}
+
+// EventOffset returns the sequence last acknowledged by consumer, or zero.
+func (d *DB) EventOffset(consumer string) (uint64, error) {
+	var seq uint64
+	err := d.view(func(tx *Tx) error {
+		feed := tx.tx.Bucket([]byte(feedBucket))
+		if feed == nil || feed.Bucket([]byte("log")) == nil {
+			return ErrEventLogDisabled()
+		}
+		if consumers := feed.Bucket([]byte("consumers")); consumers != nil {
+			seq = getUint64(consumers, consumer)
+		}
+		return nil
+	})
+	return seq, err
+}
+
+// WatchFrom is like Watch, but first replays the logged events of the
+// relation with a sequence after after. It fails with ErrEventsExpired when
+// some of those events have already been dropped from the log.
+func (pr *Persistent) WatchFrom(ctx context.Context, after uint64) iter.Seq2[ChangeEvent, error] {
+	db, relation := pr.tx.db, pr.relation
+	return func(yield func(ChangeEvent, error) bool) {
+		yield, err := pr.eventFilter(ctx, yield)
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		// Subscribe before reading the log so nothing committed in between
+		// is missed; events seen in both are skipped below.
+		sub := db.feed.subscribe(relation)
+		defer db.feed.unsubscribe(relation, sub)
+		var logged []ChangeEvent
+		err = db.view(func(tx *Tx) error {
+			var err error
+			logged, err = tx.loggedEvents(relation, after)
+			return err
+		})
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		for _, ev := range logged {
+			if !yield(ev, nil) {
+				return
+			}
+			after = ev.Seq
+		}
+		db.feed.follow(ctx, sub, after, yield)
+	}
+}
+
+func (tx *Tx) eventLogEnabled() bool {
+	feed := tx.tx.Bucket([]byte(feedBucket))
+	return feed != nil && feed.Bucket([]byte("log")) != nil
+}
+
+func (tx *Tx) loggedEvents(relation string, after uint64) ([]ChangeEvent, error) {
+	feed := tx.tx.Bucket([]byte(feedBucket))
+	if feed == nil || feed.Bucket([]byte("log")) == nil {
+		return nil, ErrEventLogDisabled()
+	}
+	if after < getUint64(feed, "trimmed") {
+		return nil, ErrEventsExpired(after)
+	}
+	var events []ChangeEvent
+	c := feed.Bucket([]byte("log")).Cursor()
+	for k, v := c.Seek(binary.BigEndian.AppendUint64(nil, after+1)); k != nil; k, v = c.Next() {
+		var ev ChangeEvent
+		if err := tx.maUn.Unmarshal(v, &ev); err != nil {
+			return nil, ErrCorruptedMetaDataEntry(feedBucket, "log")
+		}
+		if ev.Relation == relation {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// logEvent appends ev to the event log if it is enabled.
+func (pr *Persistent) logEvent(feed BackendBucket, ev ChangeEvent) error {
+	log := feed.Bucket([]byte("log"))
+	if log == nil {
+		return nil
+	}
+	if pr.encrypted {
+		ev.Before, ev.After = pr.redactEncrypted(ev.Before), pr.redactEncrypted(ev.After)
+	}
+	// The log is shared by every relation and read back with the codec of
+	// the database, whatever codec this relation is stored in.
+	raw, err := pr.tx.maUn.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if err := log.Put(binary.BigEndian.AppendUint64(nil, ev.Seq), raw); err != nil {
+		return err
+	}
+	if err := putUint64(feed, "count", getUint64(feed, "count")+1); err != nil {
+		return err
+	}
+	return trimEventLog(feed)
+}
+
+func (pr *Persistent) redactEncrypted(row map[string]any) map[string]any {
+	if row == nil {
+		return nil
+	}
+	row = maps.Clone(row)
+	for col, spec := range pr.fields {
+		if _, ok := row[col]; ok && spec.EncryptionKey != "" {
+			row[col] = Redacted
+		}
+	}
+	return row
+}
+
+// trimEventLog drops the oldest events until the log is within its limit.
+func trimEventLog(feed BackendBucket) error {
+	log := feed.Bucket([]byte("log"))
+	count, limit := getUint64(feed, "count"), getUint64(feed, "limit")
+	if count <= limit {
+		return nil
+	}
+	c := log.Cursor()
+	var dropped [][]byte
+	for k, _ := c.First(); k != nil && count > limit; k, _ = c.Next() {
+		dropped = append(dropped, bytes.Clone(k))
+		count--
+	}
+	for _, k := range dropped {
+		if err := log.Delete(k); err != nil {
+			return err
+		}
+	}
+	if err := putUint64(feed, "trimmed", binary.BigEndian.Uint64(dropped[len(dropped)-1])); err != nil {
+		return err
+	}
+	return putUint64(feed, "count", count)
+}
+
+func putUint64(bucket BackendBucket, key string, v uint64) error {
+	return bucket.Put([]byte(key), binary.BigEndian.AppendUint64(nil, v))
+}
+
+func getUint64(bucket BackendBucket, key string) uint64 {
+	raw := bucket.Get([]byte(key))
+	if len(raw) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(raw)
+}
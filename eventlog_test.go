@@ -0,0 +1,176 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_EventLogResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	db, err := OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.EnableEventLog(4); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(db *DB, ids ...int64) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("jobs")
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := p.Insert(map[string]any{"id": id}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("jobs", map[string]ColumnSpec{"id": {Unique: true}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert(db, 1, 2)
+	if err := db.AckEvents("indexer", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	offset, err := db.EventOffset("indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 1 {
+		t.Fatalf("expected offset 1, got %d", offset)
+	}
+	var jobs *Persistent
+	err = db.view(func(tx *Tx) error {
+		jobs, err = tx.LoadPersistent("jobs")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	live := make(chan error, 1)
+	var ids []any
+	for ev, err := range jobs.WatchFrom(ctx, offset) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, ev.After["id"])
+		if len(ids) == 1 {
+			// Live events follow the replayed ones.
+			go func() {
+				live <- db.update(func(tx *Tx) error {
+					p, err := tx.LoadPersistent("jobs")
+					if err != nil {
+						return err
+					}
+					return p.Insert(map[string]any{"id": int64(3)})
+				})
+			}()
+		}
+		if len(ids) == 2 {
+			break
+		}
+	}
+	if err := <-live; err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != int64(2) || ids[1] != int64(3) {
+		t.Fatalf("unexpected replay %v", ids)
+	}
+
+	insert(db, 4, 5, 6)
+	var thErr *ThunderError
+	for _, err := range jobs.WatchFrom(context.Background(), offset) {
+		if !errors.As(err, &thErr) || thErr.Code != ErrCodeEventsExpired {
+			t.Fatalf("expected ErrEventsExpired, got %v", err)
+		}
+		break
+	}
+}
+
+func TestDB_EventLogRecoded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	db, err := OpenDB(&JsonMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+	if err := db.EnableEventLog(10); err != nil {
+		t.Fatal(err)
+	}
+	specs := map[string]ColumnSpec{"id": {Unique: true}}
+	err = db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("jobs", specs)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Recode("jobs", &MsgpackMaUn, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenDB(&JsonMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Creating the recoded relation again keeps its codec.
+	err = db.update(func(tx *Tx) error {
+		jobs, err := tx.CreatePersistent("jobs", specs)
+		if err != nil {
+			return err
+		}
+		return jobs.Insert(map[string]any{"id": "a"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobs *Persistent
+	err = db.view(func(tx *Tx) error {
+		jobs, err = tx.LoadPersistent("jobs")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ev, err := range jobs.WatchFrom(context.Background(), 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev.After["id"] != "a" {
+			t.Fatalf("unexpected replay %v", ev)
+		}
+		break
+	}
+}
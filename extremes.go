@@ -0,0 +1,84 @@
+package thunder
+
+import (
+	"bytes"
+	"slices"
+)
+
+// First returns the first row matching every op in the order of the index
+// the query is planned on, or of insertion when the relation is scanned. It
+// stops reading after that row. It returns nil when no row matches.
+func (pr *Persistent) First(ops ...Op) (map[string]any, error) {
+	return pr.firstWith(ops)
+}
+
+// Last is First walking backwards, returning the last matching row.
+func (pr *Persistent) Last(ops ...Op) (map[string]any, error) {
+	return pr.firstWith(ops, Descending())
+}
+
+// MinOf returns the row matching every op with the smallest value of
+// column, or nil when no row matches. On an indexed column it reads the
+// index from its start up to the first matching row; otherwise every
+// matching row is compared.
+func (pr *Persistent) MinOf(column string, ops ...Op) (map[string]any, error) {
+	return pr.extremeOf(column, ops, false)
+}
+
+// MaxOf is MinOf for the largest value of column.
+func (pr *Persistent) MaxOf(column string, ops ...Op) (map[string]any, error) {
+	return pr.extremeOf(column, ops, true)
+}
+
+func (pr *Persistent) firstWith(ops []Op, opts ...QueryOption) (map[string]any, error) {
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := pr.SelectWith(ranges, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for row, err := range seq {
+		return row, err
+	}
+	return nil, nil
+}
+
+func (pr *Persistent) extremeOf(column string, ops []Op, largest bool) (map[string]any, error) {
+	spec, ok := pr.fields[column]
+	if !ok {
+		return nil, ErrFieldNotFound(column)
+	}
+	if _, building := pr.pending[column]; slices.Contains(pr.indexNames, column) && !building && !spec.MultiEntry {
+		opts := []QueryOption{UseIndex(column)}
+		if largest {
+			opts = append(opts, Descending())
+		}
+		return pr.firstWith(ops, opts...)
+	}
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := pr.Select(ranges)
+	if err != nil {
+		return nil, err
+	}
+	var best map[string]any
+	var bestKey []byte
+	for row, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		key, err := pr.computeKey(row, column)
+		if err != nil {
+			return nil, err
+		}
+		cmp := bytes.Compare(key, bestKey)
+		if best == nil || (largest && cmp > 0) || (!largest && cmp < 0) {
+			best, bestKey = row, key
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,59 @@
+package thunder
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPersistent_FirstLastMinMax(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	scores, err := tx.CreatePersistent("scores", map[string]ColumnSpec{
+		"user":   {Indexed: true},
+		"points": {Indexed: true, Type: TypeInt},
+		"round":  {Type: TypeInt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, points := range []int{7, 3, 9, 5} {
+		user := "ann"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		if err := scores.Insert(map[string]any{"user": user, "points": points, "round": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(name string, row map[string]any, err error, round int) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if row == nil || fmt.Sprint(row["round"]) != fmt.Sprint(round) {
+			t.Fatalf("%s: expected round %d, got %v", name, round, row)
+		}
+	}
+	row, err := scores.First(Eq("user", "ann"))
+	check("First", row, err, 0)
+	row, err = scores.Last(Eq("user", "bob"))
+	check("Last", row, err, 3)
+	row, err = scores.MinOf("points", Eq("user", "ann"))
+	check("MinOf", row, err, 0)
+	row, err = scores.MaxOf("points")
+	check("MaxOf", row, err, 2)
+	// round is not indexed, so every matching row is compared.
+	row, err = scores.MaxOf("round", Eq("user", "bob"))
+	check("MaxOf unindexed", row, err, 3)
+	if row, err := scores.First(Eq("user", "cat")); err != nil || row != nil {
+		t.Fatalf("expected no row, got %v, %v", row, err)
+	}
+	if _, err := scores.MinOf("missing"); err == nil {
+		t.Fatal("expected error for an unknown column")
+	}
+}
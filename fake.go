@@ -0,0 +1,106 @@
+package thunder
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// Relation is a named Selector over stored rows. *Persistent implements it,
+// and so does FakeRelation, so code written against Relation can be unit
+// tested without opening a database.
+type Relation interface {
+	Selector
+	Name() string
+	Count() (int, error)
+}
+
+var (
+	_ Relation = (*Persistent)(nil)
+	_ Relation = (*FakeRelation)(nil)
+)
+
+// FakeRelation is an in-memory Relation for tests. It filters rows with the
+// same key ordering as a stored relation and can be projected and joined
+// with other selectors, persistent ones included.
+type FakeRelation struct {
+	name        string
+	columns     []string
+	rows        []map[string]any
+	parentsList []*queryParent
+}
+
+// NewFakeRelation returns a FakeRelation named name with the given columns,
+// holding copies of rows.
+func NewFakeRelation(name string, columns []string, rows ...map[string]any) *FakeRelation {
+	f := &FakeRelation{name: name, columns: slices.Clone(columns)}
+	for _, row := range rows {
+		f.Insert(row)
+	}
+	return f
+}
+
+// Insert appends a copy of row to the relation.
+func (f *FakeRelation) Insert(row map[string]any) {
+	f.rows = append(f.rows, maps.Clone(row))
+}
+
+func (f *FakeRelation) Name() string {
+	return f.name
+}
+
+func (f *FakeRelation) Columns() []string {
+	return f.columns
+}
+
+func (f *FakeRelation) Count() (int, error) {
+	return len(f.rows), nil
+}
+
+func (f *FakeRelation) IsRecursive() bool {
+	return false
+}
+
+func (f *FakeRelation) addParent(parent *queryParent) {
+	f.parentsList = append(f.parentsList, parent)
+}
+
+func (f *FakeRelation) parents() []*queryParent {
+	return f.parentsList
+}
+
+func (f *FakeRelation) Project(mapping map[string]string) Selector {
+	return newProjection(f, mapping)
+}
+
+func (f *FakeRelation) Join(bodies ...Selector) Selector {
+	linkedBodies := make([]linkedSelector, len(bodies)+1)
+	linkedBodies[0] = f
+	for i, body := range bodies {
+		linkedBodies[i+1] = body.(linkedSelector)
+	}
+	return newJoining(linkedBodies)
+}
+
+func (f *FakeRelation) Select(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	for name := range ranges {
+		if !slices.Contains(f.columns, name) {
+			return nil, ErrFieldNotFound(name)
+		}
+	}
+	rows := slices.Clone(f.rows)
+	return func(yield func(map[string]any, error) bool) {
+		for _, row := range rows {
+			ok, err := rowMatches(row, ranges)
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if ok && !yield(maps.Clone(row), nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,98 @@
+package thunder
+
+import "testing"
+
+// adultNames is the kind of code a caller would unit test against Relation.
+func adultNames(r Relation) ([]any, error) {
+	ranges, err := ToKeyRanges(Ge("age", 18))
+	if err != nil {
+		return nil, err
+	}
+	seq, err := r.Select(ranges)
+	if err != nil {
+		return nil, err
+	}
+	var names []any
+	for row, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, row["name"])
+	}
+	return names, nil
+}
+
+func TestFakeRelation(t *testing.T) {
+	users := NewFakeRelation("users", []string{"name", "age", "team"},
+		map[string]any{"name": "ann", "age": 31, "team": "a"},
+		map[string]any{"name": "bob", "age": 12, "team": "b"},
+	)
+	users.Insert(map[string]any{"name": "cat", "age": 45, "team": "b"})
+	names, err := adultNames(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "ann" || names[1] != "cat" {
+		t.Fatalf("expected ann and cat, got %v", names)
+	}
+	if n, _ := users.Count(); n != 3 {
+		t.Fatalf("expected 3 rows, got %d", n)
+	}
+
+	teams := NewFakeRelation("teams", []string{"team", "floor"},
+		map[string]any{"team": "b", "floor": 2},
+	)
+	ranges, err := ToKeyRanges(Eq("name", "cat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := users.Join(teams).Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["floor"] != 2 {
+		t.Fatalf("expected cat on floor 2, got %v", rows)
+	}
+	if ranges, err = ToKeyRanges(Eq("who", "cat")); err != nil {
+		t.Fatal(err)
+	}
+	seq, err = users.Project(map[string]string{"who": "name"}).Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, err := range seq {
+		if err != nil || row["who"] != "cat" {
+			t.Fatalf("expected projected cat, got %v, %v", row, err)
+		}
+	}
+	tagged := NewFakeRelation("posts", []string{"id", "tags"},
+		map[string]any{"id": 1, "tags": []any{"go", "db"}},
+		map[string]any{"id": 2, "tags": []any{"rust"}},
+	)
+	if ranges, err = ToKeyRanges(Contains("tags", "go")); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err = tagged.Select(ranges); err != nil {
+		t.Fatal(err)
+	}
+	rows = rows[:0]
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["id"] != 1 {
+		t.Fatalf("expected only the post tagged go, got %v", rows)
+	}
+	if _, err := users.Select(map[string]*keyRange{"missing": nil}); err == nil {
+		t.Fatal("expected error selecting an unknown column")
+	}
+}
@@ -0,0 +1,106 @@
+package thunder
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// ColumnGenerator produces the value of a column for the i-th generated row.
+type ColumnGenerator func(r *rand.Rand, i int) any
+
+// Generate inserts rows synthetic rows into the relation. Every stored column
+// must have a generator. The same seed always produces the same data, so
+// benchmarks can be repeated on identical shapes.
+func (pr *Persistent) Generate(rows int, generators map[string]ColumnGenerator, seed uint64) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	for _, col := range pr.columns {
+		if _, ok := generators[col]; !ok {
+			return ErrFieldNotFound(col)
+		}
+	}
+	// Draw columns in a fixed order so a seed always yields the same rows.
+	columns := slices.Sorted(slices.Values(pr.columns))
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	for i := range rows {
+		row := make(map[string]any, len(columns))
+		for _, col := range columns {
+			row[col] = generators[col](r, i)
+		}
+		if err := pr.Insert(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenSequential yields start, start+1, ... in row order; useful for ids.
+func GenSequential(start int64) ColumnGenerator {
+	return func(_ *rand.Rand, i int) any {
+		return start + int64(i)
+	}
+}
+
+// GenUniformInt yields integers uniformly distributed in [min, max].
+func GenUniformInt(min, max int64) ColumnGenerator {
+	return func(r *rand.Rand, _ int) any {
+		return min + r.Int64N(max-min+1)
+	}
+}
+
+// GenUniformFloat yields floats uniformly distributed in [min, max).
+func GenUniformFloat(min, max float64) ColumnGenerator {
+	return func(r *rand.Rand, _ int) any {
+		return min + r.Float64()*(max-min)
+	}
+}
+
+// GenNormal yields normally distributed floats.
+func GenNormal(mean, stddev float64) ColumnGenerator {
+	return func(r *rand.Rand, _ int) any {
+		return mean + r.NormFloat64()*stddev
+	}
+}
+
+// GenZipf yields integers in [0, n) following a Zipf distribution with
+// exponent s > 1, modelling skewed columns where a few values dominate.
+func GenZipf(s float64, n uint64) ColumnGenerator {
+	var zipf *rand.Zipf
+	var source *rand.Rand
+	return func(r *rand.Rand, _ int) any {
+		if source != r {
+			zipf = rand.NewZipf(r, s, 1, n-1)
+			source = r
+		}
+		return int64(zipf.Uint64())
+	}
+}
+
+// GenOneOf picks uniformly among values.
+func GenOneOf(values ...any) ColumnGenerator {
+	return func(r *rand.Rand, _ int) any {
+		return values[r.IntN(len(values))]
+	}
+}
+
+// GenCardinality yields strings with exactly cardinality distinct values
+// of the form prefix0, prefix1, ...
+func GenCardinality(prefix string, cardinality int) ColumnGenerator {
+	return func(r *rand.Rand, _ int) any {
+		return fmt.Sprintf("%s%d", prefix, r.IntN(cardinality))
+	}
+}
+
+// GenString yields random lowercase strings of the given length.
+func GenString(length int) ColumnGenerator {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	return func(r *rand.Rand, _ int) any {
+		b := make([]byte, length)
+		for i := range b {
+			b[i] = letters[r.IntN(len(letters))]
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,78 @@
+package thunder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPersistent_Generate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	generators := map[string]ColumnGenerator{
+		"id":      GenSequential(1),
+		"country": GenCardinality("c", 4),
+		"score":   GenZipf(1.5, 100),
+		"price":   GenUniformFloat(1, 10),
+		"tier":    GenOneOf("free", "pro"),
+	}
+	collect := func(relation string) []map[string]any {
+		t.Helper()
+		p, err := tx.CreatePersistent(relation, map[string]ColumnSpec{
+			"id":      {Unique: true},
+			"country": {Indexed: true},
+			"score":   {},
+			"price":   {},
+			"tier":    {},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Generate(200, generators, 42); err != nil {
+			t.Fatal(err)
+		}
+		seq, err := p.Select(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []map[string]any
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+	first := collect("a")
+	second := collect("b")
+	if len(first) != 200 {
+		t.Fatalf("expected 200 rows, got %d", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Error("expected identical data for identical seeds")
+	}
+	countries := map[any]bool{}
+	for _, row := range first {
+		countries[row["country"]] = true
+		if score := row["score"]; score == nil {
+			t.Fatalf("missing score in %v", row)
+		}
+	}
+	if len(countries) > 4 {
+		t.Errorf("expected at most 4 distinct countries, got %d", len(countries))
+	}
+
+	p, err := tx.LoadPersistent("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Generate(1, map[string]ColumnGenerator{"id": GenSequential(0)}, 1); err == nil {
+		t.Error("expected error for missing generators")
+	}
+}
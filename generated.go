@@ -0,0 +1,56 @@
+package thunder
+
+import "maps"
+
+// Generator computes the value of a generated column from the other columns
+// of a row.
+type Generator func(row map[string]any) (any, error)
+
+// SetGenerator registers the generator that columns with Generated set to
+// name are computed with, replacing an earlier one. A nil fn removes it.
+func (d *DB) SetGenerator(name string, fn Generator) {
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	if fn == nil {
+		delete(d.generators, name)
+		return
+	}
+	if d.generators == nil {
+		d.generators = make(map[string]Generator)
+	}
+	d.generators[name] = fn
+}
+
+// applyGenerated returns obj with its generated columns computed, replacing
+// any value the caller set. obj itself is left untouched.
+func (pr *Persistent) applyGenerated(obj map[string]any) (map[string]any, error) {
+	filled, cloned := obj, false
+	for _, col := range pr.columns {
+		name := pr.fields[col].Generated
+		if name == "" {
+			continue
+		}
+		fn := pr.generator(name)
+		if fn == nil {
+			return nil, ErrGeneratorNotFound(col, name)
+		}
+		v, err := fn(obj)
+		if err != nil {
+			return nil, err
+		}
+		if !cloned {
+			filled, cloned = maps.Clone(obj), true
+		}
+		filled[col] = v
+	}
+	return filled, nil
+}
+
+func (pr *Persistent) generator(name string) Generator {
+	if pr.tx.db == nil {
+		return nil
+	}
+	pr.tx.db.hooksMu.RLock()
+	defer pr.tx.db.hooksMu.RUnlock()
+	return pr.tx.db.generators[name]
+}
@@ -0,0 +1,73 @@
+package thunder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPersistent_GeneratedColumn(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetGenerator("lower_email", func(row map[string]any) (any, error) {
+		return strings.ToLower(row["email"].(string)), nil
+	})
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":         {Unique: true},
+		"email":      {},
+		"email_norm": {Unique: true, Generated: "lower_email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "1", "email": "Ann@Example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	// The generated value is unique, whatever the case of the input.
+	if err := users.Insert(map[string]any{"id": "2", "email": "ANN@example.com"}); err == nil {
+		t.Fatal("expected a unique violation on the generated column")
+	}
+	id, err := ToKeyRanges(Eq("id", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Update(id, map[string]any{"email": "Ann@Example.org"}); err != nil {
+		t.Fatal(err)
+	}
+	norm, err := ToKeyRanges(Eq("email_norm", "ann@example.org"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := users.Select(norm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["id"] != "1" {
+			t.Fatalf("unexpected row %v", row)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("expected the updated row by its generated column, got %d rows", n)
+	}
+
+	db.SetGenerator("lower_email", nil)
+	var te *ThunderError
+	err = users.Insert(map[string]any{"id": "3", "email": "c@example.com"})
+	if !errors.As(err, &te) || te.Code != ErrCodeGeneratorNotFound {
+		t.Fatalf("expected generator not found, got %v", err)
+	}
+}
@@ -3,9 +3,22 @@ module github.com/longlodw/thunder
 go 1.25.3
 
 require (
-	github.com/openkvlab/boltdb v0.0.0-20251208110043-2c67ff523b74 // indirect
-	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/google/btree v1.1.3
+	github.com/openkvlab/boltdb v0.0.0-20251208110043-2c67ff523b74
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/text v0.41.0
+	google.golang.org/protobuf v1.36.12
+	gopkg.in/yaml.v3 v3.0.1
+	rsc.io/ordered v1.1.1
+)
+
+require (
+	github.com/kr/pretty v0.3.1 // indirect
+	github.com/rogpeppe/go-internal v1.14.1 // indirect
+	github.com/stretchr/testify v1.12.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
-	rsc.io/ordered v1.1.1 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
@@ -0,0 +1,156 @@
+package thunder
+
+import (
+	"iter"
+	"maps"
+)
+
+// Graph treats a relation of edges, each row linking the node in its from
+// column to the node in its to column, as a directed graph.
+type Graph struct {
+	edges *Persistent
+	from  string
+	to    string
+}
+
+// Visit is a node reached by Graph.Traverse or a Tree walk, Depth edges away
+// from the start. Row is the node's row in a Tree walk and nil otherwise.
+type Visit struct {
+	Node  any
+	Depth int
+	Row   map[string]any
+}
+
+// CreateGraph creates relation as an edge relation with the given from and
+// to columns, both indexed so that edges can be followed either way, plus
+// any extra columns for edge attributes.
+func (tx *Tx) CreateGraph(relation, from, to string, extra map[string]ColumnSpec) (*Graph, error) {
+	columns := maps.Clone(extra)
+	if columns == nil {
+		columns = make(map[string]ColumnSpec)
+	}
+	for _, name := range []string{from, to} {
+		spec := columns[name]
+		spec.Indexed = true
+		columns[name] = spec
+	}
+	edges, err := tx.CreatePersistent(relation, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{edges: edges, from: from, to: to}, nil
+}
+
+// LoadGraph returns the edge relation relation as a Graph over its from and
+// to columns.
+func (tx *Tx) LoadGraph(relation, from, to string) (*Graph, error) {
+	edges, err := tx.LoadPersistent(relation)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{from, to} {
+		if spec, ok := edges.fields[name]; !ok || len(spec.ReferenceCols) != 0 {
+			return nil, ErrFieldNotFound(name)
+		}
+	}
+	return &Graph{edges: edges, from: from, to: to}, nil
+}
+
+// Edges returns the relation holding the edges.
+func (g *Graph) Edges() *Persistent {
+	return g.edges
+}
+
+// AddEdge adds an edge from one node to another, with optional attributes.
+func (g *Graph) AddEdge(from, to any, attrs map[string]any) error {
+	row := maps.Clone(attrs)
+	if row == nil {
+		row = make(map[string]any, 2)
+	}
+	row[g.from], row[g.to] = from, to
+	return g.edges.Insert(row)
+}
+
+// RemoveEdge removes every edge from one node to another.
+func (g *Graph) RemoveEdge(from, to any) error {
+	ranges, err := ToKeyRanges(Eq(g.from, from), Eq(g.to, to))
+	if err != nil {
+		return err
+	}
+	return g.edges.Delete(ranges)
+}
+
+// Neighbors returns the nodes the edges leaving node point to.
+func (g *Graph) Neighbors(node any) ([]any, error) {
+	return g.follow(node, g.from, g.to)
+}
+
+// InNeighbors returns the nodes with an edge pointing to node.
+func (g *Graph) InNeighbors(node any) ([]any, error) {
+	return g.follow(node, g.to, g.from)
+}
+
+func (g *Graph) follow(node any, along, to string) ([]any, error) {
+	ranges, err := ToKeyRanges(Eq(along, node))
+	if err != nil {
+		return nil, err
+	}
+	seq, err := g.edges.SelectWith(ranges, SelectColumns(to))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []any
+	for row, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, row[to])
+	}
+	return nodes, nil
+}
+
+// Traverse walks the graph breadth first from start, following edges
+// forwards, and yields every node reached within depth edges, start
+// included at depth 0. Each node is yielded once, so cycles are safe. A
+// negative depth means no limit.
+func (g *Graph) Traverse(start any, depth int) iter.Seq2[Visit, error] {
+	return g.edges.walk(start, depth, g.from, g.Neighbors)
+}
+
+// walk yields the nodes reachable from start through next, breadth first,
+// up to depth steps away. Nodes are told apart by their key as values of
+// column.
+func (pr *Persistent) walk(start any, depth int, column string, next func(node any) ([]any, error)) iter.Seq2[Visit, error] {
+	return func(yield func(Visit, error) bool) {
+		spec := pr.fields[column]
+		seen := make(map[string]bool)
+		level := []any{start}
+		for d := 0; len(level) > 0 && (depth < 0 || d <= depth); d++ {
+			var following []any
+			for _, node := range level {
+				key, err := spec.appendValue(nil, node)
+				if err != nil {
+					yield(Visit{}, err)
+					return
+				}
+				if seen[string(key)] {
+					continue
+				}
+				seen[string(key)] = true
+				if !yield(Visit{Node: node, Depth: d}, nil) {
+					return
+				}
+				if d == depth {
+					continue
+				}
+				nodes, err := next(node)
+				if err != nil {
+					yield(Visit{}, err)
+					return
+				}
+				following = append(following, nodes...)
+			}
+			level = following
+		}
+	}
+}
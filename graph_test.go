@@ -0,0 +1,83 @@
+package thunder
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestTx_Graph(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	follows, err := tx.CreateGraph("follows", "src", "dst", map[string]ColumnSpec{"since": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"d", "a"}, {"c", "d"}, {"d", "e"}} {
+		if err := follows.AddEdge(e[0], e[1], map[string]any{"since": 2020}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sorted := func(nodes []any, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := make([]string, len(nodes))
+		for i, n := range nodes {
+			s[i] = fmt.Sprint(n)
+		}
+		slices.Sort(s)
+		return fmt.Sprint(s)
+	}
+	if got := sorted(follows.Neighbors("a")); got != "[b c]" {
+		t.Fatalf("expected a to follow b and c, got %s", got)
+	}
+	if got := sorted(follows.InNeighbors("d")); got != "[b c]" {
+		t.Fatalf("expected d to be followed by b and c, got %s", got)
+	}
+
+	loaded, err := tx.LoadGraph("follows", "src", "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	depths := make(map[string]int)
+	for v, err := range loaded.Traverse("a", 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, dup := depths[v.Node.(string)]; dup {
+			t.Fatalf("%v visited twice", v.Node)
+		}
+		depths[v.Node.(string)] = v.Depth
+	}
+	want := map[string]int{"a": 0, "b": 1, "c": 1, "d": 2}
+	if fmt.Sprint(depths) != fmt.Sprint(want) {
+		t.Fatalf("expected %v within 2 hops, got %v", want, depths)
+	}
+	n := 0
+	for _, err := range loaded.Traverse("a", -1) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Fatalf("expected the cycle through a to end after 5 nodes, got %d", n)
+	}
+
+	if err := loaded.RemoveEdge("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted(loaded.Neighbors("a")); got != "[b]" {
+		t.Fatalf("expected only b after removing a->c, got %s", got)
+	}
+	if _, err := tx.LoadGraph("follows", "src", "missing"); err == nil {
+		t.Fatal("expected error for an unknown column")
+	}
+}
@@ -0,0 +1,174 @@
+package grpcthunder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/longlodw/thunder"
+	"github.com/longlodw/thunder/grpcthunder/thunderpb"
+	"google.golang.org/grpc"
+)
+
+// Client calls a Thunder service. Errors returned by the server are gRPC
+// status errors whose code reflects the ThunderError behind them.
+type Client struct {
+	rpc thunderpb.ThunderClient
+}
+
+// NewClient returns a Client using conn.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{rpc: thunderpb.NewThunderClient(conn)}
+}
+
+// Columns returns the columns of relation.
+func (c *Client) Columns(ctx context.Context, relation string) ([]string, error) {
+	resp, err := c.rpc.Columns(ctx, &thunderpb.ColumnsRequest{Relation: relation})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetColumns(), nil
+}
+
+// Insert inserts rows into relation in a single transaction.
+func (c *Client) Insert(ctx context.Context, relation string, rows ...map[string]any) error {
+	req := &thunderpb.InsertRequest{Relation: relation, Rows: make([]*thunderpb.Row, len(rows))}
+	for i, row := range rows {
+		data, err := rowCodec.Marshal(row)
+		if err != nil {
+			return err
+		}
+		req.Rows[i] = &thunderpb.Row{Msgpack: data}
+	}
+	_, err := c.rpc.Insert(ctx, req)
+	return err
+}
+
+// Delete deletes the rows of relation matching ranges; see
+// thunder.RangeSpecs.
+func (c *Client) Delete(ctx context.Context, relation string, ranges map[string]thunder.RangeSpec) error {
+	_, err := c.rpc.Delete(ctx, &thunderpb.DeleteRequest{Relation: relation, Ranges: rangeMessages(ranges)})
+	return err
+}
+
+// Select streams the rows of relation matching ranges, restricted to
+// columns when any are given.
+func (c *Client) Select(ctx context.Context, relation string, ranges map[string]thunder.RangeSpec, columns ...string) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := c.rpc.Select(ctx, &thunderpb.SelectRequest{
+			Relation: relation,
+			Ranges:   rangeMessages(ranges),
+			Columns:  columns,
+		})
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			msg, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			var row map[string]any
+			if err := rowCodec.Unmarshal(msg.GetMsgpack(), &row); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Watch streams the changes committed to relation until ctx is done. With
+// after set, the server first replays its logged events with a later
+// sequence; see thunder.Persistent.WatchFrom.
+func (c *Client) Watch(ctx context.Context, relation string, after uint64) iter.Seq2[thunder.ChangeEvent, error] {
+	return func(yield func(thunder.ChangeEvent, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := c.rpc.Watch(ctx, &thunderpb.WatchRequest{Relation: relation, After: after})
+		if err != nil {
+			yield(thunder.ChangeEvent{}, err)
+			return
+		}
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				yield(thunder.ChangeEvent{}, err)
+				return
+			}
+			ev := thunder.ChangeEvent{
+				Seq:      msg.GetSeq(),
+				Relation: msg.GetRelation(),
+				Op:       thunder.ChangeOp(msg.GetOp()),
+			}
+			if msg.Before != nil {
+				if err := rowCodec.Unmarshal(msg.Before.GetMsgpack(), &ev.Before); err != nil {
+					yield(thunder.ChangeEvent{}, err)
+					return
+				}
+			}
+			if msg.After != nil {
+				if err := rowCodec.Unmarshal(msg.After.GetMsgpack(), &ev.After); err != nil {
+					yield(thunder.ChangeEvent{}, err)
+					return
+				}
+			}
+			if !yield(ev, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Source returns a thunder.RemoteSource over relation, which a local
+// database can attach with DB.AttachRemote and join with its own relations.
+func (c *Client) Source(ctx context.Context, relation string) (thunder.RemoteSource, error) {
+	columns, err := c.Columns(ctx, relation)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteSource{client: c, ctx: ctx, relation: relation, columns: columns}, nil
+}
+
+type remoteSource struct {
+	client   *Client
+	ctx      context.Context
+	relation string
+	columns  []string
+}
+
+func (s *remoteSource) Columns() []string {
+	return s.columns
+}
+
+func (s *remoteSource) Select(ranges map[string]thunder.RangeSpec) (iter.Seq2[map[string]any, error], error) {
+	return s.client.Select(s.ctx, s.relation, ranges), nil
+}
+
+func rangeMessages(specs map[string]thunder.RangeSpec) map[string]*thunderpb.Range {
+	ranges := make(map[string]*thunderpb.Range, len(specs))
+	for name, spec := range specs {
+		ranges[name] = &thunderpb.Range{
+			Start:        spec.Start,
+			End:          spec.End,
+			IncludeStart: spec.IncludeStart,
+			IncludeEnd:   spec.IncludeEnd,
+			Excludes:     spec.Excludes,
+			All:          spec.All,
+		}
+		for _, group := range spec.AnyOf {
+			ranges[name].AnyOf = append(ranges[name].AnyOf, &thunderpb.KeyGroup{Keys: group})
+		}
+	}
+	return ranges
+}
@@ -0,0 +1,25 @@
+module github.com/longlodw/thunder/grpcthunder
+
+go 1.25.3
+
+require (
+	github.com/longlodw/thunder v0.0.0-00010101000000-000000000000
+	github.com/openkvlab/boltdb v0.0.0-20251208110043-2c67ff523b74
+	google.golang.org/grpc v1.75.0
+	google.golang.org/protobuf v1.36.12
+)
+
+require (
+	github.com/fxamacker/cbor/v2 v2.9.2 // indirect
+	github.com/google/btree v1.1.3 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/net v0.58.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	rsc.io/ordered v1.1.1 // indirect
+)
+
+replace github.com/longlodw/thunder => ../
@@ -0,0 +1,208 @@
+package grpcthunder
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/longlodw/thunder"
+	"github.com/longlodw/thunder/grpcthunder/thunderpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestClientServer(t *testing.T) {
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.EnableEventLog(100); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{
+		"email": {Unique: true},
+		"name":  {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	thunderpb.RegisterThunderServer(srv, NewServer(db))
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewClient(conn)
+	ctx := context.Background()
+
+	if err := client.Insert(ctx, "users", map[string]any{"email": "ada@example.com", "name": "ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Insert(ctx, "users",
+		map[string]any{"email": "bob@example.com", "name": "bob"},
+		map[string]any{"email": "eve@example.com", "name": "eve"},
+	); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Insert(ctx, "users", map[string]any{"email": "ada@example.com", "name": "ada"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+	if _, err := client.Columns(ctx, "missing"); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	ranges, err := thunder.ToKeyRanges(thunder.Eq("email", "bob@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for row, err := range client.Select(ctx, "users", thunder.RangeSpecs(ranges), "name") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["name"] != "bob" || len(rows[0]) != 1 {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if err := client.Delete(ctx, "users", thunder.RangeSpecs(ranges)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first insert has sequence 1; replay what followed it.
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var ops []thunder.ChangeOp
+	for ev, err := range client.Watch(watchCtx, "users", 1) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ops = append(ops, ev.Op)
+		if len(ops) == 3 {
+			if ev.Before["name"] != "bob" || ev.After != nil {
+				t.Fatalf("unexpected delete event %+v", ev)
+			}
+			break
+		}
+	}
+	if ops[0] != thunder.ChangeInsert || ops[2] != thunder.ChangeDelete {
+		t.Fatalf("unexpected events %v", ops)
+	}
+
+	local, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	source, err := client.Source(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local.AttachRemote("users", source)
+	ltx, err := local.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ltx.Rollback()
+	remote, err := ltx.LoadRemote("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := remote.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected two remote rows, got %d", n)
+	}
+}
+
+func TestClientDeleteContains(t *testing.T) {
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("posts", map[string]thunder.ColumnSpec{
+		"id":   {Unique: true},
+		"tags": {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	thunderpb.RegisterThunderServer(srv, NewServer(db))
+	go srv.Serve(lis)
+	defer srv.Stop()
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewClient(conn)
+	ctx := context.Background()
+
+	if err := client.Insert(ctx, "posts",
+		map[string]any{"id": "1", "tags": []any{"go", "db"}},
+		map[string]any{"id": "2", "tags": []any{"rust"}},
+		map[string]any{"id": "3", "tags": []any{"go", "web"}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := thunder.ToKeyRanges(thunder.Contains("tags", "go"), thunder.ContainsAny("tags", "db", "rust"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the row with both conditions goes, not every row of the relation.
+	if err := client.Delete(ctx, "posts", thunder.RangeSpecs(ranges)); err != nil {
+		t.Fatal(err)
+	}
+	var ids []any
+	for row, err := range client.Select(ctx, "posts", nil, "id") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, row["id"])
+	}
+	if len(ids) != 2 || ids[0] != "2" || ids[1] != "3" {
+		t.Fatalf("expected rows 2 and 3 to remain, got %v", ids)
+	}
+}
@@ -0,0 +1,234 @@
+// Package grpcthunder serves a thunder database over gRPC and provides a
+// client for it, so that several processes can share one database through a
+// single writer. The service is defined in thunderpb/thunder.proto; rows
+// travel as MessagePack-encoded maps.
+package grpcthunder
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative thunderpb/thunder.proto
+
+import (
+	"context"
+	"errors"
+
+	"github.com/longlodw/thunder"
+	"github.com/longlodw/thunder/grpcthunder/thunderpb"
+	boltdb_errors "github.com/openkvlab/boltdb/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var rowCodec = &thunder.MsgpackMaUn
+
+// Server implements the Thunder service over a database. Register it with
+// thunderpb.RegisterThunderServer.
+type Server struct {
+	thunderpb.UnimplementedThunderServer
+	db *thunder.DB
+}
+
+var _ thunderpb.ThunderServer = (*Server)(nil)
+
+// NewServer returns a Server for db.
+func NewServer(db *thunder.DB) *Server {
+	return &Server{db: db}
+}
+
+func (s *Server) Columns(ctx context.Context, req *thunderpb.ColumnsRequest) (*thunderpb.ColumnsResponse, error) {
+	tx, err := s.db.BeginContext(ctx, false)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(req.GetRelation())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &thunderpb.ColumnsResponse{Columns: p.Columns()}, nil
+}
+
+func (s *Server) Insert(ctx context.Context, req *thunderpb.InsertRequest) (*thunderpb.InsertResponse, error) {
+	rows := make([]map[string]any, len(req.GetRows()))
+	for i, row := range req.GetRows() {
+		if err := rowCodec.Unmarshal(row.GetMsgpack(), &rows[i]); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "row %d: %v", i, err)
+		}
+	}
+	tx, err := s.db.BeginContext(ctx, true)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(req.GetRelation())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	for _, row := range rows {
+		if err := p.Insert(row); err != nil {
+			return nil, statusError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, statusError(err)
+	}
+	return &thunderpb.InsertResponse{Inserted: int64(len(rows))}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *thunderpb.DeleteRequest) (*thunderpb.DeleteResponse, error) {
+	tx, err := s.db.BeginContext(ctx, true)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(req.GetRelation())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	if err := p.Delete(thunder.KeyRanges(rangeSpecs(req.GetRanges()))); err != nil {
+		return nil, statusError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, statusError(err)
+	}
+	return &thunderpb.DeleteResponse{}, nil
+}
+
+func (s *Server) Select(req *thunderpb.SelectRequest, stream grpc.ServerStreamingServer[thunderpb.Row]) error {
+	tx, err := s.db.BeginContext(stream.Context(), false)
+	if err != nil {
+		return statusError(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(req.GetRelation())
+	if err != nil {
+		return statusError(err)
+	}
+	var opts []thunder.QueryOption
+	if len(req.GetColumns()) > 0 {
+		opts = append(opts, thunder.SelectColumns(req.GetColumns()...))
+	}
+	seq, err := p.SelectWith(thunder.KeyRanges(rangeSpecs(req.GetRanges())), opts...)
+	if err != nil {
+		return statusError(err)
+	}
+	var n int64
+	for row, err := range seq {
+		if err != nil {
+			return statusError(err)
+		}
+		data, err := rowCodec.Marshal(row)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(&thunderpb.Row{Msgpack: data}); err != nil {
+			return err
+		}
+		if n++; req.GetLimit() > 0 && n >= req.GetLimit() {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Server) Watch(req *thunderpb.WatchRequest, stream grpc.ServerStreamingServer[thunderpb.ChangeEvent]) error {
+	tx, err := s.db.BeginContext(stream.Context(), false)
+	if err != nil {
+		return statusError(err)
+	}
+	p, err := tx.LoadPersistent(req.GetRelation())
+	// Watching does not need the transaction.
+	tx.Rollback()
+	if err != nil {
+		return statusError(err)
+	}
+	events := p.Watch(stream.Context())
+	if req.GetAfter() > 0 {
+		events = p.WatchFrom(stream.Context(), req.GetAfter())
+	}
+	for ev, err := range events {
+		if err != nil {
+			if stream.Context().Err() != nil {
+				return status.FromContextError(err).Err()
+			}
+			return statusError(err)
+		}
+		msg, err := eventMessage(ev)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func eventMessage(ev thunder.ChangeEvent) (*thunderpb.ChangeEvent, error) {
+	msg := &thunderpb.ChangeEvent{
+		Seq:      ev.Seq,
+		Relation: ev.Relation,
+		Op:       thunderpb.ChangeOp(ev.Op),
+	}
+	for _, side := range []struct {
+		row map[string]any
+		dst **thunderpb.Row
+	}{{ev.Before, &msg.Before}, {ev.After, &msg.After}} {
+		if side.row == nil {
+			continue
+		}
+		data, err := rowCodec.Marshal(side.row)
+		if err != nil {
+			return nil, err
+		}
+		*side.dst = &thunderpb.Row{Msgpack: data}
+	}
+	return msg, nil
+}
+
+func rangeSpecs(ranges map[string]*thunderpb.Range) map[string]thunder.RangeSpec {
+	specs := make(map[string]thunder.RangeSpec, len(ranges))
+	for name, r := range ranges {
+		spec := thunder.RangeSpec{
+			Start:        r.Start,
+			End:          r.End,
+			IncludeStart: r.GetIncludeStart(),
+			IncludeEnd:   r.GetIncludeEnd(),
+			Excludes:     r.GetExcludes(),
+			All:          r.GetAll(),
+		}
+		for _, group := range r.GetAnyOf() {
+			spec.AnyOf = append(spec.AnyOf, group.GetKeys())
+		}
+		specs[name] = spec
+	}
+	return specs
+}
+
+// statusError converts err to a gRPC status carrying the matching code.
+func statusError(err error) error {
+	code := codes.Internal
+	var te *thunder.ThunderError
+	switch {
+	case errors.Is(err, boltdb_errors.ErrBucketNotFound):
+		code = codes.NotFound
+	case errors.As(err, &te):
+		switch te.Code {
+		case thunder.ErrCodeRelationNotFound:
+			code = codes.NotFound
+		case thunder.ErrCodeUniqueConstraint, thunder.ErrCodeRelationAlreadyExists, thunder.ErrCodeIndexAlreadyExists:
+			code = codes.AlreadyExists
+		case thunder.ErrCodeVersionConflict:
+			code = codes.Aborted
+		case thunder.ErrCodeFieldNotFound, thunder.ErrCodeFieldNotFoundInColumns, thunder.ErrCodeTypeMismatch,
+			thunder.ErrCodeUnsupportedOperator, thunder.ErrCodeColumnMasked, thunder.ErrCodeColumnEncrypted, thunder.ErrCodeValidationFailed:
+			code = codes.InvalidArgument
+		case thunder.ErrCodeCapacityExceeded, thunder.ErrCodeQueryBudgetExceeded:
+			code = codes.ResourceExhausted
+		case thunder.ErrCodeQueryTimeout:
+			code = codes.DeadlineExceeded
+		case thunder.ErrCodeEventsExpired, thunder.ErrCodeChangeTrackingDisabled, thunder.ErrCodeEventLogDisabled:
+			code = codes.FailedPrecondition
+		}
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,885 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: thunderpb/thunder.proto
+
+package thunderpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ChangeOp int32
+
+const (
+	ChangeOp_CHANGE_OP_UNSPECIFIED ChangeOp = 0
+	ChangeOp_CHANGE_OP_INSERT      ChangeOp = 1
+	ChangeOp_CHANGE_OP_UPDATE      ChangeOp = 2
+	ChangeOp_CHANGE_OP_DELETE      ChangeOp = 3
+	ChangeOp_CHANGE_OP_TRUNCATE    ChangeOp = 4
+)
+
+// Enum value maps for ChangeOp.
+var (
+	ChangeOp_name = map[int32]string{
+		0: "CHANGE_OP_UNSPECIFIED",
+		1: "CHANGE_OP_INSERT",
+		2: "CHANGE_OP_UPDATE",
+		3: "CHANGE_OP_DELETE",
+		4: "CHANGE_OP_TRUNCATE",
+	}
+	ChangeOp_value = map[string]int32{
+		"CHANGE_OP_UNSPECIFIED": 0,
+		"CHANGE_OP_INSERT":      1,
+		"CHANGE_OP_UPDATE":      2,
+		"CHANGE_OP_DELETE":      3,
+		"CHANGE_OP_TRUNCATE":    4,
+	}
+)
+
+func (x ChangeOp) Enum() *ChangeOp {
+	p := new(ChangeOp)
+	*p = x
+	return p
+}
+
+func (x ChangeOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ChangeOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_thunderpb_thunder_proto_enumTypes[0].Descriptor()
+}
+
+func (ChangeOp) Type() protoreflect.EnumType {
+	return &file_thunderpb_thunder_proto_enumTypes[0]
+}
+
+func (x ChangeOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ChangeOp.Descriptor instead.
+func (ChangeOp) EnumDescriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{0}
+}
+
+// Row is a row encoded as a MessagePack map.
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Msgpack       []byte                 `protobuf:"bytes,1,opt,name=msgpack,proto3" json:"msgpack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Row) GetMsgpack() []byte {
+	if x != nil {
+		return x.Msgpack
+	}
+	return nil
+}
+
+// Range is a key range over a column; see thunder.RangeSpec. Unset bounds
+// are open.
+type Range struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Start        []byte                 `protobuf:"bytes,1,opt,name=start,proto3,oneof" json:"start,omitempty"`
+	End          []byte                 `protobuf:"bytes,2,opt,name=end,proto3,oneof" json:"end,omitempty"`
+	IncludeStart bool                   `protobuf:"varint,3,opt,name=include_start,json=includeStart,proto3" json:"include_start,omitempty"`
+	IncludeEnd   bool                   `protobuf:"varint,4,opt,name=include_end,json=includeEnd,proto3" json:"include_end,omitempty"`
+	Excludes     [][]byte               `protobuf:"bytes,5,rep,name=excludes,proto3" json:"excludes,omitempty"`
+	// all holds element keys that must all be among the column's elements.
+	All [][]byte `protobuf:"bytes,6,rep,name=all,proto3" json:"all,omitempty"`
+	// any_of holds groups of element keys, one of each must be among them.
+	AnyOf         []*KeyGroup `protobuf:"bytes,7,rep,name=any_of,json=anyOf,proto3" json:"any_of,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Range) Reset() {
+	*x = Range{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Range) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Range) ProtoMessage() {}
+
+func (x *Range) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Range.ProtoReflect.Descriptor instead.
+func (*Range) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Range) GetStart() []byte {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Range) GetEnd() []byte {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Range) GetIncludeStart() bool {
+	if x != nil {
+		return x.IncludeStart
+	}
+	return false
+}
+
+func (x *Range) GetIncludeEnd() bool {
+	if x != nil {
+		return x.IncludeEnd
+	}
+	return false
+}
+
+func (x *Range) GetExcludes() [][]byte {
+	if x != nil {
+		return x.Excludes
+	}
+	return nil
+}
+
+func (x *Range) GetAll() [][]byte {
+	if x != nil {
+		return x.All
+	}
+	return nil
+}
+
+func (x *Range) GetAnyOf() []*KeyGroup {
+	if x != nil {
+		return x.AnyOf
+	}
+	return nil
+}
+
+// KeyGroup is a group of keys encoded with thunder.ToKey.
+type KeyGroup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Keys          [][]byte               `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyGroup) Reset() {
+	*x = KeyGroup{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyGroup) ProtoMessage() {}
+
+func (x *KeyGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyGroup.ProtoReflect.Descriptor instead.
+func (*KeyGroup) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *KeyGroup) GetKeys() [][]byte {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+type ColumnsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Relation      string                 `protobuf:"bytes,1,opt,name=relation,proto3" json:"relation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColumnsRequest) Reset() {
+	*x = ColumnsRequest{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnsRequest) ProtoMessage() {}
+
+func (x *ColumnsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnsRequest.ProtoReflect.Descriptor instead.
+func (*ColumnsRequest) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ColumnsRequest) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+type ColumnsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []string               `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColumnsResponse) Reset() {
+	*x = ColumnsResponse{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnsResponse) ProtoMessage() {}
+
+func (x *ColumnsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnsResponse.ProtoReflect.Descriptor instead.
+func (*ColumnsResponse) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ColumnsResponse) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+type InsertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Relation      string                 `protobuf:"bytes,1,opt,name=relation,proto3" json:"relation,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,2,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertRequest) Reset() {
+	*x = InsertRequest{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertRequest) ProtoMessage() {}
+
+func (x *InsertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertRequest.ProtoReflect.Descriptor instead.
+func (*InsertRequest) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InsertRequest) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *InsertRequest) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type InsertResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inserted      int64                  `protobuf:"varint,1,opt,name=inserted,proto3" json:"inserted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertResponse) Reset() {
+	*x = InsertResponse{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertResponse) ProtoMessage() {}
+
+func (x *InsertResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertResponse.ProtoReflect.Descriptor instead.
+func (*InsertResponse) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InsertResponse) GetInserted() int64 {
+	if x != nil {
+		return x.Inserted
+	}
+	return 0
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Relation      string                 `protobuf:"bytes,1,opt,name=relation,proto3" json:"relation,omitempty"`
+	Ranges        map[string]*Range      `protobuf:"bytes,2,rep,name=ranges,proto3" json:"ranges,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteRequest) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetRanges() map[string]*Range {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{8}
+}
+
+type SelectRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Relation string                 `protobuf:"bytes,1,opt,name=relation,proto3" json:"relation,omitempty"`
+	Ranges   map[string]*Range      `protobuf:"bytes,2,rep,name=ranges,proto3" json:"ranges,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// columns limits the rows to these columns when set.
+	Columns []string `protobuf:"bytes,3,rep,name=columns,proto3" json:"columns,omitempty"`
+	// limit is the maximum number of rows to stream; zero streams all.
+	Limit         int64 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SelectRequest) Reset() {
+	*x = SelectRequest{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelectRequest) ProtoMessage() {}
+
+func (x *SelectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelectRequest.ProtoReflect.Descriptor instead.
+func (*SelectRequest) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SelectRequest) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *SelectRequest) GetRanges() map[string]*Range {
+	if x != nil {
+		return x.Ranges
+	}
+	return nil
+}
+
+func (x *SelectRequest) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *SelectRequest) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type WatchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Relation string                 `protobuf:"bytes,1,opt,name=relation,proto3" json:"relation,omitempty"`
+	// after, when set, first replays the logged events with a later sequence.
+	After         uint64 `protobuf:"varint,2,opt,name=after,proto3" json:"after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *WatchRequest) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetAfter() uint64 {
+	if x != nil {
+		return x.After
+	}
+	return 0
+}
+
+type ChangeEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Relation      string                 `protobuf:"bytes,2,opt,name=relation,proto3" json:"relation,omitempty"`
+	Op            ChangeOp               `protobuf:"varint,3,opt,name=op,proto3,enum=thunder.v1.ChangeOp" json:"op,omitempty"`
+	Before        *Row                   `protobuf:"bytes,4,opt,name=before,proto3" json:"before,omitempty"`
+	After         *Row                   `protobuf:"bytes,5,opt,name=after,proto3" json:"after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangeEvent) Reset() {
+	*x = ChangeEvent{}
+	mi := &file_thunderpb_thunder_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeEvent) ProtoMessage() {}
+
+func (x *ChangeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_thunderpb_thunder_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeEvent.ProtoReflect.Descriptor instead.
+func (*ChangeEvent) Descriptor() ([]byte, []int) {
+	return file_thunderpb_thunder_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ChangeEvent) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *ChangeEvent) GetRelation() string {
+	if x != nil {
+		return x.Relation
+	}
+	return ""
+}
+
+func (x *ChangeEvent) GetOp() ChangeOp {
+	if x != nil {
+		return x.Op
+	}
+	return ChangeOp_CHANGE_OP_UNSPECIFIED
+}
+
+func (x *ChangeEvent) GetBefore() *Row {
+	if x != nil {
+		return x.Before
+	}
+	return nil
+}
+
+func (x *ChangeEvent) GetAfter() *Row {
+	if x != nil {
+		return x.After
+	}
+	return nil
+}
+
+var File_thunderpb_thunder_proto protoreflect.FileDescriptor
+
+const file_thunderpb_thunder_proto_rawDesc = "" +
+	"\n" +
+	"\x17thunderpb/thunder.proto\x12\n" +
+	"thunder.v1\"\x1f\n" +
+	"\x03Row\x12\x18\n" +
+	"\amsgpack\x18\x01 \x01(\fR\amsgpack\"\xec\x01\n" +
+	"\x05Range\x12\x19\n" +
+	"\x05start\x18\x01 \x01(\fH\x00R\x05start\x88\x01\x01\x12\x15\n" +
+	"\x03end\x18\x02 \x01(\fH\x01R\x03end\x88\x01\x01\x12#\n" +
+	"\rinclude_start\x18\x03 \x01(\bR\fincludeStart\x12\x1f\n" +
+	"\vinclude_end\x18\x04 \x01(\bR\n" +
+	"includeEnd\x12\x1a\n" +
+	"\bexcludes\x18\x05 \x03(\fR\bexcludes\x12\x10\n" +
+	"\x03all\x18\x06 \x03(\fR\x03all\x12+\n" +
+	"\x06any_of\x18\a \x03(\v2\x14.thunder.v1.KeyGroupR\x05anyOfB\b\n" +
+	"\x06_startB\x06\n" +
+	"\x04_end\"\x1e\n" +
+	"\bKeyGroup\x12\x12\n" +
+	"\x04keys\x18\x01 \x03(\fR\x04keys\",\n" +
+	"\x0eColumnsRequest\x12\x1a\n" +
+	"\brelation\x18\x01 \x01(\tR\brelation\"+\n" +
+	"\x0fColumnsResponse\x12\x18\n" +
+	"\acolumns\x18\x01 \x03(\tR\acolumns\"P\n" +
+	"\rInsertRequest\x12\x1a\n" +
+	"\brelation\x18\x01 \x01(\tR\brelation\x12#\n" +
+	"\x04rows\x18\x02 \x03(\v2\x0f.thunder.v1.RowR\x04rows\",\n" +
+	"\x0eInsertResponse\x12\x1a\n" +
+	"\binserted\x18\x01 \x01(\x03R\binserted\"\xb8\x01\n" +
+	"\rDeleteRequest\x12\x1a\n" +
+	"\brelation\x18\x01 \x01(\tR\brelation\x12=\n" +
+	"\x06ranges\x18\x02 \x03(\v2%.thunder.v1.DeleteRequest.RangesEntryR\x06ranges\x1aL\n" +
+	"\vRangesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12'\n" +
+	"\x05value\x18\x02 \x01(\v2\x11.thunder.v1.RangeR\x05value:\x028\x01\"\x10\n" +
+	"\x0eDeleteResponse\"\xe8\x01\n" +
+	"\rSelectRequest\x12\x1a\n" +
+	"\brelation\x18\x01 \x01(\tR\brelation\x12=\n" +
+	"\x06ranges\x18\x02 \x03(\v2%.thunder.v1.SelectRequest.RangesEntryR\x06ranges\x12\x18\n" +
+	"\acolumns\x18\x03 \x03(\tR\acolumns\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x03R\x05limit\x1aL\n" +
+	"\vRangesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12'\n" +
+	"\x05value\x18\x02 \x01(\v2\x11.thunder.v1.RangeR\x05value:\x028\x01\"@\n" +
+	"\fWatchRequest\x12\x1a\n" +
+	"\brelation\x18\x01 \x01(\tR\brelation\x12\x14\n" +
+	"\x05after\x18\x02 \x01(\x04R\x05after\"\xb1\x01\n" +
+	"\vChangeEvent\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x1a\n" +
+	"\brelation\x18\x02 \x01(\tR\brelation\x12$\n" +
+	"\x02op\x18\x03 \x01(\x0e2\x14.thunder.v1.ChangeOpR\x02op\x12'\n" +
+	"\x06before\x18\x04 \x01(\v2\x0f.thunder.v1.RowR\x06before\x12%\n" +
+	"\x05after\x18\x05 \x01(\v2\x0f.thunder.v1.RowR\x05after*\x7f\n" +
+	"\bChangeOp\x12\x19\n" +
+	"\x15CHANGE_OP_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10CHANGE_OP_INSERT\x10\x01\x12\x14\n" +
+	"\x10CHANGE_OP_UPDATE\x10\x02\x12\x14\n" +
+	"\x10CHANGE_OP_DELETE\x10\x03\x12\x16\n" +
+	"\x12CHANGE_OP_TRUNCATE\x10\x042\xc5\x02\n" +
+	"\aThunder\x12B\n" +
+	"\aColumns\x12\x1a.thunder.v1.ColumnsRequest\x1a\x1b.thunder.v1.ColumnsResponse\x12?\n" +
+	"\x06Insert\x12\x19.thunder.v1.InsertRequest\x1a\x1a.thunder.v1.InsertResponse\x12?\n" +
+	"\x06Delete\x12\x19.thunder.v1.DeleteRequest\x1a\x1a.thunder.v1.DeleteResponse\x126\n" +
+	"\x06Select\x12\x19.thunder.v1.SelectRequest\x1a\x0f.thunder.v1.Row0\x01\x12<\n" +
+	"\x05Watch\x12\x18.thunder.v1.WatchRequest\x1a\x17.thunder.v1.ChangeEvent0\x01B3Z1github.com/longlodw/thunder/grpcthunder/thunderpbb\x06proto3"
+
+var (
+	file_thunderpb_thunder_proto_rawDescOnce sync.Once
+	file_thunderpb_thunder_proto_rawDescData []byte
+)
+
+func file_thunderpb_thunder_proto_rawDescGZIP() []byte {
+	file_thunderpb_thunder_proto_rawDescOnce.Do(func() {
+		file_thunderpb_thunder_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_thunderpb_thunder_proto_rawDesc), len(file_thunderpb_thunder_proto_rawDesc)))
+	})
+	return file_thunderpb_thunder_proto_rawDescData
+}
+
+var file_thunderpb_thunder_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_thunderpb_thunder_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_thunderpb_thunder_proto_goTypes = []any{
+	(ChangeOp)(0),           // 0: thunder.v1.ChangeOp
+	(*Row)(nil),             // 1: thunder.v1.Row
+	(*Range)(nil),           // 2: thunder.v1.Range
+	(*KeyGroup)(nil),        // 3: thunder.v1.KeyGroup
+	(*ColumnsRequest)(nil),  // 4: thunder.v1.ColumnsRequest
+	(*ColumnsResponse)(nil), // 5: thunder.v1.ColumnsResponse
+	(*InsertRequest)(nil),   // 6: thunder.v1.InsertRequest
+	(*InsertResponse)(nil),  // 7: thunder.v1.InsertResponse
+	(*DeleteRequest)(nil),   // 8: thunder.v1.DeleteRequest
+	(*DeleteResponse)(nil),  // 9: thunder.v1.DeleteResponse
+	(*SelectRequest)(nil),   // 10: thunder.v1.SelectRequest
+	(*WatchRequest)(nil),    // 11: thunder.v1.WatchRequest
+	(*ChangeEvent)(nil),     // 12: thunder.v1.ChangeEvent
+	nil,                     // 13: thunder.v1.DeleteRequest.RangesEntry
+	nil,                     // 14: thunder.v1.SelectRequest.RangesEntry
+}
+var file_thunderpb_thunder_proto_depIdxs = []int32{
+	3,  // 0: thunder.v1.Range.any_of:type_name -> thunder.v1.KeyGroup
+	1,  // 1: thunder.v1.InsertRequest.rows:type_name -> thunder.v1.Row
+	13, // 2: thunder.v1.DeleteRequest.ranges:type_name -> thunder.v1.DeleteRequest.RangesEntry
+	14, // 3: thunder.v1.SelectRequest.ranges:type_name -> thunder.v1.SelectRequest.RangesEntry
+	0,  // 4: thunder.v1.ChangeEvent.op:type_name -> thunder.v1.ChangeOp
+	1,  // 5: thunder.v1.ChangeEvent.before:type_name -> thunder.v1.Row
+	1,  // 6: thunder.v1.ChangeEvent.after:type_name -> thunder.v1.Row
+	2,  // 7: thunder.v1.DeleteRequest.RangesEntry.value:type_name -> thunder.v1.Range
+	2,  // 8: thunder.v1.SelectRequest.RangesEntry.value:type_name -> thunder.v1.Range
+	4,  // 9: thunder.v1.Thunder.Columns:input_type -> thunder.v1.ColumnsRequest
+	6,  // 10: thunder.v1.Thunder.Insert:input_type -> thunder.v1.InsertRequest
+	8,  // 11: thunder.v1.Thunder.Delete:input_type -> thunder.v1.DeleteRequest
+	10, // 12: thunder.v1.Thunder.Select:input_type -> thunder.v1.SelectRequest
+	11, // 13: thunder.v1.Thunder.Watch:input_type -> thunder.v1.WatchRequest
+	5,  // 14: thunder.v1.Thunder.Columns:output_type -> thunder.v1.ColumnsResponse
+	7,  // 15: thunder.v1.Thunder.Insert:output_type -> thunder.v1.InsertResponse
+	9,  // 16: thunder.v1.Thunder.Delete:output_type -> thunder.v1.DeleteResponse
+	1,  // 17: thunder.v1.Thunder.Select:output_type -> thunder.v1.Row
+	12, // 18: thunder.v1.Thunder.Watch:output_type -> thunder.v1.ChangeEvent
+	14, // [14:19] is the sub-list for method output_type
+	9,  // [9:14] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_thunderpb_thunder_proto_init() }
+func file_thunderpb_thunder_proto_init() {
+	if File_thunderpb_thunder_proto != nil {
+		return
+	}
+	file_thunderpb_thunder_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_thunderpb_thunder_proto_rawDesc), len(file_thunderpb_thunder_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_thunderpb_thunder_proto_goTypes,
+		DependencyIndexes: file_thunderpb_thunder_proto_depIdxs,
+		EnumInfos:         file_thunderpb_thunder_proto_enumTypes,
+		MessageInfos:      file_thunderpb_thunder_proto_msgTypes,
+	}.Build()
+	File_thunderpb_thunder_proto = out.File
+	file_thunderpb_thunder_proto_goTypes = nil
+	file_thunderpb_thunder_proto_depIdxs = nil
+}
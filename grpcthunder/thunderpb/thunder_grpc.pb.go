@@ -0,0 +1,294 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: thunderpb/thunder.proto
+
+package thunderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Thunder_Columns_FullMethodName = "/thunder.v1.Thunder/Columns"
+	Thunder_Insert_FullMethodName  = "/thunder.v1.Thunder/Insert"
+	Thunder_Delete_FullMethodName  = "/thunder.v1.Thunder/Delete"
+	Thunder_Select_FullMethodName  = "/thunder.v1.Thunder/Select"
+	Thunder_Watch_FullMethodName   = "/thunder.v1.Thunder/Watch"
+)
+
+// ThunderClient is the client API for Thunder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Thunder serves the relations of a thunder database.
+type ThunderClient interface {
+	// Columns returns the columns of a relation.
+	Columns(ctx context.Context, in *ColumnsRequest, opts ...grpc.CallOption) (*ColumnsResponse, error)
+	// Insert inserts rows into a relation in a single transaction.
+	Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error)
+	// Delete deletes the rows of a relation matching ranges.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Select streams the rows of a relation matching ranges.
+	Select(ctx context.Context, in *SelectRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error)
+	// Watch streams the changes committed to a relation.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+}
+
+type thunderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewThunderClient(cc grpc.ClientConnInterface) ThunderClient {
+	return &thunderClient{cc}
+}
+
+func (c *thunderClient) Columns(ctx context.Context, in *ColumnsRequest, opts ...grpc.CallOption) (*ColumnsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ColumnsResponse)
+	err := c.cc.Invoke(ctx, Thunder_Columns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *thunderClient) Insert(ctx context.Context, in *InsertRequest, opts ...grpc.CallOption) (*InsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InsertResponse)
+	err := c.cc.Invoke(ctx, Thunder_Insert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *thunderClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Thunder_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *thunderClient) Select(ctx context.Context, in *SelectRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Thunder_ServiceDesc.Streams[0], Thunder_Select_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SelectRequest, Row]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Thunder_SelectClient = grpc.ServerStreamingClient[Row]
+
+func (c *thunderClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Thunder_ServiceDesc.Streams[1], Thunder_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Thunder_WatchClient = grpc.ServerStreamingClient[ChangeEvent]
+
+// ThunderServer is the server API for Thunder service.
+// All implementations must embed UnimplementedThunderServer
+// for forward compatibility.
+//
+// Thunder serves the relations of a thunder database.
+type ThunderServer interface {
+	// Columns returns the columns of a relation.
+	Columns(context.Context, *ColumnsRequest) (*ColumnsResponse, error)
+	// Insert inserts rows into a relation in a single transaction.
+	Insert(context.Context, *InsertRequest) (*InsertResponse, error)
+	// Delete deletes the rows of a relation matching ranges.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Select streams the rows of a relation matching ranges.
+	Select(*SelectRequest, grpc.ServerStreamingServer[Row]) error
+	// Watch streams the changes committed to a relation.
+	Watch(*WatchRequest, grpc.ServerStreamingServer[ChangeEvent]) error
+	mustEmbedUnimplementedThunderServer()
+}
+
+// UnimplementedThunderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedThunderServer struct{}
+
+func (UnimplementedThunderServer) Columns(context.Context, *ColumnsRequest) (*ColumnsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Columns not implemented")
+}
+func (UnimplementedThunderServer) Insert(context.Context, *InsertRequest) (*InsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Insert not implemented")
+}
+func (UnimplementedThunderServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedThunderServer) Select(*SelectRequest, grpc.ServerStreamingServer[Row]) error {
+	return status.Errorf(codes.Unimplemented, "method Select not implemented")
+}
+func (UnimplementedThunderServer) Watch(*WatchRequest, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedThunderServer) mustEmbedUnimplementedThunderServer() {}
+func (UnimplementedThunderServer) testEmbeddedByValue()                 {}
+
+// UnsafeThunderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ThunderServer will
+// result in compilation errors.
+type UnsafeThunderServer interface {
+	mustEmbedUnimplementedThunderServer()
+}
+
+func RegisterThunderServer(s grpc.ServiceRegistrar, srv ThunderServer) {
+	// If the following call pancis, it indicates UnimplementedThunderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Thunder_ServiceDesc, srv)
+}
+
+func _Thunder_Columns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ColumnsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThunderServer).Columns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Thunder_Columns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThunderServer).Columns(ctx, req.(*ColumnsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Thunder_Insert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThunderServer).Insert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Thunder_Insert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThunderServer).Insert(ctx, req.(*InsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Thunder_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThunderServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Thunder_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThunderServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Thunder_Select_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SelectRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ThunderServer).Select(m, &grpc.GenericServerStream[SelectRequest, Row]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Thunder_SelectServer = grpc.ServerStreamingServer[Row]
+
+func _Thunder_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ThunderServer).Watch(m, &grpc.GenericServerStream[WatchRequest, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Thunder_WatchServer = grpc.ServerStreamingServer[ChangeEvent]
+
+// Thunder_ServiceDesc is the grpc.ServiceDesc for Thunder service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Thunder_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "thunder.v1.Thunder",
+	HandlerType: (*ThunderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Columns",
+			Handler:    _Thunder_Columns_Handler,
+		},
+		{
+			MethodName: "Insert",
+			Handler:    _Thunder_Insert_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Thunder_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Select",
+			Handler:       _Thunder_Select_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Thunder_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "thunderpb/thunder.proto",
+}
@@ -0,0 +1,279 @@
+package thunder
+
+import (
+	"encoding/binary"
+	"iter"
+	"maps"
+	"strings"
+	"time"
+)
+
+// Columns added to history relations, holding the Unix nanosecond interval
+// during which a row version was current. Rows that existed before history
+// was enabled have a valid-from of zero.
+const (
+	HistoryValidFrom = "_valid_from"
+	HistoryValidTo   = "_valid_to"
+)
+
+// EnableHistory keeps the prior versions of the relation's rows: every row
+// that is updated, deleted or truncated away is copied, with the interval it
+// was current in, to the relation <name>_history. Select with the AsOf option
+// then returns the rows as they were at a past time. Reads of the history
+// relation are restricted and masked by the policy and masks of the
+// relation. Once history is on, removing rows with encrypted columns
+// requires their keys.
+func (pr *Persistent) EnableHistory() error {
+	if pr.versions != nil {
+		return nil
+	}
+	name := pr.relation + "_history"
+	specs := map[string]ColumnSpec{
+		HistoryValidFrom: {Type: TypeInt},
+		HistoryValidTo:   {Type: TypeInt, Indexed: true},
+	}
+	for col, spec := range pr.fields {
+		if len(spec.ReferenceCols) > 0 {
+			specs[col] = ColumnSpec{ReferenceCols: spec.ReferenceCols, Indexed: true}
+			continue
+		}
+		typ := spec.Type
+		if typ == TypeBlob {
+			// The blobs stay in the relation, which keeps them for the
+			// archived rows.
+			typ = TypeAny
+		}
+		specs[col] = ColumnSpec{Type: typ, EncryptionKey: spec.EncryptionKey}
+	}
+	if pr.tx.tx.Bucket([]byte(name)) != nil {
+		return ErrRelationAlreadyExists(name)
+	}
+	if _, err := pr.tx.CreatePersistent(name, specs); err != nil {
+		return err
+	}
+	versions, err := pr.bucket.CreateBucket([]byte("versions"))
+	if err != nil {
+		return err
+	}
+	pr.versions = versions
+	return pr.bucket.Bucket([]byte("meta")).Put([]byte("history"), []byte(name))
+}
+
+// HistoryRelation returns the name of the relation keeping the prior versions
+// of rows, or "" when history is off.
+func (pr *Persistent) HistoryRelation() string {
+	if pr.versions == nil {
+		return ""
+	}
+	return string(pr.bucket.Bucket([]byte("meta")).Get([]byte("history")))
+}
+
+// accessRelation returns the relation whose policy and masks govern reads
+// of relation: the one a history relation keeps the versions of, the
+// partitioned relation for a partition, and relation itself otherwise.
+func (tx *Tx) accessRelation(relation string) string {
+	if base, ok := strings.CutSuffix(relation, "_history"); ok {
+		if bucket := tx.tx.Bucket([]byte(base)); isRelationBucket(bucket) &&
+			string(bucket.Bucket([]byte("meta")).Get([]byte("history"))) == relation {
+			return base
+		}
+	}
+	return ruleRelation(relation)
+}
+
+// AsOf makes Select return the rows as they were at the given time. The
+// relation must have history enabled.
+func AsOf(at time.Time) QueryOption {
+	return func(o *queryOptions) {
+		o.asOf = at
+	}
+}
+
+// SelectAsOf returns the rows matching every op as they were at the given
+// time. The relation must have history enabled.
+func (pr *Persistent) SelectAsOf(at time.Time, ops ...Op) (iter.Seq2[map[string]any, error], error) {
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	return pr.SelectWith(ranges, AsOf(at))
+}
+
+// SetHistoryHorizon keeps the prior versions of the rows of relation for
+// horizon after they were replaced; older ones are removed by
+// EnforceRetention and RunRetention. Reads as of a time further back than
+// horizon fail with ErrHistoryExpired. A zero horizon keeps every version.
+func (d *DB) SetHistoryHorizon(relation string, horizon time.Duration) {
+	d.retentionMu.Lock()
+	defer d.retentionMu.Unlock()
+	if horizon <= 0 {
+		delete(d.horizons, relation)
+		return
+	}
+	if d.horizons == nil {
+		d.horizons = make(map[string]time.Duration)
+	}
+	d.horizons[relation] = horizon
+}
+
+func (d *DB) historyHorizon(relation string) time.Duration {
+	d.retentionMu.Lock()
+	defer d.retentionMu.Unlock()
+	return d.horizons[relation]
+}
+
+// collectHistory removes the versions of the rows of relation replaced
+// before cutoff, which no read within the horizon can see.
+func (d *DB) collectHistory(relation string, cutoff time.Time) (int, error) {
+	var history string
+	err := d.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		history = p.HistoryRelation()
+		return nil
+	})
+	if err != nil || history == "" {
+		return 0, err
+	}
+	return d.deleteExpired(history, Retention{Column: HistoryValidTo}, cutoff)
+}
+
+// stampVersion records when the row id became current.
+func (pr *Persistent) stampVersion(id []byte) error {
+	if pr.versions == nil {
+		return nil
+	}
+	return pr.versions.Put(id, binary.BigEndian.AppendUint64(nil, uint64(pr.tx.Now().UnixNano())))
+}
+
+// archiveVersion copies a row about to be removed to the history relation.
+func (pr *Persistent) archiveVersion(e entry) error {
+	if pr.versions == nil {
+		return nil
+	}
+	row, err := pr.decryptedCopy(e.value)
+	if err != nil {
+		return err
+	}
+	for _, col := range pr.columns {
+		if key := pr.fields[col].EncryptionKey; key != "" && row[col] == Redacted {
+			return ErrKeyNotFound(key)
+		}
+	}
+	var from int64
+	if raw := pr.versions.Get(e.id[:]); len(raw) == 8 {
+		from = int64(binary.BigEndian.Uint64(raw))
+	}
+	row[HistoryValidFrom] = from
+	row[HistoryValidTo] = pr.tx.Now().UnixNano()
+	history, err := pr.tx.LoadPersistent(pr.HistoryRelation())
+	if err != nil {
+		return err
+	}
+	if err := history.insertRow(row); err != nil {
+		return err
+	}
+	return pr.versions.Delete(e.id[:])
+}
+
+// archiveAll archives every row ahead of a truncation.
+func (pr *Persistent) archiveAll() error {
+	if pr.versions == nil {
+		return nil
+	}
+	all, err := pr.iter(nil)
+	if err != nil {
+		return err
+	}
+	var entries []entry
+	for e, err := range all {
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+	for _, e := range entries {
+		if err := pr.archiveVersion(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pr *Persistent) selectAsOf(ranges map[string]*keyRange, plan QueryPlan, at time.Time) (iter.Seq2[map[string]any, error], error) {
+	if pr.versions == nil {
+		return nil, ErrHistoryDisabled(pr.relation)
+	}
+	if pr.tx.db != nil {
+		if h := pr.tx.db.historyHorizon(pr.relation); h > 0 && at.Before(pr.tx.Now().Add(-h)) {
+			return nil, ErrHistoryExpired(pr.relation, at)
+		}
+	}
+	masked := pr.maskedFor()
+	if err := pr.checkMaskedRanges(masked, ranges); err != nil {
+		return nil, err
+	}
+	if err := pr.checkEncryptedRanges(ranges); err != nil {
+		return nil, err
+	}
+	current, err := pr.iterPlan(ranges, plan, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	history, err := pr.tx.LoadPersistent(pr.HistoryRelation())
+	if err != nil {
+		return nil, err
+	}
+	cutoff := at.UnixNano()
+	interval, err := ToKeyRanges(Gt(HistoryValidTo, cutoff), Le(HistoryValidFrom, cutoff))
+	if err != nil {
+		return nil, err
+	}
+	histRanges := maps.Clone(ranges)
+	if histRanges == nil {
+		histRanges = make(map[string]*keyRange)
+	}
+	maps.Copy(histRanges, interval)
+	past, err := history.Select(histRanges)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for e, err := range current {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if raw := pr.versions.Get(e.id[:]); len(raw) == 8 && int64(binary.BigEndian.Uint64(raw)) > cutoff {
+				continue
+			}
+			if err := pr.readRow(e.value, masked, nil); err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if !yield(e.value, nil) {
+				return
+			}
+		}
+		for row, err := range past {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			delete(row, HistoryValidFrom)
+			delete(row, HistoryValidTo)
+			redact(row, masked)
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,268 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPersistent_HistoryAsOf(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	db.SetClock(clock)
+
+	write := func(fn func(p *Persistent) error) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("prices")
+			if err != nil {
+				return err
+			}
+			return fn(p)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Hour)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("prices", map[string]ColumnSpec{
+			"sku":   {Unique: true},
+			"price": {},
+		})
+		if err != nil {
+			return err
+		}
+		return p.EnableHistory()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sku, err := ToKeyRanges(Eq("sku", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(func(p *Persistent) error {
+		return p.Insert(map[string]any{"sku": "a", "price": int64(10)})
+	})
+	write(func(p *Persistent) error {
+		_, err := p.Update(sku, map[string]any{"price": int64(12)})
+		return err
+	})
+	write(func(p *Persistent) error {
+		return p.Delete(sku)
+	})
+
+	priceAt := func(at time.Time) []any {
+		t.Helper()
+		var prices []any
+		err := db.view(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("prices")
+			if err != nil {
+				return err
+			}
+			rows, err := p.SelectWith(sku, AsOf(at))
+			if err != nil {
+				return err
+			}
+			for row, err := range rows {
+				if err != nil {
+					return err
+				}
+				prices = append(prices, row["price"])
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prices
+	}
+	cases := []struct {
+		at   time.Time
+		want []any
+	}{
+		{start.Add(-time.Minute), nil},
+		{start.Add(30 * time.Minute), []any{int64(10)}},
+		{start.Add(90 * time.Minute), []any{int64(12)}},
+		{start.Add(150 * time.Minute), nil},
+	}
+	for _, c := range cases {
+		got := priceAt(c.at)
+		if len(got) != len(c.want) || (len(got) == 1 && got[0] != c.want[0]) {
+			t.Errorf("as of %v: expected %v, got %v", c.at, c.want, got)
+		}
+	}
+
+	err = db.update(func(tx *Tx) error {
+		if _, err := tx.LoadPersistent("prices_history"); err != nil {
+			return err
+		}
+		plain, err := tx.CreatePersistent("plain", map[string]ColumnSpec{"x": {}})
+		if err == nil {
+			_, err = plain.SelectWith(nil, AsOf(start))
+		}
+		return err
+	})
+	var thErr *ThunderError
+	if !errors.As(err, &thErr) || thErr.Code != ErrCodeHistoryDisabled {
+		t.Fatalf("expected ErrHistoryDisabled, got %v", err)
+	}
+}
+
+func TestDB_HistoryHorizon(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	start := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	db.SetClock(clock)
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("prices", map[string]ColumnSpec{
+			"sku":   {Unique: true},
+			"price": {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := p.EnableHistory(); err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"sku": "a", "price": int64(10)})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range []int64{11, 12, 13} {
+		clock.Advance(time.Hour)
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("prices")
+			if err != nil {
+				return err
+			}
+			sku, err := ToKeyRanges(Eq("sku", "a"))
+			if err != nil {
+				return err
+			}
+			_, err = p.Update(sku, map[string]any{"price": price})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	priceAt := func(at time.Time) (any, error) {
+		var price any
+		err := db.view(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("prices")
+			if err != nil {
+				return err
+			}
+			seq, err := p.SelectAsOf(at, Eq("sku", "a"))
+			if err != nil {
+				return err
+			}
+			for row, err := range seq {
+				if err != nil {
+					return err
+				}
+				price = row["price"]
+			}
+			return nil
+		})
+		return price, err
+	}
+	if price, err := priceAt(start.Add(90 * time.Minute)); err != nil || fmt.Sprint(price) != "11" {
+		t.Fatalf("expected price 11 after 90 minutes, got %v, %v", price, err)
+	}
+
+	// Only 10, replaced more than 90 minutes ago, goes.
+	db.SetHistoryHorizon("prices", 90*time.Minute)
+	n, err := db.EnforceRetention()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 version collected, got %d", n)
+	}
+	var te *ThunderError
+	if _, err := priceAt(start.Add(30 * time.Minute)); !errors.As(err, &te) || te.Code != ErrCodeHistoryExpired {
+		t.Errorf("expected ErrHistoryExpired beyond the horizon, got %v", err)
+	}
+	if price, err := priceAt(start.Add(90 * time.Minute)); err != nil || fmt.Sprint(price) != "11" {
+		t.Errorf("expected price 11 within the horizon, got %v, %v", price, err)
+	}
+}
+
+func TestPersistent_HistoryMasksAndPolicy(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		u, err := tx.CreatePersistent("u", map[string]ColumnSpec{
+			"id":     {Unique: true},
+			"tenant": {},
+			"ssn":    {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := u.MaskColumn("ssn", "support"); err != nil {
+			return err
+		}
+		if err := u.EnableHistory(); err != nil {
+			return err
+		}
+		for i, tenant := range []string{"acme", "globex"} {
+			if err := u.Insert(map[string]any{"id": i, "tenant": tenant, "ssn": "123-45"}); err != nil {
+				return err
+			}
+		}
+		return u.Truncate()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetPolicy("u", func(ctx context.Context) ([]Op, error) {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []Op{Eq("tenant", tenant)}, nil
+		}
+		return nil, nil
+	})
+
+	tx, err := db.BeginContext(context.WithValue(context.Background(), tenantKey{}, "acme"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tx.SetIdentity("support")
+	history, err := tx.LoadPersistent("u_history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := history.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["tenant"] != "acme" || rows[0]["ssn"] != Redacted {
+		t.Fatalf("expected acme's version with ssn redacted, got %v", rows)
+	}
+}
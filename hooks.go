@@ -0,0 +1,74 @@
+package thunder
+
+// RelationHooks are triggers run inside the transaction that writes to a
+// relation. Insert hooks get the row being inserted, after column defaults
+// are applied; BeforeInsert may modify it. Delete hooks get the row being
+// removed. An error from any hook aborts the write. Nil hooks are skipped.
+type RelationHooks struct {
+	BeforeInsert func(tx *Tx, row map[string]any) error
+	AfterInsert  func(tx *Tx, row map[string]any) error
+	BeforeDelete func(tx *Tx, row map[string]any) error
+	AfterDelete  func(tx *Tx, row map[string]any) error
+	// OnEvict gets each row a capped relation evicts to make room, after
+	// the delete hooks ran for it; see Persistent.SetCap.
+	OnEvict func(tx *Tx, row map[string]any) error
+}
+
+// SetHooks registers the hooks of relation, replacing earlier ones. Pass the
+// zero RelationHooks to remove them.
+func (d *DB) SetHooks(relation string, hooks RelationHooks) {
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	if !hooks.onInsert() && !hooks.onDelete() && hooks.OnEvict == nil {
+		delete(d.hooks, relation)
+		return
+	}
+	if d.hooks == nil {
+		d.hooks = make(map[string]RelationHooks)
+	}
+	d.hooks[relation] = hooks
+}
+
+// hooks returns the hooks to run for writes to pr, which are none while
+// triggers are suppressed.
+func (pr *Persistent) hooks() RelationHooks {
+	if pr.ephemeral || pr.tx.db == nil || pr.tx.TriggersSuppressed() {
+		return RelationHooks{}
+	}
+	pr.tx.db.hooksMu.RLock()
+	defer pr.tx.db.hooksMu.RUnlock()
+	return pr.tx.db.hooks[pr.ruleRelation()]
+}
+
+func (h RelationHooks) onInsert() bool {
+	return h.BeforeInsert != nil || h.AfterInsert != nil
+}
+
+func (h RelationHooks) onDelete() bool {
+	return h.BeforeDelete != nil || h.AfterDelete != nil
+}
+
+func (h RelationHooks) run(hook func(tx *Tx, row map[string]any) error, tx *Tx, row map[string]any) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(tx, row)
+}
+
+// WithoutTriggers runs fn with relation triggers suppressed for the rest of
+// this transaction's writes made inside fn. Suppression nests, and the
+// previous state is restored when fn returns, even if it panics. Use it for
+// migrations and bulk imports whose writes must not fire business-logic hooks.
+func (tx *Tx) WithoutTriggers(fn func() error) error {
+	tx.triggersSuppressed++
+	defer func() {
+		tx.triggersSuppressed--
+	}()
+	return fn()
+}
+
+// TriggersSuppressed reports whether writes in this transaction currently
+// skip relation triggers.
+func (tx *Tx) TriggersSuppressed() bool {
+	return tx.triggersSuppressed > 0
+}
@@ -0,0 +1,122 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTx_WithoutTriggers(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if tx.TriggersSuppressed() {
+		t.Fatal("triggers must be active by default")
+	}
+	errStop := errors.New("stop")
+	err = tx.WithoutTriggers(func() error {
+		if !tx.TriggersSuppressed() {
+			t.Error("expected triggers to be suppressed")
+		}
+		if err := tx.WithoutTriggers(func() error { return nil }); err != nil {
+			return err
+		}
+		if !tx.TriggersSuppressed() {
+			t.Error("expected nested scope to keep outer suppression")
+		}
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected fn error to propagate, got %v", err)
+	}
+	if tx.TriggersSuppressed() {
+		t.Error("expected triggers to be restored after scope")
+	}
+}
+
+func TestDB_SetHooks(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	errEmpty := errors.New("empty name")
+	audit := func(action string) func(tx *Tx, row map[string]any) error {
+		return func(tx *Tx, row map[string]any) error {
+			log, err := tx.LoadPersistent("audit")
+			if err != nil {
+				return err
+			}
+			return log.Insert(map[string]any{"action": action, "name": row["name"]})
+		}
+	}
+	db.SetHooks("users", RelationHooks{
+		BeforeInsert: func(tx *Tx, row map[string]any) error {
+			if row["name"] == "" {
+				return errEmpty
+			}
+			row["slug"] = "user-" + row["name"].(string)
+			return nil
+		},
+		AfterInsert: audit("insert"),
+		AfterDelete: audit("delete"),
+	})
+
+	err := db.update(func(tx *Tx) error {
+		if _, err := tx.CreatePersistent("audit", map[string]ColumnSpec{"action": {}, "name": {}}); err != nil {
+			return err
+		}
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{"name": {Unique: true}, "slug": {}})
+		if err != nil {
+			return err
+		}
+		if err := users.Insert(map[string]any{"name": ""}); !errors.Is(err, errEmpty) {
+			t.Errorf("expected BeforeInsert to reject the row, got %v", err)
+		}
+		if err := users.Insert(map[string]any{"name": "ada"}); err != nil {
+			return err
+		}
+		if err := tx.WithoutTriggers(func() error {
+			return users.Insert(map[string]any{"name": "bob", "slug": "bob"})
+		}); err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("name", "ada"))
+		if err != nil {
+			return err
+		}
+		rows, err := users.Select(ranges)
+		if err != nil {
+			return err
+		}
+		for row, err := range rows {
+			if err != nil {
+				return err
+			}
+			if row["slug"] != "user-ada" {
+				t.Errorf("expected denormalized slug, got %v", row["slug"])
+			}
+		}
+		return users.Delete(ranges)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Select("audit", nil, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := map[string]int{}
+	for _, row := range rows {
+		if row["name"] != "ada" {
+			t.Errorf("unexpected audit row %v", row)
+		}
+		actions[row["action"].(string)]++
+	}
+	if len(rows) != 2 || actions["insert"] != 1 || actions["delete"] != 1 {
+		t.Fatalf("unexpected audit log %v", rows)
+	}
+}
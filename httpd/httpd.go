@@ -0,0 +1,346 @@
+// Package httpd serves the relations of a thunder database over HTTP.
+//
+// The routes are:
+//
+//	GET    /relations                  list the relations
+//	GET    /relations/{name}/schema    describe a relation
+//	GET    /relations/{name}?filters   stream the matching rows as NDJSON
+//	POST   /relations/{name}           insert the JSON objects of the body
+//	DELETE /relations/{name}?filters   delete the matching rows
+//
+// Filters are query parameters of the form column=value or column=op.value,
+// where op is one of eq, ne, gt, gte, lt and lte, as in ?age=gte.30. Values
+// are converted to the declared type of their column. GET also accepts
+// limit, the maximum number of rows, and columns, a comma-separated list of
+// the columns to return. DELETE without filters is refused unless all=true.
+package httpd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/longlodw/thunder"
+	boltdb_errors "github.com/openkvlab/boltdb/errors"
+)
+
+// Authorizer decides whether r may access relation. write is set for
+// inserts and deletes. A non-nil error denies the request with 403
+// Forbidden; relation is empty when listing relations.
+type Authorizer func(r *http.Request, relation string, write bool) error
+
+// Server is an http.Handler exposing a database.
+type Server struct {
+	db        *thunder.DB
+	authorize Authorizer
+	mux       *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// New returns a Server for db that allows every request.
+func New(db *thunder.DB) *Server {
+	s := &Server{db: db, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /relations", s.listRelations)
+	s.mux.HandleFunc("GET /relations/{relation}/schema", s.schema)
+	s.mux.HandleFunc("GET /relations/{relation}", s.selectRows)
+	s.mux.HandleFunc("POST /relations/{relation}", s.insert)
+	s.mux.HandleFunc("DELETE /relations/{relation}", s.delete)
+	return s
+}
+
+// SetAuthorizer registers the function that authorizes requests, or allows
+// every request when a is nil.
+func (s *Server) SetAuthorizer(a Authorizer) {
+	s.authorize = a
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) allowed(w http.ResponseWriter, r *http.Request, relation string, write bool) bool {
+	if s.authorize == nil {
+		return true
+	}
+	if err := s.authorize(r, relation, write); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) listRelations(w http.ResponseWriter, r *http.Request) {
+	if !s.allowed(w, r, "", false) {
+		return
+	}
+	names, err := s.db.Relations()
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"relations": names})
+}
+
+func (s *Server) schema(w http.ResponseWriter, r *http.Request) {
+	relation := r.PathValue("relation")
+	if !s.allowed(w, r, relation, false) {
+		return
+	}
+	tx, err := s.db.BeginContext(r.Context(), false)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(relation)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	info, err := p.Info()
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	columns := make(map[string]any, len(info.Columns))
+	for name, spec := range info.Columns {
+		columns[name] = map[string]any{
+			"type":       spec.Type.String(),
+			"indexed":    spec.Indexed,
+			"unique":     spec.Unique,
+			"references": spec.ReferenceCols,
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":    info.Name,
+		"columns": columns,
+		"indexes": info.Indexes,
+		"uniques": info.Uniques,
+		"rows":    info.Rows,
+		"bytes":   info.Bytes,
+	})
+}
+
+func (s *Server) selectRows(w http.ResponseWriter, r *http.Request) {
+	relation := r.PathValue("relation")
+	if !s.allowed(w, r, relation, false) {
+		return
+	}
+	query := r.URL.Query()
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+	var opts []thunder.QueryOption
+	if v := query.Get("columns"); v != "" {
+		opts = append(opts, thunder.SelectColumns(strings.Split(v, ",")...))
+	}
+	query.Del("limit")
+	query.Del("columns")
+
+	tx, err := s.db.BeginContext(r.Context(), false)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(relation)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	seq, err := selectFiltered(p, query, opts)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	enc := json.NewEncoder(w)
+	n := 0
+	for row, err := range seq {
+		if err != nil {
+			// Once rows are sent the status is too; cut the stream short.
+			if n == 0 {
+				writeThunderError(w, err)
+			}
+			return
+		}
+		if n == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		if enc.Encode(row) != nil {
+			return
+		}
+		if n++; limit > 0 && n >= limit {
+			return
+		}
+	}
+	if n == 0 {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) insert(w http.ResponseWriter, r *http.Request) {
+	relation := r.PathValue("relation")
+	if !s.allowed(w, r, relation, true) {
+		return
+	}
+	tx, err := s.db.BeginContext(r.Context(), true)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(relation)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	n, err := p.Import(r.Body, 0)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"inserted": n})
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	relation := r.PathValue("relation")
+	if !s.allowed(w, r, relation, true) {
+		return
+	}
+	query := r.URL.Query()
+	all := query.Get("all") == "true"
+	query.Del("all")
+	if len(query) == 0 && !all {
+		writeError(w, http.StatusBadRequest, errors.New("refusing to delete every row without all=true"))
+		return
+	}
+	tx, err := s.db.BeginContext(r.Context(), true)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	p, err := tx.LoadPersistent(relation)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	ops, err := filterOps(p, query)
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	ranges, err := thunder.ToKeyRanges(ops...)
+	if err == nil {
+		err = p.Delete(ranges)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		writeThunderError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func selectFiltered(p *thunder.Persistent, query map[string][]string, opts []thunder.QueryOption) (iter.Seq2[map[string]any, error], error) {
+	ops, err := filterOps(p, query)
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := thunder.ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	return p.SelectWith(ranges, opts...)
+}
+
+var opsByName = map[string]func(string, ...any) thunder.Op{
+	"eq":  thunder.Eq,
+	"ne":  thunder.Ne,
+	"gt":  thunder.Gt,
+	"gte": thunder.Ge,
+	"lt":  thunder.Lt,
+	"lte": thunder.Le,
+}
+
+// filterOps converts query parameters to Ops over the columns of p.
+func filterOps(p *thunder.Persistent, query map[string][]string) ([]thunder.Op, error) {
+	specs := p.ColumnSpecs()
+	var ops []thunder.Op
+	for column, values := range query {
+		spec, ok := specs[column]
+		if !ok {
+			return nil, thunder.ErrFieldNotFound(column)
+		}
+		for _, v := range values {
+			op := thunder.Eq
+			if name, rest, found := strings.Cut(v, "."); found {
+				if fn, ok := opsByName[name]; ok {
+					op, v = fn, rest
+				}
+			}
+			value, err := spec.Type.Parse(v)
+			if err != nil {
+				return nil, thunder.ErrTypeMismatch(column, spec.Type, v)
+			}
+			ops = append(ops, op(column, value))
+		}
+	}
+	return ops, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]any{"error": err.Error()})
+}
+
+// writeThunderError reports err with the status matching its ThunderError
+// code, if any.
+func writeThunderError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var te *thunder.ThunderError
+	var se *json.SyntaxError
+	switch {
+	case errors.Is(err, boltdb_errors.ErrBucketNotFound):
+		// LoadPersistent reports a missing relation with the storage error.
+		status = http.StatusNotFound
+	case errors.As(err, &te):
+		switch te.Code {
+		case thunder.ErrCodeRelationNotFound:
+			status = http.StatusNotFound
+		case thunder.ErrCodeUniqueConstraint, thunder.ErrCodeVersionConflict:
+			status = http.StatusConflict
+		case thunder.ErrCodeFieldNotFound, thunder.ErrCodeFieldNotFoundInColumns, thunder.ErrCodeTypeMismatch,
+			thunder.ErrCodeUnsupportedOperator, thunder.ErrCodeColumnMasked, thunder.ErrCodeColumnEncrypted,
+			thunder.ErrCodeCapacityExceeded, thunder.ErrCodeValidationFailed, thunder.ErrCodeQueryBudgetExceeded:
+			status = http.StatusBadRequest
+		case thunder.ErrCodeQueryTimeout:
+			status = http.StatusServiceUnavailable
+		}
+	case errors.As(err, &se):
+		status = http.StatusBadRequest
+	}
+	writeError(w, status, err)
+}
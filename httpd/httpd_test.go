@@ -0,0 +1,167 @@
+package httpd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/longlodw/thunder"
+)
+
+func TestServer(t *testing.T) {
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{
+		"email": {Unique: true, Type: thunder.TypeString},
+		"age":   {Indexed: true, Type: thunder.TypeInt},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(db)
+	s.SetAuthorizer(func(r *http.Request, relation string, write bool) error {
+		if write && r.Header.Get("Authorization") != "Bearer secret" {
+			return errors.New("read only")
+		}
+		return nil
+	})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	do := func(method, path, body string, auth bool) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		out, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(out)
+	}
+
+	rows := `{"email":"ada@example.com","age":36}
+{"email":"bob@example.com","age":41}
+{"email":"eve@example.com","age":29}`
+	if status, _ := do("POST", "/relations/users", rows, false); status != http.StatusForbidden {
+		t.Fatalf("expected an unauthorized insert to be forbidden, got %d", status)
+	}
+	if status, body := do("POST", "/relations/users", rows, true); status != http.StatusCreated || !strings.Contains(body, `"inserted":3`) {
+		t.Fatalf("unexpected insert response %d %s", status, body)
+	}
+	if status, _ := do("POST", "/relations/users", `{"email":"ada@example.com","age":1}`, true); status != http.StatusConflict {
+		t.Fatalf("expected a conflict for a duplicate email, got %d", status)
+	}
+	if status, body := do("GET", "/relations", "", false); status != http.StatusOK || !strings.Contains(body, "users") {
+		t.Fatalf("unexpected relations response %d %s", status, body)
+	}
+	if status, body := do("GET", "/relations/users/schema", "", false); status != http.StatusOK || !strings.Contains(body, `"rows":3`) {
+		t.Fatalf("unexpected schema response %d %s", status, body)
+	}
+	status, body := do("GET", "/relations/users?age=gte.36&columns=email", "", false)
+	if status != http.StatusOK || strings.Count(body, "\n") != 2 || strings.Contains(body, "age") {
+		t.Fatalf("unexpected select response %d %s", status, body)
+	}
+	if _, body := do("GET", "/relations/users?limit=1", "", false); strings.Count(body, "\n") != 1 {
+		t.Fatalf("expected one row, got %s", body)
+	}
+	if status, _ := do("GET", "/relations/users?age=old", "", false); status != http.StatusBadRequest {
+		t.Fatalf("expected a bad request for a non-integer age, got %d", status)
+	}
+	if status, _ := do("GET", "/relations/missing", "", false); status != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", status)
+	}
+	if status, _ := do("DELETE", "/relations/users", "", true); status != http.StatusBadRequest {
+		t.Fatalf("expected an unfiltered delete to be refused, got %d", status)
+	}
+	if status, _ := do("DELETE", "/relations/users?email=bob@example.com", "", true); status != http.StatusNoContent {
+		t.Fatalf("unexpected delete status %d", status)
+	}
+	if _, body := do("GET", "/relations/users", "", false); strings.Count(body, "\n") != 2 || strings.Contains(body, "bob") {
+		t.Fatalf("expected bob to be deleted, got %s", body)
+	}
+}
+
+type tenantKey struct{}
+
+func TestServerPolicy(t *testing.T) {
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := tx.CreatePersistent("docs", map[string]thunder.ColumnSpec{
+		"id":     {Unique: true, Type: thunder.TypeInt},
+		"tenant": {Indexed: true, Type: thunder.TypeString},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tenant := range []string{"acme", "globex"} {
+		if err := docs.Insert(map[string]any{"id": int64(i), "tenant": tenant}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	db.SetPolicy("docs", func(ctx context.Context) ([]thunder.Op, error) {
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		if !ok {
+			return nil, errors.New("no tenant")
+		}
+		return []thunder.Op{thunder.Eq("tenant", tenant)}, nil
+	})
+
+	// The policy sees the tenant the request was authenticated as.
+	s := New(db)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), tenantKey{}, r.Header.Get("X-Tenant"))
+		s.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/relations/docs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Tenant", "acme")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || strings.Count(string(body), "\n") != 1 || !strings.Contains(string(body), "acme") {
+		t.Fatalf("expected only the acme row, got %d %s", resp.StatusCode, body)
+	}
+}
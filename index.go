@@ -4,16 +4,16 @@ import (
 	"bytes"
 	"iter"
 
-	"github.com/openkvlab/boltdb"
+	"rsc.io/ordered"
 )
 
 type indexStorage struct {
-	bucket *boltdb.Bucket
+	bucket BackendBucket
 	maUn   MarshalUnmarshaler
 }
 
 func newIndex(
-	parentBucket *boltdb.Bucket,
+	parentBucket BackendBucket,
 	idxNames []string,
 	maUn MarshalUnmarshaler,
 ) (*indexStorage, error) {
@@ -34,7 +34,7 @@ func newIndex(
 }
 
 func loadIndex(
-	parentBucket *boltdb.Bucket,
+	parentBucket BackendBucket,
 	maUn MarshalUnmarshaler,
 ) (*indexStorage, error) {
 	bucket := parentBucket.Bucket([]byte("indexes"))
@@ -71,7 +71,9 @@ func (idx *indexStorage) delete(name string, key, id []byte) error {
 	return indexBk.Delete(compositeKey)
 }
 
-func (idx *indexStorage) get(name string, kr *keyRange) (iter.Seq2[[8]byte, error], error) {
+// get yields the ids of the entries of index name with a value in kr, in
+// index order or, when desc is set, in reverse.
+func (idx *indexStorage) get(name string, kr *keyRange, desc bool) (iter.Seq2[[8]byte, error], error) {
 	idxBk := idx.bucket.Bucket([]byte(name))
 	if idxBk == nil {
 		return nil, ErrIndexNotFound(name)
@@ -79,31 +81,54 @@ func (idx *indexStorage) get(name string, kr *keyRange) (iter.Seq2[[8]byte, erro
 	return func(yield func([8]byte, error) bool) {
 		c := idxBk.Cursor()
 		var k []byte
-		var seekPrefix []byte
 		var err error
-
-		if kr.startKey != nil {
-			seekPrefix, err = ToKey(kr.startKey)
-			if err != nil {
-				if !yield([8]byte{}, err) {
-					return
-				}
+		next := c.Next
+		switch {
+		case desc && kr.endKey != nil:
+			// Every entry for the end value sorts before the end value
+			// followed by Inf, so the entry before that is the last in
+			// range.
+			var seekPast []byte
+			if seekPast, err = ToKey(kr.endKey, ordered.Inf); err != nil {
+				yield([8]byte{}, err)
+				return
+			}
+			if k, _ = c.Seek(seekPast); k == nil {
+				k, _ = c.Last()
+			} else {
+				k, _ = c.Prev()
+			}
+		case desc:
+			k, _ = c.Last()
+		case kr.startKey != nil:
+			var seekPrefix []byte
+			if seekPrefix, err = ToKey(kr.startKey); err != nil {
+				yield([8]byte{}, err)
 				return
 			}
 			k, _ = c.Seek(seekPrefix)
-		} else {
+		default:
 			k, _ = c.First()
 		}
+		if desc {
+			next = c.Prev
+		}
 
-		lessThanEnd := func(k []byte) bool {
-			if kr.endKey == nil {
+		// inBounds reports whether the walk has not gone past the end of
+		// the range, or past its start when walking backwards.
+		inBounds := func(k []byte) bool {
+			bound, include, sign := kr.endKey, kr.includeEnd, 1
+			if desc {
+				bound, include, sign = kr.startKey, kr.includeStart, -1
+			}
+			if bound == nil {
 				return true
 			}
-			cmpEnd := bytes.Compare(k, kr.endKey)
-			return cmpEnd < 0 || (cmpEnd == 0 && kr.includeEnd)
+			cmp := bytes.Compare(k, bound) * sign
+			return cmp < 0 || (cmp == 0 && include)
 		}
 
-		for ; k != nil; k, _ = c.Next() {
+		for ; k != nil; k, _ = next() {
 			var parts []any
 			if err := orderedMa.Unmarshal(k, &parts); err != nil {
 				if !yield([8]byte{}, err) {
@@ -164,7 +189,7 @@ func (idx *indexStorage) get(name string, kr *keyRange) (iter.Seq2[[8]byte, erro
 				}
 			}
 
-			if !lessThanEnd(valBytes) {
+			if !inBounds(valBytes) {
 				break
 			}
 			if !kr.contains(valBytes) {
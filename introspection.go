@@ -0,0 +1,184 @@
+package thunder
+
+import (
+	"maps"
+	"slices"
+)
+
+// RelationInfo summarises a persistent relation for catalog listings.
+// Rows and Bytes reflect the committed state of the transaction.
+type RelationInfo struct {
+	Name    string
+	Columns map[string]ColumnSpec
+	Indexes []string
+	Uniques []string
+	Rows    int
+	Bytes   int64
+	// Stats holds the statistics of the last Analyze, if any.
+	Stats map[string]ColumnStats
+}
+
+// Relations returns the names of all persistent relations.
+func (d *DB) Relations() ([]string, error) {
+	var names []string
+	err := d.view(func(tx *Tx) error {
+		var err error
+		names, err = tx.Relations()
+		return err
+	})
+	return names, err
+}
+
+// Catalog describes every persistent relation in the database.
+func (d *DB) Catalog() ([]RelationInfo, error) {
+	var infos []RelationInfo
+	err := d.view(func(tx *Tx) error {
+		return tx.ForEachRelation(func(p *Persistent) error {
+			info, err := p.Info()
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	return infos, err
+}
+
+// Indexes returns the names of all indexes of the relation, including unique ones.
+func (pr *Persistent) Indexes() []string {
+	return slices.Sorted(slices.Values(pr.indexNames))
+}
+
+// Uniques returns the names of the unique indexes of the relation.
+func (pr *Persistent) Uniques() []string {
+	return slices.Sorted(slices.Values(pr.uniqueNames))
+}
+
+// ColumnSpecs returns a copy of the column specs the relation was created with,
+// including composite indexes.
+func (pr *Persistent) ColumnSpecs() map[string]ColumnSpec {
+	return maps.Clone(pr.fields)
+}
+
+// Count returns the number of rows stored in the relation, or under a row
+// policy the number the transaction can see.
+func (pr *Persistent) Count() (int, error) {
+	rows, _, restricted, err := pr.visibleRows()
+	if err != nil || restricted {
+		return rows, err
+	}
+	return bucketKeyCount(pr.data.bucket)
+}
+
+// visibleRows counts the rows the relation's policy lets the transaction
+// see, and the bytes they take, when the policy restricts it at all.
+func (pr *Persistent) visibleRows() (int, int64, bool, error) {
+	ranges, err := pr.restrict(nil)
+	if err != nil || len(ranges) == 0 {
+		return 0, 0, false, err
+	}
+	entries, err := pr.iterAll(ranges)
+	if err != nil {
+		return 0, 0, true, err
+	}
+	rows, size := 0, int64(0)
+	for e, err := range entries {
+		if err != nil {
+			return 0, 0, true, err
+		}
+		rows++
+		size += int64(len(pr.data.bucket.Get(e.id[:])))
+	}
+	return rows, size, true, nil
+}
+
+// Size returns the number of bytes the relation occupies on disk,
+// including its indexes.
+func (pr *Persistent) Size() (int64, error) {
+	return bucketBytes(pr.bucket)
+}
+
+// RelationStats describes the storage a relation occupies.
+type RelationStats struct {
+	Rows      int
+	DataBytes int64
+	// IndexBytes holds the bytes of each index, unique ones included.
+	IndexBytes map[string]int64
+	// AvgRowBytes is DataBytes spread over Rows, zero when empty.
+	AvgRowBytes float64
+	// Depth is the number of levels of the tree holding the rows, or zero
+	// when the backend does not report it.
+	Depth int
+}
+
+// Stats reports the row count and storage of the relation and its indexes.
+// Under a row policy restricting the transaction only the rows it can see
+// are reported, without their indexes.
+func (pr *Persistent) Stats() (RelationStats, error) {
+	rows, dataBytes, restricted, err := pr.visibleRows()
+	if err != nil {
+		return RelationStats{}, err
+	}
+	if restricted {
+		stats := RelationStats{Rows: rows, DataBytes: dataBytes}
+		if rows > 0 {
+			stats.AvgRowBytes = float64(dataBytes) / float64(rows)
+		}
+		return stats, nil
+	}
+	rows, err = bucketKeyCount(pr.data.bucket)
+	if err != nil {
+		return RelationStats{}, err
+	}
+	dataBytes, err = bucketBytes(pr.data.bucket)
+	if err != nil {
+		return RelationStats{}, err
+	}
+	stats := RelationStats{
+		Rows:       rows,
+		DataBytes:  dataBytes,
+		IndexBytes: make(map[string]int64, len(pr.indexNames)),
+	}
+	for _, name := range pr.indexNames {
+		bucket := pr.indexes.bucket.Bucket([]byte(name))
+		if bucket == nil {
+			continue
+		}
+		if stats.IndexBytes[name], err = bucketBytes(bucket); err != nil {
+			return RelationStats{}, err
+		}
+	}
+	if rows > 0 {
+		stats.AvgRowBytes = float64(dataBytes) / float64(rows)
+	}
+	if depth, ok := pr.data.bucket.(BackendBucketDepth); ok {
+		stats.Depth = depth.Depth()
+	}
+	return stats, nil
+}
+
+// Info collects the catalog information of the relation.
+func (pr *Persistent) Info() (RelationInfo, error) {
+	rows, err := pr.Count()
+	if err != nil {
+		return RelationInfo{}, err
+	}
+	size, err := pr.Size()
+	if err != nil {
+		return RelationInfo{}, err
+	}
+	stats, err := pr.ColumnStats()
+	if err != nil {
+		return RelationInfo{}, err
+	}
+	return RelationInfo{
+		Name:    pr.relation,
+		Columns: pr.ColumnSpecs(),
+		Indexes: pr.Indexes(),
+		Uniques: pr.Uniques(),
+		Rows:    rows,
+		Bytes:   size,
+		Stats:   stats,
+	}, nil
+}
@@ -0,0 +1,261 @@
+package thunder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDB_Catalog(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":    {Unique: true, Type: TypeInt},
+		"email": {Indexed: true, Type: TypeString},
+		"bio":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Generate(50, map[string]ColumnGenerator{
+		"id":    GenSequential(0),
+		"email": GenString(12),
+		"bio":   GenString(64),
+	}, 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("empty", map[string]ColumnSpec{"x": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := db.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"empty", "users"}) {
+		t.Errorf("unexpected relations %v", names)
+	}
+	infos, err := db.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 relations, got %d", len(infos))
+	}
+	for _, info := range infos {
+		switch info.Name {
+		case "users":
+			if info.Rows != 50 {
+				t.Errorf("expected 50 rows, got %d", info.Rows)
+			}
+			if info.Bytes <= 0 {
+				t.Errorf("expected positive size, got %d", info.Bytes)
+			}
+			if !slices.Equal(info.Indexes, []string{"email", "id"}) || !slices.Equal(info.Uniques, []string{"id"}) {
+				t.Errorf("unexpected indexes %v / uniques %v", info.Indexes, info.Uniques)
+			}
+			if info.Columns["email"].Type != TypeString {
+				t.Errorf("expected email to be a string column, got %v", info.Columns["email"].Type)
+			}
+		case "empty":
+			if info.Rows != 0 {
+				t.Errorf("expected empty relation, got %d rows", info.Rows)
+			}
+		}
+	}
+}
+
+func TestPersistent_Analyze(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ranges, err := ToKeyRanges(Eq("status", "done"), Ge("created", int64(990)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("tasks", map[string]ColumnSpec{
+			"status":  {Indexed: true},
+			"created": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 1000 {
+			status := "done"
+			if i%100 == 0 {
+				status = "open"
+			}
+			if err := p.Insert(map[string]any{"status": status, "created": int64(i)}); err != nil {
+				return err
+			}
+		}
+		// Without statistics the equality looks narrower than the range.
+		plan, err := p.Explain(ranges)
+		if err != nil {
+			return err
+		}
+		if plan.Index != "status" {
+			t.Errorf("expected status before Analyze, got %v", plan)
+		}
+		return p.Analyze()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("tasks")
+		if err != nil {
+			return err
+		}
+		plan, err := p.Explain(ranges)
+		if err != nil {
+			return err
+		}
+		if plan.Index != "created" || plan.Reason != PlanAnalyzed {
+			t.Errorf("expected created from the statistics, got %v", plan)
+		}
+		info, err := p.Info()
+		if err != nil {
+			return err
+		}
+		if s := info.Stats["status"]; s.Distinct != 2 || s.Rows != 1000 {
+			t.Errorf("unexpected status stats %+v", s)
+		}
+		if s := info.Stats["created"]; s.Distinct != 1000 || len(s.Bounds) != analyzeBuckets {
+			t.Errorf("unexpected created stats: %d distinct, %d bounds", s.Distinct, len(s.Bounds))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersistent_EstimateCount(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		orders, err := tx.CreatePersistent("orders", map[string]ColumnSpec{
+			"n":      {Indexed: true, Type: TypeInt},
+			"status": {},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 1000 {
+			status := "closed"
+			if i%4 == 0 {
+				status = "open"
+			}
+			if err := orders.Insert(map[string]any{"n": i, "status": status}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimate := func(ops ...Op) int {
+		t.Helper()
+		var n int
+		err := db.view(func(tx *Tx) error {
+			orders, err := tx.LoadPersistent("orders")
+			if err != nil {
+				return err
+			}
+			n, err = orders.EstimateCount(ops...)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := estimate(); n != 1000 {
+		t.Fatalf("expected the exact count without ops, got %d", n)
+	}
+	// Without statistics the default selectivities apply.
+	if n := estimate(Eq("status", "open")); n != 100 {
+		t.Fatalf("expected the default equality estimate of 100, got %d", n)
+	}
+	err = db.update(func(tx *Tx) error {
+		orders, err := tx.LoadPersistent("orders")
+		if err != nil {
+			return err
+		}
+		return orders.Analyze()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := estimate(Ge("n", 500)); n < 400 || n > 600 {
+		t.Fatalf("expected about 500 rows from n 500 on, got %d", n)
+	}
+	if n := estimate(Eq("status", "open")); n < 200 || n > 300 {
+		t.Fatalf("expected about 250 open orders, got %d", n)
+	}
+	if n := estimate(Eq("status", "open"), Lt("n", 500)); n < 75 || n > 175 {
+		t.Fatalf("expected about 125 early open orders, got %d", n)
+	}
+}
+
+func TestPersistent_Stats(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":    {Unique: true, Type: TypeInt},
+			"email": {Indexed: true, Type: TypeString},
+			"bio":   {},
+		})
+		if err != nil {
+			return err
+		}
+		return users.Generate(200, map[string]ColumnGenerator{
+			"id":    GenSequential(0),
+			"email": GenString(12),
+			"bio":   GenString(64),
+		}, 7)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		stats, err := users.Stats()
+		if err != nil {
+			return err
+		}
+		if stats.Rows != 200 || stats.DataBytes <= 0 || stats.Depth < 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+		if stats.AvgRowBytes != float64(stats.DataBytes)/200 {
+			t.Errorf("expected the average row size to follow the data size, got %v", stats.AvgRowBytes)
+		}
+		for _, name := range []string{"id", "email"} {
+			if stats.IndexBytes[name] <= 0 {
+				t.Errorf("expected a size for index %s, got %v", name, stats.IndexBytes)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -13,6 +13,9 @@ type keyRange struct {
 	endKey       []byte
 	excludes     [][]byte
 	distance     []byte
+	// all and anyOf are element conditions, see Contains.
+	all   [][]byte
+	anyOf [][][]byte
 }
 
 func ToKey(values ...any) ([]byte, error) {
@@ -31,6 +34,37 @@ func KeyRange(startKey, endKey []byte, includeStart, includeEnd bool, excludes [
 	return res
 }
 
+// intersect returns the range of keys in both ir and other.
+func (ir *keyRange) intersect(other *keyRange) *keyRange {
+	res := &keyRange{
+		startKey:     ir.startKey,
+		endKey:       ir.endKey,
+		includeStart: ir.includeStart,
+		includeEnd:   ir.includeEnd,
+		excludes:     slices.Concat(ir.excludes, other.excludes),
+		all:          slices.Concat(ir.all, other.all),
+		anyOf:        slices.Concat(ir.anyOf, other.anyOf),
+	}
+	if other.startKey != nil {
+		cmp := bytes.Compare(other.startKey, res.startKey)
+		if res.startKey == nil || cmp > 0 {
+			res.startKey, res.includeStart = other.startKey, other.includeStart
+		} else if cmp == 0 {
+			res.includeStart = res.includeStart && other.includeStart
+		}
+	}
+	if other.endKey != nil {
+		cmp := bytes.Compare(other.endKey, res.endKey)
+		if res.endKey == nil || cmp < 0 {
+			res.endKey, res.includeEnd = other.endKey, other.includeEnd
+		} else if cmp == 0 {
+			res.includeEnd = res.includeEnd && other.includeEnd
+		}
+	}
+	res.distance = res.computeDistance()
+	return res
+}
+
 func (ir *keyRange) contains(key []byte) bool {
 	if ir.startKey != nil {
 		cmpStart := bytes.Compare(key, ir.startKey)
@@ -0,0 +1,109 @@
+package thunder
+
+import (
+	"context"
+	"iter"
+)
+
+// Subscribe runs a live query over relation: it first yields the rows
+// matching every op as ChangeInsert events with a zero Seq, then keeps the
+// caller's copy of the result current. Each committed change affecting the
+// result is yielded as a ChangeInsert for a row entering it, a ChangeDelete
+// for one leaving it, a ChangeUpdate for one changed within it and a
+// ChangeTruncate when the relation is emptied. No change is missed or
+// reported twice between the initial rows and the updates. Iteration ends
+// when ctx is done, with ctx.Err() yielded. The query holds no encryption
+// keys, so encrypted columns read as Redacted in the changes as in the
+// initial rows.
+func (d *DB) Subscribe(ctx context.Context, relation string, ops ...Op) iter.Seq2[ChangeEvent, error] {
+	return func(yield func(ChangeEvent, error) bool) {
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		sub := d.feed.subscribe(relation)
+		defer d.feed.unsubscribe(relation, sub)
+		// Changes committed up to the snapshot are part of the initial rows;
+		// the feed's sequence as of the snapshot tells them apart.
+		var initial []map[string]any
+		var after uint64
+		var match func(map[string]any) (bool, error)
+		var redacted func(ChangeEvent, error) bool
+		err = d.viewContext(ctx, func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			if bucket := tx.tx.Bucket([]byte(feedBucket)); bucket != nil {
+				after = bucket.Sequence()
+			}
+			if ranges, err = p.restrict(ranges); err != nil {
+				return err
+			}
+			ranges = p.coerceRanges(ranges)
+			match = p.matcher(ranges, "")
+			redacted = p.redactEvents(yield)
+			seq, err := p.Select(ranges)
+			if err != nil {
+				return err
+			}
+			for row, err := range seq {
+				if err != nil {
+					return err
+				}
+				initial = append(initial, ownedRow(tx.maUn, row))
+			}
+			return nil
+		})
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		for _, row := range initial {
+			if !yield(ChangeEvent{Relation: relation, Op: ChangeInsert, After: row}, nil) {
+				return
+			}
+		}
+		d.feed.follow(ctx, sub, after, matchEvents(match, redacted))
+	}
+}
+
+// matchEvents returns yield seen through match: events of rows entering or
+// leaving the matching rows become inserts and deletes, and events of other
+// rows are dropped. A nil match lets every row through.
+func matchEvents(match func(map[string]any) (bool, error), yield func(ChangeEvent, error) bool) func(ChangeEvent, error) bool {
+	matches := func(row map[string]any) (bool, error) {
+		if row == nil {
+			return false, nil
+		}
+		if match == nil {
+			return true, nil
+		}
+		return match(row)
+	}
+	return func(ev ChangeEvent, err error) bool {
+		if err != nil || ev.Op == ChangeTruncate {
+			return yield(ev, err)
+		}
+		was, err := matches(ev.Before)
+		if err != nil {
+			return yield(ChangeEvent{}, err)
+		}
+		is, err := matches(ev.After)
+		if err != nil {
+			return yield(ChangeEvent{}, err)
+		}
+		switch {
+		case was && is:
+			ev.Op = ChangeUpdate
+		case is:
+			ev.Op, ev.Before = ChangeInsert, nil
+		case was:
+			ev.Op, ev.After = ChangeDelete, nil
+		default:
+			return true
+		}
+		return yield(ev, nil)
+	}
+}
@@ -0,0 +1,101 @@
+package thunder
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDB_Subscribe(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		tasks, err := tx.CreatePersistent("tasks", map[string]ColumnSpec{
+			"id":    {Unique: true},
+			"owner": {Indexed: true},
+			"title": {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := tasks.Insert(map[string]any{"id": "1", "owner": "ann", "title": "a"}); err != nil {
+			return err
+		}
+		return tasks.Insert(map[string]any{"id": "2", "owner": "bob", "title": "b"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan ChangeEvent)
+	done := make(chan error, 1)
+	go func() {
+		for ev, err := range db.Subscribe(ctx, "tasks", Eq("owner", "ann")) {
+			if err != nil {
+				done <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+	expect := func(op ChangeOp, id string) {
+		t.Helper()
+		ev := <-events
+		row := ev.After
+		if op == ChangeDelete {
+			row = ev.Before
+		}
+		if ev.Op != op || row["id"] != id {
+			t.Fatalf("expected %v of %s, got %v %v", op, id, ev.Op, row)
+		}
+	}
+	expect(ChangeInsert, "1")
+
+	write := func(fn func(p *Persistent) error) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("tasks")
+			if err != nil {
+				return err
+			}
+			return fn(p)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	byID := func(id string) map[string]*keyRange {
+		ranges, err := ToKeyRanges(Eq("id", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranges
+	}
+	write(func(p *Persistent) error {
+		// Outside the result: not reported.
+		return p.Insert(map[string]any{"id": "3", "owner": "bob", "title": "c"})
+	})
+	write(func(p *Persistent) error {
+		_, err := p.Update(byID("2"), map[string]any{"owner": "ann"})
+		return err
+	})
+	expect(ChangeInsert, "2")
+	write(func(p *Persistent) error {
+		_, err := p.Update(byID("1"), map[string]any{"title": "a2"})
+		return err
+	})
+	expect(ChangeUpdate, "1")
+	write(func(p *Persistent) error {
+		return p.Delete(byID("2"))
+	})
+	expect(ChangeDelete, "2")
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+const logsBucket = "__thunder_logs"
+
+// defaultSegmentSize is the number of records per segment of a log created
+// without one.
+const defaultSegmentSize = 1024
+
+// The bucket of a log, under logsBucket, holds:
+//   - its sequence: the offset of the last appended record
+//   - "segmentSize", "first": records per segment and the oldest offset kept
+//   - "segments": segment number -> bucket of offset -> record
+//   - "ends": segment number -> time of its newest record, in Unix nanoseconds
+//   - with change tracking, "created": the commit sequence the log was
+//     created at, and "commits": commit sequence -> first offset appended
+// A record is its append time in Unix nanoseconds followed by the encoded row.
+
+// Log is an append-only sequence of rows numbered by offset from 1, for
+// event sourcing and audit trails. Rows are not indexed; they are read back
+// by offset or by append time, and dropped a whole segment at a time, oldest
+// first.
+type Log struct {
+	tx          *Tx
+	name        string
+	bucket      BackendBucket
+	segmentSize uint64
+}
+
+// LogRecord is a row of a Log with the offset it was appended at and the
+// database time it was appended.
+type LogRecord struct {
+	Offset uint64
+	Time   time.Time
+	Row    map[string]any
+}
+
+// CreateLog creates the log called name, stored in segments of segmentSize
+// records, 1024 when zero.
+func (tx *Tx) CreateLog(name string, segmentSize int) (*Log, error) {
+	if err := tx.checkWritable(); err != nil {
+		return nil, err
+	}
+	logs, err := tx.tx.CreateBucketIfNotExists([]byte(logsBucket))
+	if err != nil {
+		return nil, err
+	}
+	if logs.Bucket([]byte(name)) != nil {
+		return nil, ErrLogAlreadyExists(name)
+	}
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	bucket, err := logs.CreateBucket([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range []string{"segments", "ends"} {
+		if _, err := bucket.CreateBucket([]byte(sub)); err != nil {
+			return nil, err
+		}
+	}
+	if err := putUint64(bucket, "segmentSize", uint64(segmentSize)); err != nil {
+		return nil, err
+	}
+	if err := putUint64(bucket, "first", 1); err != nil {
+		return nil, err
+	}
+	root, seq, err := tx.changeSeq()
+	if err != nil {
+		return nil, err
+	}
+	if root != nil {
+		if err := putUint64(bucket, "created", seq); err != nil {
+			return nil, err
+		}
+	}
+	return &Log{tx: tx, name: name, bucket: bucket, segmentSize: uint64(segmentSize)}, nil
+}
+
+// LoadLog returns the log called name.
+func (tx *Tx) LoadLog(name string) (*Log, error) {
+	logs := tx.tx.Bucket([]byte(logsBucket))
+	if logs == nil || logs.Bucket([]byte(name)) == nil {
+		return nil, ErrLogNotFound(name)
+	}
+	bucket := logs.Bucket([]byte(name))
+	return &Log{tx: tx, name: name, bucket: bucket, segmentSize: getUint64(bucket, "segmentSize")}, nil
+}
+
+// DropLog removes the log called name with all its records.
+func (tx *Tx) DropLog(name string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	logs := tx.tx.Bucket([]byte(logsBucket))
+	if logs == nil || logs.Bucket([]byte(name)) == nil {
+		return ErrLogNotFound(name)
+	}
+	if _, _, err := tx.changeSeq(); err != nil {
+		return err
+	}
+	return logs.DeleteBucket([]byte(name))
+}
+
+// Name returns the name of the log.
+func (l *Log) Name() string {
+	return l.name
+}
+
+// Append adds row at the end of the log and returns its offset.
+func (l *Log) Append(row map[string]any) (uint64, error) {
+	if err := l.tx.checkWritable(); err != nil {
+		return 0, err
+	}
+	offset, err := l.bucket.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	segment := l.segmentKey(offset)
+	records, err := l.bucket.Bucket([]byte("segments")).CreateBucketIfNotExists(segment)
+	if err != nil {
+		return 0, err
+	}
+	now := l.tx.Now().UnixNano()
+	raw, err := l.tx.maUn.Marshal(row)
+	if err != nil {
+		return 0, err
+	}
+	record := binary.BigEndian.AppendUint64(nil, uint64(now))
+	if err := records.Put(binary.BigEndian.AppendUint64(nil, offset), append(record, raw...)); err != nil {
+		return 0, err
+	}
+	if err := l.trackAppend(offset); err != nil {
+		return 0, err
+	}
+	return offset, l.extendSegment(segment, now)
+}
+
+// extendSegment records that segment holds a record appended at now.
+func (l *Log) extendSegment(segment []byte, now int64) error {
+	ends := l.bucket.Bucket([]byte("ends"))
+	if end := ends.Get(segment); end != nil && int64(binary.BigEndian.Uint64(end)) >= now {
+		return nil
+	}
+	return ends.Put(segment, binary.BigEndian.AppendUint64(nil, uint64(now)))
+}
+
+// trackAppend records, with change tracking on, the first offset appended
+// by the transaction, so that change streams carry the records from there.
+func (l *Log) trackAppend(offset uint64) error {
+	root, seq, err := l.tx.changeSeq()
+	if root == nil || err != nil {
+		return err
+	}
+	commits, err := l.bucket.CreateBucketIfNotExists([]byte("commits"))
+	if err != nil {
+		return err
+	}
+	key := binary.BigEndian.AppendUint64(nil, seq)
+	if commits.Get(key) != nil {
+		return nil
+	}
+	return commits.Put(key, binary.BigEndian.AppendUint64(nil, offset))
+}
+
+// First returns the offset of the oldest record kept. The log is empty when
+// it is greater than Last.
+func (l *Log) First() uint64 {
+	return getUint64(l.bucket, "first")
+}
+
+// Last returns the offset of the newest record appended, or zero.
+func (l *Log) Last() uint64 {
+	return l.bucket.Sequence()
+}
+
+// Read returns the records from offset from onwards, oldest first.
+func (l *Log) Read(from uint64) iter.Seq2[LogRecord, error] {
+	return l.read(max(from, 1), time.Time{})
+}
+
+// ReadSince returns the records appended at or after since, oldest first.
+// Segments whose newest record is older than since are skipped unread.
+func (l *Log) ReadSince(since time.Time) iter.Seq2[LogRecord, error] {
+	return l.read(1, since)
+}
+
+func (l *Log) read(from uint64, since time.Time) iter.Seq2[LogRecord, error] {
+	return func(yield func(LogRecord, error) bool) {
+		segments, ends := l.bucket.Bucket([]byte("segments")), l.bucket.Bucket([]byte("ends"))
+		c := segments.Cursor()
+		start := binary.BigEndian.AppendUint64(nil, from)
+		for segment, _ := c.Seek(l.segmentKey(from)); segment != nil; segment, _ = c.Next() {
+			if end := ends.Get(segment); !since.IsZero() && end != nil && int64(binary.BigEndian.Uint64(end)) < since.UnixNano() {
+				continue
+			}
+			rc := segments.Bucket(segment).Cursor()
+			for k, v := rc.Seek(start); k != nil; k, v = rc.Next() {
+				at := time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))
+				if !since.IsZero() && at.Before(since) {
+					continue
+				}
+				record := LogRecord{Offset: binary.BigEndian.Uint64(k), Time: at}
+				if err := l.tx.maUn.Unmarshal(v[8:], &record.Row); err != nil {
+					if !yield(LogRecord{}, err) {
+						return
+					}
+					continue
+				}
+				if !yield(record, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Trim drops the segments holding only records before offset before and
+// returns how many records were dropped. Records of a segment that also
+// holds later ones are kept.
+func (l *Log) Trim(before uint64) (int, error) {
+	if err := l.tx.checkWritable(); err != nil {
+		return 0, err
+	}
+	return l.trim(func(segment []byte, last uint64) bool {
+		return last < before
+	})
+}
+
+// TrimOlderThan drops the oldest segments whose records were all appended
+// before cutoff and returns how many records were dropped.
+func (l *Log) TrimOlderThan(cutoff time.Time) (int, error) {
+	if err := l.tx.checkWritable(); err != nil {
+		return 0, err
+	}
+	ends := l.bucket.Bucket([]byte("ends"))
+	return l.trim(func(segment []byte, _ uint64) bool {
+		end := ends.Get(segment)
+		return end != nil && int64(binary.BigEndian.Uint64(end)) < cutoff.UnixNano()
+	})
+}
+
+// trim drops segments from the oldest for as long as drop returns true for
+// them. last is the offset of the newest record the segment can hold; the
+// segment being appended to is never dropped.
+func (l *Log) trim(drop func(segment []byte, last uint64) bool) (int, error) {
+	segments, ends := l.bucket.Bucket([]byte("segments")), l.bucket.Bucket([]byte("ends"))
+	current := l.segmentKey(l.Last())
+	var dropped [][]byte
+	first := l.First()
+	n := 0
+	c := segments.Cursor()
+	for segment, _ := c.First(); segment != nil && !bytes.Equal(segment, current); segment, _ = c.Next() {
+		last := (binary.BigEndian.Uint64(segment) + 1) * l.segmentSize
+		if !drop(segment, last) {
+			break
+		}
+		dropped = append(dropped, bytes.Clone(segment))
+		n += int(last - first + 1)
+		first = last + 1
+	}
+	for _, segment := range dropped {
+		if err := segments.DeleteBucket(segment); err != nil {
+			return 0, err
+		}
+		if err := ends.Delete(segment); err != nil {
+			return 0, err
+		}
+	}
+	if len(dropped) == 0 {
+		return 0, nil
+	}
+	if err := l.forgetCommits(first); err != nil {
+		return 0, err
+	}
+	return n, putUint64(l.bucket, "first", first)
+}
+
+// forgetCommits drops the tracked commits whose records were all trimmed
+// away before first. The last commit starting before first is kept, as it
+// may have appended records from first on.
+func (l *Log) forgetCommits(first uint64) error {
+	if _, _, err := l.tx.changeSeq(); err != nil {
+		return err
+	}
+	commits := l.bucket.Bucket([]byte("commits"))
+	if commits == nil {
+		return nil
+	}
+	var stale [][]byte
+	c := commits.Cursor()
+	for k, v := c.First(); k != nil && binary.BigEndian.Uint64(v) < first; k, v = c.Next() {
+		stale = append(stale, bytes.Clone(k))
+	}
+	for _, k := range stale[:max(len(stale)-1, 0)] {
+		if err := commits.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segmentKey returns the key of the segment holding offset.
+func (l *Log) segmentKey(offset uint64) []byte {
+	var segment uint64
+	if offset > 0 {
+		segment = (offset - 1) / l.segmentSize
+	}
+	return binary.BigEndian.AppendUint64(nil, segment)
+}
+
+// backupLog is a log in a change stream: its layout, the offsets of its
+// oldest and newest records and the records appended after the stream's
+// From, or all of them when Reset.
+type backupLog struct {
+	Name        string
+	SegmentSize uint64
+	First       uint64
+	Last        uint64
+	Reset       bool
+	Records     map[uint64][]byte
+}
+
+// backupLogs returns every log with its records appended after the commit
+// sequence since.
+func (tx *Tx) backupLogs(since uint64) ([]backupLog, error) {
+	logs := tx.tx.Bucket([]byte(logsBucket))
+	if logs == nil {
+		return nil, nil
+	}
+	var out []backupLog
+	err := logs.ForEachBucket(func(name []byte) error {
+		l, err := tx.LoadLog(string(name))
+		if err != nil {
+			return err
+		}
+		bl := backupLog{
+			Name:        l.name,
+			SegmentSize: l.segmentSize,
+			First:       l.First(),
+			Last:        l.Last(),
+			Reset:       since == 0 || getUint64(l.bucket, "created") > since,
+		}
+		from := l.Last() + 1
+		if bl.Reset {
+			from = bl.First
+		} else if commits := l.bucket.Bucket([]byte("commits")); commits != nil {
+			if k, v := commits.Cursor().Seek(binary.BigEndian.AppendUint64(nil, since+1)); k != nil {
+				from = binary.BigEndian.Uint64(v)
+			}
+		}
+		from = max(from, bl.First)
+		segments := l.bucket.Bucket([]byte("segments"))
+		c := segments.Cursor()
+		start := binary.BigEndian.AppendUint64(nil, from)
+		for segment, _ := c.Seek(l.segmentKey(from)); segment != nil; segment, _ = c.Next() {
+			rc := segments.Bucket(segment).Cursor()
+			for k, v := rc.Seek(start); k != nil; k, v = rc.Next() {
+				if bl.Records == nil {
+					bl.Records = make(map[uint64][]byte)
+				}
+				bl.Records[binary.BigEndian.Uint64(k)] = bytes.Clone(v)
+			}
+		}
+		out = append(out, bl)
+		return nil
+	})
+	return out, err
+}
+
+// applyLogs brings the logs up to date with a change stream, dropping those
+// it does not list.
+func (tx *Tx) applyLogs(logs []backupLog) error {
+	keep := make(map[string]bool, len(logs))
+	for _, bl := range logs {
+		keep[bl.Name] = true
+		l, err := tx.LoadLog(bl.Name)
+		if err == nil && bl.Reset {
+			if err := tx.DropLog(bl.Name); err != nil {
+				return err
+			}
+		}
+		if err != nil || bl.Reset {
+			if l, err = tx.CreateLog(bl.Name, int(bl.SegmentSize)); err != nil {
+				return err
+			}
+		}
+		if err := l.applyRecords(bl.Records); err != nil {
+			return err
+		}
+		if bl.Last > l.Last() {
+			if err := l.bucket.SetSequence(bl.Last); err != nil {
+				return err
+			}
+		}
+		if _, err := l.Trim(bl.First); err != nil {
+			return err
+		}
+		if err := putUint64(l.bucket, "first", bl.First); err != nil {
+			return err
+		}
+	}
+	root := tx.tx.Bucket([]byte(logsBucket))
+	if root == nil {
+		return nil
+	}
+	var dropped []string
+	err := root.ForEachBucket(func(name []byte) error {
+		if !keep[string(name)] {
+			dropped = append(dropped, string(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range dropped {
+		if err := tx.DropLog(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRecords stores records read from a change stream at their offsets.
+func (l *Log) applyRecords(records map[uint64][]byte) error {
+	offsets := slices.Sorted(maps.Keys(records))
+	for _, offset := range offsets {
+		record := records[offset]
+		segment := l.segmentKey(offset)
+		bucket, err := l.bucket.Bucket([]byte("segments")).CreateBucketIfNotExists(segment)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(binary.BigEndian.AppendUint64(nil, offset), record); err != nil {
+			return err
+		}
+		if err := l.extendSegment(segment, int64(binary.BigEndian.Uint64(record[:8]))); err != nil {
+			return err
+		}
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	return l.trackAppend(offsets[0])
+}
@@ -0,0 +1,91 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTx_Log(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	db.SetClock(clock)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	audit, err := tx.CreateLog("audit", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	if _, err := tx.CreateLog("audit", 3); !errors.As(err, &te) || te.Code != ErrCodeLogAlreadyExists {
+		t.Fatalf("expected ErrLogAlreadyExists, got %v", err)
+	}
+	for i := range 8 {
+		offset, err := audit.Append(map[string]any{"action": "login", "n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != uint64(i+1) {
+			t.Fatalf("expected offset %d, got %d", i+1, offset)
+		}
+		clock.Advance(time.Minute)
+	}
+	offsets := func(seq func(func(LogRecord, error) bool)) []uint64 {
+		var out []uint64
+		for r, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, r.Offset)
+		}
+		return out
+	}
+	if got := offsets(audit.Read(6)); len(got) != 3 || got[0] != 6 || got[2] != 8 {
+		t.Errorf("expected offsets 6..8, got %v", got)
+	}
+	since := time.Date(2024, 1, 1, 0, 4, 0, 0, time.UTC)
+	if got := offsets(audit.ReadSince(since)); len(got) != 4 || got[0] != 5 {
+		t.Errorf("expected offsets 5..8 since minute 4, got %v", got)
+	}
+
+	// Segments hold offsets 1-3, 4-6 and 7-8; only whole segments go.
+	n, err := audit.Trim(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || audit.First() != 4 {
+		t.Errorf("expected 3 records trimmed up to offset 4, got %d and %d", n, audit.First())
+	}
+	n, err = audit.TrimOlderThan(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || audit.First() != 7 || audit.Last() != 8 {
+		t.Errorf("expected the current segment kept, got %d trimmed and offsets %d..%d", n, audit.First(), audit.Last())
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.view(func(tx *Tx) error {
+		audit, err := tx.LoadLog("audit")
+		if err != nil {
+			return err
+		}
+		if got := offsets(audit.Read(0)); len(got) != 2 || got[0] != 7 {
+			t.Errorf("expected offsets 7 and 8 after reloading, got %v", got)
+		}
+		if _, err := tx.LoadLog("missing"); !errors.As(err, &te) || te.Code != ErrCodeLogNotFound {
+			t.Errorf("expected ErrLogNotFound, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
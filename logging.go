@@ -0,0 +1,63 @@
+package thunder
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Logger receives the database's log messages as a message and alternating
+// keys and values. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// SetLogger registers the logger the database reports to, or removes it when
+// l is nil. Without a warning handler, schema warnings are logged. Queries
+// that scan a relation despite having ranges are logged at debug level.
+func (d *DB) SetLogger(l Logger) {
+	d.logger = l
+}
+
+// SetSlowQueryThreshold logs, at warn level, every Select, Update or Delete
+// that takes longer than threshold, with its ranges, plan and elapsed time.
+// A Select is timed from the start to the end of its iteration. Zero turns
+// the slow-query log off.
+func (d *DB) SetSlowQueryThreshold(threshold time.Duration) {
+	d.slowQuery = threshold
+}
+
+// logSlowQuery logs the query if it took longer than the slow-query
+// threshold.
+func (pr *Persistent) logSlowQuery(op string, ranges map[string]*keyRange, plan QueryPlan, elapsed time.Duration, rows int) {
+	d := pr.tx.db
+	if d.logger == nil || d.slowQuery <= 0 || elapsed <= d.slowQuery {
+		return
+	}
+	d.logger.Warn("thunder: slow query",
+		"op", op,
+		"relation", pr.relation,
+		"ranges", describeRanges(ranges),
+		"plan", plan.String(),
+		"elapsed", elapsed,
+		"rows", rows,
+	)
+}
+
+func (pr *Persistent) slowQueryLogged() bool {
+	return !pr.ephemeral && pr.tx.db != nil && pr.tx.db.logger != nil && pr.tx.db.slowQuery > 0
+}
+
+// describeRanges renders ranges as `column range` clauses in column order.
+func describeRanges(ranges map[string]*keyRange) string {
+	specs := rangeSpecs(ranges)
+	clauses := make([]string, 0, len(specs))
+	for _, name := range slices.Sorted(maps.Keys(specs)) {
+		clauses = append(clauses, name+" "+specs[name].String())
+	}
+	return strings.Join(clauses, ", ")
+}
@@ -0,0 +1,100 @@
+package thunder
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type recordedLog struct {
+	level string
+	msg   string
+	attrs map[string]any
+}
+
+type recordingLogger struct {
+	logs []recordedLog
+}
+
+func (l *recordingLogger) record(level, msg string, args []any) {
+	attrs := map[string]any{}
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.logs = append(l.logs, recordedLog{level: level, msg: msg, attrs: attrs})
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record("debug", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record("info", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record("warn", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record("error", msg, args) }
+
+func TestDB_SlowQueryLog(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	logger := &recordingLogger{}
+	db.SetLogger(logger)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"name": {Indexed: true},
+		"age":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"name": "bob", "age": 30}); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("name", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectAll := func() {
+		t.Helper()
+		seq, err := users.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	selectAll()
+	if len(logger.logs) != 0 {
+		t.Fatalf("expected no logs without a threshold, got %v", logger.logs)
+	}
+
+	db.SetSlowQueryThreshold(time.Nanosecond)
+	selectAll()
+	if err := users.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.logs) != 2 {
+		t.Fatalf("expected two slow query logs, got %v", logger.logs)
+	}
+	for i, op := range []string{SpanSelect, SpanDelete} {
+		l := logger.logs[i]
+		if l.level != "warn" || l.msg != "thunder: slow query" {
+			t.Fatalf("unexpected log %v", l)
+		}
+		if l.attrs["op"] != op || l.attrs["relation"] != "users" || l.attrs["ranges"] != `name = ("bob")` {
+			t.Fatalf("unexpected attributes %v", l.attrs)
+		}
+		if plan, _ := l.attrs["plan"].(string); plan == "" {
+			t.Fatalf("expected a plan in %v", l.attrs)
+		}
+		if elapsed, _ := l.attrs["elapsed"].(time.Duration); elapsed <= 0 {
+			t.Fatalf("expected an elapsed time in %v", l.attrs)
+		}
+	}
+	if rows := logger.logs[0].attrs["rows"]; rows != 1 {
+		t.Fatalf("expected one selected row, got %v", rows)
+	}
+}
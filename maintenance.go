@@ -0,0 +1,251 @@
+package thunder
+
+import (
+	"context"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openkvlab/boltdb"
+)
+
+// JobFunc is a maintenance job run by DB.RunMaintenance.
+type JobFunc func(ctx context.Context, d *DB) error
+
+// JobRun reports one run of a maintenance job to the hook set with
+// OnMaintenance.
+type JobRun struct {
+	Job     string
+	Started time.Time
+	Elapsed time.Duration
+	Err     error
+}
+
+type scheduledJob struct {
+	interval time.Duration
+	run      JobFunc
+}
+
+type maintenance struct {
+	mu     sync.Mutex
+	jobs   map[string]scheduledJob
+	jitter float64
+	paused bool
+	hook   func(JobRun)
+	// wake tells RunMaintenance that the schedule has changed.
+	wake chan struct{}
+}
+
+func (m *maintenance) changed() {
+	if m.wake == nil {
+		m.wake = make(chan struct{}, 1)
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// JobAnalyze refreshes the planner statistics of every relation.
+func JobAnalyze(ctx context.Context, d *DB) error {
+	return d.ForEachRelation(func(p *Persistent) error {
+		return p.Analyze()
+	})
+}
+
+// JobSweepExpired deletes the rows that have outlived the TTL of their
+// relation.
+func JobSweepExpired(ctx context.Context, d *DB) error {
+	_, err := d.SweepExpired()
+	return err
+}
+
+// JobRetention enforces the retention of every relation and the history
+// horizons.
+func JobRetention(ctx context.Context, d *DB) error {
+	_, err := d.EnforceRetention()
+	return err
+}
+
+// JobCompactionCheck returns a job that logs a warning when more than maxFree
+// of the database file is free pages, as a hint to run Compact during a
+// quiet period. Compacting itself needs the DB to be idle, so it is
+// left to the application.
+func JobCompactionCheck(maxFree float64) JobFunc {
+	return func(ctx context.Context, d *DB) error {
+		src, ok := d.backend.(*boltBackend)
+		if !ok || d.logger == nil {
+			return nil
+		}
+		var size int64
+		err := src.db.View(func(tx *boltdb.Tx) error {
+			size = tx.Size()
+			return nil
+		})
+		if err != nil || size == 0 {
+			return err
+		}
+		free := src.db.Stats().FreeAlloc
+		if float64(free)/float64(size) > maxFree {
+			d.logger.Warn("thunder: database file is fragmented, consider Compact",
+				"free", free,
+				"size", size,
+			)
+		}
+		return nil
+	}
+}
+
+// ScheduleJob makes RunMaintenance run job every interval under name,
+// replacing any job of that name. A zero interval or nil job removes it.
+func (d *DB) ScheduleJob(name string, interval time.Duration, job JobFunc) {
+	m := &d.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	defer m.changed()
+	if interval <= 0 || job == nil {
+		delete(m.jobs, name)
+		return
+	}
+	if m.jobs == nil {
+		m.jobs = make(map[string]scheduledJob)
+	}
+	m.jobs[name] = scheduledJob{interval: interval, run: job}
+}
+
+// SetMaintenanceJitter spreads the runs of every job by up to fraction of
+// its interval either way, so that the jobs of many processes do not fire
+// together. It takes effect from each job's next run.
+func (d *DB) SetMaintenanceJitter(fraction float64) {
+	m := &d.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jitter = min(max(fraction, 0), 1)
+}
+
+// PauseMaintenance skips the jobs falling due until ResumeMaintenance,
+// such as during a bulk load. A job already running finishes.
+func (d *DB) PauseMaintenance() {
+	m := &d.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = true
+}
+
+// ResumeMaintenance undoes PauseMaintenance.
+func (d *DB) ResumeMaintenance() {
+	m := &d.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = false
+}
+
+// OnMaintenance registers fn to be called after every job run, or removes
+// the hook when fn is nil.
+func (d *DB) OnMaintenance(fn func(JobRun)) {
+	m := &d.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook = fn
+}
+
+// RunJob runs the scheduled job called name now, whether or not maintenance
+// is paused, and returns its error.
+func (d *DB) RunJob(ctx context.Context, name string) error {
+	m := &d.maintenance
+	m.mu.Lock()
+	job, ok := m.jobs[name]
+	m.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound(name)
+	}
+	return d.runJob(ctx, name, job.run)
+}
+
+func (d *DB) runJob(ctx context.Context, name string, job JobFunc) error {
+	started := time.Now()
+	err := job(ctx, d)
+	m := &d.maintenance
+	m.mu.Lock()
+	hook := m.hook
+	m.mu.Unlock()
+	if hook != nil {
+		hook(JobRun{Job: name, Started: started, Elapsed: time.Since(started), Err: err})
+	}
+	if err != nil && d.logger != nil {
+		d.logger.Error("thunder: maintenance job failed", "job", name, "error", err)
+	}
+	return err
+}
+
+// RunMaintenance runs the scheduled jobs, one at a time, each every interval
+// from its last run, until ctx is done, then returns ctx's error. Failed runs
+// are logged and retried at the next interval. Jobs scheduled while it runs
+// are picked up.
+func (d *DB) RunMaintenance(ctx context.Context) error {
+	m := &d.maintenance
+	m.mu.Lock()
+	m.changed()
+	wake := m.wake
+	m.mu.Unlock()
+	next := make(map[string]time.Time)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		m.mu.Lock()
+		jobs, jitter, paused := maps.Clone(m.jobs), m.jitter, m.paused
+		m.mu.Unlock()
+		now := time.Now()
+		for name := range next {
+			if _, ok := jobs[name]; !ok {
+				delete(next, name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(jobs)) {
+			at, ok := next[name]
+			if !ok {
+				next[name] = now.Add(jittered(jobs[name].interval, jitter))
+				continue
+			}
+			if at.After(now) {
+				continue
+			}
+			if !paused {
+				d.runJob(ctx, name, jobs[name].run)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+			}
+			next[name] = time.Now().Add(jittered(jobs[name].interval, jitter))
+		}
+		wait := time.Duration(-1)
+		for _, at := range next {
+			if until := time.Until(at); wait < 0 || until < wait {
+				wait = max(until, 0)
+			}
+		}
+		timer.Stop()
+		var fire <-chan time.Time
+		if wait >= 0 {
+			timer.Reset(wait)
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-fire:
+		case <-wake:
+		}
+	}
+}
+
+// jittered returns interval moved by a random amount of up to fraction of it
+// either way.
+func jittered(interval time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return interval
+	}
+	return interval + time.Duration((rand.Float64()*2-1)*fraction*float64(interval))
+}
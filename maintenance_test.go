@@ -0,0 +1,75 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDB_Maintenance(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var runs atomic.Int32
+	failing := errors.New("boom")
+	db.ScheduleJob("tick", 5*time.Millisecond, func(ctx context.Context, d *DB) error {
+		runs.Add(1)
+		return failing
+	})
+	db.ScheduleJob("retention", time.Hour, JobRetention)
+	db.SetMaintenanceJitter(0.2)
+	reported := make(chan JobRun, 100)
+	db.OnMaintenance(func(r JobRun) {
+		select {
+		case reported <- r:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- db.RunMaintenance(ctx) }()
+	waitFor := func(n int32) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for runs.Load() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d runs, got %d", n, runs.Load())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(2)
+	r := <-reported
+	if r.Job != "tick" || !errors.Is(r.Err, failing) {
+		t.Errorf("expected the failed tick reported, got %+v", r)
+	}
+
+	db.PauseMaintenance()
+	time.Sleep(20 * time.Millisecond)
+	paused := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != paused {
+		t.Errorf("expected no runs while paused, got %d more", runs.Load()-paused)
+	}
+	db.ResumeMaintenance()
+	waitFor(paused + 1)
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if err := db.RunJob(context.Background(), "retention"); err != nil {
+		t.Errorf("expected the retention job to run, got %v", err)
+	}
+	var te *ThunderError
+	if err := db.RunJob(context.Background(), "missing"); !errors.As(err, &te) || te.Code != ErrCodeJobNotFound {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
@@ -4,6 +4,13 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
 	"github.com/vmihailenco/msgpack/v5"
 	"rsc.io/ordered"
 )
@@ -21,10 +28,17 @@ type MarshalUnmarshaler interface {
 	Unmarshaler
 }
 
+// ColumnUnmarshaler is implemented by codecs that can decode some of the
+// columns of a stored row without decoding the others.
+type ColumnUnmarshaler interface {
+	UnmarshalColumns(data []byte, columns []string, v *map[string]any) error
+}
+
 var (
 	JsonMaUn    = jsonMarshalUnmarshaler{}
 	GobMaUn     = gobMarshalUnmarshaler{}
 	MsgpackMaUn = msgpackMarshalUnmarshaler{}
+	CborMaUn    = cborMarshalUnmarshaler{}
 	orderedMa   = orderedMarshaler{}
 )
 
@@ -66,15 +80,128 @@ func (m *msgpackMarshalUnmarshaler) Unmarshal(data []byte, v any) error {
 	return msgpack.Unmarshal(data, v)
 }
 
+// UnmarshalColumns walks the encoded map and skips the values of the columns
+// not asked for.
+func (m *msgpackMarshalUnmarshaler) UnmarshalColumns(data []byte, columns []string, v *map[string]any) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+	if *v == nil {
+		*v = make(map[string]any, len(columns))
+	}
+	for range n {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(columns, key) {
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if (*v)[key], err = dec.DecodeInterface(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cborMarshalUnmarshaler encodes with CBOR, keeping integers as integers and
+// times as tagged RFC 3339 text with nanoseconds. Non-negative integers
+// decode as uint64, negative ones as int64 and maps as map[string]any.
+type cborMarshalUnmarshaler struct{}
+
+var (
+	cborEnc, _ = cbor.EncOptions{
+		Time:    cbor.TimeRFC3339Nano,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	cborDec, _ = cbor.DecOptions{
+		DefaultMapType: reflect.TypeFor[map[string]any](),
+	}.DecMode()
+)
+
+func (c *cborMarshalUnmarshaler) Marshal(v any) ([]byte, error) {
+	return cborEnc.Marshal(v)
+}
+
+func (c *cborMarshalUnmarshaler) Unmarshal(data []byte, v any) error {
+	return cborDec.Unmarshal(data, v)
+}
+
 type orderedMarshaler struct{}
 
+// keyValues replaces the values in v that the ordered encoding cannot hold
+// with ones that sort the same way: a time.Time becomes its UTC Unix time in
+// nanoseconds, a *big.Int or *big.Rat its decimal key, and a value of a type
+// registered with RegisterType its key. Floats get a total order: -0 keys as
+// 0 and every NaN keys the same, below -Inf. v is copied only when something
+// is replaced.
+func keyValues(v []any) []any {
+	var out []any
+	for i, x := range v {
+		var k any
+		switch x := x.(type) {
+		case time.Time:
+			k = x.UTC().UnixNano()
+		case float64:
+			if x != 0 || !math.Signbit(x) {
+				continue
+			}
+			k = float64(0)
+		case float32:
+			if x != 0 || !math.Signbit(float64(x)) {
+				continue
+			}
+			k = float32(0)
+		case *big.Int:
+			k, _ = decimalKey(new(big.Rat).SetInt(x))
+		case *big.Rat:
+			key, ok := decimalKey(x)
+			if !ok {
+				continue
+			}
+			k = key
+		default:
+			key, ok := customKey(x)
+			if !ok {
+				continue
+			}
+			k = key
+		}
+		if out == nil {
+			out = slices.Clone(v)
+		}
+		out[i] = k
+	}
+	if out == nil {
+		return v
+	}
+	return out
+}
+
 func (o *orderedMarshaler) Marshal(v []any) ([]byte, error) {
+	v = keyValues(v)
 	if !ordered.CanEncode(v...) {
 		return nil, ErrCannotMarshal(v)
 	}
 	return ordered.Encode(v...), nil
 }
 
+// Append is like Marshal but appends the encoding to buf.
+func (o *orderedMarshaler) Append(buf []byte, v ...any) ([]byte, error) {
+	v = keyValues(v)
+	if !ordered.CanEncode(v...) {
+		return nil, ErrCannotMarshal(v)
+	}
+	return ordered.Append(buf, v...), nil
+}
+
 func (o *orderedMarshaler) Unmarshal(data []byte, v *[]any) error {
 	decoded, err := ordered.DecodeAny(data)
 	if err != nil {
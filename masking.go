@@ -0,0 +1,138 @@
+package thunder
+
+import "slices"
+
+// Redacted replaces the value of a column the reading identity may not see.
+const Redacted = "[REDACTED]"
+
+// SetIdentity sets the identity the transaction acts as. Column masks are
+// applied to reads according to it; the empty identity is never masked.
+func (tx *Tx) SetIdentity(identity string) {
+	tx.identity = identity
+}
+
+// Identity returns the identity set with SetIdentity.
+func (tx *Tx) Identity() string {
+	return tx.identity
+}
+
+// MaskColumn hides column from the given identities: Select returns Redacted
+// in its place, and filtering on it fails with ErrColumnMasked so its values
+// cannot be probed through ranges.
+func (pr *Persistent) MaskColumn(column string, identities ...string) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if !slices.Contains(pr.columns, column) {
+		return ErrFieldNotFound(column)
+	}
+	masks := pr.masks[column]
+	for _, identity := range identities {
+		if !slices.Contains(masks, identity) {
+			masks = append(masks, identity)
+		}
+	}
+	if pr.masks == nil {
+		pr.masks = make(map[string][]string)
+	}
+	pr.masks[column] = masks
+	return pr.saveMasks()
+}
+
+// UnmaskColumn lifts the mask on column for the given identities.
+func (pr *Persistent) UnmaskColumn(column string, identities ...string) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	masks := slices.DeleteFunc(pr.masks[column], func(identity string) bool {
+		return slices.Contains(identities, identity)
+	})
+	if len(masks) == 0 {
+		delete(pr.masks, column)
+	} else {
+		pr.masks[column] = masks
+	}
+	return pr.saveMasks()
+}
+
+// MaskedColumns returns the columns hidden from identity.
+func (pr *Persistent) MaskedColumns(identity string) []string {
+	var columns []string
+	for column, identities := range pr.masks {
+		if slices.Contains(identities, identity) {
+			columns = append(columns, column)
+		}
+	}
+	slices.Sort(columns)
+	return columns
+}
+
+// loadMasks reads the masks applying to relation, which are those of its
+// access relation.
+func (tx *Tx) loadMasks(relation string) (map[string][]string, error) {
+	owner := tx.accessRelation(relation)
+	bucket := tx.tx.Bucket([]byte(owner))
+	if bucket == nil || bucket.Bucket([]byte("meta")) == nil {
+		return nil, nil
+	}
+	metaBucket := bucket.Bucket([]byte("meta"))
+	masksBytes := metaBucket.Get([]byte("masks"))
+	if masksBytes == nil {
+		return nil, nil
+	}
+	maUn, err := tx.relationCodec(metaBucket)
+	if err != nil {
+		return nil, err
+	}
+	var masks map[string][]string
+	if err := maUn.Unmarshal(masksBytes, &masks); err != nil {
+		return nil, ErrCorruptedMetaDataEntry(owner, "masks")
+	}
+	return masks, nil
+}
+
+func (pr *Persistent) saveMasks() error {
+	pr.tx.touch(pr.relation)
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	if len(pr.masks) == 0 {
+		return metaBucket.Delete([]byte("masks"))
+	}
+	masksBytes, err := pr.maUn.Marshal(pr.masks)
+	if err != nil {
+		return err
+	}
+	return metaBucket.Put([]byte("masks"), masksBytes)
+}
+
+// maskedFor returns the columns masked for the transaction's identity, or
+// nil when nothing is masked.
+func (pr *Persistent) maskedFor() []string {
+	if len(pr.masks) == 0 || pr.tx.identity == "" {
+		return nil
+	}
+	return pr.MaskedColumns(pr.tx.identity)
+}
+
+// checkMaskedRanges rejects filters reading a masked column, through a path
+// into it or an index over it included, which would tell its values apart.
+func (pr *Persistent) checkMaskedRanges(masked []string, ranges map[string]*keyRange) error {
+	if len(masked) == 0 {
+		return nil
+	}
+	for name := range ranges {
+		for _, col := range pr.filteredColumns(name) {
+			if slices.Contains(masked, col) {
+				return ErrColumnMasked(col)
+			}
+		}
+	}
+	return nil
+}
+
+func redact(value map[string]any, masked []string) {
+	for _, column := range masked {
+		if _, ok := value[column]; ok {
+			value[column] = Redacted
+		}
+	}
+}
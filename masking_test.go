@@ -0,0 +1,129 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPersistent_MaskColumn(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	customers, err := tx.CreatePersistent("customers", map[string]ColumnSpec{
+		"id":    {Unique: true},
+		"email": {Indexed: true},
+		"plan":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := customers.Insert(map[string]any{"id": int64(1), "email": "a@example.com", "plan": "pro"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := customers.MaskColumn("email", "support"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(identity string) map[string]any {
+		t.Helper()
+		tx, err := db.Begin(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		tx.SetIdentity(identity)
+		customers, err := tx.LoadPersistent("customers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := customers.Select(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			return row
+		}
+		t.Fatal("no rows")
+		return nil
+	}
+	if row := read("support"); row["email"] != Redacted || row["plan"] != "pro" {
+		t.Fatalf("expected masked email for support, got %v", row)
+	}
+	if row := read("billing"); row["email"] != "a@example.com" {
+		t.Fatalf("expected clear email for billing, got %v", row)
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tx.SetIdentity("support")
+	customers, err = tx.LoadPersistent("customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("email", "a@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = customers.Select(ranges)
+	var thunderErr *ThunderError
+	if !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeColumnMasked {
+		t.Fatalf("expected ErrCodeColumnMasked when filtering on a masked column, got %v", err)
+	}
+}
+
+func TestPersistent_MaskColumnPaths(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	err := db.update(func(tx *Tx) error {
+		people, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+			"id":      {Unique: true},
+			"profile": {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := people.Insert(map[string]any{"id": int64(1), "profile": map[string]any{"ssn": "123-45-6789"}}); err != nil {
+			return err
+		}
+		return people.MaskColumn("profile", "support")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.view(func(tx *Tx) error {
+		tx.SetIdentity("support")
+		people, err := tx.LoadPersistent("people")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("profile.ssn", "123-45-6789"))
+		if err != nil {
+			return err
+		}
+		_, err = people.Select(ranges)
+		var thunderErr *ThunderError
+		if !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeColumnMasked {
+			t.Fatalf("expected ErrCodeColumnMasked when filtering on a path into a masked column, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,439 @@
+package thunder
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/btree"
+	boltdb_errors "github.com/openkvlab/boltdb/errors"
+)
+
+// memoryBackend is a Backend holding everything in memory, for tests and
+// caches that need no durability. Buckets are copy-on-write btrees: a write
+// transaction clones the buckets it touches and publishes its root on commit,
+// so readers keep the snapshot they started with.
+type memoryBackend struct {
+	mu     sync.Mutex
+	root   *memBucket
+	closed bool
+	writer sync.Mutex
+	lastID atomic.Int64
+}
+
+// NewMemoryBackend returns an empty in-memory Backend. Its contents are lost
+// when it is closed.
+func NewMemoryBackend() Backend {
+	return &memoryBackend{root: newMemBucket(0)}
+}
+
+// OpenMemory opens an empty database kept entirely in memory.
+func OpenMemory(maUn MarshalUnmarshaler) (*DB, error) {
+	return OpenBackend(maUn, NewMemoryBackend())
+}
+
+func (m *memoryBackend) Begin(writable bool) (BackendTx, error) {
+	if writable {
+		m.writer.Lock()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		if writable {
+			m.writer.Unlock()
+		}
+		return nil, boltdb_errors.ErrDatabaseNotOpen
+	}
+	return &memTx{
+		backend:  m,
+		id:       m.lastID.Add(1),
+		writable: writable,
+		root:     m.root,
+	}, nil
+}
+
+func (m *memoryBackend) ReadOnly() bool {
+	return false
+}
+
+func (m *memoryBackend) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.root = nil
+	return nil
+}
+
+type memItem struct {
+	key    []byte
+	value  []byte
+	bucket *memBucket
+}
+
+func memItemLess(a, b memItem) bool {
+	return bytes.Compare(a.key, b.key) < 0
+}
+
+type memBucket struct {
+	items *btree.BTreeG[memItem]
+	seq   uint64
+	// owner is the transaction that created this copy; only it may modify it.
+	owner int64
+}
+
+func newMemBucket(owner int64) *memBucket {
+	return &memBucket{items: btree.NewG(32, memItemLess), owner: owner}
+}
+
+func (b *memBucket) child(name []byte) *memBucket {
+	item, ok := b.items.Get(memItem{key: name})
+	if !ok {
+		return nil
+	}
+	return item.bucket
+}
+
+type memTx struct {
+	backend  *memoryBackend
+	id       int64
+	writable bool
+	root     *memBucket
+	closed   bool
+}
+
+func (t *memTx) ID() int {
+	return int(t.id)
+}
+
+// resolve returns the current copy of the bucket at path, or nil if it does
+// not exist.
+func (t *memTx) resolve(path [][]byte) *memBucket {
+	b := t.root
+	for _, name := range path {
+		if b == nil {
+			return nil
+		}
+		b = b.child(name)
+	}
+	return b
+}
+
+// mutable returns a copy of the bucket at path owned by t, cloning it and its
+// ancestors on first use.
+func (t *memTx) mutable(path [][]byte) (*memBucket, error) {
+	switch {
+	case t.closed:
+		return nil, boltdb_errors.ErrTxClosed
+	case !t.writable:
+		return nil, boltdb_errors.ErrTxNotWritable
+	}
+	if t.root.owner != t.id {
+		t.root = &memBucket{items: t.root.items.Clone(), seq: t.root.seq, owner: t.id}
+	}
+	b := t.root
+	for _, name := range path {
+		child := b.child(name)
+		if child == nil {
+			return nil, boltdb_errors.ErrBucketNotFound
+		}
+		if child.owner != t.id {
+			child = &memBucket{items: child.items.Clone(), seq: child.seq, owner: t.id}
+			b.items.ReplaceOrInsert(memItem{key: name, bucket: child})
+		}
+		b = child
+	}
+	return b, nil
+}
+
+func (t *memTx) Bucket(name []byte) BackendBucket {
+	return (&memHandle{tx: t}).Bucket(name)
+}
+
+func (t *memTx) CreateBucket(name []byte) (BackendBucket, error) {
+	return (&memHandle{tx: t}).CreateBucket(name)
+}
+
+func (t *memTx) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	return (&memHandle{tx: t}).CreateBucketIfNotExists(name)
+}
+
+func (t *memTx) DeleteBucket(name []byte) error {
+	return (&memHandle{tx: t}).DeleteBucket(name)
+}
+
+func (t *memTx) ForEach(fn func(name []byte, b BackendBucket) error) error {
+	root := &memHandle{tx: t}
+	return root.ForEachBucket(func(name []byte) error {
+		return fn(name, root.Bucket(name))
+	})
+}
+
+func (t *memTx) Commit() error {
+	switch {
+	case t.closed:
+		return boltdb_errors.ErrTxClosed
+	case !t.writable:
+		return boltdb_errors.ErrTxNotWritable
+	}
+	t.closed = true
+	defer t.backend.writer.Unlock()
+	t.backend.mu.Lock()
+	defer t.backend.mu.Unlock()
+	if t.backend.closed {
+		return boltdb_errors.ErrDatabaseNotOpen
+	}
+	t.backend.root = t.root
+	return nil
+}
+
+func (t *memTx) Rollback() error {
+	if t.closed {
+		return boltdb_errors.ErrTxClosed
+	}
+	t.closed = true
+	if t.writable {
+		t.backend.writer.Unlock()
+	}
+	return nil
+}
+
+// memHandle names a bucket by its path so that it keeps working after the
+// transaction replaces the bucket with its own copy.
+type memHandle struct {
+	tx   *memTx
+	path [][]byte
+}
+
+func (h *memHandle) childPath(name []byte) [][]byte {
+	return append(slices.Clip(h.path), bytes.Clone(name))
+}
+
+func (h *memHandle) Get(key []byte) []byte {
+	b := h.tx.resolve(h.path)
+	if b == nil {
+		return nil
+	}
+	item, ok := b.items.Get(memItem{key: key})
+	if !ok || item.bucket != nil {
+		return nil
+	}
+	return item.value
+}
+
+func (h *memHandle) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return boltdb_errors.ErrKeyRequired
+	}
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return err
+	}
+	if b.child(key) != nil {
+		return boltdb_errors.ErrIncompatibleValue
+	}
+	// Keep empty values distinguishable from missing keys, as bolt does.
+	b.items.ReplaceOrInsert(memItem{key: bytes.Clone(key), value: append([]byte{}, value...)})
+	return nil
+}
+
+func (h *memHandle) Delete(key []byte) error {
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return err
+	}
+	if b.child(key) != nil {
+		return boltdb_errors.ErrIncompatibleValue
+	}
+	b.items.Delete(memItem{key: key})
+	return nil
+}
+
+func (h *memHandle) Cursor() BackendCursor {
+	return &memCursor{h: h}
+}
+
+func (h *memHandle) ForEach(fn func(k, v []byte) error) error {
+	c := h.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *memHandle) ForEachBucket(fn func(name []byte) error) error {
+	return h.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		return fn(k)
+	})
+}
+
+func (h *memHandle) Bucket(name []byte) BackendBucket {
+	b := h.tx.resolve(h.path)
+	if b == nil || b.child(name) == nil {
+		return nil
+	}
+	return &memHandle{tx: h.tx, path: h.childPath(name)}
+}
+
+func (h *memHandle) CreateBucket(name []byte) (BackendBucket, error) {
+	if len(name) == 0 {
+		return nil, boltdb_errors.ErrBucketNameRequired
+	}
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return nil, err
+	}
+	if item, ok := b.items.Get(memItem{key: name}); ok {
+		if item.bucket != nil {
+			return nil, boltdb_errors.ErrBucketExists
+		}
+		return nil, boltdb_errors.ErrIncompatibleValue
+	}
+	b.items.ReplaceOrInsert(memItem{key: bytes.Clone(name), bucket: newMemBucket(h.tx.id)})
+	return &memHandle{tx: h.tx, path: h.childPath(name)}, nil
+}
+
+func (h *memHandle) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	if bucket := h.Bucket(name); bucket != nil {
+		return bucket, nil
+	}
+	return h.CreateBucket(name)
+}
+
+func (h *memHandle) DeleteBucket(name []byte) error {
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return err
+	}
+	item, ok := b.items.Get(memItem{key: name})
+	switch {
+	case !ok:
+		return boltdb_errors.ErrBucketNotFound
+	case item.bucket == nil:
+		return boltdb_errors.ErrIncompatibleValue
+	}
+	b.items.Delete(item)
+	return nil
+}
+
+func (h *memHandle) Sequence() uint64 {
+	b := h.tx.resolve(h.path)
+	if b == nil {
+		return 0
+	}
+	return b.seq
+}
+
+func (h *memHandle) SetSequence(v uint64) error {
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return err
+	}
+	b.seq = v
+	return nil
+}
+
+func (h *memHandle) NextSequence() (uint64, error) {
+	b, err := h.tx.mutable(h.path)
+	if err != nil {
+		return 0, err
+	}
+	b.seq++
+	return b.seq, nil
+}
+
+// memCursor remembers the key it is positioned on and looks up its neighbour
+// on every move, so the bucket may be modified while it is walked.
+type memCursor struct {
+	h       *memHandle
+	key     []byte
+	started bool
+}
+
+func (c *memCursor) position(item memItem, ok bool) ([]byte, []byte) {
+	c.started = true
+	if !ok {
+		c.key = nil
+		return nil, nil
+	}
+	c.key = item.key
+	if item.bucket != nil {
+		return item.key, nil
+	}
+	return item.key, item.value
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	b := c.h.tx.resolve(c.h.path)
+	if b == nil {
+		return c.position(memItem{}, false)
+	}
+	return c.position(b.items.Min())
+}
+
+func (c *memCursor) Last() ([]byte, []byte) {
+	b := c.h.tx.resolve(c.h.path)
+	if b == nil {
+		return c.position(memItem{}, false)
+	}
+	return c.position(b.items.Max())
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.after(seek, true)
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	switch {
+	case !c.started:
+		return c.First()
+	case c.key == nil:
+		return nil, nil
+	}
+	return c.after(c.key, false)
+}
+
+func (c *memCursor) Prev() ([]byte, []byte) {
+	switch {
+	case !c.started:
+		return c.Last()
+	case c.key == nil:
+		return nil, nil
+	}
+	b := c.h.tx.resolve(c.h.path)
+	if b == nil {
+		return c.position(memItem{}, false)
+	}
+	var found memItem
+	ok := false
+	b.items.DescendLessOrEqual(memItem{key: c.key}, func(item memItem) bool {
+		if bytes.Equal(item.key, c.key) {
+			return true
+		}
+		found, ok = item, true
+		return false
+	})
+	return c.position(found, ok)
+}
+
+func (c *memCursor) after(key []byte, inclusive bool) ([]byte, []byte) {
+	b := c.h.tx.resolve(c.h.path)
+	if b == nil {
+		return c.position(memItem{}, false)
+	}
+	var found memItem
+	ok := false
+	b.items.AscendGreaterOrEqual(memItem{key: key}, func(item memItem) bool {
+		if !inclusive && bytes.Equal(item.key, key) {
+			return true
+		}
+		found, ok = item, true
+		return false
+	})
+	return c.position(found, ok)
+}
@@ -0,0 +1,94 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenMemory_SnapshotsAndRollback(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("items", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"name": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, name := range []string{"a", "b", "c"} {
+			if err := p.Insert(map[string]any{"id": int64(i), "name": name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+
+	errAbort := errors.New("abort")
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("items")
+		if err != nil {
+			return err
+		}
+		if err := p.Insert(map[string]any{"id": int64(9), "name": "z"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected abort, got %v", err)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("items")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("name", "a"))
+		if err != nil {
+			return err
+		}
+		return p.Delete(ranges)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(c Consistency) int {
+		rows, err := db.Select("items", nil, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+	if n := count(ConsistencyLatest); n != 2 {
+		t.Fatalf("expected 2 rows after delete, got %d", n)
+	}
+	if n := count(AtSnapshot(snap)); n != 3 {
+		t.Fatalf("expected snapshot to keep 3 rows, got %d", n)
+	}
+
+	ranges, err := ToKeyRanges(Eq("name", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Select("items", ranges, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["id"] != int64(2) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
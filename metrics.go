@@ -0,0 +1,69 @@
+package thunder
+
+import (
+	"errors"
+	"time"
+)
+
+// MetricsSink receives counters and timings from a database. Its methods are
+// called synchronously from the operations they describe and must be safe
+// for concurrent use. Ephemeral relations are not reported.
+type MetricsSink interface {
+	// RowWritten is called for every row inserted, updated or deleted and
+	// once per truncation, whether or not the transaction later commits.
+	RowWritten(relation string, op ChangeOp)
+	// QueryPlanned is called when a read of a relation starts, including the
+	// reads done by Update and Delete.
+	QueryPlanned(plan QueryPlan)
+	// RowsDecoded reports how many stored rows a read decoded, counting those
+	// its ranges then filtered out.
+	RowsDecoded(relation string, n int)
+	// TxDone reports how long a transaction stayed open and how it ended.
+	TxDone(writable, committed bool, elapsed time.Duration)
+	// ConstraintViolation is called when a write is rejected by a unique,
+	// type, capacity or version check. code is the ThunderError code.
+	ConstraintViolation(relation string, code int)
+}
+
+// SetMetrics registers the sink that receives the database's metrics, or
+// removes it when sink is nil. It should be set before the database is used.
+func (d *DB) SetMetrics(sink MetricsSink) {
+	d.metrics = sink
+}
+
+func (pr *Persistent) metrics() MetricsSink {
+	if pr.ephemeral || pr.tx.db == nil {
+		return nil
+	}
+	return pr.tx.db.metrics
+}
+
+func (pr *Persistent) reportWrite(op ChangeOp) {
+	if m := pr.metrics(); m != nil {
+		m.RowWritten(pr.relation, op)
+	}
+}
+
+// reportViolation reports err if it is a constraint violation.
+func (pr *Persistent) reportViolation(err error) {
+	m := pr.metrics()
+	var te *ThunderError
+	if m == nil || !errors.As(err, &te) {
+		return
+	}
+	switch te.Code {
+	case ErrCodeUniqueConstraint, ErrCodeTypeMismatch, ErrCodeCapacityExceeded, ErrCodeQuotaExceeded, ErrCodeVersionConflict, ErrCodeValidationFailed:
+		m.ConstraintViolation(pr.relation, te.Code)
+	}
+}
+
+// finish reports the end of the transaction the first time it is called.
+func (tx *Tx) finish(committed bool) {
+	if tx.finished {
+		return
+	}
+	tx.finished = true
+	if m := tx.db.metrics; m != nil {
+		m.TxDone(tx.writable, committed, time.Since(tx.started))
+	}
+}
@@ -0,0 +1,126 @@
+package thunder
+
+import (
+	"encoding/binary"
+	"maps"
+	"slices"
+)
+
+const migrationsBucket = "__thunder_migrations"
+
+// Migration changes the schema or data of a relation. It runs inside the
+// transaction that also records its version, so it is applied exactly once.
+type Migration func(tx *Tx) error
+
+// Migrator runs registered migrations in version order for each relation.
+type Migrator struct {
+	db         *DB
+	migrations map[string]map[uint64]Migration
+}
+
+func NewMigrator(db *DB) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: make(map[string]map[uint64]Migration),
+	}
+}
+
+// Register adds the migration that brings relation to version.
+// Versions must be positive and unique per relation.
+func (m *Migrator) Register(relation string, version uint64, migration Migration) error {
+	if version == 0 {
+		return ErrInvalidMigration(relation, version)
+	}
+	versions, ok := m.migrations[relation]
+	if !ok {
+		versions = make(map[uint64]Migration)
+		m.migrations[relation] = versions
+	}
+	if _, exists := versions[version]; exists {
+		return ErrInvalidMigration(relation, version)
+	}
+	versions[version] = migration
+	return nil
+}
+
+// Run applies every registered migration newer than the recorded schema
+// version of its relation. Each migration runs in its own transaction with
+// triggers suppressed; Run stops at the first failure, leaving the versions
+// applied so far recorded.
+func (m *Migrator) Run() error {
+	for _, relation := range slices.Sorted(maps.Keys(m.migrations)) {
+		versions := m.migrations[relation]
+		for _, version := range slices.Sorted(maps.Keys(versions)) {
+			err := m.db.update(func(tx *Tx) error {
+				current, err := tx.SchemaVersion(relation)
+				if err != nil {
+					return err
+				}
+				if version <= current {
+					return nil
+				}
+				if err := tx.WithoutTriggers(func() error {
+					return versions[version](tx)
+				}); err != nil {
+					return err
+				}
+				return tx.setSchemaVersion(relation, version)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SchemaVersion returns the latest migration version applied to relation,
+// or 0 if none has been applied.
+func (tx *Tx) SchemaVersion(relation string) (uint64, error) {
+	bucket := tx.tx.Bucket([]byte(migrationsBucket))
+	if bucket == nil {
+		return 0, nil
+	}
+	relBucket := bucket.Bucket([]byte(relation))
+	if relBucket == nil {
+		return 0, nil
+	}
+	k, _ := relBucket.Cursor().Last()
+	if k == nil {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(k), nil
+}
+
+// AppliedVersions returns every migration version recorded for relation in
+// ascending order.
+func (tx *Tx) AppliedVersions(relation string) ([]uint64, error) {
+	versions := make([]uint64, 0)
+	bucket := tx.tx.Bucket([]byte(migrationsBucket))
+	if bucket == nil {
+		return versions, nil
+	}
+	relBucket := bucket.Bucket([]byte(relation))
+	if relBucket == nil {
+		return versions, nil
+	}
+	err := relBucket.ForEach(func(k, _ []byte) error {
+		versions = append(versions, binary.BigEndian.Uint64(k))
+		return nil
+	})
+	return versions, err
+}
+
+func (tx *Tx) setSchemaVersion(relation string, version uint64) error {
+	bucket, err := tx.tx.CreateBucketIfNotExists([]byte(migrationsBucket))
+	if err != nil {
+		return err
+	}
+	relBucket, err := bucket.CreateBucketIfNotExists([]byte(relation))
+	if err != nil {
+		return err
+	}
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], version)
+	return relBucket.Put(key[:], []byte{})
+}
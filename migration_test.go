@@ -0,0 +1,99 @@
+package thunder
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestMigrator_RunsOnce(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	runs := map[uint64]int{}
+	register := func(m *Migrator) {
+		t.Helper()
+		if err := m.Register("users", 1, func(tx *Tx) error {
+			runs[1]++
+			_, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+				"id":    {},
+				"email": {},
+			})
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Register("users", 2, func(tx *Tx) error {
+			runs[2]++
+			if !tx.TriggersSuppressed() {
+				t.Error("expected migrations to run with triggers suppressed")
+			}
+			p, err := tx.LoadPersistent("users")
+			if err != nil {
+				return err
+			}
+			return p.CreateIndex("email", []string{"email"}, true)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewMigrator(db)
+	register(m)
+	if err := m.Register("users", 2, func(tx *Tx) error { return nil }); err == nil {
+		t.Fatal("expected duplicate version to be rejected")
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// A fresh migrator, e.g. after a restart, must not re-apply anything.
+	m = NewMigrator(db)
+	register(m)
+	errBroken := errors.New("broken")
+	if err := m.Register("users", 3, func(tx *Tx) error {
+		runs[3]++
+		return errBroken
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Run(); !errors.Is(err, errBroken) {
+		t.Fatalf("expected failing migration error, got %v", err)
+	}
+	if runs[1] != 1 || runs[2] != 1 || runs[3] != 1 {
+		t.Errorf("unexpected run counts %v", runs)
+	}
+
+	tx, err := db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	version, err := tx.SchemaVersion("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("expected schema version 2, got %d", version)
+	}
+	applied, err := tx.AppliedVersions("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(applied, []uint64{1, 2}) {
+		t.Errorf("unexpected applied versions %v", applied)
+	}
+	p, err := tx.LoadPersistent("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(p.Uniques(), []string{"email"}) {
+		t.Errorf("expected unique email index, got %v", p.Uniques())
+	}
+	names, err := tx.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"users"}) {
+		t.Errorf("migration bookkeeping must not show up as a relation: %v", names)
+	}
+}
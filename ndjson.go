@@ -0,0 +1,158 @@
+package thunder
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Export writes every row of the relation to w as one JSON object per line.
+func (pr *Persistent) Export(w io.Writer) error {
+	seq, err := pr.Select(nil)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for row, err := range seq {
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Import reads JSON objects, one per line, from r and inserts them into the
+// relation, maintaining its indexes as it goes. Rows are decoded and
+// type-coerced batchSize at a time before any of them is inserted, so a
+// malformed line aborts the import before its batch is written. Import
+// returns the number of rows inserted.
+func (pr *Persistent) Import(r io.Reader, batchSize int) (int, error) {
+	dec := newNDJSONDecoder(r)
+	total := 0
+	for {
+		n, done, err := pr.importBatch(dec, batchSize)
+		total += n
+		if err != nil || done {
+			return total, err
+		}
+	}
+}
+
+// Import streams NDJSON rows from r into relation, committing a transaction
+// after every batchSize rows so that large imports do not hold the write lock
+// for their whole duration. Batches committed before an error stay committed.
+func (d *DB) Import(relation string, r io.Reader, batchSize int) (int, error) {
+	dec := newNDJSONDecoder(r)
+	total := 0
+	for done := false; !done; {
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			var n int
+			n, done, err = p.importBatch(dec, batchSize)
+			if err != nil {
+				return err
+			}
+			total += n
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func newNDJSONDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	return dec
+}
+
+func (pr *Persistent) importBatch(dec *json.Decoder, batchSize int) (int, bool, error) {
+	if batchSize <= 0 {
+		batchSize = 1024
+	}
+	batch := make([]map[string]any, 0, batchSize)
+	done := false
+	for len(batch) < batchSize {
+		var raw map[string]any
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			return 0, false, err
+		}
+		row := make(map[string]any, len(raw))
+		for col, v := range raw {
+			coerced, err := coerceJSON(col, pr.fields[col].Type, v)
+			if err != nil {
+				return 0, false, err
+			}
+			row[col] = coerced
+		}
+		batch = append(batch, row)
+	}
+	for i, row := range batch {
+		if err := pr.Insert(row); err != nil {
+			return i, false, err
+		}
+	}
+	return len(batch), done, nil
+}
+
+// coerceJSON converts a decoded JSON value into the declared column type.
+// Numbers of untyped columns become int64 when they are integral and
+// float64 otherwise.
+func coerceJSON(column string, t ColumnType, v any) (any, error) {
+	switch val := v.(type) {
+	case json.Number:
+		switch t {
+		case TypeInt:
+			if n, err := val.Int64(); err == nil {
+				return n, nil
+			}
+			f, err := val.Float64()
+			if err != nil || !TypeInt.accepts(f) {
+				return nil, ErrTypeMismatch(column, t, v)
+			}
+			return int64(f), nil
+		case TypeFloat:
+			return val.Float64()
+		case TypeBigInt, TypeDecimal:
+			return val.String(), nil
+		case TypeAny:
+			if n, err := strconv.ParseInt(val.String(), 10, 64); err == nil {
+				return n, nil
+			}
+			return val.Float64()
+		}
+	case string:
+		if t == TypeBytes {
+			b, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return nil, ErrTypeMismatch(column, t, v)
+			}
+			return b, nil
+		}
+		if t == TypeTime {
+			at, err := time.Parse(time.RFC3339Nano, val)
+			if err != nil {
+				return nil, ErrTypeMismatch(column, t, v)
+			}
+			return at, nil
+		}
+	}
+	return v, nil
+}
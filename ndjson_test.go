@@ -0,0 +1,103 @@
+package thunder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPersistent_ExportImportNDJSON(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	specs := map[string]ColumnSpec{
+		"id":      {Unique: true, Type: TypeInt},
+		"name":    {Indexed: true},
+		"score":   {Type: TypeFloat},
+		"payload": {Type: TypeBytes},
+	}
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	src, err := tx.CreatePersistent("src", specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if err := src.Insert(map[string]any{
+			"id":      int64(i),
+			"name":    strings.Repeat("n", i%3+1),
+			"score":   float64(i) / 2,
+			"payload": []byte{byte(i), 0xff},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 10 {
+		t.Fatalf("expected 10 lines, got %d", lines)
+	}
+	if _, err := tx.CreatePersistent("dst", specs); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := db.Import("dst", bytes.NewReader(buf.Bytes()), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("expected 10 imported rows, got %d", n)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	dst, err := tx.LoadPersistent("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("id", int64(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := dst.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		found++
+		if row["score"] != 2.0 || !bytes.Equal(row["payload"].([]byte), []byte{4, 0xff}) {
+			t.Errorf("unexpected row after round trip: %v", row)
+		}
+	}
+	if found != 1 {
+		t.Fatalf("expected imported row to be reachable through the unique index, got %d", found)
+	}
+
+	// A malformed line aborts before its batch is written.
+	bad := `{"id": 100, "name": "x", "score": 1.5, "payload": ""}` + "\n" + `{"id": 101,`
+	if _, err := dst.Import(strings.NewReader(bad), 10); err == nil {
+		t.Fatal("expected malformed input to fail")
+	}
+	count, err := dst.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 10 {
+		t.Errorf("expected no partial batch to be written, got %d rows", count)
+	}
+}
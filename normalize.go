@@ -0,0 +1,76 @@
+package thunder
+
+import "golang.org/x/text/unicode/norm"
+
+// Normalization is the Unicode normalization form a column's string values
+// are put in before they are indexed or compared, so that composed and
+// decomposed spellings of the same text are equal.
+type Normalization uint8
+
+const (
+	NormNone Normalization = iota
+	NormNFC
+	NormNFKC
+)
+
+func (n Normalization) form() (norm.Form, bool) {
+	switch n {
+	case NormNFC:
+		return norm.NFC, true
+	case NormNFKC:
+		return norm.NFKC, true
+	}
+	return 0, false
+}
+
+// normalize returns v in form n when it is a string.
+func (n Normalization) normalize(v any) any {
+	f, ok := n.form()
+	if !ok {
+		return v
+	}
+	if s, ok := v.(string); ok {
+		return f.String(s)
+	}
+	return v
+}
+
+// normalizeStrings returns a copy of kr with the string bounds and excludes
+// put in form n, and whether anything changed.
+func (kr *keyRange) normalizeStrings(n Normalization) (*keyRange, bool) {
+	c := *kr
+	var changed, ok bool
+	c.startKey, ok = normalizedKey(kr.startKey, n)
+	changed = changed || ok
+	c.endKey, ok = normalizedKey(kr.endKey, n)
+	changed = changed || ok
+	c.excludes = make([][]byte, len(kr.excludes))
+	for i, key := range kr.excludes {
+		c.excludes[i], ok = normalizedKey(key, n)
+		changed = changed || ok
+	}
+	if !changed {
+		return kr, false
+	}
+	c.distance = c.computeDistance()
+	return &c, true
+}
+
+func normalizedKey(key []byte, n Normalization) ([]byte, bool) {
+	if key == nil {
+		return nil, false
+	}
+	var values []any
+	if err := orderedMa.Unmarshal(key, &values); err != nil || len(values) != 1 {
+		return key, false
+	}
+	s, ok := values[0].(string)
+	if !ok || n.normalize(s) == s {
+		return key, false
+	}
+	normalized, err := ToKey(n.normalize(s))
+	if err != nil {
+		return key, false
+	}
+	return normalized, true
+}
@@ -0,0 +1,56 @@
+package thunder
+
+import "testing"
+
+func TestPersistent_NormalizedColumn(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {},
+		"name": {Unique: true, Normalize: NormNFC},
+		"city": {Normalize: NormNFKC},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const composed, decomposed = "Jos\u00e9", "Jose\u0301"
+	if err := users.Insert(map[string]any{"id": "1", "name": composed, "city": "\uff2f\uff53\uff4c\uff4f"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "2", "name": decomposed, "city": "Oslo"}); err == nil {
+		t.Fatal("expected a unique violation for the decomposed spelling")
+	}
+
+	for _, op := range []Op{Eq("name", decomposed), Eq("city", "Oslo")} {
+		ranges, err := ToKeyRanges(op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := users.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []map[string]any
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) != 1 || rows[0]["id"] != "1" {
+			t.Fatalf("%v: expected row 1, got %v", op, rows)
+		}
+		// The stored text is kept as written.
+		if rows[0]["name"] != composed {
+			t.Fatalf("expected the name as written, got %q", rows[0]["name"])
+		}
+	}
+}
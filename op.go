@@ -80,6 +80,10 @@ func ToKeyRanges(ops ...Op) (map[string]*keyRange, error) {
 			keyRanges[op.field] = kr
 		}
 		switch op.opType {
+		case OpContains, OpContainsAny, OpContainsAll:
+			if err := kr.addElements(op); err != nil {
+				return nil, err
+			}
 		case OpEq:
 			if kr.startKey == nil || bytes.Compare(encodedKey, kr.startKey) > 0 {
 				kr.startKey = encodedKey
@@ -0,0 +1,72 @@
+// Package otelthunder records thunder operations as OpenTelemetry spans.
+package otelthunder
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/longlodw/thunder"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/longlodw/thunder"
+
+// Span attributes.
+const (
+	AttrRelation = attribute.Key("thunder.relation")
+	AttrIndex    = attribute.Key("thunder.index")
+	AttrPlan     = attribute.Key("thunder.plan")
+	AttrRanges   = attribute.Key("thunder.ranges")
+	AttrRows     = attribute.Key("thunder.rows")
+)
+
+// Tracer is a thunder.Tracer creating spans with an OpenTelemetry tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ thunder.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer using tp. Pass it to DB.SetTracer.
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (t *Tracer) Start(ctx context.Context, op, relation string) thunder.TraceSpan {
+	_, span := t.tracer.Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(AttrRelation.String(relation)),
+	)
+	return &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetPlan(plan thunder.QueryPlan, ranges map[string]thunder.RangeSpec) {
+	attrs := []attribute.KeyValue{
+		AttrIndex.String(plan.Index),
+		AttrPlan.String(plan.String()),
+	}
+	if len(ranges) > 0 {
+		var described []string
+		for _, name := range slices.Sorted(maps.Keys(ranges)) {
+			described = append(described, name+" "+ranges[name].String())
+		}
+		attrs = append(attrs, AttrRanges.StringSlice(described))
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+func (s *otelSpan) End(rows int, err error) {
+	s.span.SetAttributes(AttrRows.Int(rows))
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,72 @@
+package otelthunder
+
+import (
+	"context"
+	"testing"
+
+	"github.com/longlodw/thunder"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	db, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetTracer(New(tp))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+	tx, err := db.BeginContext(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{
+		"name": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ada", "bob"} {
+		if err := p.Insert(map[string]any{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := thunder.ToKeyRanges(thunder.Eq("name", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := p.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	sel := spans[2]
+	if sel.Name() != thunder.SpanSelect || sel.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("expected a select span under the handler, got %s", sel.Name())
+	}
+	attrs := make(map[string]string)
+	for _, kv := range sel.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["thunder.relation"] != "users" || attrs["thunder.index"] != "name" || attrs["thunder.rows"] != "1" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+	if attrs["thunder.ranges"] != `["name = (\"bob\")"]` {
+		t.Fatalf("unexpected ranges %q", attrs["thunder.ranges"])
+	}
+}
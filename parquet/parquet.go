@@ -0,0 +1,162 @@
+// Package parquet exports thunder relations and query results as Parquet
+// files so they can be loaded by analytics engines such as DuckDB or Spark.
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/longlodw/thunder"
+	pq "github.com/parquet-go/parquet-go"
+)
+
+// WriteRelation writes every row of p to w. The schema is derived from the
+// relation's column types.
+func WriteRelation(w io.Writer, p *thunder.Persistent) error {
+	types := make(map[string]thunder.ColumnType)
+	for name, spec := range p.ColumnSpecs() {
+		if len(spec.ReferenceCols) == 0 {
+			types[name] = spec.Type
+		}
+	}
+	return Write(w, p, types)
+}
+
+// Write writes the rows of sel matching ops to w. types gives the
+// Parquet type of each column; columns missing from types, or typed
+// TypeAny, are written as JSON.
+func Write(w io.Writer, sel thunder.Selector, types map[string]thunder.ColumnType, ops ...thunder.Op) error {
+	columns := slices.Sorted(slices.Values(sel.Columns()))
+	group := make(pq.Group, len(columns))
+	for _, col := range columns {
+		group[col] = pq.Optional(node(types[col]))
+	}
+	ranges, err := thunder.ToKeyRanges(ops...)
+	if err != nil {
+		return err
+	}
+	seq, err := sel.Select(ranges)
+	if err != nil {
+		return err
+	}
+	pw := pq.NewWriter(w, pq.NewSchema("thunder", group))
+	row := make(pq.Row, len(columns))
+	for value, err := range seq {
+		if err != nil {
+			return err
+		}
+		for i, col := range columns {
+			v, err := convert(col, types[col], value[col])
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				row[i] = pq.Value{}.Level(0, 0, i)
+			} else {
+				row[i] = pq.ValueOf(v).Level(0, 1, i)
+			}
+		}
+		if _, err := pw.WriteRows([]pq.Row{row}); err != nil {
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+func node(t thunder.ColumnType) pq.Node {
+	switch t {
+	case thunder.TypeString:
+		return pq.String()
+	case thunder.TypeInt:
+		return pq.Int(64)
+	case thunder.TypeFloat:
+		return pq.Leaf(pq.DoubleType)
+	case thunder.TypeBool:
+		return pq.Leaf(pq.BooleanType)
+	case thunder.TypeBytes:
+		return pq.Leaf(pq.ByteArrayType)
+	default:
+		return pq.JSON()
+	}
+}
+
+func convert(column string, t thunder.ColumnType, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch t {
+	case thunder.TypeString:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case thunder.TypeInt:
+		if i, ok := toInt64(v); ok {
+			return i, nil
+		}
+	case thunder.TypeFloat:
+		if f, ok := toFloat64(v); ok {
+			return f, nil
+		}
+	case thunder.TypeBool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case thunder.TypeBytes:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("parquet: column %s: cannot write %T as %s", column, v, t)
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float32:
+		return int64(n), float32(int64(n)) == n
+	case float64:
+		return int64(n), float64(int64(n)) == n
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package parquet
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/longlodw/thunder"
+	pq "github.com/parquet-go/parquet-go"
+)
+
+func TestWriteRelation(t *testing.T) {
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("events", map[string]thunder.ColumnSpec{
+		"name":  {Type: thunder.TypeString},
+		"count": {Type: thunder.TypeInt},
+		"score": {Type: thunder.TypeFloat},
+		"extra": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []map[string]any{
+		{"name": "a", "count": 1, "score": 0.5, "extra": map[string]any{"k": "v"}},
+		{"name": "b", "count": 2, "score": 1.5, "extra": nil},
+	}
+	for _, row := range rows {
+		if err := p.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRelation(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	f, err := pq.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.NumRows() != 2 {
+		t.Fatalf("expected 2 rows, got %d", f.NumRows())
+	}
+	// Columns are laid out in name order: count, extra, name, score.
+	r := pq.NewReader(f)
+	got := make([]pq.Row, 2)
+	if n, _ := r.ReadRows(got); n != 2 {
+		t.Fatalf("expected to read 2 rows, got %d", n)
+	}
+	byName := map[string]pq.Row{}
+	for _, row := range got {
+		byName[row[2].String()] = row
+	}
+	a := byName["a"]
+	if a[0].Int64() != 1 || a[3].Double() != 0.5 || a[1].String() != `{"k":"v"}` {
+		t.Fatalf("unexpected row %v", a)
+	}
+	if !byName["b"][1].IsNull() {
+		t.Fatalf("expected null extra, got %v", byName["b"][1])
+	}
+}
@@ -0,0 +1,243 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// partitionSep separates a relation's name from the hex encoded partition
+// key in the names of its partitions.
+const partitionSep = "$"
+
+// Partitioned stores the rows of a relation in one relation per value of a
+// partition column, such as a day or a tenant. Selects constraining the
+// column only read the partitions in range, and DropPartition removes all
+// the rows of one value by dropping its bucket.
+type Partitioned struct {
+	tx     *Tx
+	base   *Persistent
+	column string
+	spec   ColumnSpec
+	loaded map[string]*Persistent
+}
+
+// Partitioned returns relation partitioned by column. relation must exist;
+// it holds the schema every partition is created with and no rows. The
+// partition of key k is stored as the relation relation$hex(k).
+func (tx *Tx) Partitioned(relation, column string) (*Partitioned, error) {
+	base, err := tx.LoadPersistent(relation)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := base.fields[column]
+	if !ok || len(spec.ReferenceCols) != 0 {
+		return nil, ErrFieldNotFound(column)
+	}
+	return &Partitioned{
+		tx:     tx,
+		base:   base,
+		column: column,
+		spec:   spec,
+		loaded: make(map[string]*Persistent),
+	}, nil
+}
+
+// Name returns the name of the partitioned relation.
+func (p *Partitioned) Name() string {
+	return p.base.relation
+}
+
+// Insert stores row in the partition of its partition column, creating the
+// partition if needed.
+func (p *Partitioned) Insert(row map[string]any) error {
+	v, ok := row[p.column]
+	if !ok {
+		return ErrFieldNotFound(p.column)
+	}
+	key, err := p.spec.appendValue(nil, v)
+	if err != nil {
+		return err
+	}
+	part, err := p.partition(key, true)
+	if err != nil {
+		return err
+	}
+	return part.Insert(row)
+}
+
+// Select returns the rows matching ranges, partition by partition in the
+// order of the partition column.
+func (p *Partitioned) Select(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	parts, err := p.prune(ranges)
+	if err != nil {
+		return nil, err
+	}
+	seqs := make([]iter.Seq2[map[string]any, error], 0, len(parts))
+	for _, part := range parts {
+		seq, err := part.Select(ranges)
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for _, seq := range seqs {
+			for row, err := range seq {
+				if !yield(row, err) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// Delete removes the rows matching ranges from the partitions in range.
+func (p *Partitioned) Delete(ranges map[string]*keyRange) error {
+	parts, err := p.prune(ranges)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if err := part.Delete(ranges); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of rows across all partitions.
+func (p *Partitioned) Count() (int, error) {
+	parts, err := p.prune(nil)
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, part := range parts {
+		n, err := part.Count()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Partitions returns the values of the partition column that have a
+// partition, in order.
+func (p *Partitioned) Partitions() ([]any, error) {
+	keys, err := p.keys()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		var parts []any
+		if err := orderedMa.Unmarshal(key, &parts); err != nil {
+			return nil, err
+		}
+		values = append(values, parts...)
+	}
+	return values, nil
+}
+
+// DropPartition removes the partition of value with all its rows.
+func (p *Partitioned) DropPartition(value any) error {
+	key, err := p.spec.appendValue(nil, value)
+	if err != nil {
+		return err
+	}
+	return p.dropKey(key)
+}
+
+func (p *Partitioned) dropKey(key []byte) error {
+	name := p.partitionName(key)
+	delete(p.loaded, name)
+	return p.tx.DropRelation(name)
+}
+
+// ruleRelation returns the relation whose policy, masks, hooks and
+// validators apply to pr: the partitioned relation for a partition, and pr
+// itself otherwise.
+func (pr *Persistent) ruleRelation() string {
+	return ruleRelation(pr.relation)
+}
+
+func ruleRelation(relation string) string {
+	if base, _, ok := strings.Cut(relation, partitionSep); ok && base != "" {
+		return base
+	}
+	return relation
+}
+
+func (p *Partitioned) partitionName(key []byte) string {
+	return p.base.relation + partitionSep + hex.EncodeToString(key)
+}
+
+// keys returns the partition keys in order.
+func (p *Partitioned) keys() ([][]byte, error) {
+	names, err := p.tx.Relations()
+	if err != nil {
+		return nil, err
+	}
+	prefix := p.base.relation + partitionSep
+	var keys [][]byte
+	for _, name := range names {
+		encoded, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		key, err := hex.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, bytes.Compare)
+	return keys, nil
+}
+
+// prune returns the partitions that can hold rows matching ranges.
+func (p *Partitioned) prune(ranges map[string]*keyRange) ([]*Persistent, error) {
+	keys, err := p.keys()
+	if err != nil {
+		return nil, err
+	}
+	kr := p.base.coerceRanges(ranges)[p.column]
+	if kr != nil && kr.elementwise() {
+		kr = nil
+	}
+	var parts []*Persistent
+	for _, key := range keys {
+		if kr != nil && !kr.contains(key) {
+			continue
+		}
+		part, err := p.partition(key, false)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func (p *Partitioned) partition(key []byte, create bool) (*Persistent, error) {
+	name := p.partitionName(key)
+	if part, ok := p.loaded[name]; ok {
+		return part, nil
+	}
+	var part *Persistent
+	var err error
+	if create && !isRelationBucket(p.tx.tx.Bucket([]byte(name))) {
+		part, err = p.tx.CreatePersistent(name, p.base.ColumnSpecs())
+	} else {
+		part, err = p.tx.LoadPersistent(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.loaded[name] = part
+	return part, nil
+}
@@ -0,0 +1,164 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTx_Partitioned(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		_, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+			"day": {Type: TypeInt},
+			"id":  {Unique: true},
+		})
+		if err != nil {
+			return err
+		}
+		events, err := tx.Partitioned("events", "day")
+		if err != nil {
+			return err
+		}
+		for i, day := range []int{3, 1, 2, 3, 1, 3} {
+			if err := events.Insert(map[string]any{"day": day, "id": i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	events, err := tx.Partitioned("events", "day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	days, err := events.Partitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 3 || days[0] != int64(1) || days[2] != int64(3) {
+		t.Fatalf("expected partitions 1, 2 and 3, got %v", days)
+	}
+	ranges, err := ToKeyRanges(Ge("day", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts, err := events.prune(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 partitions in range, got %d", len(parts))
+	}
+	seq, err := events.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row["day"])
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 rows from day 2 on, got %v", got)
+	}
+
+	if err := events.DropPartition(3); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := events.Count(); err != nil || n != 3 {
+		t.Fatalf("expected 3 rows after dropping day 3, got %d, %v", n, err)
+	}
+	if err := events.DropPartition(3); err == nil {
+		t.Fatal("expected error dropping a missing partition")
+	}
+	if _, err := tx.Partitioned("events", "missing"); err == nil {
+		t.Fatal("expected error for an unknown partition column")
+	}
+}
+
+func TestTx_PartitionedRules(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+			"tenant": {},
+			"id":     {Unique: true},
+			"ssn":    {},
+		})
+		if err != nil {
+			return err
+		}
+		return docs.MaskColumn("ssn", "support")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetPolicy("docs", func(ctx context.Context) ([]Op, error) {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			return []Op{Eq("tenant", tenant)}, nil
+		}
+		return nil, nil
+	})
+	db.SetValidators("docs", "id", func(v any) error {
+		if v == "bad" {
+			return errors.New("bad id")
+		}
+		return nil
+	})
+	err = db.update(func(tx *Tx) error {
+		docs, err := tx.Partitioned("docs", "tenant")
+		if err != nil {
+			return err
+		}
+		for i, tenant := range []string{"acme", "globex"} {
+			if err := docs.Insert(map[string]any{"tenant": tenant, "id": fmt.Sprint(i), "ssn": "123-45"}); err != nil {
+				return err
+			}
+		}
+		if err := docs.Insert(map[string]any{"tenant": "acme", "id": "bad", "ssn": ""}); err == nil {
+			t.Error("expected the validator of docs to reject the row")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.BeginContext(context.WithValue(context.Background(), tenantKey{}, "acme"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tx.SetIdentity("support")
+	docs, err := tx.Partitioned("docs", "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := docs.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["tenant"] != "acme" || rows[0]["ssn"] != Redacted {
+		t.Fatalf("expected acme's row with ssn redacted, got %v", rows)
+	}
+}
@@ -0,0 +1,76 @@
+package thunder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// isPath reports whether name is a path into the nested value of a column,
+// such as "profile.address.city" for the column profile. Segments after the
+// column select map keys or, when numeric, slice elements.
+func (pr *Persistent) isPath(name string) bool {
+	if spec, ok := pr.fields[name]; ok && len(spec.ReferenceCols) == 0 {
+		return false
+	}
+	root, _, ok := strings.Cut(name, ".")
+	if !ok {
+		return false
+	}
+	spec, ok := pr.fields[root]
+	return ok && len(spec.ReferenceCols) == 0
+}
+
+// pathRoot returns the column a column name or path reads from.
+func pathRoot(name string) string {
+	root, _, _ := strings.Cut(name, ".")
+	return root
+}
+
+// filteredColumns returns the stored columns a filter on name reads: the
+// root of a path, the columns an index refers to, or name itself.
+func (pr *Persistent) filteredColumns(name string) []string {
+	refs := pr.fields[name].ReferenceCols
+	if len(refs) == 0 {
+		refs = []string{name}
+	}
+	columns := make([]string, len(refs))
+	for i, ref := range refs {
+		columns[i] = pathRoot(ref)
+	}
+	return columns
+}
+
+// valueAt returns the value of the column or path name in row. A path that
+// leads nowhere, or to nil, is reported as missing.
+func (pr *Persistent) valueAt(row map[string]any, name string) (any, bool) {
+	if !pr.isPath(name) {
+		v, ok := row[name]
+		return v, ok
+	}
+	var cur any = row
+	for _, seg := range strings.Split(name, ".") {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case map[any]any:
+			v, ok := c[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, cur != nil
+}
@@ -0,0 +1,80 @@
+package thunder
+
+import "testing"
+
+func TestPersistent_PathQueries(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":      {Unique: true},
+		"profile": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"id": "1", "profile": map[string]any{"address": map[string]any{"city": "Oslo"}, "tags": []any{"a", "b"}}},
+		{"id": "2", "profile": map[string]any{"address": map[string]any{"city": "Bergen"}}},
+		{"id": "3", "profile": map[string]any{}},
+	} {
+		if err := users.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(ops ...Op) int {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := users.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		return n
+	}
+	if n := count(Eq("profile.address.city", "Oslo")); n != 1 {
+		t.Fatalf("expected 1 row in Oslo, got %d", n)
+	}
+	if n := count(Eq("profile.tags.1", "b")); n != 1 {
+		t.Fatalf("expected 1 row by slice element, got %d", n)
+	}
+
+	if err := users.CreateIndex("profile.address.city", []string{"profile.address.city"}, true); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("profile.address.city", "Bergen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := users.Explain(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Index != "profile.address.city" {
+		t.Fatalf("expected the path index to be used, got %v", plan)
+	}
+	if n := count(Eq("profile.address.city", "Bergen")); n != 1 {
+		t.Fatalf("expected 1 row in Bergen, got %d", n)
+	}
+	// Rows without the path are not checked for uniqueness.
+	if err := users.Insert(map[string]any{"id": "4", "profile": map[string]any{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "5", "profile": map[string]any{"address": map[string]any{"city": "Oslo"}}}); err == nil {
+		t.Fatal("expected a unique violation on the path index")
+	}
+}
@@ -2,7 +2,9 @@ package thunder
 
 import (
 	"bytes"
+	"errors"
 	"iter"
+	"maps"
 	"slices"
 
 	boltdb_errors "github.com/openkvlab/boltdb/errors"
@@ -10,14 +12,32 @@ import (
 
 // Persistent represents an object relation in the database.
 type Persistent struct {
-	data        *dataStorage
-	indexes     *indexStorage
-	fields      map[string]ColumnSpec
-	relation    string
-	uniqueNames []string
-	indexNames  []string
-	columns     []string
-	parentsList []*queryParent
+	bucket    BackendBucket
+	maUn      MarshalUnmarshaler
+	pending   map[string]uint64
+	tx        *Tx
+	typed     bool
+	encrypted bool
+	encoded   bool
+	capacity  *capacity
+	ephemeral bool
+	shared    []*sharedUnique
+	masks     map[string][]string
+	// access is the relation whose policy applies, see Tx.accessRelation.
+	access   string
+	versions BackendBucket
+	// analysis caches ColumnStats; analysisLoaded tells a relation never
+	// analyzed from one not yet read.
+	analysis       map[string]ColumnStats
+	analysisLoaded bool
+	data           *dataStorage
+	indexes        *indexStorage
+	fields         map[string]ColumnSpec
+	relation       string
+	uniqueNames    []string
+	indexNames     []string
+	columns        []string
+	parentsList    []*queryParent
 }
 
 func newPersistent(tx *Tx, relation string, columnSpecs map[string]ColumnSpec, emepheral bool) (*Persistent, error) {
@@ -25,7 +45,6 @@ func newPersistent(tx *Tx, relation string, columnSpecs map[string]ColumnSpec, e
 	if emepheral {
 		tnx = tx.tempTx
 	}
-	maUn := tx.maUn
 	bucket, err := tnx.CreateBucketIfNotExists([]byte(relation))
 	if err != nil {
 		return nil, err
@@ -34,10 +53,11 @@ func newPersistent(tx *Tx, relation string, columnSpecs map[string]ColumnSpec, e
 	if err != nil {
 		return nil, err
 	}
-	columns := make([]string, 0, len(columnSpecs))
-	indexNames := make([]string, 0, len(columnSpecs))
-	uniquesNames := make([]string, 0, len(columnSpecs))
-
+	// An existing relation keeps the codec it was recoded to.
+	maUn, err := tx.relationCodec(metaBucket)
+	if err != nil {
+		return nil, err
+	}
 	columnsBytes, err := maUn.Marshal(columnSpecs)
 	if err != nil {
 		return nil, err
@@ -45,37 +65,21 @@ func newPersistent(tx *Tx, relation string, columnSpecs map[string]ColumnSpec, e
 	if err := metaBucket.Put([]byte("columnSpecs"), columnsBytes); err != nil {
 		return nil, err
 	}
-	for colName, colSpec := range columnSpecs {
-		refCols := colSpec.ReferenceCols
-		if len(refCols) == 0 {
-			columns = append(columns, colName)
-		}
-	}
-	for colName, colSpec := range columnSpecs {
-		refCols := colSpec.ReferenceCols
-		if colSpec.Indexed {
-			indexNames = append(indexNames, colName)
-		}
-		if colSpec.Unique {
-			uniquesNames = append(uniquesNames, colName)
-			indexNames = append(indexNames, colName)
-		}
-		for _, refCol := range refCols {
-			if !slices.Contains(columns, refCol) {
-				return nil, ErrFieldNotFound(refCol)
-			}
-		}
+	columns, indexNames, uniquesNames, err := deriveNames(columnSpecs)
+	if err != nil {
+		return nil, err
 	}
 	indexesStore, err := newIndex(bucket, indexNames, maUn)
 	if err != nil {
 		return nil, err
 	}
-	dataStore, err := newData(bucket, columns, maUn)
+	dataStore, err := newData(bucket, columns, columnarNames(columnSpecs), maUn)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Persistent{
+	pr := &Persistent{
+		bucket:      bucket,
+		maUn:        maUn,
 		data:        dataStore,
 		indexes:     indexesStore,
 		fields:      columnSpecs,
@@ -83,12 +87,51 @@ func newPersistent(tx *Tx, relation string, columnSpecs map[string]ColumnSpec, e
 		uniqueNames: uniquesNames,
 		indexNames:  indexNames,
 		columns:     columns,
-	}, nil
+		tx:          tx,
+		typed:       hasTypedColumns(columnSpecs),
+		encrypted:   hasEncryptedColumns(columnSpecs),
+		encoded:     hasCodecColumns(columnSpecs),
+		ephemeral:   emepheral,
+	}
+	// The relation may already exist, with its own state kept beside the
+	// column specs.
+	if err := pr.loadMeta(metaBucket); err != nil {
+		return nil, err
+	}
+	tx.loaded = append(tx.loaded, pr)
+	return pr, nil
 }
 
 func loadPersistent(tx *Tx, relation string) (*Persistent, error) {
-	tnx := tx.tx
-	maUn := tx.maUn
+	pr, err := readPersistent(tx, tx.tx, relation, false)
+	if err != nil {
+		return nil, err
+	}
+	tx.loaded = append(tx.loaded, pr)
+	return pr, nil
+}
+
+// reload reads the state of the relation again after RollbackTo reverted
+// it. A relation created after the savepoint no longer exists and is left
+// as it is.
+func (pr *Persistent) reload() error {
+	tnx := pr.tx.tx
+	if pr.ephemeral {
+		tnx = pr.tx.tempTx
+	}
+	if tnx.Bucket([]byte(pr.relation)) == nil {
+		return nil
+	}
+	fresh, err := readPersistent(pr.tx, tnx, pr.relation, pr.ephemeral)
+	if err != nil {
+		return err
+	}
+	fresh.parentsList = pr.parentsList
+	*pr = *fresh
+	return nil
+}
+
+func readPersistent(tx *Tx, tnx BackendTx, relation string, ephemeral bool) (*Persistent, error) {
 	bucket := tnx.Bucket([]byte(relation))
 	if bucket == nil {
 		return nil, boltdb_errors.ErrBucketNotFound
@@ -98,6 +141,10 @@ func loadPersistent(tx *Tx, relation string) (*Persistent, error) {
 	if metaBucket == nil {
 		return nil, ErrMetaDataNotFound(relation)
 	}
+	maUn, err := tx.relationCodec(metaBucket)
+	if err != nil {
+		return nil, err
+	}
 	columnSpecsBytes := metaBucket.Get([]byte("columnSpecs"))
 	if columnSpecsBytes == nil {
 		return nil, ErrCorruptedMetaDataEntry(relation, "columnSpecs")
@@ -106,41 +153,21 @@ func loadPersistent(tx *Tx, relation string) (*Persistent, error) {
 	if err := maUn.Unmarshal(columnSpecsBytes, &columnSpecs); err != nil {
 		return nil, err
 	}
-	columns := make([]string, 0, len(columnSpecs))
-	indexNames := make([]string, 0, len(columnSpecs))
-	uniquesNames := make([]string, 0, len(columnSpecs))
-	for colName, colSpec := range columnSpecs {
-		refCols := colSpec.ReferenceCols
-		if len(refCols) == 0 {
-			columns = append(columns, colName)
-		}
-	}
-	for colName, colSpec := range columnSpecs {
-		refCols := colSpec.ReferenceCols
-		if colSpec.Indexed {
-			indexNames = append(indexNames, colName)
-		}
-		if colSpec.Unique {
-			uniquesNames = append(uniquesNames, colName)
-			indexNames = append(indexNames, colName)
-		}
-		for _, refCol := range refCols {
-			if !slices.Contains(columns, refCol) {
-				return nil, ErrFieldNotFound(refCol)
-			}
-		}
+	columns, indexNames, uniquesNames, err := deriveNames(columnSpecs)
+	if err != nil {
+		return nil, err
 	}
-
 	indexesStore, err := loadIndex(bucket, maUn)
 	if err != nil {
 		return nil, err
 	}
-	dataStore, err := loadData(bucket, columns, maUn)
+	dataStore, err := loadData(bucket, columns, columnarNames(columnSpecs), maUn)
 	if err != nil {
 		return nil, err
 	}
-
-	return &Persistent{
+	pr := &Persistent{
+		bucket:      bucket,
+		maUn:        maUn,
 		data:        dataStore,
 		indexes:     indexesStore,
 		fields:      columnSpecs,
@@ -148,7 +175,123 @@ func loadPersistent(tx *Tx, relation string) (*Persistent, error) {
 		uniqueNames: uniquesNames,
 		indexNames:  indexNames,
 		columns:     columns,
-	}, nil
+		tx:          tx,
+		typed:       hasTypedColumns(columnSpecs),
+		encrypted:   hasEncryptedColumns(columnSpecs),
+		encoded:     hasCodecColumns(columnSpecs),
+		ephemeral:   ephemeral,
+	}
+	if err := pr.loadMeta(metaBucket); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// loadMeta reads the state of the relation kept in metaBucket besides its
+// column specs: pending indexes, capacity, an unfinished recode, and for
+// stored relations the shared uniques, masks and history versions.
+func (pr *Persistent) loadMeta(metaBucket BackendBucket) error {
+	tx, relation := pr.tx, pr.relation
+	pr.pending = make(map[string]uint64)
+	if pendingBytes := metaBucket.Get([]byte("pendingIndexes")); pendingBytes != nil {
+		if err := pr.maUn.Unmarshal(pendingBytes, &pr.pending); err != nil {
+			return ErrCorruptedMetaDataEntry(relation, "pendingIndexes")
+		}
+	}
+	if capBytes := metaBucket.Get([]byte("capacity")); capBytes != nil {
+		pr.capacity = &capacity{}
+		if err := pr.maUn.Unmarshal(capBytes, pr.capacity); err != nil {
+			return ErrCorruptedMetaDataEntry(relation, "capacity")
+		}
+	}
+	if state := metaBucket.Get([]byte("recode")); state != nil {
+		if err := tx.loadRecode(pr.data, state); err != nil {
+			return err
+		}
+	}
+	pr.versions = pr.bucket.Bucket([]byte("versions"))
+	if pr.ephemeral {
+		pr.access = ruleRelation(relation)
+		return nil
+	}
+	var err error
+	if pr.shared, err = tx.loadSharedUniques(relation); err != nil {
+		return err
+	}
+	if pr.masks, err = tx.loadMasks(relation); err != nil {
+		return err
+	}
+	pr.access = tx.accessRelation(relation)
+	return nil
+}
+
+// deriveNames splits column specs into stored columns, index names and unique names.
+func deriveNames(columnSpecs map[string]ColumnSpec) (columns, indexNames, uniqueNames []string, err error) {
+	columns = make([]string, 0, len(columnSpecs))
+	indexNames = make([]string, 0, len(columnSpecs))
+	uniqueNames = make([]string, 0, len(columnSpecs))
+	versioned := false
+	for colName, colSpec := range columnSpecs {
+		if len(colSpec.ReferenceCols) == 0 {
+			columns = append(columns, colName)
+		}
+	}
+	for colName, colSpec := range columnSpecs {
+		if colSpec.Indexed || colSpec.Unique {
+			indexNames = append(indexNames, colName)
+		}
+		if colSpec.Unique {
+			uniqueNames = append(uniqueNames, colName)
+		}
+		if colSpec.Unique && colSpec.AsyncIndex {
+			return nil, nil, nil, ErrAsyncUniqueIndex(colName)
+		}
+		if colSpec.EncryptionKey != "" && (colSpec.Indexed || colSpec.Unique) {
+			return nil, nil, nil, ErrColumnEncrypted(colName)
+		}
+		if colSpec.EncryptionKey != "" && colSpec.Columnar {
+			return nil, nil, nil, ErrColumnEncrypted(colName)
+		}
+		if colSpec.Codec != "" && (colSpec.Indexed || colSpec.Unique || colSpec.Version || colSpec.Columnar) {
+			return nil, nil, nil, ErrColumnEncoded(colName)
+		}
+		// Blob references are read in stored rows to find the blobs.
+		if colSpec.Type == TypeBlob && colSpec.EncryptionKey != "" {
+			return nil, nil, nil, ErrColumnEncrypted(colName)
+		}
+		if colSpec.Type == TypeBlob && colSpec.Codec != "" {
+			return nil, nil, nil, ErrColumnEncoded(colName)
+		}
+		if colSpec.Version {
+			if versioned || colSpec.EncryptionKey != "" || len(colSpec.ReferenceCols) > 0 {
+				return nil, nil, nil, ErrInvalidVersionColumn(colName)
+			}
+			versioned = true
+		}
+		for _, refCol := range colSpec.ReferenceCols {
+			// Composites may also cover paths into a column's value.
+			root := pathRoot(refCol)
+			if !slices.Contains(columns, refCol) && !slices.Contains(columns, root) {
+				return nil, nil, nil, ErrFieldNotFound(refCol)
+			}
+			if columnSpecs[root].EncryptionKey != "" || columnSpecs[refCol].EncryptionKey != "" {
+				return nil, nil, nil, ErrColumnEncrypted(refCol)
+			}
+			if columnSpecs[root].Codec != "" || columnSpecs[refCol].Codec != "" {
+				return nil, nil, nil, ErrColumnEncoded(refCol)
+			}
+		}
+	}
+	return columns, indexNames, uniqueNames, nil
+}
+
+// checkWritable fails with ErrReadOnly when the relation is read through a
+// read-only transaction, before any work is done.
+func (pr *Persistent) checkWritable() error {
+	if pr.ephemeral {
+		return nil
+	}
+	return pr.tx.checkWritable()
 }
 
 func (pr *Persistent) IsRecursive() bool {
@@ -173,108 +316,683 @@ func (pr *Persistent) Join(bodies ...Selector) Selector {
 }
 
 func (pr *Persistent) Insert(obj map[string]any) error {
-	id, err := pr.data.insert(obj)
+	_, err := pr.observeWrite(SpanInsert, nil, func() (int, error) {
+		if err := pr.insert(obj); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	})
+	return err
+}
+
+func (pr *Persistent) insert(obj map[string]any) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	obj, err := pr.completeRow(obj, true)
+	if err != nil {
+		return err
+	}
+	return pr.insertComplete(obj)
+}
+
+// completeRow fills in the column defaults of obj, runs the BeforeInsert
+// hook, if hooks is set, and computes the generated columns, giving the row
+// as it is checked and stored.
+func (pr *Persistent) completeRow(obj map[string]any, hooks bool) (map[string]any, error) {
+	obj = pr.applyDefaults(obj)
+	if hooks {
+		h := pr.hooks()
+		if err := h.run(h.BeforeInsert, pr.tx, obj); err != nil {
+			return nil, err
+		}
+	}
+	return pr.applyGenerated(obj)
+}
+
+// insertComplete inserts a row completed by completeRow.
+func (pr *Persistent) insertComplete(obj map[string]any) error {
+	hooks := pr.hooks()
+	if err := pr.insertRow(obj); err != nil {
+		pr.reportViolation(err)
+		return err
+	}
+	pr.reportWrite(ChangeInsert)
+	if err := pr.recordChange(ChangeInsert, nil, obj); err != nil {
+		return err
+	}
+	return hooks.run(hooks.AfterInsert, pr.tx, obj)
+}
+
+// insertRow stores obj with its index entries. Every check that can reject
+// the row runs before the first write, and the writes are undone together if
+// one of them fails, so a rejected row leaves nothing behind.
+func (pr *Persistent) insertRow(obj map[string]any) error {
+	obj, value, err := pr.prepareRow(obj, nil)
 	if err != nil {
 		return err
 	}
-	value := make(map[string][]byte)
+	if err := pr.checkCapacity(obj); err != nil {
+		return err
+	}
+	// The checks above run before anything is written, but the store
+	// itself, for a key too large, can still reject the row once part of
+	// it is.
+	sp := pr.tx.savepoint()
+	var usage capacity
+	if pr.capacity != nil {
+		usage = *pr.capacity
+	}
+	if err := pr.writeRow(obj, value); err != nil {
+		if pr.capacity != nil {
+			*pr.capacity = usage
+		}
+		return errors.Join(err, pr.tx.RollbackTo(sp), pr.tx.Release(sp))
+	}
+	return pr.tx.Release(sp)
+}
+
+// prepareRow runs every check that can reject obj before it is written and
+// returns it in stored form with its index keys. Unique keys held by the rows
+// for which held returns true, if set, do not count as taken.
+func (pr *Persistent) prepareRow(obj map[string]any, held func(id [8]byte) bool) (map[string]any, map[string][][]byte, error) {
+	if err := pr.validateRow(obj); err != nil {
+		return nil, nil, err
+	}
+	if err := pr.runValidators(obj); err != nil {
+		return nil, nil, err
+	}
+	if err := pr.checkBlobs(obj); err != nil {
+		return nil, nil, err
+	}
+	obj = pr.storeDecimals(obj)
+	obj, err := pr.encodeRow(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err = pr.encryptRow(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, su := range pr.shared {
+		if err := pr.checkShared(su, obj, held); err != nil {
+			return nil, nil, err
+		}
+	}
+	value := make(map[string][][]byte)
 	for k, v := range pr.fields {
-		if !(v.Indexed || v.Unique) {
+		if !(v.Indexed || v.Unique) || v.AsyncIndex {
 			continue
 		}
-		refs := v.ReferenceCols
-		if len(refs) > 0 {
-			key, err := pr.computeKey(obj, k)
+		keys, err := pr.indexKeys(obj, k)
+		if err != nil {
+			return nil, nil, err
+		}
+		value[k] = keys
+	}
+	// Check uniques
+	for _, uniqueName := range pr.uniqueNames {
+		for _, key := range value[uniqueName] {
+			if len(key) == 0 {
+				// Rows missing every path of the index are not checked.
+				continue
+			}
+			exists, err := pr.indexes.get(uniqueName, KeyRange(key, key, true, true, nil), false)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
-			value[k] = key
-		} else {
-			v, ok := obj[k]
-			if !ok {
-				return ErrFieldNotFound(k)
+			for id, err := range exists {
+				if err != nil {
+					return nil, nil, err
+				}
+				if held == nil || !held(id) {
+					return nil, nil, ErrUniqueConstraint(uniqueName, key)
+				}
 			}
-			vBytes, err := ToKey(v)
-			if err != nil {
+		}
+	}
+	return obj, value, nil
+}
+
+func (pr *Persistent) writeRow(obj map[string]any, value map[string][][]byte) error {
+	id, err := pr.data.insert(obj)
+	if err != nil {
+		return err
+	}
+	if err := pr.stampVersion(id[:]); err != nil {
+		return err
+	}
+	if err := pr.trackChange(id[:]); err != nil {
+		return err
+	}
+	if err := pr.refBlobs(obj, id[:], true); err != nil {
+		return err
+	}
+	for _, idxName := range pr.indexNames {
+		for _, key := range value[idxName] {
+			if err := pr.indexes.insert(idxName, key, id[:]); err != nil {
 				return err
 			}
-			value[k] = vBytes
 		}
 	}
-	// Check uniques
-	for _, uniqueName := range pr.uniqueNames {
-		idxRange := &keyRange{
-			includeEnd:   true,
-			includeStart: true,
-			startKey:     value[uniqueName],
-			endKey:       value[uniqueName],
-		}
-		exists, err := pr.indexes.get(uniqueName, idxRange)
-		if err != nil {
+	if err := pr.queueIndexing(id[:]); err != nil {
+		return err
+	}
+	for _, su := range pr.shared {
+		if err := pr.claimShared(su, entry{id: id, value: obj}); err != nil {
 			return err
 		}
-		for range exists {
-			return ErrUniqueConstraint(uniqueName, value[uniqueName])
-		}
 	}
+	if pr.capacity != nil {
+		return pr.enforceCap(id[:])
+	}
+	return nil
+}
 
-	for _, idxName := range pr.indexNames {
-		err := pr.indexes.insert(idxName, value[idxName], id[:])
+func (pr *Persistent) Delete(ranges map[string]*keyRange) error {
+	_, err := pr.observeWrite(SpanDelete, ranges, func() (int, error) {
+		return pr.deleteMatching(ranges, nil)
+	})
+	return err
+}
+
+// Update sets the columns in changes on every row matching ranges and returns
+// how many rows were updated. Relations with a version column need the
+// expected version in changes; see ColumnSpec.Version. Updated rows get new
+// row ids, and the delete hooks run for the old row and the insert hooks for
+// the new one. Rows with encrypted columns can only be updated while the
+// transaction holds their keys. Changes moving a row out of the relation's
+// policy fail with ErrPolicyViolation.
+func (pr *Persistent) Update(ranges map[string]*keyRange, changes map[string]any) (int, error) {
+	return pr.observeWrite(SpanUpdate, ranges, func() (int, error) {
+		return pr.update(ranges, changes, nil)
+	})
+}
+
+// update applies changes to the rows matching ranges, passing every row
+// before and after the change to updated, if set.
+func (pr *Persistent) update(ranges map[string]*keyRange, changes map[string]any, updated func(before, after map[string]any)) (int, error) {
+	if err := pr.checkWritable(); err != nil {
+		return 0, err
+	}
+	matched, change, err := pr.matchUpdate(ranges, changes)
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range matched {
+		before, after, err := change(e)
 		if err != nil {
-			return err
+			pr.reportViolation(err)
+			return 0, err
+		}
+		hooks := pr.hooks()
+		if err := hooks.run(hooks.BeforeDelete, pr.tx, before); err != nil {
+			return 0, err
+		}
+		if after, err = pr.replaceEntry(e, before, after, hooks); err != nil {
+			return 0, err
+		}
+		pr.reportWrite(ChangeUpdate)
+		if err := pr.recordChange(ChangeUpdate, before, after); err != nil {
+			return 0, err
+		}
+		if err := hooks.run(hooks.AfterInsert, pr.tx, after); err != nil {
+			return 0, err
+		}
+		if updated != nil {
+			updated(pr.returnedRow(before), pr.returnedRow(after))
 		}
 	}
-	return nil
+	return len(matched), nil
 }
 
-func (pr *Persistent) Delete(ranges map[string]*keyRange) error {
+// matchUpdate checks changes and collects the rows matching ranges. The
+// returned change func gives every row before and after the change, failing
+// as the update must for it.
+func (pr *Persistent) matchUpdate(ranges map[string]*keyRange, changes map[string]any) ([]entry, func(e entry) (before, after map[string]any, err error), error) {
+	for name := range changes {
+		if !slices.Contains(pr.columns, name) {
+			return nil, nil, ErrFieldNotFound(name)
+		}
+	}
+	versionCol := pr.versionColumn()
+	var expected int64
+	if versionCol != "" {
+		v, ok := changes[versionCol]
+		if !ok {
+			return nil, nil, ErrFieldNotFound(versionCol)
+		}
+		if expected, ok = versionNumber(v); !ok {
+			return nil, nil, ErrTypeMismatch(versionCol, TypeInt, v)
+		}
+	}
 	iterEntries, err := pr.iter(ranges)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	allowed, err := pr.policyMatcher()
+	if err != nil {
+		return nil, nil, err
 	}
+	var matched []entry
 	for e, err := range iterEntries {
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		// Delete from indexes
-		for _, idxName := range pr.indexNames {
-			key, err := pr.computeKey(e.value, idxName)
+		matched = append(matched, e)
+	}
+	change := func(e entry) (map[string]any, map[string]any, error) {
+		before, err := pr.rowCopy(e.value)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, col := range pr.columns {
+			if key := pr.fields[col].EncryptionKey; key != "" && before[col] == Redacted {
+				return nil, nil, ErrKeyNotFound(key)
+			}
+		}
+		after := maps.Clone(before)
+		maps.Copy(after, changes)
+		if versionCol != "" {
+			if actual, _ := versionNumber(before[versionCol]); actual != expected {
+				return nil, nil, ErrVersionConflict(pr.relation, expected, before[versionCol])
+			}
+			after[versionCol] = expected + 1
+		}
+		if allowed != nil {
+			ok, err := allowed(pr.storeDecimals(after))
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
-			if err := pr.indexes.delete(idxName, key, e.id[:]); err != nil {
-				return err
+			if !ok {
+				return nil, nil, ErrPolicyViolation(pr.access)
 			}
 		}
-		// Delete from data
-		if err := pr.data.delete(e.id[:]); err != nil {
-			return err
+		return before, after, nil
+	}
+	return matched, change, nil
+}
+
+// replaceEntry swaps the row of e for after, running the hooks in between.
+// Its writes are undone together if any step fails, so that a rejected new
+// row leaves the old one in place.
+func (pr *Persistent) replaceEntry(e entry, before, after map[string]any, hooks RelationHooks) (map[string]any, error) {
+	sp := pr.tx.savepoint()
+	var usage capacity
+	if pr.capacity != nil {
+		usage = *pr.capacity
+	}
+	fail := func(err error) (map[string]any, error) {
+		if pr.capacity != nil {
+			*pr.capacity = usage
 		}
+		return nil, errors.Join(err, pr.tx.RollbackTo(sp), pr.tx.Release(sp))
 	}
-	return nil
+	if err := pr.removeEntry(e); err != nil {
+		return fail(err)
+	}
+	if err := hooks.run(hooks.AfterDelete, pr.tx, before); err != nil {
+		return fail(err)
+	}
+	if err := hooks.run(hooks.BeforeInsert, pr.tx, after); err != nil {
+		return fail(err)
+	}
+	after, err := pr.applyGenerated(after)
+	if err != nil {
+		return fail(err)
+	}
+	if err := pr.insertRow(after); err != nil {
+		pr.reportViolation(err)
+		return fail(err)
+	}
+	if err := pr.dropBlobs(e.value); err != nil {
+		return fail(err)
+	}
+	return after, pr.tx.Release(sp)
 }
 
-func (pr *Persistent) Select(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+// deleteMatching deletes the rows matching ranges, passing every row to
+// deleted, if set, before it is removed.
+func (pr *Persistent) deleteMatching(ranges map[string]*keyRange, deleted func(e entry) error) (int, error) {
+	if err := pr.checkWritable(); err != nil {
+		return 0, err
+	}
 	iterEntries, err := pr.iter(ranges)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return func(yield func(map[string]any, error) bool) {
-		iterEntries(func(e entry, err error) bool {
-			if err != nil {
-				return yield(nil, err)
+	// Collect matches first: deleting keys while a cursor walks the same
+	// bucket can make the cursor skip entries.
+	var matched []entry
+	for e, err := range iterEntries {
+		if err != nil {
+			return 0, err
+		}
+		matched = append(matched, e)
+	}
+	for _, e := range matched {
+		if deleted != nil {
+			if err := deleted(e); err != nil {
+				return 0, err
 			}
-			return yield(e.value, nil)
-		})
-	}, nil
+		}
+		if err := pr.deleteEntry(e); err != nil {
+			return 0, err
+		}
+	}
+	return len(matched), nil
 }
 
-func (pr *Persistent) Name() string {
-	return pr.relation
+// deleteEntries deletes the rows of entries and returns how many there were.
+func (pr *Persistent) deleteEntries(entries []entry) (int, error) {
+	return pr.observeWrite(SpanDelete, nil, func() (int, error) {
+		for _, e := range entries {
+			if err := pr.deleteEntry(e); err != nil {
+				return 0, err
+			}
+		}
+		return len(entries), nil
+	})
 }
 
-func (pr *Persistent) Columns() []string {
-	return pr.columns
+// deleteEntry removes a row, running the delete hooks and reporting the
+// deletion to watchers.
+func (pr *Persistent) deleteEntry(e entry) error {
+	hooks := pr.hooks()
+	if !hooks.onDelete() && !pr.watched() {
+		if err := pr.removeEntry(e); err != nil {
+			return err
+		}
+		if err := pr.dropBlobs(e.value); err != nil {
+			return err
+		}
+		pr.reportWrite(ChangeDelete)
+		return nil
+	}
+	before, err := pr.rowCopy(e.value)
+	if err != nil {
+		return err
+	}
+	if err := hooks.run(hooks.BeforeDelete, pr.tx, before); err != nil {
+		return err
+	}
+	if err := pr.removeEntry(e); err != nil {
+		return err
+	}
+	if err := pr.dropBlobs(e.value); err != nil {
+		return err
+	}
+	pr.reportWrite(ChangeDelete)
+	if err := pr.recordChange(ChangeDelete, before, nil); err != nil {
+		return err
+	}
+	return hooks.run(hooks.AfterDelete, pr.tx, before)
+}
+
+// removeEntry removes a row from the data bucket and all its index entries.
+func (pr *Persistent) removeEntry(e entry) error {
+	if err := pr.archiveVersion(e); err != nil {
+		return err
+	}
+	for _, idxName := range pr.indexNames {
+		keys, err := pr.indexKeys(e.value, idxName)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := pr.indexes.delete(idxName, key, e.id[:]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, su := range pr.shared {
+		if err := pr.releaseShared(su, e); err != nil {
+			return err
+		}
+	}
+	if pr.capacity != nil {
+		if err := pr.releaseUsage(e.id[:]); err != nil {
+			return err
+		}
+	}
+	if err := pr.trackChange(e.id[:]); err != nil {
+		return err
+	}
+	if err := pr.refBlobs(e.value, e.id[:], false); err != nil {
+		return err
+	}
+	if queue := pr.bucket.Bucket([]byte("indexQueue")); queue != nil {
+		if err := queue.Delete(e.id[:]); err != nil {
+			return err
+		}
+	}
+	return pr.data.delete(e.id[:])
+}
+
+func (pr *Persistent) Select(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	ranges, err := pr.restrict(ranges)
+	if err != nil {
+		return nil, err
+	}
+	ranges = pr.coerceRanges(ranges)
+	plan, err := pr.plan(ranges, queryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	seq, err := pr.selectPlan(ranges, plan, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return pr.observeSelect(ranges, plan, seq), nil
+}
+
+// selectPlan runs the query as plan says, within budget. When columns is set
+// only those columns are decoded and returned, plus any needed to match
+// ranges, which are dropped after matching.
+func (pr *Persistent) selectPlan(ranges map[string]*keyRange, plan QueryPlan, columns []string, budget *queryBudget) (iter.Seq2[map[string]any, error], error) {
+	masked := pr.maskedFor()
+	if err := pr.checkMaskedRanges(masked, ranges); err != nil {
+		return nil, err
+	}
+	if err := pr.checkEncryptedRanges(ranges); err != nil {
+		return nil, err
+	}
+	if err := pr.checkEncodedRanges(ranges); err != nil {
+		return nil, err
+	}
+	decode, err := pr.decodeColumns(ranges, plan, columns)
+	if err != nil {
+		return nil, err
+	}
+	iterEntries, err := pr.iterPlan(ranges, plan, decode, budget)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, error) bool) {
+		iterEntries(func(e entry, err error) bool {
+			if err != nil {
+				return yield(nil, err)
+			}
+			for col := range e.value {
+				if len(decode) > len(columns) && !slices.Contains(columns, col) {
+					delete(e.value, col)
+				}
+			}
+			if err := pr.readRow(e.value, masked, columns); err != nil {
+				return yield(nil, err)
+			}
+			return yield(e.value, nil)
+		})
+	}, nil
+}
+
+// decodeColumns returns the columns a projected query has to decode: the
+// requested ones and those the ranges not answered by the index refer to.
+// It returns nil, meaning every column, when columns is nil.
+func (pr *Persistent) decodeColumns(ranges map[string]*keyRange, plan QueryPlan, columns []string) ([]string, error) {
+	if columns == nil {
+		return nil, nil
+	}
+	decode := make([]string, 0, len(columns)+len(ranges))
+	for _, col := range columns {
+		if spec, ok := pr.fields[col]; !ok || len(spec.ReferenceCols) > 0 {
+			return nil, ErrFieldNotFound(col)
+		}
+		decode = append(decode, col)
+	}
+	for name := range ranges {
+		if name == plan.Index {
+			continue
+		}
+		refs := pr.fields[name].ReferenceCols
+		if len(refs) == 0 {
+			refs = []string{name}
+		}
+		for _, ref := range refs {
+			col := ref
+			if pr.isPath(ref) {
+				col = pathRoot(ref)
+			}
+			if !slices.Contains(decode, col) {
+				decode = append(decode, col)
+			}
+		}
+	}
+	return decode, nil
+}
+
+// readRow turns a stored row into the form returned to callers: decrypted,
+// validated and with masked columns redacted. columns, when set, are the
+// only columns the row was decoded with.
+func (pr *Persistent) readRow(value map[string]any, masked []string, columns []string) error {
+	if err := pr.decryptRow(value); err != nil {
+		return err
+	}
+	if err := pr.decodeRow(value); err != nil {
+		return err
+	}
+	pr.loadDecimals(value)
+	pr.loadCustom(value)
+	if err := pr.checkStoredRow(value, columns); err != nil {
+		return err
+	}
+	redact(value, masked)
+	return nil
+}
+
+// rowCopy returns a copy of a stored row in the form it was written in:
+// decrypted where the transaction holds the key, with decimals and values of
+// registered types loaded, and nothing redacted.
+func (pr *Persistent) rowCopy(value map[string]any) (map[string]any, error) {
+	row, err := pr.decryptedCopy(value)
+	if err != nil {
+		return nil, err
+	}
+	pr.loadDecimals(row)
+	pr.loadCustom(row)
+	return row, nil
+}
+
+// returnedRow returns a copy of a row read or written by a write in the form
+// Select returns rows: with decimals and values of registered types loaded
+// and the columns masked for the transaction's identity redacted.
+func (pr *Persistent) returnedRow(row map[string]any) map[string]any {
+	row = maps.Clone(row)
+	pr.loadDecimals(row)
+	pr.loadCustom(row)
+	redact(row, pr.maskedFor())
+	return ownedRow(pr.maUn, row)
+}
+
+// Truncate removes every row from the relation and clears its indexes while
+// keeping the schema. Row ids keep increasing across truncations. Under a row
+// policy restricting the transaction, only the rows it can see are deleted,
+// one by one.
+func (pr *Persistent) Truncate() error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	restricted, err := pr.restrict(nil)
+	if err != nil {
+		return err
+	}
+	if len(restricted) > 0 {
+		_, err := pr.deleteMatching(nil, nil)
+		return err
+	}
+	if err := pr.archiveAll(); err != nil {
+		return err
+	}
+	seq := pr.data.bucket.Sequence()
+	if err := pr.bucket.DeleteBucket([]byte("data")); err != nil {
+		return err
+	}
+	dataBucket, err := pr.bucket.CreateBucket([]byte("data"))
+	if err != nil {
+		return err
+	}
+	if err := dataBucket.SetSequence(seq); err != nil {
+		return err
+	}
+	pr.data.bucket = dataBucket
+	if pr.bucket.Bucket([]byte("indexQueue")) != nil {
+		if err := pr.bucket.DeleteBucket([]byte("indexQueue")); err != nil {
+			return err
+		}
+	}
+	if err := pr.data.resetColumns(pr.bucket); err != nil {
+		return err
+	}
+	if !pr.ephemeral {
+		if err := pr.tx.trackRelationReset(pr.relation); err != nil {
+			return err
+		}
+	}
+	if err := pr.releaseAllShared(); err != nil {
+		return err
+	}
+	if pr.versions == nil && pr.bucket.Bucket([]byte("blobs")) != nil {
+		if err := pr.bucket.DeleteBucket([]byte("blobs")); err != nil {
+			return err
+		}
+	}
+	if pr.bucket.Bucket([]byte("blobRefs")) != nil {
+		if err := pr.bucket.DeleteBucket([]byte("blobRefs")); err != nil {
+			return err
+		}
+	}
+	for _, idxName := range pr.indexNames {
+		if err := pr.indexes.bucket.DeleteBucket([]byte(idxName)); err != nil {
+			return err
+		}
+		if _, err := pr.indexes.bucket.CreateBucket([]byte(idxName)); err != nil {
+			return err
+		}
+	}
+	if pr.capacity != nil {
+		pr.capacity.Rows = 0
+		pr.capacity.Bytes = 0
+		if err := pr.saveCapacity(); err != nil {
+			return err
+		}
+	}
+	pr.reportWrite(ChangeTruncate)
+	if err := pr.recordChange(ChangeTruncate, nil, nil); err != nil {
+		return err
+	}
+	if len(pr.pending) == 0 {
+		return nil
+	}
+	clear(pr.pending)
+	return pr.saveSpecs()
+}
+
+func (pr *Persistent) Name() string {
+	return pr.relation
+}
+
+func (pr *Persistent) Columns() []string {
+	return pr.columns
 }
 
 func (pr *Persistent) Project(mapping map[string]string) Selector {
@@ -282,151 +1000,337 @@ func (pr *Persistent) Project(mapping map[string]string) Selector {
 }
 
 func (pr *Persistent) iter(ranges map[string]*keyRange) (iter.Seq2[entry, error], error) {
-	selectedIndexes := make([]string, 0, len(ranges))
-	for _, idxName := range pr.indexNames {
-		if _, ok := ranges[idxName]; ok {
-			selectedIndexes = append(selectedIndexes, idxName)
-		}
+	ranges, err := pr.restrict(ranges)
+	if err != nil {
+		return nil, err
 	}
-	if len(selectedIndexes) == 0 {
+	return pr.iterAll(ranges)
+}
+
+// iterAll is iter regardless of the relation's policy, for the upkeep the
+// database does on its own behalf.
+func (pr *Persistent) iterAll(ranges map[string]*keyRange) (iter.Seq2[entry, error], error) {
+	ranges = pr.coerceRanges(ranges)
+	plan, err := pr.plan(ranges, queryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return pr.iterPlan(ranges, plan, nil, nil)
+}
+
+// iterPlan yields the entries matching ranges, reading them as plan says and
+// charging every row read to budget. Only the decode columns of each row are
+// read, or all of them when nil.
+func (pr *Persistent) iterPlan(ranges map[string]*keyRange, plan QueryPlan, decode []string, budget *queryBudget) (iter.Seq2[entry, error], error) {
+	m := pr.metrics()
+	if m != nil {
+		m.QueryPlanned(plan)
+	}
+	shortestRangeIdxName := plan.Index
+	if shortestRangeIdxName == "" {
+		pr.reportScan(ranges, plan)
 		// No indexes defined, full scan
-		entries, err := pr.data.get(&keyRange{
-			includeEnd:   true,
-			includeStart: true,
-		})
-		if err != nil {
-			return nil, err
-		}
 		return func(yield func(entry, error) bool) {
-			for e, err := range entries {
-				if err != nil {
-					if !yield(entry{}, err) {
-						return
-					}
-					continue
-				}
-				value := make(map[string][]byte)
-				for k := range ranges {
-					key, err := pr.computeKey(e.value, k)
-					if err != nil {
-						if !yield(entry{}, err) {
-							return
-						}
-						continue
-					}
-					value[k] = key
+			decoded := 0
+			if m != nil {
+				defer func() { m.RowsDecoded(pr.relation, decoded) }()
+			}
+			match := pr.matcher(ranges, "")
+			var exceeded error
+			entries, err := pr.data.get(&keyRange{
+				includeEnd:   true,
+				includeStart: true,
+			}, decode, func(value map[string]any) (bool, error) {
+				decoded++
+				if exceeded = budget.scan(); exceeded != nil {
+					return false, exceeded
 				}
-				matches, err := pr.matchOps(value, ranges, "")
-				if err != nil {
-					if !yield(entry{}, err) {
-						return
-					}
-					continue
+				if match == nil {
+					return true, nil
 				}
-				if matches && !yield(e, nil) {
+				start := budget.startFilter()
+				ok, err := match(value)
+				budget.filtered(start, ok)
+				return ok, err
+			}, plan.Descending)
+			if err != nil {
+				yield(entry{}, err)
+				return
+			}
+			for e, err := range entries {
+				if !yield(e, err) || exceeded != nil {
 					return
 				}
 			}
 		}, nil
 	}
-	shortestRangeIdxName := slices.MinFunc(selectedIndexes, func(a, b string) int {
-		distA := ranges[a].distance
-		distB := ranges[b].distance
-		return bytes.Compare(distA, distB)
-	})
-	rangeIdx := ranges[shortestRangeIdxName]
-	idxes, err := pr.indexes.get(shortestRangeIdxName, rangeIdx)
+	rangeIdx, ok := ranges[shortestRangeIdxName]
+	if !ok {
+		// Hinted index without a range on it: walk the whole index.
+		rangeIdx = KeyRange(nil, nil, true, true, nil)
+	}
+	idxes, err := pr.lookupIndex(shortestRangeIdxName, rangeIdx, plan.Descending)
 	if err != nil {
 		return nil, err
 	}
+	idxes = pr.recordLookup(shortestRangeIdxName, rangeIdx, idxes)
+	var queue BackendBucket
+	if pr.fields[shortestRangeIdxName].AsyncIndex && !plan.Stale {
+		queue = pr.bucket.Bucket([]byte("indexQueue"))
+	}
+	// Match other ops, and the index's own when the index cannot answer it.
+	skip := shortestRangeIdxName
+	if pr.elementMatch(skip, rangeIdx) {
+		skip = ""
+	}
+	match := pr.matcher(ranges, skip)
 	return func(yield func(entry, error) bool) {
+		decoded := 0
+		if m != nil {
+			defer func() { m.RowsDecoded(pr.relation, decoded) }()
+		}
 		for id := range idxes {
-			values, err := pr.data.get(&keyRange{
-				includeEnd:   true,
-				includeStart: true,
-				startKey:     id[:],
-				endKey:       id[:],
-			})
+			budget.indexEntry()
+			if queue != nil && queue.Get(id[:]) != nil {
+				// Reindexed while queued; read with the queue below.
+				continue
+			}
+			value, err := pr.data.getByID(id[:], decode)
 			if err != nil {
 				if !yield(entry{}, err) {
 					return
 				}
 				continue
 			}
-			for e, err := range values {
+			if value == nil {
+				continue
+			}
+			decoded++
+			if err := budget.scan(); err != nil {
+				yield(entry{}, err)
+				return
+			}
+			if match != nil {
+				start := budget.startFilter()
+				ok, err := match(value)
+				budget.filtered(start, ok)
 				if err != nil {
 					if !yield(entry{}, err) {
 						return
 					}
 					continue
 				}
-				// Match other ops
-				value := make(map[string][]byte)
-				for k := range ranges {
-					if k == shortestRangeIdxName {
-						continue
-					}
-					key, err := pr.computeKey(e.value, k)
-					if err != nil {
-						if !yield(entry{}, err) {
-							return
-						}
-						continue
-					}
-					value[k] = key
+				if !ok {
+					continue
 				}
-				matches, err := pr.matchOps(value, ranges, shortestRangeIdxName)
+			}
+			if !yield(entry{id: id, value: value}, nil) {
+				return
+			}
+		}
+		if queue != nil {
+			pr.queuedEntries(queue, ranges, decode)(yield)
+		}
+	}, nil
+}
+
+// matcher returns a function reporting whether a row falls in every range
+// but skip, or nil when there is nothing left to check. Keys are encoded into
+// a buffer shared by all the rows of the query.
+func (pr *Persistent) matcher(ranges map[string]*keyRange, skip string) func(map[string]any) (bool, error) {
+	names := make([]string, 0, len(ranges))
+	for name := range ranges {
+		if name != skip {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	var buf []byte
+	return func(value map[string]any) (bool, error) {
+		for _, name := range names {
+			if pr.elementMatch(name, ranges[name]) {
+				keys, err := elementKeys(value[name])
 				if err != nil {
-					if !yield(entry{}, err) {
-						return
-					}
-					continue
+					return false, err
 				}
-				if matches && !yield(e, nil) {
-					return
+				if !ranges[name].matchElements(keys) {
+					return false, nil
 				}
+				continue
+			}
+			var err error
+			buf, err = pr.appendKey(buf[:0], value, name)
+			if err != nil {
+				return false, err
+			}
+			if !ranges[name].contains(buf) {
+				return false, nil
 			}
 		}
-	}, nil
+		return true, nil
+	}
+}
+
+// plan picks the index used to drive a query over ranges, or none when the
+// relation has to be scanned, honouring index hints in opts.
+func (pr *Persistent) plan(ranges map[string]*keyRange, opts queryOptions) (QueryPlan, error) {
+	plan, err := pr.choosePlan(ranges, opts)
+	if err == nil && plan.Index != "" && pr.fields[plan.Index].AsyncIndex {
+		plan.Stale = opts.stale
+	}
+	return plan, err
+}
+
+func (pr *Persistent) choosePlan(ranges map[string]*keyRange, opts queryOptions) (QueryPlan, error) {
+	plan := QueryPlan{Relation: pr.relation, Descending: opts.desc}
+	switch {
+	case opts.noIndex:
+		plan.Reason = PlanForcedScan
+		return plan, nil
+	case opts.useIndex != "":
+		_, building := pr.pending[opts.useIndex]
+		if !slices.Contains(pr.indexNames, opts.useIndex) || building {
+			return plan, ErrIndexNotFound(opts.useIndex)
+		}
+		plan.Index, plan.Reason = opts.useIndex, PlanHinted
+		return plan, nil
+	}
+	for _, name := range opts.ignore {
+		if !slices.Contains(pr.indexNames, name) {
+			return plan, ErrIndexNotFound(name)
+		}
+	}
+	selectedIndexes := make([]string, 0, len(ranges))
+	ignored := false
+	for _, idxName := range pr.indexNames {
+		if _, building := pr.pending[idxName]; building {
+			continue
+		}
+		kr, ok := ranges[idxName]
+		if ok && (!kr.elementwise() || pr.fields[idxName].MultiEntry) {
+			if slices.Contains(opts.ignore, idxName) {
+				ignored = true
+				continue
+			}
+			selectedIndexes = append(selectedIndexes, idxName)
+		}
+	}
+	if len(selectedIndexes) == 0 {
+		plan.Reason = PlanNoIndex
+		if ignored {
+			plan.Reason = PlanIgnored
+		}
+		return plan, nil
+	}
+	if best, ok := pr.analyzedBestIndex(selectedIndexes, ranges); ok {
+		pr.rememberPlan(selectedIndexes, ranges, best)
+		plan.Index, plan.Reason = best, PlanAnalyzed
+		return plan, nil
+	}
+	if best, ok := pr.observedBestIndex(selectedIndexes, ranges); ok {
+		pr.rememberPlan(selectedIndexes, ranges, best)
+		plan.Index, plan.Reason = best, PlanObserved
+		return plan, nil
+	}
+	if best, ok := pr.rememberedPlan(selectedIndexes, ranges); ok {
+		plan.Index, plan.Reason = best, PlanRemembered
+		return plan, nil
+	}
+	plan.Index = slices.MinFunc(selectedIndexes, func(a, b string) int {
+		distA := ranges[a].distance
+		distB := ranges[b].distance
+		return bytes.Compare(distA, distB)
+	})
+	plan.Reason = PlanNarrowestRange
+	return plan, nil
 }
 
 func (pr *Persistent) computeKey(obj map[string]any, name string) ([]byte, error) {
+	return pr.appendKey(nil, obj, name)
+}
+
+// appendKey appends the key of obj for the column or composite name to buf.
+func (pr *Persistent) appendKey(buf []byte, obj map[string]any, name string) ([]byte, error) {
 	keySpec, ok := pr.fields[name]
 	if !ok {
-		return nil, ErrFieldNotFound(name)
-	}
-	var keyParts []any
-	if len(keySpec.ReferenceCols) > 0 {
-		keyParts = make([]any, 0, len(keySpec.ReferenceCols))
-		for _, refCol := range keySpec.ReferenceCols {
-			v, ok := obj[refCol]
-			if !ok {
-				return nil, ErrFieldNotFound(refCol)
-			}
-			keyParts = append(keyParts, v)
+		if !pr.isPath(name) {
+			return nil, ErrFieldNotFound(name)
+		}
+		// A row missing the path encodes to nothing, which sorts first.
+		if v, ok := pr.valueAt(obj, name); ok {
+			return orderedMa.Append(buf, v)
 		}
-	} else {
+		return buf, nil
+	}
+	if len(keySpec.ReferenceCols) == 0 {
 		v, ok := obj[name]
 		if !ok {
 			return nil, ErrFieldNotFound(name)
 		}
-		keyParts = []any{v}
+		return keySpec.appendValue(buf, v)
+	}
+	keyParts := make([]any, 0, len(keySpec.ReferenceCols))
+	for _, refCol := range keySpec.ReferenceCols {
+		v, ok := pr.valueAt(obj, refCol)
+		if !ok {
+			if pr.isPath(refCol) {
+				break
+			}
+			return nil, ErrFieldNotFound(refCol)
+		}
+		keyParts = append(keyParts, v)
 	}
-	return ToKey(keyParts...)
+	return orderedMa.Append(buf, keyParts...)
 }
 
-func (pr *Persistent) matchOps(value map[string][]byte, keyRanges map[string]*keyRange, skip string) (bool, error) {
-	for name, r := range keyRanges {
-		if name == skip {
-			continue
+// appendValue appends the key of the column value v to buf, after putting v
+// in the canonical form of the column's type.
+func (spec ColumnSpec) appendValue(buf []byte, v any) ([]byte, error) {
+	v = spec.Normalize.normalize(v)
+	switch spec.Type {
+	case TypeInt:
+		if f, ok := v.(float64); ok && TypeInt.accepts(f) {
+			v = int64(f)
 		}
-		v, ok := value[name]
-		if !ok {
-			return false, nil
+	case TypeFloat:
+		if f, ok := v.(float32); ok {
+			v = float64(f)
+		}
+	case TypeTime:
+		if t, ok := timeValue(v); ok {
+			v = t
+		}
+	case TypeBigInt, TypeDecimal:
+		if x, ok := decimalValue(v); ok {
+			v = x
 		}
-		if !r.contains(v) {
-			return false, nil
+	default:
+		if ct := spec.Type.custom(); ct != nil {
+			if x, ok := ct.value(v); ok {
+				v = x
+			}
+		}
+	}
+	return orderedMa.Append(buf, v)
+}
+
+func (pr *Persistent) hasFields(ranges map[string]*keyRange) bool {
+	for name := range ranges {
+		if _, ok := pr.fields[name]; !ok && !pr.isPath(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// versionColumn returns the column declared with ColumnSpec.Version, or "".
+func (pr *Persistent) versionColumn() string {
+	for col, spec := range pr.fields {
+		if spec.Version {
+			return col
 		}
 	}
-	return true, nil
+	return ""
 }
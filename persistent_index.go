@@ -0,0 +1,242 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+)
+
+// CreateIndex adds an index named name over columns and backfills it from the
+// existing rows of the relation. When name is an existing column and columns
+// is just that column, the column itself becomes indexed.
+func (pr *Persistent) CreateIndex(name string, columns []string, unique bool) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if err := pr.registerIndex(name, columns, unique); err != nil {
+		return err
+	}
+	return pr.buildIndex(name, nil)
+}
+
+// reindexBatch is how many rows are indexed between progress reports.
+const reindexBatch = 1024
+
+// Reindex drops every entry of the index name and rebuilds it from the rows
+// of the relation, repairing the inconsistencies Verify reports. progress,
+// when not nil, is called after each batch of rows with the number of rows
+// indexed so far. A unique index fails with ErrUniqueConstraint if the rows
+// hold a duplicate key.
+func (pr *Persistent) Reindex(name string, progress func(index string, rows int)) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if !slices.Contains(pr.indexNames, name) {
+		return ErrIndexNotFound(name)
+	}
+	if err := pr.indexes.bucket.DeleteBucket([]byte(name)); err != nil {
+		return err
+	}
+	if _, err := pr.indexes.bucket.CreateBucket([]byte(name)); err != nil {
+		return err
+	}
+	pr.pending[name] = pr.data.bucket.Sequence()
+	if err := pr.saveSpecs(); err != nil {
+		return err
+	}
+	return pr.buildIndex(name, progress)
+}
+
+// ReindexAll is Reindex for every index of the relation.
+func (pr *Persistent) ReindexAll(progress func(index string, rows int)) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	for _, name := range slices.Clone(pr.indexNames) {
+		if err := pr.Reindex(name, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildIndex backfills the pending index name in batches and marks it ready.
+func (pr *Persistent) buildIndex(name string, progress func(index string, rows int)) error {
+	var after []byte
+	rows := 0
+	for {
+		last, n, done, err := pr.backfillIndex(name, after, reindexBatch)
+		if err != nil {
+			return err
+		}
+		rows += n
+		if progress != nil {
+			progress(name, rows)
+		}
+		if done {
+			break
+		}
+		after = last
+	}
+	return pr.finishIndex(name)
+}
+
+// DropIndex removes the index named name together with its bucket.
+func (pr *Persistent) DropIndex(name string) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	if !slices.Contains(pr.indexNames, name) {
+		return ErrIndexNotFound(name)
+	}
+	if err := pr.indexes.bucket.DeleteBucket([]byte(name)); err != nil {
+		return err
+	}
+	spec := pr.fields[name]
+	if len(spec.ReferenceCols) > 0 {
+		delete(pr.fields, name)
+	} else {
+		spec.Indexed = false
+		spec.Unique = false
+		pr.fields[name] = spec
+	}
+	delete(pr.pending, name)
+	return pr.saveSpecs()
+}
+
+func (pr *Persistent) registerIndex(name string, columns []string, unique bool) error {
+	if slices.Contains(pr.indexNames, name) {
+		return ErrIndexAlreadyExists(name)
+	}
+	for _, col := range columns {
+		if !slices.Contains(pr.columns, col) && !pr.isPath(col) {
+			return ErrFieldNotFound(col)
+		}
+	}
+	spec, exists := pr.fields[name]
+	switch {
+	case exists && len(columns) == 1 && columns[0] == name:
+		spec.Indexed = !unique
+		spec.Unique = unique
+	case exists:
+		return ErrIndexAlreadyExists(name)
+	default:
+		spec = ColumnSpec{
+			ReferenceCols: slices.Clone(columns),
+			Indexed:       !unique,
+			Unique:        unique,
+		}
+	}
+	if _, err := pr.indexes.bucket.CreateBucketIfNotExists([]byte(name)); err != nil {
+		return err
+	}
+	pr.fields[name] = spec
+	pr.pending[name] = pr.data.bucket.Sequence()
+	return pr.saveSpecs()
+}
+
+// backfillIndex indexes at most limit rows with ids greater than after and not
+// newer than the high-water mark recorded when the index was registered, and
+// returns the last id and the number of rows indexed. Rows inserted after
+// registration are maintained by Insert itself.
+func (pr *Persistent) backfillIndex(name string, after []byte, limit int) ([]byte, int, bool, error) {
+	highWater, ok := pr.pending[name]
+	if !ok {
+		return nil, 0, true, nil
+	}
+	var end [8]byte
+	binary.BigEndian.PutUint64(end[:], highWater)
+	unique := pr.fields[name].Unique
+	c := pr.data.bucket.Cursor()
+	var k, v []byte
+	if after == nil {
+		k, v = c.First()
+	} else {
+		k, v = c.Seek(after)
+		if k != nil && bytes.Equal(k, after) {
+			k, v = c.Next()
+		}
+	}
+	n := 0
+	for ; k != nil && bytes.Compare(k, end[:]) <= 0; k, v = c.Next() {
+		if n == limit {
+			return after, n, false, nil
+		}
+		var value map[string]any
+		if err := pr.data.unmarshal(k, v, &value); err != nil {
+			return nil, 0, false, err
+		}
+		keys, err := pr.indexKeys(value, name)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		for _, key := range keys {
+			if unique && len(key) > 0 {
+				existing, err := pr.indexes.get(name, KeyRange(key, key, true, true, nil), false)
+				if err != nil {
+					return nil, 0, false, err
+				}
+				for id, err := range existing {
+					if err != nil {
+						return nil, 0, false, err
+					}
+					if !bytes.Equal(id[:], k) {
+						return nil, 0, false, ErrUniqueConstraint(name, key)
+					}
+				}
+			}
+			if err := pr.indexes.insert(name, key, k); err != nil {
+				return nil, 0, false, err
+			}
+		}
+		after = slices.Clone(k)
+		n++
+	}
+	return after, n, true, nil
+}
+
+func (pr *Persistent) finishIndex(name string) error {
+	delete(pr.pending, name)
+	return pr.saveSpecs()
+}
+
+// saveSpecs persists the column specs and pending indexes and refreshes the
+// derived column, index and unique name lists.
+func (pr *Persistent) saveSpecs() error {
+	pr.tx.touch(pr.relation)
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	if metaBucket == nil {
+		return ErrMetaDataNotFound(pr.relation)
+	}
+	specsBytes, err := pr.maUn.Marshal(pr.fields)
+	if err != nil {
+		return err
+	}
+	if err := metaBucket.Put([]byte("columnSpecs"), specsBytes); err != nil {
+		return err
+	}
+	if len(pr.pending) == 0 {
+		if err := metaBucket.Delete([]byte("pendingIndexes")); err != nil {
+			return err
+		}
+	} else {
+		pendingBytes, err := pr.maUn.Marshal(pr.pending)
+		if err != nil {
+			return err
+		}
+		if err := metaBucket.Put([]byte("pendingIndexes"), pendingBytes); err != nil {
+			return err
+		}
+	}
+	columns, indexNames, uniqueNames, err := deriveNames(pr.fields)
+	if err != nil {
+		return err
+	}
+	pr.columns = columns
+	pr.indexNames = indexNames
+	pr.uniqueNames = uniqueNames
+	pr.typed = hasTypedColumns(pr.fields)
+	pr.encrypted = hasEncryptedColumns(pr.fields)
+	pr.encoded = hasCodecColumns(pr.fields)
+	return nil
+}
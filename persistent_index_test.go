@@ -0,0 +1,176 @@
+package thunder
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPersistent_CreateAndDropIndex(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+		"id":    {},
+		"first": {},
+		"last":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if err := p.Insert(map[string]any{
+			"id":    fmt.Sprintf("%d", i),
+			"first": fmt.Sprintf("f%d", i%3),
+			"last":  "doe",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.CreateIndex("first", []string{"first"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateIndex("full_name", []string{"first", "last"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CreateIndex("first", []string{"first"}, false); err == nil {
+		t.Fatal("expected duplicate index error")
+	}
+	if err := p.Insert(map[string]any{"id": "10", "first": "f0", "last": "doe"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err = tx.LoadPersistent("people")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(p.indexNames, "first") || !slices.Contains(p.indexNames, "full_name") {
+		t.Fatalf("expected indexes to be persisted, got %v", p.indexNames)
+	}
+	assertCount := func(ops []Op, expected int) {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := p.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		if count != expected {
+			t.Errorf("expected %d rows for %v, got %d", expected, ops, count)
+		}
+	}
+	assertCount([]Op{Eq("first", "f0")}, 5)
+	assertCount([]Op{Eq("full_name", "f1", "doe")}, 3)
+
+	if err := p.DropIndex("full_name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DropIndex("first"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.indexNames) != 0 {
+		t.Errorf("expected no indexes after drop, got %v", p.indexNames)
+	}
+	if _, ok := p.fields["full_name"]; ok {
+		t.Error("expected composite spec to be removed")
+	}
+	assertCount([]Op{Eq("first", "f0")}, 5)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_CreateIndexOnline(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("accounts", map[string]ColumnSpec{
+		"email": {},
+		"team":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 25 {
+		if err := p.Insert(map[string]any{
+			"email": fmt.Sprintf("user%d@example.com", i),
+			"team":  fmt.Sprintf("t%d", i%5),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.CreateIndex("accounts", "email", []string{"email"}, true, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateIndex("accounts", "team", []string{"team"}, true, 4); err == nil {
+		t.Fatal("expected unique violation while backfilling team")
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err = tx.LoadPersistent("accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("expected no pending indexes, got %v", p.pending)
+	}
+	if !slices.Contains(p.uniqueNames, "email") || slices.Contains(p.indexNames, "team") {
+		t.Errorf("unexpected indexes: %v", p.indexNames)
+	}
+	ranges, err := ToKeyRanges(Eq("email", "user7@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := p.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["team"] != "t2" {
+			t.Errorf("unexpected row %v", row)
+		}
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected 1 row, got %d", count)
+	}
+}
@@ -1,7 +1,9 @@
 package thunder
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"testing"
 )
 
@@ -106,3 +108,195 @@ func TestPersistent_AllOperators(t *testing.T) {
 		})
 	}
 }
+
+func TestPersistent_UpdateVersionColumn(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+		"id":      {Unique: true},
+		"body":    {},
+		"version": {Version: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := docs.Insert(map[string]any{"id": int64(1), "body": "draft"}); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("id", int64(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.Update(ranges, map[string]any{"body": "final", "version": int64(1)}); err != nil {
+		t.Fatal(err)
+	}
+	var thErr *ThunderError
+	_, err = docs.Update(ranges, map[string]any{"body": "stale", "version": int64(1)})
+	if !errors.As(err, &thErr) || thErr.Code != ErrCodeVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	_, err = docs.Update(ranges, map[string]any{"body": "blind"})
+	if !errors.As(err, &thErr) || thErr.Code != ErrCodeFieldNotFound {
+		t.Fatalf("expected the version to be required, got %v", err)
+	}
+	rows, err := docs.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, err := range rows {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["body"] != "final" || row["version"] != int64(2) {
+			t.Fatalf("unexpected row %v", row)
+		}
+	}
+
+	_, err = tx.CreatePersistent("bad", map[string]ColumnSpec{
+		"a": {Version: true},
+		"b": {Version: true},
+	})
+	if !errors.As(err, &thErr) || thErr.Code != ErrCodeInvalidVersionColumn {
+		t.Fatalf("expected ErrInvalidVersionColumn, got %v", err)
+	}
+}
+
+func TestPersistent_FloatSpecialValues(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	values := map[string]float64{
+		"nan":     math.NaN(),
+		"-inf":    math.Inf(-1),
+		"-one":    -1,
+		"-zero":   math.Copysign(0, -1),
+		"zero":    0,
+		"one":     1,
+		"inf":     math.Inf(1),
+		"nan-too": math.Float64frombits(0x7ff8000000000001),
+	}
+	err := db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("floats", map[string]ColumnSpec{
+			"id":      {},
+			"indexed": {Indexed: true, Type: TypeFloat},
+			"plain":   {Type: TypeFloat},
+		})
+		if err != nil {
+			return err
+		}
+		for id, v := range values {
+			if err := p.Insert(map[string]any{"id": id, "indexed": v, "plain": v}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("floats")
+		if err != nil {
+			return err
+		}
+		for _, column := range []string{"indexed", "plain"} {
+			for _, tt := range []struct {
+				op   Op
+				want int
+			}{
+				{Eq(column, 0.0), 2},
+				{Eq(column, math.Copysign(0, -1)), 2},
+				{Eq(column, math.NaN()), 2},
+				{Lt(column, math.Inf(-1)), 2},
+				{Gt(column, math.Inf(-1)), 5},
+				{Ge(column, -1.0), 5},
+				{Le(column, math.Inf(1)), 8},
+			} {
+				ranges, err := ToKeyRanges(tt.op)
+				if err != nil {
+					return err
+				}
+				seq, err := p.Select(ranges)
+				if err != nil {
+					return err
+				}
+				n := 0
+				for _, err := range seq {
+					if err != nil {
+						return err
+					}
+					n++
+				}
+				if n != tt.want {
+					t.Errorf("%s %v: expected %d rows, got %d", column, tt.op, tt.want, n)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersistent_UpdateUniqueViolationKeepsRow(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"email": {Unique: true},
+		"name":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@x", "b@x"} {
+		if err := users.Insert(map[string]any{"email": email, "name": email}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := ToKeyRanges(Eq("email", "b@x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	if _, err := users.Update(ranges, map[string]any{"email": "a@x"}); !errors.As(err, &te) || te.Code != ErrCodeUniqueConstraint {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+	seq, err := users.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["name"] != "b@x" {
+			t.Errorf("expected the original row, got %v", row)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("expected the original row to survive, got %d rows", n)
+	}
+	report, err := users.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("expected consistent indexes, got %v", report.Issues)
+	}
+}
@@ -1,8 +1,12 @@
 package thunder
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func setupTestDB(t *testing.T) (*DB, func()) {
@@ -625,3 +629,342 @@ func TestPersistent_CompositeIndex(t *testing.T) {
 		t.Errorf("Expected Doe and Smith, got Doe=%v Smith=%v", foundDoe, foundSmith)
 	}
 }
+
+func TestPersistent_InsertAtomic(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{
+		"seq": {Unique: true},
+		"msg": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Insert(map[string]any{"seq": int64(1), "msg": "boot"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.SetCap(0, 64); err != nil {
+		t.Fatal(err)
+	}
+	// A unique violation and a row too large for the cap are both rejected
+	// after their checks, leaving no data row or index entry behind.
+	if err := logs.Insert(map[string]any{"seq": int64(1), "msg": "dup"}); err == nil {
+		t.Fatal("expected unique violation")
+	}
+	huge := strings.Repeat("x", 128)
+	if err := logs.Insert(map[string]any{"seq": int64(2), "msg": huge}); err == nil {
+		t.Fatal("expected capacity exceeded")
+	}
+	n := 0
+	err = logs.data.bucket.ForEach(func(_, _ []byte) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || logs.capacity.Rows != 1 {
+		t.Fatalf("expected only the first row, got %d rows (usage %d)", n, logs.capacity.Rows)
+	}
+	for _, tc := range []struct{ col, value string }{{"msg", huge}, {"msg", "dup"}} {
+		ranges, err := ToKeyRanges(Eq(tc.col, tc.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := logs.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for row, err := range seq {
+			t.Fatalf("expected no %s row, got %v (%v)", tc.value, row, err)
+		}
+	}
+}
+
+func TestTx_CreatePersistentExisting(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	specs := map[string]ColumnSpec{
+		"seq": {Unique: true},
+		"msg": {},
+	}
+	err := db.update(func(tx *Tx) error {
+		logs, err := tx.CreatePersistent("logs", specs)
+		if err != nil {
+			return err
+		}
+		if err := logs.SetCap(2, 0); err != nil {
+			return err
+		}
+		return logs.MaskColumn("msg", "support")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tx.SetIdentity("support")
+	// Creating a relation that exists keeps its cap and masks.
+	logs, err := tx.CreatePersistent("logs", specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		if err := logs.Insert(map[string]any{"seq": int64(i), "msg": "boot"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seq, err := logs.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["msg"] != Redacted {
+			t.Errorf("expected masked msg, got %v", row)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected the cap to keep 2 rows, got %d", n)
+	}
+}
+
+func TestPersistent_InsertAtomicUncapped(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{
+		"seq": {Unique: true},
+		"msg": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The index key is only rejected by the store, after the data row is
+	// written.
+	huge := strings.Repeat("x", 1<<16)
+	if err := logs.Insert(map[string]any{"seq": int64(1), "msg": huge}); err == nil {
+		t.Fatal("expected the index key to be rejected")
+	}
+	n := 0
+	err = logs.data.bucket.ForEach(func(_, _ []byte) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no data row left behind, got %d", n)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.db")
+	db, err := OpenDB(&MsgpackMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("sales", map[string]ColumnSpec{"amount": {}})
+		if err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"amount": int64(5)})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := OpenReadOnly(&MsgpackMaUn, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := OpenReadOnly(&MsgpackMaUn, path)
+	if err != nil {
+		t.Fatalf("expected readers to share the file, got %v", err)
+	}
+	defer second.Close()
+
+	var te *ThunderError
+	if _, err := first.Begin(true); !errors.As(err, &te) || te.Code != ErrCodeReadOnly {
+		t.Errorf("expected ErrReadOnly beginning a write, got %v", err)
+	}
+	if _, err := first.InsertMany("sales", []map[string]any{{"amount": int64(1)}}, 0); !errors.As(err, &te) || te.Code != ErrCodeReadOnly {
+		t.Errorf("expected ErrReadOnly inserting, got %v", err)
+	}
+	err = second.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("sales")
+		if err != nil {
+			return err
+		}
+		if err := p.Insert(map[string]any{"amount": int64(1)}); !errors.As(err, &te) || te.Code != ErrCodeReadOnly {
+			t.Errorf("expected ErrReadOnly from a read-only handle, got %v", err)
+		}
+		if n, err := p.Count(); err != nil || n != 1 {
+			t.Errorf("expected the row readable, got %d, %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTx_ReadOnlyWrites(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("files", map[string]ColumnSpec{
+			"name": {Unique: true},
+			"at":   {Type: TypeTime},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateLog("events", 0); err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"name": "a", "at": time.Now()})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("files")
+		if err != nil {
+			return err
+		}
+		l, err := tx.LoadLog("events")
+		if err != nil {
+			return err
+		}
+		all := map[string]*keyRange{}
+		writes := map[string]func() error{
+			"CreatePersistent": func() error {
+				_, err := tx.CreatePersistent("other", map[string]ColumnSpec{"x": {}})
+				return err
+			},
+			"DropRelation":   func() error { return tx.DropRelation("files") },
+			"RenameRelation": func() error { return tx.RenameRelation("files", "other") },
+			"CopyRelation":   func() error { return tx.CopyRelation("files", "other") },
+			"CreateSharedUnique": func() error {
+				return tx.CreateSharedUnique("names", map[string][]string{"files": {"name"}})
+			},
+			"DropSharedUnique": func() error { return tx.DropSharedUnique("names") },
+			"Savepoint": func() error {
+				_, err := tx.Savepoint()
+				return err
+			},
+			"Sequence.Next": func() error {
+				_, err := tx.Sequence("ids").Next()
+				return err
+			},
+			"Sequence.SetStart": func() error { return tx.Sequence("ids").SetStart(5) },
+			"CreateLog": func() error {
+				_, err := tx.CreateLog("other", 0)
+				return err
+			},
+			"DropLog": func() error { return tx.DropLog("events") },
+			"Log.Append": func() error {
+				_, err := l.Append(map[string]any{"n": int64(1)})
+				return err
+			},
+			"Log.Trim": func() error {
+				_, err := l.Trim(1)
+				return err
+			},
+			"Log.TrimOlderThan": func() error {
+				_, err := l.TrimOlderThan(time.Now())
+				return err
+			},
+			"Insert": func() error { return p.Insert(map[string]any{"name": "b"}) },
+			"InsertMany": func() error {
+				_, err := p.InsertMany([]map[string]any{{"name": "b"}})
+				return err
+			},
+			"Delete": func() error { return p.Delete(all) },
+			"Update": func() error {
+				_, err := p.Update(all, map[string]any{"name": "b"})
+				return err
+			},
+			"DeleteReturning": func() error {
+				_, err := p.DeleteReturning(all)
+				return err
+			},
+			"UpdateReturning": func() error {
+				_, err := p.UpdateReturning(all, map[string]any{"name": "b"})
+				return err
+			},
+			"Truncate": func() error { return p.Truncate() },
+			"Analyze":  func() error { return p.Analyze() },
+			"ApplyIndexQueue": func() error {
+				_, err := p.ApplyIndexQueue(0)
+				return err
+			},
+			"CreateBlob": func() error {
+				_, err := p.CreateBlob()
+				return err
+			},
+			"SetCap":        func() error { return p.SetCap(1, 0) },
+			"SetQuota":      func() error { return p.SetQuota(1, 0) },
+			"EnableHistory": func() error { return p.EnableHistory() },
+			"MaskColumn":    func() error { return p.MaskColumn("name", "guest") },
+			"UnmaskColumn":  func() error { return p.UnmaskColumn("name", "guest") },
+			"Recode":        func() error { return p.Recode(&JsonMaUn) },
+			"CreateIndex":   func() error { return p.CreateIndex("by_at", []string{"at"}, false) },
+			"Reindex":       func() error { return p.Reindex("name", nil) },
+			"ReindexAll":    func() error { return p.ReindexAll(nil) },
+			"DropIndex":     func() error { return p.DropIndex("name") },
+			"SetTTL":        func() error { return p.SetTTL("at", time.Hour) },
+			"SweepExpired": func() error {
+				_, err := p.SweepExpired()
+				return err
+			},
+			"SetColumnType": func() error { return p.SetColumnType("name", TypeString) },
+			"Generate":      func() error { return p.Generate(1, nil, 1) },
+			"Import": func() error {
+				_, err := p.Import(strings.NewReader(`{"name":"b"}`+"\n"), 0)
+				return err
+			},
+			"ImportCSV": func() error {
+				_, err := p.ImportCSV(strings.NewReader("name\nb\n"), CSVOptions{})
+				return err
+			},
+		}
+		for name, write := range writes {
+			var te *ThunderError
+			if err := write(); !errors.As(err, &te) || te.Code != ErrCodeReadOnly {
+				t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+package thunder
+
+// PlannerEvent reports a notable planner decision. It is currently emitted
+// when a filtered query falls back to scanning a relation's data because no
+// index covers any of its ranges.
+type PlannerEvent struct {
+	Plan   QueryPlan
+	Ranges map[string]RangeSpec
+}
+
+// SetPlannerHook registers the function that receives planner events. The
+// hook runs synchronously inside the query, so it should return quickly.
+func (d *DB) SetPlannerHook(hook func(PlannerEvent)) {
+	d.plannerHook = hook
+}
+
+// reportScan emits a PlannerEvent when plan scans a relation even though the
+// query filters on some of its columns.
+func (pr *Persistent) reportScan(ranges map[string]*keyRange, plan QueryPlan) {
+	if plan.Reason != PlanNoIndex || len(ranges) == 0 || pr.ephemeral || pr.tx.db == nil {
+		return
+	}
+	if logger := pr.tx.db.logger; logger != nil {
+		logger.Debug("thunder: scanning relation", "relation", pr.relation, "ranges", describeRanges(ranges))
+	}
+	if pr.tx.db.plannerHook != nil {
+		pr.tx.db.plannerHook(PlannerEvent{Plan: plan, Ranges: rangeSpecs(ranges)})
+	}
+}
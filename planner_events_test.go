@@ -0,0 +1,62 @@
+package thunder
+
+import (
+	"testing"
+)
+
+func TestDB_PlannerHookReportsFullScans(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	var events []PlannerEvent
+	db.SetPlannerHook(func(e PlannerEvent) {
+		events = append(events, e)
+	})
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{
+		"level": {Indexed: true},
+		"msg":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Insert(map[string]any{"level": "warn", "msg": "disk"}); err != nil {
+		t.Fatal(err)
+	}
+	query := func(ops ...Op) {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := logs.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	query(Eq("level", "warn"))
+	query()
+	if len(events) != 0 {
+		t.Fatalf("expected no events for indexed or unfiltered queries, got %v", events)
+	}
+	query(Eq("msg", "disk"))
+	if len(events) != 1 {
+		t.Fatalf("expected one full scan event, got %v", events)
+	}
+	e := events[0]
+	if e.Plan.Relation != "logs" || e.Plan.Index != "" {
+		t.Fatalf("unexpected plan %v", e.Plan)
+	}
+	if _, ok := e.Ranges["msg"]; !ok || len(e.Ranges) != 1 {
+		t.Fatalf("expected the msg range in the event, got %v", e.Ranges)
+	}
+}
@@ -0,0 +1,231 @@
+package thunder
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	statsBucket = "__thunder_stats"
+	// minObservedLookups is the number of completed lookups an index needs
+	// before its observed selectivity overrides the range-width heuristic.
+	minObservedLookups = 8
+)
+
+// indexStats records how many rows lookups through an index produced.
+type indexStats struct {
+	Lookups uint64
+	Rows    uint64
+}
+
+// plannerStats accumulates index selectivity observed at runtime, and the
+// index last chosen from statistics for every query shape. It is persisted
+// on Close, when it changed, and loaded on open so planning decisions
+// survive restarts. Analyze statistics are stored as they are computed.
+type plannerStats struct {
+	mu      sync.Mutex
+	indexes map[string]indexStats
+	// plans maps a query shape to the index statistics last chose for it,
+	// which is chosen again while they cannot decide.
+	plans map[string]string
+	// version counts the changes recorded; saved is the version last
+	// persisted.
+	version uint64
+	saved   uint64
+}
+
+func newPlannerStats() *plannerStats {
+	return &plannerStats{indexes: make(map[string]indexStats), plans: make(map[string]string)}
+}
+
+func (ps *plannerStats) get(key string) (indexStats, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	s, ok := ps.indexes[key]
+	return s, ok
+}
+
+func (ps *plannerStats) record(key string, rows uint64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	s := ps.indexes[key]
+	s.Lookups++
+	s.Rows += rows
+	ps.indexes[key] = s
+	ps.version++
+}
+
+func (ps *plannerStats) plan(shape string) (string, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	index, ok := ps.plans[shape]
+	return index, ok
+}
+
+func (ps *plannerStats) recordPlan(shape, index string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.plans[shape] == index {
+		return
+	}
+	ps.plans[shape] = index
+	ps.version++
+}
+
+// snapshot returns a copy of the stats and their version, or ok false when
+// nothing was recorded since they were last saved.
+func (ps *plannerStats) snapshot() (indexes map[string]indexStats, plans map[string]string, version uint64, ok bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.version == ps.saved {
+		return nil, nil, 0, false
+	}
+	return maps.Clone(ps.indexes), maps.Clone(ps.plans), ps.version, true
+}
+
+func (ps *plannerStats) markSaved(version uint64) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.saved = max(ps.saved, version)
+}
+
+// statsKey identifies lookups of one shape: equality lookups and range scans
+// on the same index have very different selectivity.
+func (pr *Persistent) statsKey(idxName string, kr *keyRange) string {
+	shape := "range"
+	if kr.startKey != nil && kr.includeStart && kr.includeEnd && string(kr.startKey) == string(kr.endKey) {
+		shape = "eq"
+	}
+	return pr.relation + "\x00" + idxName + "\x00" + shape
+}
+
+// observedBestIndex returns the candidate with the lowest observed rows per
+// lookup, provided every candidate has been observed often enough.
+func (pr *Persistent) observedBestIndex(candidates []string, ranges map[string]*keyRange) (string, bool) {
+	if pr.ephemeral || len(candidates) < 2 {
+		return "", false
+	}
+	best := ""
+	bestAvg := 0.0
+	for _, name := range candidates {
+		s, ok := pr.tx.db.stats.get(pr.statsKey(name, ranges[name]))
+		if !ok || s.Lookups < minObservedLookups {
+			return "", false
+		}
+		avg := float64(s.Rows) / float64(s.Lookups)
+		if best == "" || avg < bestAvg {
+			best, bestAvg = name, avg
+		}
+	}
+	return best, true
+}
+
+// planShape identifies queries over the same candidate indexes with lookups
+// of the same shapes.
+func (pr *Persistent) planShape(candidates []string, ranges map[string]*keyRange) string {
+	keys := make([]string, len(candidates))
+	for i, name := range candidates {
+		keys[i] = pr.statsKey(name, ranges[name])
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, "\x01")
+}
+
+// rememberPlan records that statistics chose index for the candidates.
+func (pr *Persistent) rememberPlan(candidates []string, ranges map[string]*keyRange, index string) {
+	if pr.ephemeral || pr.tx.db == nil {
+		return
+	}
+	pr.tx.db.stats.recordPlan(pr.planShape(candidates, ranges), index)
+}
+
+// rememberedPlan returns the index statistics last chose for the
+// candidates.
+func (pr *Persistent) rememberedPlan(candidates []string, ranges map[string]*keyRange) (string, bool) {
+	if pr.ephemeral || pr.tx.db == nil || len(candidates) < 2 {
+		return "", false
+	}
+	index, ok := pr.tx.db.stats.plan(pr.planShape(candidates, ranges))
+	return index, ok && slices.Contains(candidates, index)
+}
+
+// recordLookup wraps an index lookup so that the number of ids it produces is
+// recorded once the lookup has been fully consumed.
+func (pr *Persistent) recordLookup(idxName string, kr *keyRange, ids iter.Seq2[[8]byte, error]) iter.Seq2[[8]byte, error] {
+	if pr.ephemeral {
+		return ids
+	}
+	key := pr.statsKey(idxName, kr)
+	return func(yield func([8]byte, error) bool) {
+		var rows uint64
+		for id, err := range ids {
+			if err == nil {
+				rows++
+			}
+			if !yield(id, err) {
+				return
+			}
+		}
+		pr.tx.db.stats.record(key, rows)
+	}
+}
+
+func (d *DB) loadStats() error {
+	return d.backendView(func(tx BackendTx) error {
+		bucket := tx.Bucket([]byte(statsBucket))
+		if bucket == nil {
+			return nil
+		}
+		if raw := bucket.Get([]byte("planner")); raw != nil {
+			indexes := make(map[string]indexStats)
+			if err := d.maUn.Unmarshal(raw, &indexes); err != nil {
+				return ErrCorruptedMetaDataEntry(statsBucket, "planner")
+			}
+			d.stats.indexes = indexes
+		}
+		if raw := bucket.Get([]byte("plans")); raw != nil {
+			plans := make(map[string]string)
+			if err := d.maUn.Unmarshal(raw, &plans); err != nil {
+				return ErrCorruptedMetaDataEntry(statsBucket, "plans")
+			}
+			d.stats.plans = plans
+		}
+		return nil
+	})
+}
+
+func (d *DB) saveStats() error {
+	if d.backend.ReadOnly() {
+		return nil
+	}
+	indexes, plans, version, ok := d.stats.snapshot()
+	if !ok {
+		return nil
+	}
+	rawIndexes, err := d.maUn.Marshal(indexes)
+	if err != nil {
+		return err
+	}
+	rawPlans, err := d.maUn.Marshal(plans)
+	if err != nil {
+		return err
+	}
+	err = d.backendUpdate(func(tx BackendTx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(statsBucket))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("planner"), rawIndexes); err != nil {
+			return err
+		}
+		return bucket.Put([]byte("plans"), rawPlans)
+	})
+	if err != nil {
+		return err
+	}
+	d.stats.markSaved(version)
+	return nil
+}
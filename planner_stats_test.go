@@ -0,0 +1,209 @@
+package thunder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openkvlab/boltdb"
+)
+
+func TestPlannerStats_PersistAcrossRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "stats.db")
+	db, err := OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dbPath)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tickets, err := tx.CreatePersistent("tickets", map[string]ColumnSpec{
+		"status": {Indexed: true},
+		"user":   {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 200 {
+		if err := tickets.Insert(map[string]any{
+			"status": "open",
+			"user":   fmt.Sprintf("u%d", i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	consume := func(p *Persistent, ops ...Op) {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := p.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := range minObservedLookups {
+		consume(tickets, Eq("status", "open"))
+		consume(tickets, Eq("user", fmt.Sprintf("u%d", i)))
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	combined, err := ToKeyRanges(Eq("status", "open"), Eq("user", "u3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickets, err = tx.LoadPersistent("tickets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := tickets.Explain(combined); err != nil || plan.Index != "user" {
+		t.Errorf("expected the selective user index, got %+v (%v)", plan, err)
+	}
+	tx.Rollback()
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	tickets, err = tx.LoadPersistent("tickets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := tickets.Explain(combined); err != nil || plan.Index != "user" {
+		t.Errorf("expected persisted stats to pick the user index after restart, got %+v (%v)", plan, err)
+	}
+}
+
+func TestPlannerStats_CloseWithoutLookupsDoesNotWrite(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "stats.db")
+	txID := func() int {
+		t.Helper()
+		bdb, err := boltdb.Open(dbPath, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer bdb.Close()
+		tx, err := bdb.Begin(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		return tx.ID()
+	}
+	reopen := func() {
+		t.Helper()
+		db, err := OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reopen()
+	before := txID()
+	reopen()
+	if after := txID(); after != before {
+		t.Errorf("expected Close not to commit unchanged stats, transaction id went from %d to %d", before, after)
+	}
+}
+
+func TestPlannerStats_RememberPlansAcrossRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "stats.db")
+	db, err := OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined, err := ToKeyRanges(Eq("status", "open"), Eq("user", "u3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		tickets, err := tx.CreatePersistent("tickets", map[string]ColumnSpec{
+			"status": {Indexed: true},
+			"user":   {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 200 {
+			status := []string{"open", "closed"}[i%2]
+			if err := tickets.Insert(map[string]any{"status": status, "user": fmt.Sprintf("u%d", i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		tickets, err := tx.LoadPersistent("tickets")
+		if err != nil {
+			return err
+		}
+		if err := tickets.Analyze(); err != nil {
+			return err
+		}
+		if plan, err := tickets.Explain(combined); err != nil || plan.Reason != PlanAnalyzed || plan.Index != "user" {
+			t.Errorf("expected Analyze to pick the user index, got %+v (%v)", plan, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recoding drops the relation's Analyze statistics.
+	db.SetCodec("json", &JsonMaUn)
+	if err := db.Recode("tickets", &JsonMaUn, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = OpenDB(&MsgpackMaUn, dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetCodec("json", &JsonMaUn)
+	err = db.view(func(tx *Tx) error {
+		tickets, err := tx.LoadPersistent("tickets")
+		if err != nil {
+			return err
+		}
+		if plan, err := tickets.Explain(combined); err != nil || plan.Reason != PlanRemembered || plan.Index != "user" {
+			t.Errorf("expected the remembered plan after restart, got %+v (%v)", plan, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
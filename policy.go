@@ -0,0 +1,91 @@
+package thunder
+
+import (
+	"context"
+	"maps"
+)
+
+// Policy restricts the rows of a relation visible to a transaction. It is
+// called with the context the transaction was begun with, see
+// DB.BeginContext, and returns the ops every row read, updated or deleted
+// must match, typically an Eq on a tenant column taken from a context value.
+// Returning no ops leaves the relation unrestricted for that context.
+type Policy func(ctx context.Context) ([]Op, error)
+
+// SetPolicy sets the row-level policy of relation, replacing any earlier
+// one. A nil policy removes it. The policy's ops are ANDed into the ranges
+// of every Select, Update, Delete and Count on the relation, and rows an
+// Update changes must still match them; inserts are not checked. A
+// transaction not begun with BeginContext fails with ErrPolicyContext on a
+// relation with a policy.
+func (d *DB) SetPolicy(relation string, p Policy) {
+	d.policyMu.Lock()
+	defer d.policyMu.Unlock()
+	if p == nil {
+		delete(d.policies, relation)
+		return
+	}
+	if d.policies == nil {
+		d.policies = make(map[string]Policy)
+	}
+	d.policies[relation] = p
+}
+
+func (d *DB) policy(relation string) Policy {
+	d.policyMu.RLock()
+	defer d.policyMu.RUnlock()
+	return d.policies[relation]
+}
+
+// restrict returns ranges ANDed with the ops of the relation's policy for
+// the transaction's context.
+func (pr *Persistent) restrict(ranges map[string]*keyRange) (map[string]*keyRange, error) {
+	return pr.restrictFor(pr.tx.ctx, ranges)
+}
+
+// restrictFor is restrict for the policy's ops under ctx. Without a ctx to
+// tell who is asking, a relation with a policy is not readable at all.
+func (pr *Persistent) restrictFor(ctx context.Context, ranges map[string]*keyRange) (map[string]*keyRange, error) {
+	if pr.tx.db == nil {
+		return ranges, nil
+	}
+	p := pr.tx.db.policy(pr.access)
+	if p == nil {
+		return ranges, nil
+	}
+	if ctx == nil {
+		return nil, ErrPolicyContext(pr.access)
+	}
+	ops, err := p(ctx)
+	if err != nil || len(ops) == 0 {
+		return ranges, err
+	}
+	policyRanges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	restricted := maps.Clone(ranges)
+	if restricted == nil {
+		restricted = make(map[string]*keyRange, len(policyRanges))
+	}
+	for name, kr := range policyRanges {
+		if _, ok := pr.fields[name]; !ok {
+			return nil, ErrFieldNotFound(name)
+		}
+		if existing, ok := restricted[name]; ok {
+			kr = existing.intersect(kr)
+		}
+		restricted[name] = kr
+	}
+	return restricted, nil
+}
+
+// policyMatcher returns a matcher for the rows the relation's policy lets
+// the transaction see, or nil when it sees them all.
+func (pr *Persistent) policyMatcher() (func(map[string]any) (bool, error), error) {
+	ranges, err := pr.restrict(nil)
+	if err != nil {
+		return nil, err
+	}
+	return pr.matcher(pr.coerceRanges(ranges), ""), nil
+}
@@ -0,0 +1,309 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type tenantKey struct{}
+
+func TestDB_SetPolicy(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+			"id":     {Unique: true},
+			"tenant": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, tenant := range []string{"acme", "acme", "globex"} {
+			if err := docs.Insert(map[string]any{"id": i, "tenant": tenant}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errNoTenant := errors.New("no tenant")
+	db.SetPolicy("docs", func(ctx context.Context) ([]Op, error) {
+		if ctx.Value(tenantKey{}) == "admin" {
+			return nil, nil
+		}
+		tenant, ok := ctx.Value(tenantKey{}).(string)
+		if !ok {
+			return nil, errNoTenant
+		}
+		return []Op{Eq("tenant", tenant)}, nil
+	})
+	as := func(tenant string, writable bool, fn func(docs *Persistent) error) error {
+		t.Helper()
+		ctx := context.Background()
+		if tenant != "" {
+			ctx = context.WithValue(ctx, tenantKey{}, tenant)
+		}
+		tx, err := db.BeginContext(ctx, writable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		docs, err := tx.LoadPersistent("docs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fn(docs); err != nil {
+			return err
+		}
+		if writable {
+			return tx.Commit()
+		}
+		return nil
+	}
+	count := func(docs *Persistent, ops ...Op) (int, error) {
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			return 0, err
+		}
+		seq, err := docs.Select(ranges)
+		if err != nil {
+			return 0, err
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				return 0, err
+			}
+			n++
+		}
+		return n, nil
+	}
+
+	err = as("acme", false, func(docs *Persistent) error {
+		if n, err := count(docs); err != nil || n != 2 {
+			t.Errorf("expected acme to see 2 rows, got %d, %v", n, err)
+		}
+		if n, err := count(docs, Eq("tenant", "globex")); err != nil || n != 0 {
+			t.Errorf("expected acme not to see globex rows, got %d, %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := as("", false, func(docs *Persistent) error {
+		_, err := count(docs)
+		return err
+	}); !errors.Is(err, errNoTenant) {
+		t.Fatalf("expected the policy error without a tenant, got %v", err)
+	}
+
+	err = as("globex", true, func(docs *Persistent) error {
+		all, err := ToKeyRanges()
+		if err != nil {
+			return err
+		}
+		n, err := docs.Update(all, map[string]any{"id": 9})
+		if err != nil || n != 1 {
+			t.Errorf("expected globex to update its one row, got %d, %v", n, err)
+		}
+		return docs.Delete(all)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = as("admin", false, func(docs *Persistent) error {
+		if n, err := count(docs); err != nil || n != 2 {
+			t.Errorf("expected the acme rows to survive globex's delete, got %d, %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncate only removes the rows the policy lets the tenant see.
+	err = as("globex", true, func(docs *Persistent) error {
+		if err := docs.Insert(map[string]any{"id": 10, "tenant": "globex"}); err != nil {
+			return err
+		}
+		return docs.Truncate()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = as("admin", false, func(docs *Persistent) error {
+		if n, err := count(docs); err != nil || n != 2 {
+			t.Errorf("expected the acme rows to survive globex's truncate, got %d, %v", n, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_SubscribePolicy(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.update(func(tx *Tx) error {
+		docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+			"id":     {Unique: true},
+			"tenant": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		if err := docs.Insert(map[string]any{"id": 1, "tenant": "globex"}); err != nil {
+			return err
+		}
+		return docs.Insert(map[string]any{"id": 2, "tenant": "acme"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetPolicy("docs", func(ctx context.Context) ([]Op, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return []Op{Eq("tenant", tenant)}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), tenantKey{}, "acme"))
+	defer cancel()
+	tx, err := db.BeginContext(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := tx.LoadPersistent("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched := docs.Watch(ctx)
+	tx.Rollback()
+
+	subscribed := make(chan ChangeEvent)
+	watchedEvents := make(chan ChangeEvent)
+	follow := func(seq func(func(ChangeEvent, error) bool), out chan<- ChangeEvent) {
+		for ev, err := range seq {
+			if err != nil {
+				close(out)
+				return
+			}
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	next := func(events <-chan ChangeEvent) ChangeEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return ChangeEvent{}
+		}
+	}
+	go follow(db.Subscribe(ctx, "docs"), subscribed)
+	if ev := next(subscribed); ev.Op != ChangeInsert || ev.After["tenant"] != "acme" {
+		t.Fatalf("expected the initial acme row, got %v %v", ev.Op, ev.After)
+	}
+	ready := make(chan struct{})
+	go func() {
+		close(ready)
+		follow(watched, watchedEvents)
+	}()
+	<-ready
+	time.Sleep(10 * time.Millisecond)
+
+	err = db.update(func(tx *Tx) error {
+		docs, err := tx.LoadPersistent("docs")
+		if err != nil {
+			return err
+		}
+		if err := docs.Insert(map[string]any{"id": 3, "tenant": "globex"}); err != nil {
+			return err
+		}
+		return docs.Insert(map[string]any{"id": 4, "tenant": "acme"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, events := range map[string]chan ChangeEvent{"Subscribe": subscribed, "Watch": watchedEvents} {
+		if ev := next(events); ev.Op != ChangeInsert || ev.After["tenant"] != "acme" {
+			t.Errorf("%s: expected only the acme insert, got %v %v", name, ev.Op, ev.After)
+		}
+	}
+}
+
+func TestDB_PolicyChecks(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		docs, err := tx.CreatePersistent("docs", map[string]ColumnSpec{
+			"id":     {Unique: true},
+			"tenant": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i, tenant := range []string{"acme", "acme", "globex"} {
+			if err := docs.Insert(map[string]any{"id": i, "tenant": tenant}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetPolicy("docs", func(ctx context.Context) ([]Op, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return []Op{Eq("tenant", tenant)}, nil
+	})
+
+	// Without a context the relation is closed.
+	var te *ThunderError
+	err = db.view(func(tx *Tx) error {
+		docs, err := tx.LoadPersistent("docs")
+		if err != nil {
+			return err
+		}
+		_, err = docs.Select(nil)
+		return err
+	})
+	if !errors.As(err, &te) || te.Code != ErrCodePolicyContext {
+		t.Fatalf("expected ErrPolicyContext without a context, got %v", err)
+	}
+
+	tx, err := db.BeginContext(context.WithValue(context.Background(), tenantKey{}, "acme"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	docs, err := tx.LoadPersistent("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := docs.Count(); err != nil || n != 2 {
+		t.Errorf("expected acme to count 2 rows, got %d, %v", n, err)
+	}
+	if stats, err := docs.Stats(); err != nil || stats.Rows != 2 {
+		t.Errorf("expected acme stats over 2 rows, got %+v, %v", stats, err)
+	}
+	all, err := ToKeyRanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.Update(all, map[string]any{"tenant": "globex"}); !errors.As(err, &te) || te.Code != ErrCodePolicyViolation {
+		t.Errorf("expected moving rows to another tenant rejected, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Package promsink exports thunder metrics to Prometheus.
+package promsink
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/longlodw/thunder"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a thunder.MetricsSink that is also a prometheus.Collector.
+// Register it with a registry and pass it to DB.SetMetrics.
+type Collector struct {
+	rowsWritten *prometheus.CounterVec
+	queries     *prometheus.CounterVec
+	rowsDecoded *prometheus.CounterVec
+	txDuration  *prometheus.HistogramVec
+	violations  *prometheus.CounterVec
+}
+
+var (
+	_ thunder.MetricsSink  = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// New returns a Collector whose metric names start with namespace, which may
+// be empty.
+func New(namespace string) *Collector {
+	return &Collector{
+		rowsWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "thunder_rows_written_total",
+			Help:      "Rows inserted, updated or deleted, and truncations, by relation and operation.",
+		}, []string{"relation", "op"}),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "thunder_queries_total",
+			Help:      "Reads of a relation by the index driving them; the index is empty for scans.",
+		}, []string{"relation", "index"}),
+		rowsDecoded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "thunder_rows_decoded_total",
+			Help:      "Stored rows decoded by reads, including those filtered out afterwards.",
+		}, []string{"relation"}),
+		txDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "thunder_transaction_duration_seconds",
+			Help:      "How long transactions stayed open.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"mode", "outcome"}),
+		violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "thunder_constraint_violations_total",
+			Help:      "Writes rejected by a constraint, by relation and ThunderError code.",
+		}, []string{"relation", "code"}),
+	}
+}
+
+func (c *Collector) RowWritten(relation string, op thunder.ChangeOp) {
+	c.rowsWritten.WithLabelValues(relation, op.String()).Inc()
+}
+
+func (c *Collector) QueryPlanned(plan thunder.QueryPlan) {
+	c.queries.WithLabelValues(plan.Relation, plan.Index).Inc()
+}
+
+func (c *Collector) RowsDecoded(relation string, n int) {
+	c.rowsDecoded.WithLabelValues(relation).Add(float64(n))
+}
+
+func (c *Collector) TxDone(writable, committed bool, elapsed time.Duration) {
+	mode, outcome := "read", "rollback"
+	if writable {
+		mode = "write"
+	}
+	if committed {
+		outcome = "commit"
+	}
+	c.txDuration.WithLabelValues(mode, outcome).Observe(elapsed.Seconds())
+}
+
+func (c *Collector) ConstraintViolation(relation string, code int) {
+	c.violations.WithLabelValues(relation, strconv.Itoa(code)).Inc()
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.rowsWritten.Describe(ch)
+	c.queries.Describe(ch)
+	c.rowsDecoded.Describe(ch)
+	c.txDuration.Describe(ch)
+	c.violations.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.rowsWritten.Collect(ch)
+	c.queries.Collect(ch)
+	c.rowsDecoded.Collect(ch)
+	c.txDuration.Collect(ch)
+	c.violations.Collect(ch)
+}
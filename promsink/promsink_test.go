@@ -0,0 +1,80 @@
+package promsink
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/longlodw/thunder"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	db, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	c := New("")
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	db.SetMetrics(c)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{
+		"email": {Unique: true},
+		"name":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ada", "bob"} {
+		if err := p.Insert(map[string]any{"email": name + "@example.com", "name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := thunder.ToKeyRanges(thunder.Eq("name", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := p.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var te *thunder.ThunderError
+	if err := p.Insert(map[string]any{"email": "ada@example.com", "name": "eve"}); !errors.As(err, &te) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := testutil.ToFloat64(c.rowsWritten.WithLabelValues("users", "insert")); n != 2 {
+		t.Errorf("expected 2 inserts, got %v", n)
+	}
+	if n := testutil.ToFloat64(c.violations.WithLabelValues("users", strconv.Itoa(te.Code))); n != 1 {
+		t.Errorf("expected 1 violation, got %v", n)
+	}
+	if n := testutil.ToFloat64(c.queries.WithLabelValues("users", "")); n != 1 {
+		t.Errorf("expected 1 scan, got %v", n)
+	}
+	// The scan decoded every row but yielded one.
+	if n := testutil.ToFloat64(c.rowsDecoded.WithLabelValues("users")); n != 2 {
+		t.Errorf("expected 2 decoded rows, got %v", n)
+	}
+	if n := testutil.CollectAndCount(c, "thunder_transaction_duration_seconds"); n != 1 {
+		t.Errorf("expected one transaction series, got %d", n)
+	}
+}
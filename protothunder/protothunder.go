@@ -0,0 +1,329 @@
+// Package protothunder stores protobuf messages in thunder, either whole as
+// the values of a column with a codec, see thunder.ColumnSpec.Codec, or
+// spread over a relation with one column per message field.
+package protothunder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/longlodw/thunder"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtoMaUn is a thunder.MarshalUnmarshaler for messages of one type. It
+// encodes with the protobuf wire format and decodes into a new message when
+// unmarshaling into a *any, as column codecs do.
+type ProtoMaUn struct {
+	prototype proto.Message
+}
+
+// New returns a ProtoMaUn decoding messages of the type of prototype.
+func New(prototype proto.Message) *ProtoMaUn {
+	return &ProtoMaUn{prototype: prototype}
+}
+
+func (p *ProtoMaUn) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protothunder: cannot marshal %T", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (p *ProtoMaUn) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case proto.Message:
+		return proto.Unmarshal(data, v)
+	case *any:
+		msg := p.prototype.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return err
+		}
+		*v = msg
+		return nil
+	}
+	return fmt.Errorf("protothunder: cannot unmarshal into %T", v)
+}
+
+// Columns returns the column specs of a relation holding messages of the
+// type of msg, one column per field named as in the .proto file. Scalar
+// fields get the matching column type; fields that may be unset, repeated
+// fields, maps and messages are TypeAny.
+func Columns(msg proto.Message) map[string]thunder.ColumnSpec {
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	columns := make(map[string]thunder.ColumnSpec, fields.Len())
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		var t thunder.ColumnType
+		if !fd.HasPresence() && !fd.IsList() && !fd.IsMap() {
+			t = columnType(fd.Kind())
+		}
+		columns[string(fd.Name())] = thunder.ColumnSpec{Type: t}
+	}
+	return columns
+}
+
+func columnType(kind protoreflect.Kind) thunder.ColumnType {
+	switch kind {
+	case protoreflect.BoolKind:
+		return thunder.TypeBool
+	case protoreflect.StringKind:
+		return thunder.TypeString
+	case protoreflect.BytesKind:
+		return thunder.TypeBytes
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return thunder.TypeFloat
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return thunder.TypeAny
+	}
+	return thunder.TypeInt
+}
+
+// ToRow returns the fields of msg as a row of the relation described by
+// Columns. Nested messages become maps, repeated fields slices, map fields
+// maps keyed by the text of their keys and enums their numbers. Unset
+// fields are nil.
+func ToRow(msg proto.Message) map[string]any {
+	return messageRow(msg.ProtoReflect())
+}
+
+func messageRow(m protoreflect.Message) map[string]any {
+	fields := m.Descriptor().Fields()
+	row := make(map[string]any, fields.Len())
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+		if fd.HasPresence() && !m.Has(fd) {
+			row[name] = nil
+			continue
+		}
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			list := v.List()
+			values := make([]any, list.Len())
+			for j := range list.Len() {
+				values[j] = scalarValue(fd, list.Get(j))
+			}
+			row[name] = values
+		case fd.IsMap():
+			entries := make(map[string]any, v.Map().Len())
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				entries[k.String()] = scalarValue(fd.MapValue(), v)
+				return true
+			})
+			row[name] = entries
+		default:
+			row[name] = scalarValue(fd, v)
+		}
+	}
+	return row
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return int64(v.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageRow(v.Message())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return v.Int()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint()
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// FromRow sets the fields of msg from a row written by ToRow. Columns the
+// message has no field for are ignored, as are nil values.
+func FromRow(row map[string]any, msg proto.Message) error {
+	return setMessage(msg.ProtoReflect(), row)
+}
+
+func setMessage(m protoreflect.Message, row map[string]any) error {
+	fields := m.Descriptor().Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		v, ok := row[string(fd.Name())]
+		if !ok || v == nil {
+			continue
+		}
+		if err := setField(m, fd, v); err != nil {
+			return fmt.Errorf("protothunder: field %s: %w", fd.Name(), err)
+		}
+	}
+	return nil
+}
+
+func setField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v any) error {
+	switch {
+	case fd.IsList():
+		values, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected a list, got %T", v)
+		}
+		list := m.Mutable(fd).List()
+		for _, x := range values {
+			pv, err := protoValue(fd, x, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(pv)
+		}
+		return nil
+	case fd.IsMap():
+		entries, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected a map, got %T", v)
+		}
+		mp := m.Mutable(fd).Map()
+		for k, x := range entries {
+			key, err := mapKey(fd.MapKey(), k)
+			if err != nil {
+				return err
+			}
+			pv, err := protoValue(fd.MapValue(), x, mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(key, pv)
+		}
+		return nil
+	}
+	pv, err := protoValue(fd, v, func() protoreflect.Value { return m.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	m.Set(fd, pv)
+	return nil
+}
+
+// protoValue converts a row value to the value of a field of fd's kind,
+// calling newMessage for an empty message to fill.
+func protoValue(fd protoreflect.FieldDescriptor, v any, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := v.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.BytesKind:
+		switch b := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+	case protoreflect.EnumKind:
+		if n, ok := toInt(v); ok {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if n, ok := toInt(v); ok {
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := toInt(v); ok {
+			return protoreflect.ValueOfInt64(n), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if n, ok := toInt(v); ok {
+			return protoreflect.ValueOfUint32(uint32(n)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := v.(uint64); ok {
+			return protoreflect.ValueOfUint64(n), nil
+		}
+		if n, ok := toInt(v); ok {
+			return protoreflect.ValueOfUint64(uint64(n)), nil
+		}
+	case protoreflect.FloatKind:
+		if f, ok := toFloat(v); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := toFloat(v); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if row, ok := v.(map[string]any); ok {
+			pv := newMessage()
+			return pv, setMessage(pv.Message(), row)
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot use %T as %v", v, fd.Kind())
+}
+
+func mapKey(fd protoreflect.FieldDescriptor, k string) (protoreflect.MapKey, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(k).MapKey(), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(k)
+		return protoreflect.ValueOfBool(b).MapKey(), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(k, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)).MapKey(), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(k, 10, 64)
+		return protoreflect.ValueOfUint64(n).MapKey(), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(k, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)).MapKey(), err
+	}
+	n, err := strconv.ParseInt(k, 10, 64)
+	return protoreflect.ValueOfInt64(n).MapKey(), err
+}
+
+// toInt converts the integer types codecs decode numbers as, and integral
+// floats, to an int64.
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch f := v.(type) {
+	case float64:
+		return f, true
+	case float32:
+		return float64(f), true
+	}
+	if n, ok := toInt(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
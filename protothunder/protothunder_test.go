@@ -0,0 +1,112 @@
+package protothunder
+
+import (
+	"testing"
+
+	"github.com/longlodw/thunder"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+func testAPI() *apipb.Api {
+	return &apipb.Api{
+		Name:    "thunder.Query",
+		Version: "v1",
+		Methods: []*apipb.Method{
+			{Name: "Select", RequestTypeUrl: "type.googleapis.com/Select", ResponseStreaming: true},
+			{Name: "Insert", Syntax: typepb.Syntax_SYNTAX_PROTO3},
+		},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "thunder.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_EDITIONS,
+	}
+}
+
+func update(db *thunder.DB, fn func(tx *thunder.Tx) error) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func TestProtoMaUn(t *testing.T) {
+	db, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetCodec("api", New(&apipb.Api{}))
+	want := testAPI()
+	var got any
+	err = update(db, func(tx *thunder.Tx) error {
+		apis, err := tx.CreatePersistent("apis", map[string]thunder.ColumnSpec{
+			"name": {Unique: true},
+			"api":  {Codec: "api"},
+		})
+		if err != nil {
+			return err
+		}
+		if err := apis.Insert(map[string]any{"name": want.Name, "api": want}); err != nil {
+			return err
+		}
+		seq, err := apis.Select(nil)
+		if err != nil {
+			return err
+		}
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			got = row["api"]
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, ok := got.(*apipb.Api); !ok || !proto.Equal(msg, want) {
+		t.Fatalf("expected %v back, got %v", want, got)
+	}
+}
+
+func TestRowMapping(t *testing.T) {
+	db, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	want := testAPI()
+	err = update(db, func(tx *thunder.Tx) error {
+		apis, err := tx.CreatePersistent("apis", Columns(&apipb.Api{}))
+		if err != nil {
+			return err
+		}
+		if err := apis.Insert(ToRow(want)); err != nil {
+			return err
+		}
+		return apis.Insert(ToRow(&apipb.Api{Name: "empty"}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT * FROM apis WHERE name = 'thunder.Query'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+	got := &apipb.Api{}
+	if err := FromRow(rows[0], got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
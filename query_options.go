@@ -0,0 +1,245 @@
+package thunder
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"time"
+)
+
+// QueryOption adjusts how a single query is executed.
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	useIndex string
+	noIndex  bool
+	ignore   []string
+	asOf     time.Time
+	columns  []string
+	desc     bool
+	stale    bool
+	// Limits, see Timeout, MaxScanned and MaxRows.
+	timeout    time.Duration
+	maxScanned int
+	maxRows    int
+}
+
+func newQueryOptions(opts []QueryOption) queryOptions {
+	var o queryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UseIndex forces the query to be driven by the named index, overriding the
+// planner. Without a range on that index the whole index is walked.
+func UseIndex(name string) QueryOption {
+	return func(o *queryOptions) {
+		o.useIndex = name
+		o.noIndex = false
+	}
+}
+
+// NoIndex forces the query to scan the relation's data instead of using an
+// index.
+func NoIndex() QueryOption {
+	return func(o *queryOptions) {
+		o.noIndex = true
+		o.useIndex = ""
+	}
+}
+
+// IgnoreIndex keeps the planner from choosing any of the named indexes,
+// leaving it to pick among the others. UseIndex and NoIndex take precedence.
+func IgnoreIndex(names ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.ignore = append(o.ignore, names...)
+	}
+}
+
+// SelectColumns makes the query return only the named columns, failing with
+// ErrFieldNotFound on a column the relation does not have. Codecs that
+// implement ColumnUnmarshaler, such as msgpack, then skip decoding the other
+// columns of each row.
+func SelectColumns(columns ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.columns = columns
+	}
+}
+
+// Descending returns rows in reverse order: from the end of the plan's
+// index range backwards, or newest first when the relation is scanned.
+// Combined with UseIndex on a timestamp it reads the latest rows without
+// walking the older ones.
+func Descending() QueryOption {
+	return func(o *queryOptions) {
+		o.desc = true
+	}
+}
+
+// StaleIndex makes a query driven by an asynchronous index, see
+// ColumnSpec.AsyncIndex, return only the rows already in the index instead
+// of also checking the rows queued for indexing.
+func StaleIndex() QueryOption {
+	return func(o *queryOptions) {
+		o.stale = true
+	}
+}
+
+// Reasons reported in QueryPlan.Reason.
+const (
+	PlanNoIndex        = "no index matches the ranges"
+	PlanForcedScan     = "index use disabled by NoIndex"
+	PlanHinted         = "index chosen by UseIndex"
+	PlanIgnored        = "no index matches the ranges but those ignored by IgnoreIndex"
+	PlanObserved       = "index with the best observed selectivity"
+	PlanAnalyzed       = "index with the fewest rows estimated by Analyze"
+	PlanRemembered     = "index statistics last chose for queries of this shape"
+	PlanNarrowestRange = "index with the narrowest range"
+)
+
+// QueryPlan describes how a query over a relation is executed. An empty Index
+// means the relation's data is scanned. Descending walks it backwards. Stale
+// leaves out the rows not yet in an asynchronous index.
+type QueryPlan struct {
+	Relation   string
+	Index      string
+	Reason     string
+	Descending bool
+	Stale      bool
+}
+
+func (p QueryPlan) String() string {
+	var s string
+	if p.Index == "" {
+		s = fmt.Sprintf("scan %s (%s)", p.Relation, p.Reason)
+	} else {
+		s = fmt.Sprintf("index %s on %s (%s)", p.Index, p.Relation, p.Reason)
+	}
+	if p.Stale {
+		s = "stale " + s
+	}
+	if p.Descending {
+		return "reverse " + s
+	}
+	return s
+}
+
+// Explain returns the plan Select would use for ranges and opts, without
+// running the query.
+func (pr *Persistent) Explain(ranges map[string]*keyRange, opts ...QueryOption) (QueryPlan, error) {
+	return pr.plan(ranges, newQueryOptions(opts))
+}
+
+// QueryAnalysis is the plan of a query together with what running it cost,
+// as reported by ExplainAnalyze. IndexEntries counts the entries read from
+// the plan's index, RowsFetched the rows read from the relation's data and
+// RowsFiltered those among them rejected by the ranges the index did not
+// answer. FilterTime is the part of ExecTime spent matching rows.
+type QueryAnalysis struct {
+	QueryPlan
+	IndexEntries int
+	RowsFetched  int
+	RowsFiltered int
+	RowsReturned int
+	PlanTime     time.Duration
+	ExecTime     time.Duration
+	FilterTime   time.Duration
+}
+
+func (a QueryAnalysis) String() string {
+	return fmt.Sprintf("%s: %d index entries, %d rows fetched, %d filtered, %d returned; plan %v, exec %v (filter %v)",
+		a.QueryPlan, a.IndexEntries, a.RowsFetched, a.RowsFiltered, a.RowsReturned, a.PlanTime, a.ExecTime, a.FilterTime)
+}
+
+// ExplainAnalyze runs the query SelectWith would run for ranges and opts,
+// discarding its rows, and reports the plan with the counters and timings
+// gathered while running it. A query that fails still reports what it did
+// until then along with the error.
+func (pr *Persistent) ExplainAnalyze(ranges map[string]*keyRange, opts ...QueryOption) (QueryAnalysis, error) {
+	var analysis QueryAnalysis
+	start := time.Now()
+	seq, err := pr.selectWith(ranges, newQueryOptions(opts), &analysis)
+	if err != nil {
+		return analysis, err
+	}
+	analysis.PlanTime = time.Since(start)
+	start = time.Now()
+	for _, err := range seq {
+		if err != nil {
+			analysis.ExecTime = time.Since(start)
+			return analysis, err
+		}
+	}
+	analysis.ExecTime = time.Since(start)
+	return analysis, nil
+}
+
+// SelectWith is Select with per-query options such as index hints.
+func (pr *Persistent) SelectWith(ranges map[string]*keyRange, opts ...QueryOption) (iter.Seq2[map[string]any, error], error) {
+	return pr.selectWith(ranges, newQueryOptions(opts), nil)
+}
+
+// selectWith runs the query of SelectWith, counting into stats when it is
+// not nil. stats.QueryPlan is set before the query starts.
+func (pr *Persistent) selectWith(ranges map[string]*keyRange, o queryOptions, stats *QueryAnalysis) (iter.Seq2[map[string]any, error], error) {
+	for _, col := range o.columns {
+		if !slices.Contains(pr.columns, col) {
+			return nil, ErrFieldNotFound(col)
+		}
+	}
+	ranges, err := pr.restrict(ranges)
+	if err != nil {
+		return nil, err
+	}
+	ranges = pr.coerceRanges(ranges)
+	plan, err := pr.plan(ranges, o)
+	if err != nil {
+		return nil, err
+	}
+	if stats != nil {
+		stats.QueryPlan = plan
+	}
+	budget := pr.budget(o, stats)
+	var seq iter.Seq2[map[string]any, error]
+	switch {
+	case !o.asOf.IsZero():
+		if seq, err = pr.selectAsOf(ranges, plan, o.asOf); err != nil {
+			return nil, err
+		}
+		if o.columns != nil {
+			seq = projectColumns(seq, o.columns)
+		}
+	default:
+		if seq, err = pr.selectPlan(ranges, plan, o.columns, budget); err != nil {
+			return nil, err
+		}
+	}
+	return pr.observeSelect(ranges, plan, budget.limit(seq)), nil
+}
+
+// SelectColumns returns the named columns of the rows matching every op.
+func (pr *Persistent) SelectColumns(columns []string, ops ...Op) (iter.Seq2[map[string]any, error], error) {
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	return pr.SelectWith(ranges, SelectColumns(columns...))
+}
+
+// projectColumns drops every column but columns from the rows of seq.
+func projectColumns(seq iter.Seq2[map[string]any, error], columns []string) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		for row, err := range seq {
+			for col := range row {
+				if !slices.Contains(columns, col) {
+					delete(row, col)
+				}
+			}
+			if !yield(row, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package thunder
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPersistent_IndexHints(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	orders, err := tx.CreatePersistent("orders", map[string]ColumnSpec{
+		"customer":   {Indexed: true},
+		"created_at": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 20 {
+		if err := orders.Insert(map[string]any{"customer": int64(i % 4), "created_at": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := ToKeyRanges(Eq("customer", int64(1)), Ge("created_at", int64(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(opts ...QueryOption) int {
+		t.Helper()
+		seq, err := orders.SelectWith(ranges, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		return n
+	}
+
+	plan, err := orders.Explain(ranges)
+	if err != nil || plan.Index != "customer" || plan.Reason != PlanNarrowestRange {
+		t.Fatalf("expected the planner to pick customer, got %v (%v)", plan, err)
+	}
+	plan, err = orders.Explain(ranges, UseIndex("created_at"))
+	if err != nil || plan.Index != "created_at" || plan.Reason != PlanHinted {
+		t.Fatalf("expected the hinted index, got %v (%v)", plan, err)
+	}
+	plan, err = orders.Explain(ranges, NoIndex())
+	if err != nil || plan.Index != "" || plan.Reason != PlanForcedScan {
+		t.Fatalf("expected a forced scan, got %v (%v)", plan, err)
+	}
+	for _, opts := range [][]QueryOption{nil, {UseIndex("created_at")}, {NoIndex()}} {
+		if n := count(opts...); n != 2 {
+			t.Fatalf("expected 2 rows with %d options, got %d", len(opts), n)
+		}
+	}
+	plan, err = orders.Explain(ranges, IgnoreIndex("customer"))
+	if err != nil || plan.Index != "created_at" {
+		t.Fatalf("expected created_at with customer ignored, got %v (%v)", plan, err)
+	}
+	plan, err = orders.Explain(ranges, IgnoreIndex("customer", "created_at"))
+	if err != nil || plan.Index != "" || plan.Reason != PlanIgnored {
+		t.Fatalf("expected a scan with both indexes ignored, got %v (%v)", plan, err)
+	}
+	if n := count(IgnoreIndex("customer")); n != 2 {
+		t.Fatalf("expected 2 rows ignoring customer, got %d", n)
+	}
+	if _, err := orders.Explain(ranges, UseIndex("missing")); err == nil {
+		t.Fatal("expected an error hinting a missing index")
+	}
+	if _, err := orders.Explain(ranges, IgnoreIndex("missing")); err == nil {
+		t.Fatal("expected an error ignoring a missing index")
+	}
+	// A hinted index without a range on it is walked in full.
+	only, err := ToKeyRanges(Eq("customer", int64(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := orders.SelectWith(only, UseIndex("created_at"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last int64 = -1
+	n := 0
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		created := row["created_at"].(int64)
+		if created < last {
+			t.Fatalf("expected rows in created_at order, got %d after %d", created, last)
+		}
+		last = created
+		n++
+	}
+	if n != 5 {
+		t.Fatalf("expected 5 rows for customer 2, got %d", n)
+	}
+}
+
+func TestPersistent_SelectColumns(t *testing.T) {
+	for name, maUn := range map[string]MarshalUnmarshaler{
+		"msgpack": &MsgpackMaUn,
+		"json":    &JsonMaUn,
+		"cbor":    &CborMaUn,
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, err := OpenMemory(maUn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tx, err := db.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			people, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+				"name": {Indexed: true},
+				"age":  {},
+				"bio":  {},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, n := range []string{"ada", "bob", "cy"} {
+				if err := people.Insert(map[string]any{"name": n, "age": int64(20 + i), "bio": fmt.Sprintf("bio %d", i)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// The range on bio is checked even though bio is not returned.
+			ranges, err := ToKeyRanges(Ge("bio", "bio 1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			seq, err := people.SelectWith(ranges, SelectColumns("name"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for row, err := range seq {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(row) != 1 {
+					t.Fatalf("expected only name, got %v", row)
+				}
+				names = append(names, row["name"].(string))
+			}
+			if len(names) != 2 {
+				t.Fatalf("expected 2 rows, got %v", names)
+			}
+			if _, err := people.SelectWith(nil, SelectColumns("missing")); err == nil {
+				t.Fatal("expected an error for an unknown column")
+			}
+			seq, err = people.SelectColumns([]string{"name", "age"}, Eq("name", "bob"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for row, err := range seq {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(row) != 2 || row["name"] != "bob" {
+					t.Fatalf("expected bob's name and age, got %v", row)
+				}
+			}
+			if _, err := people.SelectColumns([]string{"name", "missing"}); err == nil {
+				t.Fatal("expected an error for an unknown column")
+			}
+		})
+	}
+}
+
+func TestPersistent_QueryBudget(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	clock := NewManualClock(time.Unix(0, 0))
+	db.SetClock(clock)
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"role": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		role := "user"
+		if i == 9 {
+			role = "admin"
+		}
+		if err := users.Insert(map[string]any{"id": int64(i), "role": role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(ranges map[string]*keyRange, opts ...QueryOption) (int, error) {
+		t.Helper()
+		seq, err := users.SelectWith(ranges, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				return n, err
+			}
+			clock.Advance(time.Second)
+			n++
+		}
+		return n, nil
+	}
+	admins, err := ToKeyRanges(Eq("role", "admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	// The only admin is the last row, so the scan reads all ten.
+	if _, err := run(admins, MaxScanned(5)); !errors.As(err, &te) || te.Code != ErrCodeQueryBudgetExceeded {
+		t.Fatalf("expected the scan budget exceeded, got %v", err)
+	}
+	if n, err := run(admins, MaxScanned(10)); err != nil || n != 1 {
+		t.Fatalf("expected the admin within budget, got %d, %v", n, err)
+	}
+	if n, err := run(nil, MaxRows(3)); !errors.As(err, &te) || te.Code != ErrCodeQueryBudgetExceeded || n != 3 {
+		t.Fatalf("expected 3 rows then the row budget exceeded, got %d, %v", n, err)
+	}
+	if n, err := run(nil, Timeout(2500*time.Millisecond)); !errors.As(err, &te) || te.Code != ErrCodeQueryTimeout || n != 3 {
+		t.Fatalf("expected 3 rows then a timeout, got %d, %v", n, err)
+	}
+}
+
+func TestPersistent_ExplainAnalyze(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	orders, err := tx.CreatePersistent("orders", map[string]ColumnSpec{
+		"customer": {Indexed: true},
+		"status":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 6 {
+		status := "open"
+		if i%3 == 0 {
+			status = "closed"
+		}
+		if err := orders.Insert(map[string]any{"customer": fmt.Sprintf("c%d", i%2), "status": status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranges, err := ToKeyRanges(Eq("customer", "c0"), Eq("status", "open"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c0 has orders 0, 2 and 4, of which 0 is closed.
+	a, err := orders.ExplainAnalyze(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Index != "customer" || a.IndexEntries != 3 || a.RowsFetched != 3 || a.RowsFiltered != 1 || a.RowsReturned != 2 {
+		t.Fatalf("unexpected index analysis %v", a)
+	}
+	a, err = orders.ExplainAnalyze(ranges, NoIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Index != "" || a.IndexEntries != 0 || a.RowsFetched != 6 || a.RowsFiltered != 4 || a.RowsReturned != 2 {
+		t.Fatalf("unexpected scan analysis %v", a)
+	}
+}
+
+func TestPersistent_Descending(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	events, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+		"at":   {Indexed: true, Type: TypeInt},
+		"name": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, at := range []int{10, 30, 20, 30, 50, 40} {
+		if err := events.Insert(map[string]any{"at": at, "name": fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collect := func(ranges map[string]*keyRange, opts ...QueryOption) string {
+		t.Helper()
+		seq, err := events.SelectWith(ranges, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names string
+		for row, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			names += row["name"].(string)
+		}
+		return names
+	}
+	ranges, err := ToKeyRanges(Gt("at", 10), Le("at", 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rows sharing a value come newest first too.
+	if got := collect(ranges, Descending()); got != "5312" {
+		t.Fatalf("expected 5312 walking the index backwards, got %s", got)
+	}
+	if got := collect(ranges); got != "2135" {
+		t.Fatalf("expected 2135 walking the index forwards, got %s", got)
+	}
+	ranges, err = ToKeyRanges(Lt("at", 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(ranges, Descending()); got != "20" {
+		t.Fatalf("expected 20 below an exclusive end, got %s", got)
+	}
+	if got := collect(nil, NoIndex(), Descending()); got != "543210" {
+		t.Fatalf("expected a reverse scan newest first, got %s", got)
+	}
+	plan, err := events.Explain(ranges, Descending())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Descending || plan.String() != "reverse index at on events ("+PlanNarrowestRange+")" {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+}
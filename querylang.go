@@ -0,0 +1,466 @@
+package thunder
+
+import (
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query parses and runs a query written in a small SQL subset:
+//
+//	SELECT * | column [, column...]
+//	FROM relation [JOIN relation...]
+//	[USE INDEX ([index]) | IGNORE INDEX (index [, index...])]
+//	[WHERE column op value [AND column op value...]]
+//	[LIMIT n]
+//
+// Keywords are case-insensitive. JOIN is a natural join on the columns the
+// relations share. Index hints apply to a single relation: USE INDEX with an
+// index is UseIndex, with none NoIndex, and IGNORE INDEX is IgnoreIndex. op is one of =, !=, <>, <, <=, > and >=. A value is a
+// quoted string, a number, true or false, or a ? placeholder taking the next
+// of args. Values are converted to the declared type of their column.
+func (tx *Tx) Query(query string, args ...any) (iter.Seq2[map[string]any, error], error) {
+	st, err := parseQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	relations := make([]*Persistent, len(st.relations))
+	for i, name := range st.relations {
+		if relations[i], err = tx.LoadPersistent(name); err != nil {
+			return nil, err
+		}
+	}
+	ops := make([]Op, len(st.conds))
+	for i, c := range st.conds {
+		spec, ok := columnSpecOf(relations, c.column)
+		if !ok {
+			return nil, ErrFieldNotFound(c.column)
+		}
+		value, err := coerceQueryValue(c.column, spec.Type, c.value)
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = Op{field: c.column, value: []any{value}, opType: c.op}
+	}
+	for _, col := range st.columns {
+		if _, ok := columnSpecOf(relations, col); !ok {
+			return nil, ErrFieldNotFound(col)
+		}
+	}
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	var seq iter.Seq2[map[string]any, error]
+	if len(relations) == 1 {
+		opts := st.hints
+		if st.columns != nil {
+			opts = append(opts, SelectColumns(st.columns...))
+		}
+		seq, err = relations[0].SelectWith(ranges, opts...)
+	} else {
+		bodies := make([]Selector, 0, len(relations)-1)
+		for _, p := range relations[1:] {
+			bodies = append(bodies, p)
+		}
+		seq, err = relations[0].Join(bodies...).Select(ranges)
+		if err == nil && st.columns != nil {
+			seq = projectColumns(seq, st.columns)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.limit >= 0 {
+		seq = limitRows(seq, st.limit)
+	}
+	return seq, nil
+}
+
+// Query runs query, as described for Tx.Query, in a read transaction and
+// returns the rows it yields.
+func (d *DB) Query(query string, args ...any) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := d.view(func(tx *Tx) error {
+		seq, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			rows = append(rows, ownedRow(tx.maUn, row))
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func columnSpecOf(relations []*Persistent, column string) (ColumnSpec, bool) {
+	for _, p := range relations {
+		if spec, ok := p.fields[column]; ok {
+			return spec, true
+		}
+	}
+	return ColumnSpec{}, false
+}
+
+// coerceQueryValue converts a query literal to the column type t. Literals
+// are strings, int64s, float64s or bools; bound arguments are passed through
+// unless t requires a conversion.
+func coerceQueryValue(column string, t ColumnType, v any) (any, error) {
+	switch t {
+	case TypeFloat:
+		if n, ok := v.(int64); ok {
+			return float64(n), nil
+		}
+	case TypeInt:
+		if f, ok := v.(float64); ok && TypeInt.accepts(f) {
+			return int64(f), nil
+		}
+	}
+	if !t.accepts(v) {
+		return nil, ErrTypeMismatch(column, t, v)
+	}
+	return v, nil
+}
+
+func limitRows(seq iter.Seq2[map[string]any, error], limit int) iter.Seq2[map[string]any, error] {
+	return func(yield func(map[string]any, error) bool) {
+		if limit == 0 {
+			return
+		}
+		n := 0
+		for row, err := range seq {
+			if !yield(row, err) {
+				return
+			}
+			if err == nil {
+				if n++; n >= limit {
+					return
+				}
+			}
+		}
+	}
+}
+
+type queryStatement struct {
+	columns   []string
+	relations []string
+	hints     []QueryOption
+	conds     []queryCond
+	limit     int
+}
+
+type queryCond struct {
+	column string
+	op     OpType
+	value  any
+}
+
+type queryTokenKind uint8
+
+const (
+	tokEOF queryTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type queryToken struct {
+	kind queryTokenKind
+	text string
+	pos  int
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+	args   []any
+	used   int
+}
+
+func parseQuery(src string, args []any) (*queryStatement, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, args: args}
+	st, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	if p.used != len(args) {
+		return nil, ErrQuerySyntax(len(src), "query has "+strconv.Itoa(p.used)+" placeholders but "+strconv.Itoa(len(args))+" arguments were given")
+	}
+	return st, nil
+}
+
+func (p *queryParser) statement() (*queryStatement, error) {
+	st := &queryStatement{limit: -1}
+	if err := p.keyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if !p.symbol("*") {
+		for {
+			col, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			st.columns = append(st.columns, col)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if err := p.keyword("FROM"); err != nil {
+		return nil, err
+	}
+	for {
+		rel, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		st.relations = append(st.relations, rel)
+		if !p.isKeyword("JOIN") && !p.symbol(",") {
+			break
+		}
+	}
+	if tok := p.peek(); p.isKeyword("USE") || p.isKeyword("IGNORE") {
+		use := strings.EqualFold(tok.text, "USE")
+		if len(st.relations) > 1 {
+			return nil, ErrQuerySyntax(tok.pos, "index hints need a single relation")
+		}
+		names, err := p.indexList()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case use && len(names) == 0:
+			st.hints = append(st.hints, NoIndex())
+		case use && len(names) == 1:
+			st.hints = append(st.hints, UseIndex(names[0]))
+		case use:
+			return nil, ErrQuerySyntax(tok.pos, "USE INDEX takes at most one index")
+		case len(names) == 0:
+			return nil, ErrQuerySyntax(tok.pos, "IGNORE INDEX needs an index")
+		default:
+			st.hints = append(st.hints, IgnoreIndex(names...))
+		}
+	}
+	if p.isKeyword("WHERE") {
+		for {
+			c, err := p.cond()
+			if err != nil {
+				return nil, err
+			}
+			st.conds = append(st.conds, c)
+			if !p.isKeyword("AND") {
+				break
+			}
+		}
+	}
+	if p.isKeyword("LIMIT") {
+		tok := p.peek()
+		n, err := strconv.Atoi(tok.text)
+		if tok.kind != tokNumber || err != nil || n < 0 {
+			return nil, ErrQuerySyntax(tok.pos, "expected a row count after LIMIT")
+		}
+		p.next++
+		st.limit = n
+	}
+	p.symbol(";")
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, ErrQuerySyntax(tok.pos, "unexpected "+strconv.Quote(tok.text))
+	}
+	return st, nil
+}
+
+var queryOps = map[string]OpType{
+	"=":  OpEq,
+	"!=": OpNe,
+	"<>": OpNe,
+	"<":  OpLt,
+	"<=": OpLe,
+	">":  OpGt,
+	">=": OpGe,
+}
+
+// indexList parses the INDEX (name, ...) part of an index hint.
+func (p *queryParser) indexList() ([]string, error) {
+	if err := p.keyword("INDEX"); err != nil {
+		return nil, err
+	}
+	if !p.symbol("(") {
+		return nil, ErrQuerySyntax(p.peek().pos, "expected (")
+	}
+	var names []string
+	for !p.symbol(")") {
+		if len(names) > 0 && !p.symbol(",") {
+			return nil, ErrQuerySyntax(p.peek().pos, "expected , or )")
+		}
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (p *queryParser) cond() (queryCond, error) {
+	col, err := p.ident()
+	if err != nil {
+		return queryCond{}, err
+	}
+	tok := p.peek()
+	op, ok := queryOps[tok.text]
+	if tok.kind != tokSymbol || !ok {
+		return queryCond{}, ErrQuerySyntax(tok.pos, "expected a comparison after "+col)
+	}
+	p.next++
+	value, err := p.value()
+	if err != nil {
+		return queryCond{}, err
+	}
+	return queryCond{column: col, op: op, value: value}, nil
+}
+
+func (p *queryParser) value() (any, error) {
+	tok := p.peek()
+	p.next++
+	switch tok.kind {
+	case tokString:
+		return tok.text, nil
+	case tokNumber:
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, ErrQuerySyntax(tok.pos, "invalid number "+tok.text)
+		}
+		return f, nil
+	case tokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	case tokSymbol:
+		if tok.text == "?" {
+			if p.used >= len(p.args) {
+				return nil, ErrQuerySyntax(tok.pos, "missing argument for placeholder")
+			}
+			p.used++
+			return p.args[p.used-1], nil
+		}
+	}
+	p.next--
+	return nil, ErrQuerySyntax(tok.pos, "expected a value")
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) isKeyword(kw string) bool {
+	tok := p.peek()
+	if tok.kind == tokIdent && strings.EqualFold(tok.text, kw) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) keyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return ErrQuerySyntax(p.peek().pos, "expected "+kw)
+	}
+	return nil
+}
+
+func (p *queryParser) symbol(s string) bool {
+	tok := p.peek()
+	if tok.kind == tokSymbol && tok.text == s {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) ident() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokIdent || slices.ContainsFunc(queryKeywords, func(kw string) bool { return strings.EqualFold(kw, tok.text) }) {
+		return "", ErrQuerySyntax(tok.pos, "expected a name")
+	}
+	p.next++
+	return tok.text, nil
+}
+
+var queryKeywords = []string{"SELECT", "FROM", "JOIN", "USE", "IGNORE", "INDEX", "WHERE", "AND", "LIMIT"}
+
+func lexQuery(src string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(src); j++ {
+				if src[j] == c {
+					// A doubled quote stands for the quote itself.
+					if j+1 < len(src) && src[j+1] == c {
+						sb.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, ErrQuerySyntax(i, "unterminated string")
+			}
+			tokens = append(tokens, queryToken{kind: tokString, text: sb.String(), pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' || src[j] == 'e' || src[j] == 'E' ||
+				(src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			if i+1 < len(src) {
+				if _, ok := queryOps[src[i:i+2]]; ok {
+					tokens = append(tokens, queryToken{kind: tokSymbol, text: src[i : i+2], pos: i})
+					i += 2
+					continue
+				}
+			}
+			if !strings.ContainsRune("*,=<>?;()", rune(c)) {
+				return nil, ErrQuerySyntax(i, "unexpected character "+strconv.QuoteRune(rune(c)))
+			}
+			tokens = append(tokens, queryToken{kind: tokSymbol, text: src[i : i+1], pos: i})
+			i++
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, pos: len(src)}), nil
+}
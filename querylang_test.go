@@ -0,0 +1,109 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDB_Query(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"name":       {Indexed: true, Type: TypeString},
+			"age":        {Type: TypeInt},
+			"department": {},
+		})
+		if err != nil {
+			return err
+		}
+		depts, err := tx.CreatePersistent("departments", map[string]ColumnSpec{
+			"department": {Indexed: true},
+			"floor":      {Type: TypeFloat},
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range []map[string]any{
+			{"name": "ada", "age": 36, "department": "eng"},
+			{"name": "bob", "age": 41, "department": "ops"},
+			{"name": "o'neil", "age": 29, "department": "eng"},
+		} {
+			if err := users.Insert(row); err != nil {
+				return err
+			}
+		}
+		for _, row := range []map[string]any{
+			{"department": "eng", "floor": 2.0},
+			{"department": "ops", "floor": 3.0},
+		} {
+			if err := depts.Insert(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(query string, args ...any) []any {
+		t.Helper()
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var out []any
+		for _, row := range rows {
+			out = append(out, row["name"])
+		}
+		return out
+	}
+	if got := names("select * from users where age >= 36"); len(got) != 2 {
+		t.Fatalf("expected two users aged 36 or more, got %v", got)
+	}
+	if got := names(`SELECT name FROM users WHERE name = 'o''neil'`); len(got) != 1 || got[0] != "o'neil" {
+		t.Fatalf("expected o'neil, got %v", got)
+	}
+	if got := names("SELECT name FROM users WHERE age > ? AND age < ? LIMIT 1", 20, 40); len(got) != 1 {
+		t.Fatalf("expected the limit to apply, got %v", got)
+	}
+	// The integer literal is converted to the float type of floor.
+	if got := names("SELECT name, floor FROM users JOIN departments WHERE floor = 3"); len(got) != 1 || got[0] != "bob" {
+		t.Fatalf("expected bob on the third floor, got %v", got)
+	}
+	for _, hint := range []string{"USE INDEX (name)", "use index ()", "IGNORE INDEX (name)"} {
+		if got := names("SELECT name FROM users " + hint + " WHERE name = 'bob'"); len(got) != 1 || got[0] != "bob" {
+			t.Fatalf("%s: expected bob, got %v", hint, got)
+		}
+	}
+	rows, err := db.Query("SELECT name FROM users WHERE name = ?", "ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || len(rows[0]) != 1 {
+		t.Fatalf("expected only the name column, got %v", rows)
+	}
+
+	var te *ThunderError
+	for _, bad := range []string{
+		"SELECT FROM users",
+		"SELECT * FROM users WHERE age ~ 3",
+		"SELECT * FROM users WHERE name = 'ada",
+		"SELECT * FROM users LIMIT x",
+		"SELECT * FROM users WHERE name = ?",
+		"SELECT * FROM users USE INDEX (name, age)",
+		"SELECT * FROM users IGNORE INDEX ()",
+		"SELECT * FROM users JOIN departments USE INDEX (name)",
+	} {
+		if _, err := db.Query(bad); !errors.As(err, &te) || te.Code != ErrCodeQuerySyntax {
+			t.Fatalf("%s: expected a syntax error, got %v", bad, err)
+		}
+	}
+	if _, err := db.Query("SELECT * FROM users WHERE age = 'old'"); !errors.As(err, &te) || te.Code != ErrCodeTypeMismatch {
+		t.Fatalf("expected a type mismatch, got %v", err)
+	}
+	if _, err := db.Query("SELECT nope FROM users"); !errors.As(err, &te) || te.Code != ErrCodeFieldNotFound {
+		t.Fatalf("expected an unknown column error, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package raftthunder replicates a thunder database with hashicorp/raft.
+//
+// Every node runs an FSM over its own database. Writes go through Node.Update,
+// which runs the usual Persistent API in a transaction on the leader, rolls
+// it back and proposes its changes to the raft log instead; every node,
+// including the leader, then applies them once the log commits them. Reads
+// use the local database directly and may lag behind the leader on
+// followers.
+package raftthunder
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/longlodw/thunder"
+)
+
+// FSM is a raft.FSM applying committed changes to a database. Change
+// tracking must be enabled on the database.
+type FSM struct {
+	db *thunder.DB
+}
+
+var _ raft.FSM = (*FSM)(nil)
+
+// NewFSM returns an FSM for db.
+func NewFSM(db *thunder.DB) *FSM {
+	return &FSM{db: db}
+}
+
+// Apply applies the changes of a log entry and returns the error, if any,
+// as the response.
+func (f *FSM) Apply(entry *raft.Log) any {
+	_, err := f.db.ApplyChanges(bytes.NewReader(entry.Data))
+	return err
+}
+
+// Snapshot captures the database in a read transaction, which stays open
+// until the snapshot is persisted or released.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	tx, err := f.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{tx: tx}, nil
+}
+
+// Restore replaces the database with a snapshot, unless the database is
+// already past it.
+func (f *FSM) Restore(r io.ReadCloser) error {
+	defer r.Close()
+	_, err := f.db.ApplyChanges(r)
+	return err
+}
+
+type snapshot struct {
+	tx *thunder.Tx
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := s.tx.Changes(0, sink); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {
+	s.tx.Rollback()
+}
+
+// Node sends the writes to a database through raft.
+type Node struct {
+	raft    *raft.Raft
+	db      *thunder.DB
+	timeout time.Duration
+	// mu serializes updates so that each is computed on top of the last.
+	mu sync.Mutex
+}
+
+// NewNode returns a Node writing to db, whose FSM is registered with r, and
+// waiting up to timeout for writes to commit.
+func NewNode(r *raft.Raft, db *thunder.DB, timeout time.Duration) *Node {
+	return &Node{raft: r, db: db, timeout: timeout}
+}
+
+// Update runs fn in a write transaction and commits its changes through
+// raft. It fails with raft.ErrNotLeader on followers. Update returns once the
+// changes are applied to this node's database.
+func (n *Node) Update(fn func(tx *thunder.Tx) error) error {
+	if n.raft.State() != raft.Leader {
+		return raft.ErrNotLeader
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	// Entries committed by a previous leader must be applied locally before
+	// changes are computed on top of them.
+	if err := n.raft.Barrier(n.timeout).Error(); err != nil {
+		return err
+	}
+	data, err := n.changes(fn)
+	if err != nil || data == nil {
+		return err
+	}
+	future := n.raft.Apply(data, n.timeout)
+	if err := future.Error(); err != nil {
+		return err
+	}
+	if err, ok := future.Response().(error); ok && err != nil {
+		return err
+	}
+	return nil
+}
+
+// changes runs fn and returns the changes it made, or nil if it made none.
+func (n *Node) changes(fn func(tx *thunder.Tx) error) ([]byte, error) {
+	tx, err := n.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	since := tx.CommitSequence()
+	if err := fn(tx); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	to, err := tx.Changes(since, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if to == since {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
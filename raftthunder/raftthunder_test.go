@@ -0,0 +1,125 @@
+package raftthunder
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/raft"
+	"github.com/longlodw/thunder"
+)
+
+func TestCluster(t *testing.T) {
+	const size = 3
+	var (
+		dbs        [size]*thunder.DB
+		rafts      [size]*raft.Raft
+		transports [size]*raft.InmemTransport
+		servers    []raft.Server
+	)
+	for i := range size {
+		addr, transport := raft.NewInmemTransport(raft.ServerAddress(fmt.Sprintf("node%d", i)))
+		transports[i] = transport
+		servers = append(servers, raft.Server{ID: raft.ServerID(addr), Address: addr})
+	}
+	for i := range size {
+		for j := range size {
+			if i != j {
+				transports[i].Connect(transports[j].LocalAddr(), transports[j])
+			}
+		}
+	}
+	for i := range size {
+		db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "node.db"), 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.EnableChangeTracking(); err != nil {
+			t.Fatal(err)
+		}
+		dbs[i] = db
+		conf := raft.DefaultConfig()
+		conf.LocalID = servers[i].ID
+		conf.HeartbeatTimeout = 50 * time.Millisecond
+		conf.ElectionTimeout = 50 * time.Millisecond
+		conf.LeaderLeaseTimeout = 50 * time.Millisecond
+		conf.CommitTimeout = 5 * time.Millisecond
+		conf.LogLevel = "ERROR"
+		store := raft.NewInmemStore()
+		r, err := raft.NewRaft(conf, NewFSM(db), store, store, raft.NewInmemSnapshotStore(), transports[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Shutdown()
+		rafts[i] = r
+	}
+	if err := rafts[0].BootstrapCluster(raft.Configuration{Servers: servers}).Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	var leader *Node
+	deadline := time.Now().Add(10 * time.Second)
+	for leader == nil {
+		for i, r := range rafts {
+			if r.State() == raft.Leader {
+				leader = NewNode(r, dbs[i], 5*time.Second)
+			} else if err := NewNode(r, dbs[i], time.Second).Update(func(*thunder.Tx) error { return nil }); err != raft.ErrNotLeader {
+				t.Fatalf("expected ErrNotLeader on a follower, got %v", err)
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no leader elected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err := leader.Update(func(tx *thunder.Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]thunder.ColumnSpec{"name": {Unique: true}})
+		if err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"name": "ada"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = leader.Update(func(tx *thunder.Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		if err := users.Insert(map[string]any{"name": "bob"}); err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"name": "ada"})
+	})
+	if err == nil {
+		t.Fatal("expected the duplicate insert to fail")
+	}
+	err = leader.Update(func(tx *thunder.Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"name": "eve"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, db := range dbs {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			rows, err := db.Select("users", nil, thunder.ConsistencyLatest)
+			if err == nil && len(rows) == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("node %d: expected ada and eve, got %v, %v", i, rows, err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
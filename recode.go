@@ -0,0 +1,237 @@
+package thunder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// defaultRecodeBatch is the number of rows recoded per batch when no batch
+// size is given.
+const defaultRecodeBatch = 1000
+
+// builtinCodecs are the codecs a relation can be recoded to without
+// registering them with DB.SetCodec.
+var builtinCodecs = map[string]MarshalUnmarshaler{
+	"json":    &JsonMaUn,
+	"gob":     &GobMaUn,
+	"msgpack": &MsgpackMaUn,
+	"cbor":    &CborMaUn,
+}
+
+// codecName returns the name maUn is built in or registered under.
+func (d *DB) codecName(maUn MarshalUnmarshaler) (string, bool) {
+	if !reflect.TypeOf(maUn).Comparable() {
+		return "", false
+	}
+	for name, c := range builtinCodecs {
+		if reflect.TypeOf(c) == reflect.TypeOf(maUn) {
+			return name, true
+		}
+	}
+	d.hooksMu.RLock()
+	defer d.hooksMu.RUnlock()
+	for name, c := range d.codecs {
+		if c == maUn {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (d *DB) codecByName(name string) (MarshalUnmarshaler, bool) {
+	if c, ok := builtinCodecs[name]; ok {
+		return c, true
+	}
+	d.hooksMu.RLock()
+	defer d.hooksMu.RUnlock()
+	c, ok := d.codecs[name]
+	return c, ok
+}
+
+// relationCodec returns the codec of the relation with the given meta
+// bucket: the one it was recoded to, or the database's.
+func (tx *Tx) relationCodec(metaBucket BackendBucket) (MarshalUnmarshaler, error) {
+	name := metaBucket.Get([]byte("codec"))
+	if name == nil {
+		return tx.maUn, nil
+	}
+	maUn, ok := tx.db.codecByName(string(name))
+	if !ok {
+		return nil, ErrUnknownCodec(string(name))
+	}
+	return maUn, nil
+}
+
+// loadRecode sets up d to read the rows already recoded by an unfinished
+// recode, whose state is the id of the last recoded row followed by the
+// name of the new codec.
+func (tx *Tx) loadRecode(d *dataStorage, state []byte) error {
+	maUn, ok := tx.db.codecByName(string(state[8:]))
+	if !ok {
+		return ErrUnknownCodec(string(state[8:]))
+	}
+	d.recoded, d.recodedUntil = maUn, bytes.Clone(state[:8])
+	return nil
+}
+
+// Recode rewrites the rows and metadata of the relation with newMaUn, which
+// must be built in or registered with DB.SetCodec. From then on the relation
+// is read and written with newMaUn whatever codec the database is opened
+// with, so a database can be moved to another codec one relation at a time.
+// Relations with encrypted columns cannot be recoded.
+func (pr *Persistent) Recode(newMaUn MarshalUnmarshaler) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	for {
+		done, err := pr.recodeBatch(newMaUn, defaultRecodeBatch)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// Recode is Persistent.Recode run in transactions of batchSize rows, 1000
+// when zero. Between the transactions the relation stays readable and
+// writable, reading every row with the codec it is stored in. An
+// interrupted recode is resumed by calling Recode again with the same codec.
+func (d *DB) Recode(relation string, newMaUn MarshalUnmarshaler, batchSize int) error {
+	if batchSize <= 0 {
+		batchSize = defaultRecodeBatch
+	}
+	for {
+		var done bool
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			done, err = p.recodeBatch(newMaUn, batchSize)
+			return err
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// recodeBatch recodes the next limit rows and reports whether the recode is
+// done. Rows are recoded in id order; rows written meanwhile get higher ids
+// and the old codec, so every row up to the recorded id uses the new one.
+func (pr *Persistent) recodeBatch(newMaUn MarshalUnmarshaler, limit int) (bool, error) {
+	if pr.encrypted {
+		for _, col := range pr.columns {
+			if pr.fields[col].EncryptionKey != "" {
+				return false, ErrColumnEncrypted(col)
+			}
+		}
+	}
+	name, ok := pr.tx.db.codecName(newMaUn)
+	if !ok {
+		return false, ErrUnknownCodec(fmt.Sprintf("%T", newMaUn))
+	}
+	if current, _ := pr.tx.db.codecName(pr.maUn); current == name {
+		return true, nil
+	}
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	var after []byte
+	if state := metaBucket.Get([]byte("recode")); state != nil {
+		if string(state[8:]) != name {
+			return false, ErrRecodeInProgress(pr.relation, string(state[8:]))
+		}
+		after = state[:8]
+	}
+	pr.tx.touch(pr.relation)
+	type recoded struct {
+		id, value []byte
+		row       map[string]any
+	}
+	var batch []recoded
+	c := pr.data.bucket.Cursor()
+	k, v := c.First()
+	if after != nil {
+		if k, v = c.Seek(after); bytes.Equal(k, after) {
+			k, v = c.Next()
+		}
+	}
+	for ; k != nil && len(batch) < limit; k, v = c.Next() {
+		var value map[string]any
+		if err := pr.maUn.Unmarshal(v, &value); err != nil {
+			return false, err
+		}
+		raw, err := newMaUn.Marshal(value)
+		if err != nil {
+			return false, err
+		}
+		batch = append(batch, recoded{id: bytes.Clone(k), value: raw, row: value})
+		if pr.capacity != nil {
+			pr.capacity.Bytes += int64(len(raw) - len(v))
+		}
+	}
+	for _, r := range batch {
+		if err := pr.data.bucket.Put(r.id, r.value); err != nil {
+			return false, err
+		}
+		if err := pr.data.putColumns(r.id, r.row, newMaUn); err != nil {
+			return false, err
+		}
+	}
+	if pr.capacity != nil {
+		if err := pr.saveCapacity(); err != nil {
+			return false, err
+		}
+	}
+	if k != nil {
+		last := batch[len(batch)-1].id
+		state := append(bytes.Clone(last), name...)
+		pr.data.recoded, pr.data.recodedUntil = newMaUn, last
+		return false, metaBucket.Put([]byte("recode"), state)
+	}
+	return true, pr.finishRecode(newMaUn, name)
+}
+
+// finishRecode recodes the metadata of the relation once its rows are done
+// and switches it to the new codec. Statistics are dropped rather than
+// recoded; Analyze gathers them again.
+func (pr *Persistent) finishRecode(newMaUn MarshalUnmarshaler, name string) error {
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	meta := map[string]func() any{
+		"columnSpecs":    func() any { return &map[string]ColumnSpec{} },
+		"pendingIndexes": func() any { return &map[string]uint64{} },
+		"capacity":       func() any { return &capacity{} },
+		"masks":          func() any { return &map[string][]string{} },
+		"ttl":            func() any { return &ttlPolicy{} },
+	}
+	for key, newValue := range meta {
+		raw := metaBucket.Get([]byte(key))
+		if raw == nil {
+			continue
+		}
+		v := newValue()
+		if err := pr.maUn.Unmarshal(raw, v); err != nil {
+			return ErrCorruptedMetaDataEntry(pr.relation, key)
+		}
+		recoded, err := newMaUn.Marshal(reflect.ValueOf(v).Elem().Interface())
+		if err != nil {
+			return err
+		}
+		if err := metaBucket.Put([]byte(key), recoded); err != nil {
+			return err
+		}
+	}
+	if err := pr.tx.forgetAnalysis(pr.relation); err != nil {
+		return err
+	}
+	pr.analysis, pr.analysisLoaded = nil, false
+	if err := metaBucket.Delete([]byte("recode")); err != nil {
+		return err
+	}
+	if err := metaBucket.Put([]byte("codec"), []byte(name)); err != nil {
+		return err
+	}
+	pr.maUn = newMaUn
+	pr.data.maUn, pr.data.recoded, pr.data.recodedUntil = newMaUn, nil, nil
+	pr.indexes.maUn = newMaUn
+	return nil
+}
@@ -0,0 +1,133 @@
+package thunder
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDB_Recode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recode.db")
+	db, err := OpenDB(&JsonMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"team": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range 5 {
+			if err := users.Insert(map[string]any{"id": fmt.Sprint(i), "team": "red"}); err != nil {
+				return err
+			}
+		}
+		return users.MaskColumn("team", "guest")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := func(db *DB) []string {
+		t.Helper()
+		rows, err := db.Query("SELECT id FROM users WHERE team = 'red'")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, row := range rows {
+			out = append(out, row["id"].(string))
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	// Half way through, rows in either codec are read and written.
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		done, err := users.recodeBatch(&MsgpackMaUn, 2)
+		if err != nil || done {
+			return fmt.Errorf("expected an unfinished recode, got %v, %v", done, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(db); !slices.Equal(got, []string{"0", "1", "2", "3", "4"}) {
+		t.Fatalf("expected every row readable mid-recode, got %v", got)
+	}
+	err = db.update(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		ranges, err := ToKeyRanges(Eq("id", "0"))
+		if err != nil {
+			return err
+		}
+		if _, err := users.Update(ranges, map[string]any{"id": "9"}); err != nil {
+			return err
+		}
+		return users.Insert(map[string]any{"id": "5", "team": "red"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Recode("users", &CborMaUn, 2); err == nil {
+		t.Fatal("expected switching codecs mid-recode to fail")
+	}
+	if err := db.Recode("users", &MsgpackMaUn, 2); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "3", "4", "5", "9"}
+	if got := ids(db); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// The relation keeps reading as msgpack when the database is reopened.
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = OpenDB(&JsonMaUn, path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(db); !slices.Equal(got, want) {
+		t.Fatalf("expected %v after reopening, got %v", want, got)
+	}
+	err = db.view(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		if codec := users.bucket.Bucket([]byte("meta")).Get([]byte("codec")); string(codec) != "msgpack" {
+			t.Errorf("expected the relation recorded as msgpack, got %q", codec)
+		}
+		if masked := users.MaskedColumns("guest"); !slices.Equal(masked, []string{"team"}) {
+			t.Errorf("expected the masks recoded, got %v", masked)
+		}
+		report, err := users.Verify()
+		if err != nil {
+			return err
+		}
+		if len(report.Issues) != 0 {
+			t.Errorf("expected a consistent relation, got %v", report.Issues)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+package thunder
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTx_ForEachRelationAndSelectAcross(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	orders, err := tx.CreatePersistent("orders", map[string]ColumnSpec{
+		"order_id": {},
+		"user_id":  {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err := tx.CreatePersistent("comments", map[string]ColumnSpec{
+		"text":    {},
+		"user_id": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("products", map[string]ColumnSpec{
+		"sku": {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i, user := range []string{"u1", "u2", "u1", "u1"} {
+		if err := orders.Insert(map[string]any{"order_id": float64(i), "user_id": user}); err != nil {
+			t.Fatal(err)
+		}
+		if err := comments.Insert(map[string]any{"text": "hi", "user_id": user}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := tx.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Errorf("expected 3 relations, got %v", names)
+	}
+	ranges, err := ToKeyRanges(Eq("user_id", "u1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := tx.SelectAcross(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[row.Relation]++
+	}
+	tx.Rollback()
+	if counts["orders"] != 3 || counts["comments"] != 3 || len(counts) != 2 {
+		t.Errorf("unexpected cross-relation counts: %v", counts)
+	}
+
+	err = db.ForEachRelation(func(p *Persistent) error {
+		if !slices.Contains(p.Columns(), "user_id") {
+			return nil
+		}
+		return p.Delete(ranges)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	seq, err = tx.SelectAcross(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := range seq {
+		t.Errorf("expected no rows for u1 after deletion, got %v", row)
+	}
+}
+
+func TestTx_RelationLifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+		"kind": {Indexed: true},
+		"seq":  {Unique: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 5 {
+		if err := p.Insert(map[string]any{"kind": "click", "seq": float64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.RenameRelation("events", "archived_events"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.LoadPersistent("events"); err == nil {
+		t.Fatal("expected old relation name to be gone")
+	}
+	if err := tx.RenameRelation("missing", "other"); err == nil {
+		t.Fatal("expected error renaming missing relation")
+	}
+	p, err = tx.LoadPersistent("archived_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("kind", "click"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	countRows := func() int {
+		t.Helper()
+		seq, err := p.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			count++
+		}
+		return count
+	}
+	if n := countRows(); n != 5 {
+		t.Fatalf("expected 5 rows after rename, got %d", n)
+	}
+
+	if err := p.Truncate(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(); n != 0 {
+		t.Fatalf("expected 0 rows after truncate, got %d", n)
+	}
+	// Unique index must have been cleared as well.
+	if err := p.Insert(map[string]any{"kind": "click", "seq": float64(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(); n != 1 {
+		t.Fatalf("expected 1 row after reinsert, got %d", n)
+	}
+
+	if err := tx.DropRelation("archived_events"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DropRelation("archived_events"); err == nil {
+		t.Fatal("expected error dropping relation twice")
+	}
+	names, err := tx.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no relations, got %v", names)
+	}
+}
@@ -0,0 +1,246 @@
+package thunder
+
+import (
+	"iter"
+	"slices"
+)
+
+// RangeSpec is the wire form of a key range pushed down to a RemoteSource.
+// Keys are encoded with ToKey; nil bounds are open. All and AnyOf hold the
+// element conditions of Contains, ContainsAll and ContainsAny: every key of
+// All and a key of each AnyOf group must be among the elements.
+type RangeSpec struct {
+	Start        []byte
+	End          []byte
+	IncludeStart bool
+	IncludeEnd   bool
+	Excludes     [][]byte
+	All          [][]byte
+	AnyOf        [][][]byte
+}
+
+// KeyRange converts the spec back into a key range usable with Select.
+func (rs RangeSpec) KeyRange() *keyRange {
+	kr := KeyRange(rs.Start, rs.End, rs.IncludeStart, rs.IncludeEnd, rs.Excludes)
+	kr.all, kr.anyOf = rs.All, rs.AnyOf
+	return kr
+}
+
+// KeyRanges converts specs back into ranges usable with Select.
+func KeyRanges(specs map[string]RangeSpec) map[string]*keyRange {
+	ranges := make(map[string]*keyRange, len(specs))
+	for name, spec := range specs {
+		ranges[name] = spec.KeyRange()
+	}
+	return ranges
+}
+
+// RangeSpecs converts ranges, as built by ToKeyRanges, to their wire form.
+func RangeSpecs(ranges map[string]*keyRange) map[string]RangeSpec {
+	return rangeSpecs(ranges)
+}
+
+func rangeSpecs(ranges map[string]*keyRange) map[string]RangeSpec {
+	specs := make(map[string]RangeSpec, len(ranges))
+	for name, kr := range ranges {
+		specs[name] = RangeSpec{
+			Start:        kr.startKey,
+			End:          kr.endKey,
+			IncludeStart: kr.includeStart,
+			IncludeEnd:   kr.includeEnd,
+			Excludes:     kr.excludes,
+			All:          kr.all,
+			AnyOf:        kr.anyOf,
+		}
+	}
+	return specs
+}
+
+// RemoteSource serves the rows of a relation held outside this database, for
+// example by a thunder server. Select should only yield rows matching
+// ranges; rows that do not match are filtered out locally regardless.
+type RemoteSource interface {
+	Columns() []string
+	Select(ranges map[string]RangeSpec) (iter.Seq2[map[string]any, error], error)
+}
+
+// AttachRemote makes source available to transactions of this database as a
+// read-only relation named name, replacing any source attached earlier
+// under that name.
+func (d *DB) AttachRemote(name string, source RemoteSource) {
+	d.remotesMu.Lock()
+	defer d.remotesMu.Unlock()
+	if d.remotes == nil {
+		d.remotes = make(map[string]RemoteSource)
+	}
+	d.remotes[name] = source
+}
+
+// DetachRemote removes the remote source attached under name.
+func (d *DB) DetachRemote(name string) {
+	d.remotesMu.Lock()
+	defer d.remotesMu.Unlock()
+	delete(d.remotes, name)
+}
+
+// LoadRemote returns a Selector over the remote source attached under name,
+// which can be projected and joined with local relations.
+func (tx *Tx) LoadRemote(name string) (*Remote, error) {
+	tx.db.remotesMu.Lock()
+	source, ok := tx.db.remotes[name]
+	tx.db.remotesMu.Unlock()
+	if !ok {
+		return nil, ErrRelationNotFound(name)
+	}
+	return &Remote{name: name, source: source, columns: slices.Clone(source.Columns())}, nil
+}
+
+// Remote is a read-only relation backed by a RemoteSource.
+type Remote struct {
+	name        string
+	source      RemoteSource
+	columns     []string
+	parentsList []*queryParent
+}
+
+var _ linkedSelector = (*Remote)(nil)
+
+func (r *Remote) Name() string {
+	return r.name
+}
+
+func (r *Remote) Columns() []string {
+	return r.columns
+}
+
+func (r *Remote) IsRecursive() bool {
+	return false
+}
+
+func (r *Remote) addParent(parent *queryParent) {
+	r.parentsList = append(r.parentsList, parent)
+}
+
+func (r *Remote) parents() []*queryParent {
+	return r.parentsList
+}
+
+func (r *Remote) Project(mapping map[string]string) Selector {
+	return newProjection(r, mapping)
+}
+
+func (r *Remote) Join(bodies ...Selector) Selector {
+	linkedBodies := make([]linkedSelector, len(bodies)+1)
+	linkedBodies[0] = r
+	for i, body := range bodies {
+		linkedBodies[i+1] = body.(linkedSelector)
+	}
+	return newJoining(linkedBodies)
+}
+
+func (r *Remote) Select(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	for name := range ranges {
+		if !slices.Contains(r.columns, name) {
+			return nil, ErrFieldNotFound(name)
+		}
+	}
+	seq, err := r.source.Select(rangeSpecs(ranges))
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for row, err := range seq {
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			ok, err := rowMatches(row, ranges)
+			if err != nil {
+				if !yield(nil, err) {
+					return
+				}
+				continue
+			}
+			if ok && !yield(row, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+func rowMatches(row map[string]any, ranges map[string]*keyRange) (bool, error) {
+	for name, kr := range ranges {
+		v, ok := row[name]
+		if !ok {
+			return false, nil
+		}
+		if kr.elementwise() {
+			keys, err := elementKeys(v)
+			if err != nil {
+				return false, err
+			}
+			if !kr.matchElements(keys) {
+				return false, nil
+			}
+			continue
+		}
+		key, err := ToKey(v)
+		if err != nil {
+			return false, err
+		}
+		if !kr.contains(key) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// dbSource serves a relation of another thunder database as a RemoteSource.
+type dbSource struct {
+	db       *DB
+	relation string
+	columns  []string
+}
+
+// NewDBSource returns a RemoteSource reading relation from db, for federating
+// separate database files in one process. Every Select reads a fresh
+// snapshot of db.
+func NewDBSource(db *DB, relation string) (RemoteSource, error) {
+	var columns []string
+	err := db.view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		columns = slices.Clone(p.Columns())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dbSource{db: db, relation: relation, columns: columns}, nil
+}
+
+func (s *dbSource) Columns() []string {
+	return s.columns
+}
+
+func (s *dbSource) Select(ranges map[string]RangeSpec) (iter.Seq2[map[string]any, error], error) {
+	keyRanges := make(map[string]*keyRange, len(ranges))
+	for name, rs := range ranges {
+		keyRanges[name] = rs.KeyRange()
+	}
+	rows, err := s.db.Select(s.relation, keyRanges, ConsistencyLatest)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,78 @@
+package thunder
+
+import (
+	"testing"
+)
+
+func TestTx_JoinWithRemote(t *testing.T) {
+	central, cleanupCentral := setupTestDB(t)
+	defer cleanupCentral()
+	err := central.update(func(tx *Tx) error {
+		depts, err := tx.CreatePersistent("departments", map[string]ColumnSpec{
+			"department": {Indexed: true},
+			"location":   {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := depts.Insert(map[string]any{"department": "engineering", "location": "building A"}); err != nil {
+			return err
+		}
+		return depts.Insert(map[string]any{"department": "hr", "location": "building B"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	source, err := NewDBSource(central, "departments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.AttachRemote("departments", source)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"username":   {Indexed: true},
+		"department": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"username": "alice", "department": "engineering"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"username": "bob", "department": "hr"}); err != nil {
+		t.Fatal(err)
+	}
+	depts, err := tx.LoadRemote("departments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := ToKeyRanges(Eq("username", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := users.Join(depts).Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 1 || rows[0]["location"] != "building B" {
+		t.Fatalf("expected bob in building B, got %v", rows)
+	}
+	if _, err := tx.LoadRemote("missing"); err == nil {
+		t.Fatal("expected error loading an unattached remote")
+	}
+}
@@ -0,0 +1,296 @@
+package thunder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// replicationBucket holds the replication state:
+//   - "epochs": the commit sequence at which every epoch began, oldest first
+const replicationBucket = "__thunder_replication"
+
+// ReplicationState is the position of a database in a replicated commit log.
+// Epoch counts the promotions the log went through; Seq is the commit
+// sequence of change tracking.
+type ReplicationState struct {
+	Epoch uint64
+	Seq   uint64
+}
+
+// replicationBatch is a frame of a replication stream: the epochs of the
+// leader and an incremental backup bringing the follower up to date.
+type replicationBatch struct {
+	Epochs []uint64
+	Backup []byte
+}
+
+// commitSignal wakes replication streams when a write transaction commits.
+type commitSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait returns a channel closed by the next notify.
+func (s *commitSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+func (s *commitSignal) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// ReplicationState returns the position of the database in the replicated
+// commit log.
+func (d *DB) ReplicationState() (ReplicationState, error) {
+	var state ReplicationState
+	err := d.view(func(tx *Tx) error {
+		epochs, err := tx.epochs()
+		if err != nil {
+			return err
+		}
+		state = ReplicationState{Epoch: uint64(len(epochs) - 1), Seq: tx.CommitSequence()}
+		return nil
+	})
+	return state, err
+}
+
+// ServeReplication streams to w the commits a follower at from is missing,
+// then every later commit as it happens, until ctx is done or writing fails.
+// A follower whose state diverged from this database, because it applied
+// commits of an earlier leader that never reached this one, is sent a full
+// copy instead. Change tracking must be enabled.
+func (d *DB) ServeReplication(ctx context.Context, from ReplicationState, w io.Writer) error {
+	var since uint64
+	err := d.view(func(tx *Tx) error {
+		if tx.tx.Bucket([]byte(changesBucket)) == nil {
+			return ErrChangeTrackingDisabled()
+		}
+		epochs, err := tx.epochs()
+		if err != nil {
+			return err
+		}
+		if consistentReplica(epochs, tx.CommitSequence(), from) {
+			since = from.Seq
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	first := true
+	for {
+		// Take the signal before reading so no commit goes unnoticed.
+		committed := d.commits.wait()
+		var batch replicationBatch
+		var to uint64
+		err := d.view(func(tx *Tx) error {
+			to = tx.CommitSequence()
+			if to == since && !first {
+				return nil
+			}
+			var err error
+			if batch.Epochs, err = tx.epochs(); err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if _, err := tx.backupSince(since, &buf); err != nil {
+				return err
+			}
+			batch.Backup = buf.Bytes()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if batch.Backup != nil {
+			if err := writeFrame(w, d.maUn, batch); err != nil {
+				return err
+			}
+			since, first = to, false
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-committed:
+		}
+	}
+}
+
+// consistentReplica reports whether every commit applied by a follower at
+// from is also part of the log of a leader with the given epochs and commit
+// sequence.
+func consistentReplica(epochs []uint64, seq uint64, from ReplicationState) bool {
+	current := uint64(len(epochs) - 1)
+	switch {
+	case from.Epoch > current:
+		return false
+	case from.Epoch == current:
+		return from.Seq <= seq
+	default:
+		return from.Seq <= epochs[from.Epoch+1]
+	}
+}
+
+// Follow applies the replication stream read from r, as written by
+// ServeReplication, until r reaches EOF. Every batch is applied in its own
+// transaction, so a follower that stops midway stays consistent and can
+// resume from its ReplicationState. Writes other than replication fail with
+// ErrReplicaReadOnly while Follow runs. Change tracking is enabled on the
+// database so that it can be promoted.
+func (d *DB) Follow(r io.Reader) error {
+	if err := d.EnableChangeTracking(); err != nil {
+		return err
+	}
+	if !d.following.CompareAndSwap(false, true) {
+		return ErrReplicaReadOnly()
+	}
+	defer d.following.Store(false)
+	br := bufio.NewReader(r)
+	for {
+		var batch replicationBatch
+		err := readFrame(br, d.maUn, &batch)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.applyReplicationBatch(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *DB) applyReplicationBatch(batch replicationBatch) error {
+	br := bufio.NewReader(bytes.NewReader(batch.Backup))
+	var header backupHeader
+	if err := readFrame(br, d.maUn, &header); err != nil {
+		return err
+	}
+	tx, err := d.begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := tx.applyChanges(header, br); err != nil {
+		return err
+	}
+	if err := tx.setEpochs(batch.Epochs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Changes writes to w, in the format of BackupSince, the changes after the
+// commit sequence since that the transaction sees, including its own
+// uncommitted writes, and returns the sequence they bring a database up to.
+// With since zero, it writes a full copy. Together with ApplyChanges it lets
+// a write transaction be rolled back locally and replayed elsewhere, such as
+// through a consensus log.
+func (tx *Tx) Changes(since uint64, w io.Writer) (uint64, error) {
+	return tx.backupSince(since, w)
+}
+
+// ApplyChanges applies changes written by Tx.Changes or BackupSince, keeping
+// their commit sequence so that the database can serve the same sequences
+// afterwards. Changes up to a sequence the database has already reached are
+// skipped. Otherwise changes since zero replace the whole database, and
+// others must start at its commit sequence or ApplyChanges fails with
+// ErrReplicationGap. Change tracking must be enabled.
+func (d *DB) ApplyChanges(r io.Reader) (uint64, error) {
+	br := bufio.NewReader(r)
+	var header backupHeader
+	if err := readFrame(br, d.maUn, &header); err != nil {
+		return 0, err
+	}
+	tx, err := d.begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	root := tx.tx.Bucket([]byte(changesBucket))
+	if root == nil {
+		return 0, ErrChangeTrackingDisabled()
+	}
+	if header.To <= root.Sequence() {
+		return root.Sequence(), nil
+	}
+	if err := tx.applyChanges(header, br); err != nil {
+		return 0, err
+	}
+	return header.To, tx.Commit()
+}
+
+func (tx *Tx) applyChanges(header backupHeader, br *bufio.Reader) error {
+	root := tx.tx.Bucket([]byte(changesBucket))
+	// Changes from zero are a full copy and replace whatever was applied.
+	if header.From != 0 && header.From != root.Sequence() {
+		return ErrReplicationGap(header.From, root.Sequence())
+	}
+	// Number the applied changes like their origin did.
+	if err := root.SetSequence(header.To); err != nil {
+		return err
+	}
+	tx.commitSeq = header.To
+	return tx.applyBackup(header, br)
+}
+
+// Promote makes a follower the leader of a new epoch, so that followers of
+// the previous leader that applied commits this database never received are
+// resynchronized when they follow it. It must not be called while Follow
+// runs.
+func (d *DB) Promote() error {
+	if d.following.Load() {
+		return ErrReplicaReadOnly()
+	}
+	return d.update(func(tx *Tx) error {
+		if tx.tx.Bucket([]byte(changesBucket)) == nil {
+			return ErrChangeTrackingDisabled()
+		}
+		epochs, err := tx.epochs()
+		if err != nil {
+			return err
+		}
+		return tx.setEpochs(append(epochs, tx.CommitSequence()))
+	})
+}
+
+// epochs returns the commit sequence at which every epoch began. A database
+// that was never promoted is in epoch zero, which began at zero.
+func (tx *Tx) epochs() ([]uint64, error) {
+	bucket := tx.tx.Bucket([]byte(replicationBucket))
+	if bucket == nil {
+		return []uint64{0}, nil
+	}
+	var epochs []uint64
+	if err := tx.maUn.Unmarshal(bucket.Get([]byte("epochs")), &epochs); err != nil {
+		return nil, ErrCorruptedMetaDataEntry(replicationBucket, "epochs")
+	}
+	return epochs, nil
+}
+
+func (tx *Tx) setEpochs(epochs []uint64) error {
+	bucket, err := tx.tx.CreateBucketIfNotExists([]byte(replicationBucket))
+	if err != nil {
+		return err
+	}
+	raw, err := tx.maUn.Marshal(epochs)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte("epochs"), raw)
+}
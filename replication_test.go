@@ -0,0 +1,127 @@
+package thunder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDB_Replication(t *testing.T) {
+	leader, cleanupLeader := setupTestDB(t)
+	defer cleanupLeader()
+	follower, cleanupFollower := setupTestDB(t)
+	defer cleanupFollower()
+	if err := leader.EnableChangeTracking(); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(db *DB, names ...string) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("users")
+			if err != nil {
+				p, err = tx.CreatePersistent("users", map[string]ColumnSpec{"name": {Unique: true}})
+			}
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				if err := p.Insert(map[string]any{"name": name}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(db *DB) []string {
+		rows, err := db.Select("users", nil, ConsistencyLatest)
+		if err != nil {
+			return nil
+		}
+		var out []string
+		for _, row := range rows {
+			out = append(out, row["name"].(string))
+		}
+		slices.Sort(out)
+		return out
+	}
+	waitFor := func(db *DB, want ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !slices.Equal(names(db), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %v, got %v", want, names(db))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	// replicate streams from src to dst until the returned stop is called.
+	replicate := func(src, dst *DB) (stop func()) {
+		t.Helper()
+		state, err := dst.ReplicationState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		r, w := io.Pipe()
+		served, followed := make(chan error, 1), make(chan error, 1)
+		go func() {
+			served <- src.ServeReplication(ctx, state, w)
+			w.Close()
+		}()
+		go func() {
+			followed <- dst.Follow(r)
+		}()
+		return func() {
+			t.Helper()
+			cancel()
+			if err := <-served; !errors.Is(err, context.Canceled) {
+				t.Fatalf("unexpected serve error %v", err)
+			}
+			if err := <-followed; err != nil {
+				t.Fatalf("unexpected follow error %v", err)
+			}
+		}
+	}
+
+	insert(leader, "ada")
+	stop := replicate(leader, follower)
+	waitFor(follower, "ada")
+	insert(leader, "bob")
+	waitFor(follower, "ada", "bob")
+	var te *ThunderError
+	if _, err := follower.Begin(true); !errors.As(err, &te) || te.Code != ErrCodeReplicaReadOnly {
+		t.Fatalf("expected the follower to refuse writes, got %v", err)
+	}
+	stop()
+
+	// The leader fails after a commit the follower never received, and the
+	// follower takes over.
+	insert(leader, "eve")
+	if err := follower.Promote(); err != nil {
+		t.Fatal(err)
+	}
+	insert(follower, "dan")
+	state, err := follower.ReplicationState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Epoch != 1 {
+		t.Fatalf("expected epoch 1 after promotion, got %+v", state)
+	}
+
+	// The old leader rejoins as a follower and is resynchronized.
+	stop = replicate(follower, leader)
+	waitFor(leader, "ada", "bob", "dan")
+	insert(follower, "fay")
+	waitFor(leader, "ada", "bob", "dan", "fay")
+	stop()
+	if got, err := leader.ReplicationState(); err != nil || got.Epoch != 1 {
+		t.Fatalf("expected the old leader to adopt epoch 1, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,194 @@
+package thunder
+
+import (
+	"container/list"
+	"encoding/binary"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// resultCache is an LRU of DB.Select results. Entries of a relation are
+// dropped when a write transaction that touched it commits. Each relation
+// has a generation, bumped on invalidation, so that a result read before a
+// commit is not stored after the commit invalidated the relation.
+type resultCache struct {
+	mu      sync.Mutex
+	max     int
+	lru     *list.List
+	entries map[string]*list.Element
+	gens    map[string]uint64
+	hits    uint64
+	misses  uint64
+}
+
+type cachedResult struct {
+	key      string
+	relation string
+	rows     []map[string]any
+}
+
+// ResultCacheStats reports how DB.Select calls were answered.
+type ResultCacheStats struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+// EnableResultCache caches the results of up to entries distinct DB.Select
+// queries read at ConsistencyLatest, for read-mostly relations such as
+// configuration. A relation's cached results are dropped whenever a write to
+// it commits. Zero or less disables the cache.
+func (d *DB) EnableResultCache(entries int) {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+	if entries <= 0 {
+		d.cache = nil
+		return
+	}
+	d.cache = &resultCache{
+		max:     entries,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		gens:    make(map[string]uint64),
+	}
+}
+
+// ResultCacheStats returns the result cache counters, which are zero when
+// the cache is disabled.
+func (d *DB) ResultCacheStats() ResultCacheStats {
+	c := d.resultCache()
+	if c == nil {
+		return ResultCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ResultCacheStats{Entries: c.lru.Len(), Hits: c.hits, Misses: c.misses}
+}
+
+func (d *DB) resultCache() *resultCache {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+	return d.cache
+}
+
+// cachedSelect answers a DB.Select from the cache, or runs load and caches
+// its result.
+func (c *resultCache) cachedSelect(relation string, ranges map[string]*keyRange, load func() ([]map[string]any, error)) ([]map[string]any, error) {
+	key := resultCacheKey(relation, ranges)
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		c.hits++
+		rows := elem.Value.(*cachedResult).rows
+		c.mu.Unlock()
+		return cloneRows(rows), nil
+	}
+	c.misses++
+	gen := c.gens[relation]
+	c.mu.Unlock()
+
+	rows, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gens[relation] != gen {
+		return rows, nil
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return rows, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cachedResult{key: key, relation: relation, rows: cloneRows(rows)})
+	for c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResult).key)
+	}
+	return rows, nil
+}
+
+func (c *resultCache) invalidate(relations map[string]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for relation := range relations {
+		c.gens[relation]++
+	}
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cachedResult)
+		if _, ok := relations[entry.relation]; ok {
+			c.lru.Remove(elem)
+			delete(c.entries, entry.key)
+		}
+		elem = next
+	}
+}
+
+// resultCacheKey normalizes a query: ranges are keyed in column order and
+// every part is length-prefixed so distinct queries never collide.
+func resultCacheKey(relation string, ranges map[string]*keyRange) string {
+	var buf []byte
+	appendPart := func(b []byte) {
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+	appendPart([]byte(relation))
+	for _, name := range slices.Sorted(maps.Keys(ranges)) {
+		kr := ranges[name]
+		if kr == nil {
+			continue
+		}
+		appendPart([]byte(name))
+		appendPart(kr.startKey)
+		appendPart(kr.endKey)
+		buf = append(buf, boolByte(kr.includeStart), boolByte(kr.includeEnd))
+		buf = binary.AppendUvarint(buf, uint64(len(kr.excludes)))
+		for _, ex := range kr.excludes {
+			appendPart(ex)
+		}
+		appendPart(kr.distance)
+		buf = binary.AppendUvarint(buf, uint64(len(kr.all)))
+		for _, el := range kr.all {
+			appendPart(el)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(kr.anyOf)))
+		for _, group := range kr.anyOf {
+			buf = binary.AppendUvarint(buf, uint64(len(group)))
+			for _, el := range group {
+				appendPart(el)
+			}
+		}
+	}
+	return string(buf)
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// cloneRows copies rows so callers cannot modify the cached ones.
+func cloneRows(rows []map[string]any) []map[string]any {
+	if rows == nil {
+		return nil
+	}
+	out := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		out[i] = maps.Clone(row)
+	}
+	return out
+}
+
+// touch records that the transaction wrote to relation, so its cached
+// results are dropped when the transaction commits.
+func (tx *Tx) touch(relation string) {
+	if tx.touched == nil {
+		tx.touched = make(map[string]struct{})
+	}
+	tx.touched[relation] = struct{}{}
+}
@@ -0,0 +1,133 @@
+package thunder
+
+import (
+	"testing"
+)
+
+func TestDB_ResultCache(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.EnableResultCache(2)
+	insert := func(key, value string) {
+		t.Helper()
+		err := db.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent("config")
+			if err != nil {
+				return err
+			}
+			return p.Insert(map[string]any{"key": key, "value": value})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.update(func(tx *Tx) error {
+		if _, err := tx.CreatePersistent("config", map[string]ColumnSpec{
+			"key":   {Indexed: true},
+			"value": {},
+		}); err != nil {
+			return err
+		}
+		_, err := tx.CreatePersistent("other", map[string]ColumnSpec{"v": {}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert("color", "red")
+
+	ranges, err := ToKeyRanges(Eq("key", "color"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Select("config", ranges, ConsistencyLatest)
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %v, %v", rows, err)
+	}
+	// Callers may modify results without affecting the cache.
+	rows[0]["value"] = "changed"
+	rows, err = db.Select("config", ranges, ConsistencyLatest)
+	if err != nil || rows[0]["value"] != "red" {
+		t.Fatalf("expected cached red, got %v, %v", rows, err)
+	}
+	if stats := db.ResultCacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// Writes to other relations keep the entry.
+	if err := db.update(func(tx *Tx) error {
+		p, err := tx.LoadPersistent("other")
+		if err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"v": "x"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := db.ResultCacheStats(); stats.Entries != 1 {
+		t.Fatalf("expected the entry to survive, got %+v", stats)
+	}
+
+	// Writes to the relation drop it.
+	insert("color", "blue")
+	if stats := db.ResultCacheStats(); stats.Entries != 0 {
+		t.Fatalf("expected the entry to be dropped, got %+v", stats)
+	}
+	rows, err = db.Select("config", ranges, ConsistencyLatest)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected 2 rows after the write, got %v, %v", rows, err)
+	}
+
+	// The least recently used query is evicted beyond the limit.
+	for _, key := range []string{"a", "b"} {
+		r, err := ToKeyRanges(Eq("key", key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Select("config", r, ConsistencyLatest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := db.ResultCacheStats(); stats.Entries != 2 {
+		t.Fatalf("expected 2 entries, got %+v", stats)
+	}
+}
+
+func TestDB_ResultCacheContains(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.EnableResultCache(4)
+	err = db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("posts", map[string]ColumnSpec{"title": {}, "tags": {}})
+		if err != nil {
+			return err
+		}
+		if err := p.Insert(map[string]any{"title": "a", "tags": []any{"go"}}); err != nil {
+			return err
+		}
+		return p.Insert(map[string]any{"title": "b", "tags": []any{"rust"}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"go", "rust"} {
+		ranges, err := ToKeyRanges(Contains("tags", tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.Select("posts", ranges, ConsistencyLatest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"go": "a", "rust": "b"}[tag]
+		if len(rows) != 1 || rows[0]["title"] != want {
+			t.Errorf("expected post %s tagged %s, got %v", want, tag, rows)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package thunder
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"slices"
+	"time"
+)
+
+// defaultRetentionBatch is the number of rows EnforceRetention deletes per
+// transaction when a Retention leaves BatchSize unset.
+const defaultRetentionBatch = 1000
+
+// Retention keeps the rows of a relation whose Column, a time.Time or a Unix
+// nanosecond timestamp, lies within Keep of the database clock.
+type Retention struct {
+	Column string
+	Keep   time.Duration
+	// Partitioned drops whole partitions of a relation partitioned by
+	// Column, see Tx.Partitioned, instead of deleting rows one by one.
+	Partitioned bool
+	// BatchSize bounds the rows deleted per transaction, so that expiring
+	// a large backlog does not hold the write lock for long.
+	BatchSize int
+}
+
+// SetRetention sets the retention of relation, replacing any earlier one.
+// A zero Keep removes it. Rows are only removed by EnforceRetention and
+// RunRetention.
+func (d *DB) SetRetention(relation string, r Retention) {
+	d.retentionMu.Lock()
+	defer d.retentionMu.Unlock()
+	if r.Keep <= 0 {
+		delete(d.retention, relation)
+		return
+	}
+	if d.retention == nil {
+		d.retention = make(map[string]Retention)
+	}
+	d.retention[relation] = r
+}
+
+// EnforceRetention removes the rows that have fallen out of the retention of
+// every relation that has one, and the row versions beyond every history
+// horizon, and returns how many were removed.
+func (d *DB) EnforceRetention() (int, error) {
+	d.retentionMu.Lock()
+	policies := maps.Clone(d.retention)
+	horizons := maps.Clone(d.horizons)
+	d.retentionMu.Unlock()
+	total := 0
+	for _, relation := range slices.Sorted(maps.Keys(policies)) {
+		r := policies[relation]
+		cutoff := d.Now().Add(-r.Keep)
+		var n int
+		var err error
+		if r.Partitioned {
+			n, err = d.dropExpiredPartitions(relation, r.Column, cutoff)
+		} else {
+			n, err = d.deleteExpired(relation, r, cutoff)
+		}
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, relation := range slices.Sorted(maps.Keys(horizons)) {
+		n, err := d.collectHistory(relation, d.Now().Add(-horizons[relation]))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// RunRetention calls EnforceRetention every interval until ctx is done, then
+// returns ctx's error. Failed runs are logged and retried at the next tick.
+func (d *DB) RunRetention(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := d.EnforceRetention(); err != nil && d.logger != nil {
+				d.logger.Error("thunder: enforcing retention failed", "error", err)
+			}
+		}
+	}
+}
+
+func (d *DB) deleteExpired(relation string, r Retention, cutoff time.Time) (int, error) {
+	ranges, err := ToKeyRanges(Lt(r.Column, cutoff))
+	if err != nil {
+		return 0, err
+	}
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = defaultRetentionBatch
+	}
+	total := 0
+	for {
+		var n int
+		err := d.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(relation)
+			if err != nil {
+				return err
+			}
+			n, err = p.deleteBatch(ranges, batch)
+			return err
+		})
+		total += n
+		if err != nil || n < batch {
+			return total, err
+		}
+	}
+}
+
+// deleteBatch deletes at most limit rows matching ranges, whatever the
+// relation's policy.
+func (pr *Persistent) deleteBatch(ranges map[string]*keyRange, limit int) (int, error) {
+	iterEntries, err := pr.iterAll(ranges)
+	if err != nil {
+		return 0, err
+	}
+	var matched []entry
+	for e, err := range iterEntries {
+		if err != nil {
+			return 0, err
+		}
+		matched = append(matched, e)
+		if len(matched) == limit {
+			break
+		}
+	}
+	for _, e := range matched {
+		if err := pr.deleteEntry(e); err != nil {
+			return 0, err
+		}
+	}
+	return len(matched), nil
+}
+
+// dropExpiredPartitions drops the partitions of relation whose key lies
+// before cutoff and returns the number of rows they held.
+func (d *DB) dropExpiredPartitions(relation, column string, cutoff time.Time) (int, error) {
+	total := 0
+	err := d.update(func(tx *Tx) error {
+		p, err := tx.Partitioned(relation, column)
+		if err != nil {
+			return err
+		}
+		limit, err := p.spec.appendValue(nil, cutoff)
+		if err != nil {
+			return err
+		}
+		keys, err := p.keys()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if bytes.Compare(key, limit) >= 0 {
+				break
+			}
+			part, err := p.partition(key, false)
+			if err != nil {
+				return err
+			}
+			n, err := bucketKeyCount(part.data.bucket)
+			if err != nil {
+				return err
+			}
+			if err := p.dropKey(key); err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,96 @@
+package thunder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDB_EnforceRetention(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	start := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	db.SetClock(clock)
+	err := db.update(func(tx *Tx) error {
+		logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{
+			"at": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreatePersistent("days", map[string]ColumnSpec{
+			"day": {Type: TypeTime},
+			"msg": {},
+		}); err != nil {
+			return err
+		}
+		days, err := tx.Partitioned("days", "day")
+		if err != nil {
+			return err
+		}
+		for i := range 10 {
+			at := start.AddDate(0, 0, -i)
+			if err := logs.Insert(map[string]any{"at": at.UnixNano()}); err != nil {
+				return err
+			}
+			if err := days.Insert(map[string]any{"day": at, "msg": "a"}); err != nil {
+				return err
+			}
+			if err := days.Insert(map[string]any{"day": at, "msg": "b"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetRetention("logs", Retention{Column: "at", Keep: 72 * time.Hour, BatchSize: 2})
+	db.SetRetention("days", Retention{Column: "day", Keep: 72 * time.Hour, Partitioned: true})
+	n, err := db.EnforceRetention()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Days 4 to 9 back are expired: 6 log rows and 12 partitioned rows.
+	if n != 18 {
+		t.Fatalf("expected 18 rows removed, got %d", n)
+	}
+	err = db.view(func(tx *Tx) error {
+		days, err := tx.Partitioned("days", "day")
+		if err != nil {
+			return err
+		}
+		parts, err := days.Partitions()
+		if err != nil {
+			return err
+		}
+		if len(parts) != 4 {
+			t.Errorf("expected 4 partitions left, got %d", len(parts))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT * FROM logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 log rows left, got %d", len(rows))
+	}
+
+	db.SetRetention("days", Retention{})
+	clock.Advance(24 * time.Hour)
+	if n, err := db.EnforceRetention(); err != nil || n != 1 {
+		t.Fatalf("expected 1 more log row removed, got %d, %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := db.RunRetention(ctx, time.Millisecond); err != context.Canceled {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package thunder
+
+import (
+	"iter"
+	"maps"
+)
+
+// DeleteReturning deletes the rows matching ranges, like Delete, and returns
+// the deleted rows as Select would have returned them. The rows are collected
+// while deleting, so the sequence does not depend on reading them back.
+func (pr *Persistent) DeleteReturning(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	var rows []map[string]any
+	masked := pr.maskedFor()
+	_, err := pr.observeWrite(SpanDelete, ranges, func() (int, error) {
+		return pr.deleteMatching(ranges, func(e entry) error {
+			row := maps.Clone(e.value)
+			if err := pr.readRow(row, masked, nil); err != nil {
+				return err
+			}
+			rows = append(rows, ownedRow(pr.maUn, row))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// UpdateReturning updates the rows matching ranges, like Update, and returns
+// every updated row as it was before and after the update, read as Select
+// reads rows.
+func (pr *Persistent) UpdateReturning(ranges map[string]*keyRange, changes map[string]any) (iter.Seq2[map[string]any, map[string]any], error) {
+	var befores, afters []map[string]any
+	_, err := pr.observeWrite(SpanUpdate, ranges, func() (int, error) {
+		return pr.update(ranges, changes, func(before, after map[string]any) {
+			befores = append(befores, before)
+			afters = append(afters, after)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(map[string]any, map[string]any) bool) {
+		for i, before := range befores {
+			if !yield(before, afters[i]) {
+				return
+			}
+		}
+	}, nil
+}
+
+// DeleteDryRun returns the rows Delete would remove for ranges, read as
+// DeleteReturning returns them, without removing them. Nothing is written,
+// so it also works in read-only transactions; hooks do not run and no
+// metrics or spans are recorded.
+func (pr *Persistent) DeleteDryRun(ranges map[string]*keyRange) (iter.Seq2[map[string]any, error], error) {
+	iterEntries, err := pr.iter(ranges)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	masked := pr.maskedFor()
+	for e, err := range iterEntries {
+		if err != nil {
+			return nil, err
+		}
+		row := maps.Clone(e.value)
+		if err := pr.readRow(row, masked, nil); err != nil {
+			return nil, err
+		}
+		rows = append(rows, ownedRow(pr.maUn, row))
+	}
+	return func(yield func(map[string]any, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// UpdateDryRun returns the rows Update would change, before and after the
+// update, without changing them. Like DeleteDryRun it writes nothing and
+// runs no hooks, but it runs the checks Update runs, so version, policy,
+// unique and validation failures are reported as Update would report them.
+// Capacity limits are not checked.
+func (pr *Persistent) UpdateDryRun(ranges map[string]*keyRange, changes map[string]any) (iter.Seq2[map[string]any, map[string]any], error) {
+	matched, change, err := pr.matchUpdate(ranges, changes)
+	if err != nil {
+		return nil, err
+	}
+	// Rows the update has reached no longer hold their old unique keys, and
+	// hold the new ones instead.
+	moved := make(map[[8]byte]bool, len(matched))
+	claimed := make(map[string]bool)
+	claim := func(name string, key []byte) error {
+		if claimed[name+"\x00"+string(key)] {
+			return ErrUniqueConstraint(name, key)
+		}
+		claimed[name+"\x00"+string(key)] = true
+		return nil
+	}
+	var befores, afters []map[string]any
+	for _, e := range matched {
+		before, after, err := change(e)
+		if err != nil {
+			return nil, err
+		}
+		if after, err = pr.applyGenerated(after); err != nil {
+			return nil, err
+		}
+		moved[e.id] = true
+		stored, value, err := pr.prepareRow(maps.Clone(after), func(id [8]byte) bool { return moved[id] })
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range pr.uniqueNames {
+			for _, key := range value[name] {
+				if len(key) == 0 {
+					continue
+				}
+				if err := claim(name, key); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, su := range pr.shared {
+			key, err := pr.sharedKey(su, stored)
+			if err != nil {
+				return nil, err
+			}
+			if err := claim(su.name, key); err != nil {
+				return nil, err
+			}
+		}
+		befores = append(befores, pr.returnedRow(before))
+		afters = append(afters, pr.returnedRow(after))
+	}
+	return func(yield func(map[string]any, map[string]any) bool) {
+		for i, before := range befores {
+			if !yield(before, afters[i]) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,303 @@
+package thunder
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestPersistent_DeleteAndUpdateReturning(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"role": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"id": "1", "role": "admin"},
+		{"id": "2", "role": "user"},
+		{"id": "3", "role": "user"},
+	} {
+		if err := users.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	regular, err := ToKeyRanges(Eq("role", "user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := users.UpdateReturning(regular, map[string]any{"role": "guest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for before, after := range updated {
+		n++
+		if before["role"] != "user" || after["role"] != "guest" || before["id"] != after["id"] {
+			t.Errorf("unexpected update %v -> %v", before, after)
+		}
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 updated rows, got %d", n)
+	}
+
+	guests, err := ToKeyRanges(Eq("role", "guest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := users.DeleteReturning(guests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[any]bool{}
+	for row, err := range deleted {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[row["id"]] = true
+	}
+	if len(ids) != 2 || !ids["2"] || !ids["3"] {
+		t.Fatalf("expected rows 2 and 3 deleted, got %v", ids)
+	}
+	rest, err := users.Select(guests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := range rest {
+		t.Fatalf("expected guests deleted, found %v", row)
+	}
+}
+
+func TestPersistent_DryRun(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"role": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"id": "1", "role": "admin"},
+		{"id": "2", "role": "user"},
+		{"id": "3", "role": "user"},
+	} {
+		if err := users.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func(ops ...Op) int {
+		t.Helper()
+		ranges, err := ToKeyRanges(ops...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := users.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			n++
+		}
+		return n
+	}
+
+	regular, err := ToKeyRanges(Eq("role", "user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := users.DeleteDryRun(regular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for row, err := range deleted {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row["role"] != "user" {
+			t.Errorf("unexpected row %v", row)
+		}
+		n++
+	}
+	if n != 2 || count(Eq("role", "user")) != 2 {
+		t.Fatalf("expected 2 rows reported and kept, got %d reported", n)
+	}
+
+	updated, err := users.UpdateDryRun(regular, map[string]any{"role": "guest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for before, after := range updated {
+		if before["role"] != "user" || after["role"] != "guest" {
+			t.Errorf("unexpected update %v -> %v", before, after)
+		}
+		n++
+	}
+	if n != 2 || count(Eq("role", "guest")) != 0 || count(Eq("role", "user")) != 2 {
+		t.Fatalf("expected 2 rows reported and none changed, got %d reported", n)
+	}
+	if _, err := users.UpdateDryRun(regular, map[string]any{"id": "1"}); err == nil {
+		t.Fatal("expected the unique violation the update would hit")
+	}
+	if count() != 3 {
+		t.Fatal("expected every row kept after a failed dry run")
+	}
+}
+
+func TestPersistent_ReturningReadsAsSelect(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	people, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+		"id":      {Unique: true},
+		"ssn":     {},
+		"balance": {Type: TypeDecimal},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := people.MaskColumn("ssn", "support"); err != nil {
+		t.Fatal(err)
+	}
+	if err := people.Insert(map[string]any{"id": "1", "ssn": "123-45", "balance": big.NewRat(3, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	tx.SetIdentity("support")
+	check := func(what string, row map[string]any) {
+		t.Helper()
+		if row["ssn"] != Redacted {
+			t.Errorf("%s: expected ssn redacted, got %v", what, row["ssn"])
+		}
+		if b, ok := row["balance"].(*big.Rat); !ok || b.Cmp(big.NewRat(3, 2)) != 0 {
+			t.Errorf("%s: expected the balance as a decimal, got %#v", what, row["balance"])
+		}
+	}
+	deleted, err := people.DeleteDryRun(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row, err := range deleted {
+		if err != nil {
+			t.Fatal(err)
+		}
+		check("DeleteDryRun", row)
+	}
+	updated, err := people.UpdateReturning(nil, map[string]any{"id": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for before, after := range updated {
+		check("UpdateReturning before", before)
+		check("UpdateReturning after", after)
+	}
+}
+
+func TestPersistent_DryRunReadOnly(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	err := db.update(func(tx *Tx) error {
+		users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+			"id":   {Unique: true},
+			"role": {Indexed: true},
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range []map[string]any{
+			{"id": "1", "role": "admin"},
+			{"id": "2", "role": "user"},
+		} {
+			if err := users.Insert(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fired := 0
+	hook := func(tx *Tx, row map[string]any) error {
+		fired++
+		return nil
+	}
+	db.SetHooks("users", RelationHooks{BeforeInsert: hook, AfterInsert: hook, BeforeDelete: hook, AfterDelete: hook})
+
+	err = db.view(func(tx *Tx) error {
+		users, err := tx.LoadPersistent("users")
+		if err != nil {
+			return err
+		}
+		all, err := ToKeyRanges()
+		if err != nil {
+			return err
+		}
+		deleted, err := users.DeleteDryRun(all)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, err := range deleted {
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		if n != 2 {
+			t.Errorf("expected 2 rows reported deleted, got %d", n)
+		}
+		updated, err := users.UpdateDryRun(all, map[string]any{"role": "guest"})
+		if err != nil {
+			return err
+		}
+		n = 0
+		for before, after := range updated {
+			if after["role"] != "guest" || after["id"] != before["id"] {
+				t.Errorf("unexpected update %v -> %v", before, after)
+			}
+			n++
+		}
+		if n != 2 {
+			t.Errorf("expected 2 rows reported updated, got %d", n)
+		}
+		var te *ThunderError
+		if _, err := users.UpdateDryRun(all, map[string]any{"id": "3"}); !errors.As(err, &te) || te.Code != ErrCodeUniqueConstraint {
+			t.Errorf("expected the rows to collide on the new id, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fired != 0 {
+		t.Errorf("expected no hooks run, got %d calls", fired)
+	}
+}
@@ -0,0 +1,80 @@
+// Package s3sink stores thunder backups in an Amazon S3 (or S3 compatible)
+// bucket.
+package s3sink
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/longlodw/thunder"
+)
+
+// Client is the subset of the S3 API used by Sink; *s3.Client implements it.
+type Client interface {
+	transfermanager.S3APIClient
+	DeleteObject(context.Context, *s3.DeleteObjectInput, ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// Sink is a thunder.BackupSink writing each backup as an object under Prefix
+// in Bucket. Large backups are uploaded in parts, so they are streamed
+// without being buffered whole.
+type Sink struct {
+	client   Client
+	uploader *transfermanager.Client
+	bucket   string
+	prefix   string
+}
+
+var _ thunder.BackupSink = (*Sink)(nil)
+
+// New returns a Sink storing backups in bucket under prefix.
+func New(client Client, bucket, prefix string) *Sink {
+	return &Sink{
+		client:   client,
+		uploader: transfermanager.New(client),
+		bucket:   bucket,
+		prefix:   prefix,
+	}
+}
+
+func (s *Sink) Put(ctx context.Context, name string, r io.Reader) error {
+	_, err := s.uploader.UploadObject(ctx, &transfermanager.UploadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+		Body:   r,
+	})
+	return err
+}
+
+func (s *Sink) List(ctx context.Context) ([]string, error) {
+	var names []string
+	p := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), s.prefix)
+			if !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+func (s *Sink) Delete(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+	return err
+}
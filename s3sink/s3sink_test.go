@@ -0,0 +1,79 @@
+package s3sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 keeps objects in memory. Backups in this test are small enough to
+// be uploaded with a single PutObject, so multipart calls are not needed.
+type fakeS3 struct {
+	Client
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &s3.ListObjectsV2Output{}
+	prefix := aws.ToString(in.Bucket) + "/" + aws.ToString(in.Prefix)
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(strings.TrimPrefix(k, aws.ToString(in.Bucket)+"/"))})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, aws.ToString(in.Bucket)+"/"+aws.ToString(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestSink(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	sink := New(fake, "backups", "prod/")
+	ctx := context.Background()
+	if err := sink.Put(ctx, "thunder-a", bytes.NewReader([]byte("full"))); err != nil {
+		t.Fatal(err)
+	}
+	if string(fake.objects["backups/prod/thunder-a"]) != "full" {
+		t.Fatalf("unexpected objects %v", fake.objects)
+	}
+	fake.objects["backups/other/thunder-b"] = nil
+	names, err := sink.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "thunder-a" {
+		t.Fatalf("expected [thunder-a], got %v", names)
+	}
+	if err := sink.Delete(ctx, "thunder-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.objects["backups/prod/thunder-a"]; ok {
+		t.Fatal("expected object to be deleted")
+	}
+}
@@ -0,0 +1,34 @@
+package thunder
+
+import "math/rand/v2"
+
+// Sample returns up to n rows chosen uniformly at random among those
+// matching every op, in no particular order. It reads every matching row
+// once, keeping only the sample in memory.
+func (pr *Persistent) Sample(n int, ops ...Op) ([]map[string]any, error) {
+	ranges, err := ToKeyRanges(ops...)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := pr.Select(ranges)
+	if err != nil {
+		return nil, err
+	}
+	sample := make([]map[string]any, 0, max(n, 0))
+	seen := 0
+	for row, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		seen++
+		if len(sample) < n {
+			sample = append(sample, row)
+			continue
+		}
+		// Reservoir sampling: keep each row with probability n/seen.
+		if slot := rand.IntN(seen); slot < n {
+			sample[slot] = row
+		}
+	}
+	return sample, nil
+}
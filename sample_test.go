@@ -0,0 +1,60 @@
+package thunder
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPersistent_Sample(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	items, err := tx.CreatePersistent("items", map[string]ColumnSpec{
+		"n": {Indexed: true, Type: TypeInt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 100 {
+		if err := items.Insert(map[string]any{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Over many samples every matching row turns up.
+	hits := make(map[string]int)
+	for range 100 {
+		sample, err := items.Sample(10, Ge("n", 50))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sample) != 10 {
+			t.Fatalf("expected 10 rows, got %d", len(sample))
+		}
+		seen := make(map[string]bool)
+		for _, row := range sample {
+			key := fmt.Sprint(row["n"])
+			if seen[key] {
+				t.Fatalf("row %s sampled twice", key)
+			}
+			seen[key] = true
+			hits[key]++
+		}
+	}
+	if len(hits) != 50 {
+		t.Fatalf("expected all 50 matching rows to be sampled, got %d", len(hits))
+	}
+	for key := range hits {
+		var n int
+		fmt.Sscan(key, &n)
+		if n < 50 {
+			t.Fatalf("sampled row %d outside the range", n)
+		}
+	}
+	if sample, err := items.Sample(500); err != nil || len(sample) != 100 {
+		t.Fatalf("expected every row when n exceeds the matches, got %d, %v", len(sample), err)
+	}
+}
@@ -0,0 +1,476 @@
+package thunder
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"slices"
+
+	boltdb_errors "github.com/openkvlab/boltdb/errors"
+)
+
+// Savepoint marks a point in a write transaction that RollbackTo returns to.
+type Savepoint struct {
+	tx        *Tx
+	undo      int
+	events    int
+	commitSeq uint64
+	touched   map[string]struct{}
+}
+
+// Savepoint marks the current state of a write transaction. RollbackTo undoes
+// every write made after it, across all relations, while keeping the
+// transaction open. Writes are journaled from the first savepoint until the
+// last one is released or the transaction ends.
+func (tx *Tx) Savepoint() (*Savepoint, error) {
+	if tx.finished {
+		return nil, boltdb_errors.ErrTxClosed
+	}
+	if err := tx.checkWritable(); err != nil {
+		return nil, err
+	}
+	return tx.savepoint(), nil
+}
+
+// savepoint takes a savepoint without checking the transaction, so that
+// writes to ephemeral relations can use one in read transactions.
+func (tx *Tx) savepoint() *Savepoint {
+	sp := &Savepoint{
+		tx:        tx,
+		undo:      len(tx.journal.entries),
+		events:    len(tx.events),
+		commitSeq: tx.commitSeq,
+		touched:   maps.Clone(tx.touched),
+	}
+	tx.savepoints = append(tx.savepoints, sp)
+	tx.journal.recording = true
+	return sp
+}
+
+// RollbackTo undoes the writes made since sp. sp stays valid and can be
+// rolled back to again; savepoints taken after it are released. Relations
+// loaded in the transaction read their schema, capacity, pending indexes
+// and masks again, so they match the reverted state.
+func (tx *Tx) RollbackTo(sp *Savepoint) error {
+	i, err := tx.savepointIndex(sp)
+	if err != nil {
+		return err
+	}
+	if err := tx.journal.undo(sp.undo); err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+	tx.events = tx.events[:sp.events]
+	tx.commitSeq = sp.commitSeq
+	tx.touched = maps.Clone(sp.touched)
+	for _, pr := range tx.loaded {
+		if err := pr.reload(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release discards sp and the savepoints taken after it, keeping their
+// writes.
+func (tx *Tx) Release(sp *Savepoint) error {
+	i, err := tx.savepointIndex(sp)
+	if err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i]
+	if len(tx.savepoints) == 0 {
+		tx.journal.entries = nil
+		tx.journal.recording = false
+	}
+	return nil
+}
+
+func (tx *Tx) savepointIndex(sp *Savepoint) (int, error) {
+	if tx.finished {
+		return 0, boltdb_errors.ErrTxClosed
+	}
+	i := slices.Index(tx.savepoints, sp)
+	if i < 0 {
+		return 0, ErrSavepointReleased()
+	}
+	return i, nil
+}
+
+type undoKind uint8
+
+const (
+	// undoPut restores a key to value, or deletes it if it did not exist.
+	undoPut undoKind = iota
+	// undoCreate deletes a created bucket.
+	undoCreate
+	// undoDelete recreates a deleted bucket from its copy.
+	undoDelete
+	// undoSequence restores the sequence of a bucket.
+	undoSequence
+)
+
+// undoEntry reverts one write to root. path names the bucket the write went
+// to, or the top level when empty.
+type undoEntry struct {
+	kind    undoKind
+	root    BackendTx
+	path    [][]byte
+	key     []byte
+	value   []byte
+	existed bool
+	copy    *bucketCopy
+	seq     uint64
+}
+
+// bucketCopy is the content of a bucket and its nested buckets.
+type bucketCopy struct {
+	name     []byte
+	seq      uint64
+	keys     [][]byte
+	values   [][]byte
+	children []*bucketCopy
+}
+
+// journal records how to revert the writes of a transaction while it has
+// savepoints. Buckets handed out before a rollback may refer to buckets it
+// recreated, so they look their bucket up again once gen changes.
+type journal struct {
+	entries   []undoEntry
+	recording bool
+	gen       int
+}
+
+func (j *journal) record(e undoEntry) {
+	j.entries = append(j.entries, e)
+}
+
+// undo reverts the entries after n, newest first.
+func (j *journal) undo(n int) error {
+	defer func() { j.gen++ }()
+	for i := len(j.entries) - 1; i >= n; i-- {
+		if err := j.revert(j.entries[i]); err != nil {
+			return err
+		}
+		j.entries = j.entries[:i]
+	}
+	return nil
+}
+
+func (j *journal) revert(e undoEntry) error {
+	switch e.kind {
+	case undoPut:
+		b := resolveBucket(e.root, e.path)
+		if b == nil {
+			return boltdb_errors.ErrBucketNotFound
+		}
+		if e.existed {
+			return b.Put(e.key, e.value)
+		}
+		return b.Delete(e.key)
+	case undoCreate:
+		if len(e.path) == 0 {
+			return e.root.DeleteBucket(e.key)
+		}
+		b := resolveBucket(e.root, e.path)
+		if b == nil {
+			return boltdb_errors.ErrBucketNotFound
+		}
+		return b.DeleteBucket(e.key)
+	case undoDelete:
+		var created BackendBucket
+		var err error
+		if len(e.path) == 0 {
+			created, err = e.root.CreateBucket(e.key)
+		} else if b := resolveBucket(e.root, e.path); b == nil {
+			err = boltdb_errors.ErrBucketNotFound
+		} else {
+			created, err = b.CreateBucket(e.key)
+		}
+		if err != nil {
+			return err
+		}
+		return restoreBucket(created, e.copy)
+	default:
+		b := resolveBucket(e.root, e.path)
+		if b == nil {
+			return boltdb_errors.ErrBucketNotFound
+		}
+		return b.SetSequence(e.seq)
+	}
+}
+
+func resolveBucket(root BackendTx, path [][]byte) BackendBucket {
+	b := root.Bucket(path[0])
+	for _, name := range path[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
+
+func snapshotBucket(name []byte, b BackendBucket) (*bucketCopy, error) {
+	c := &bucketCopy{name: bytes.Clone(name), seq: b.Sequence()}
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if child := b.Bucket(k); child != nil {
+				cc, err := snapshotBucket(k, child)
+				c.children = append(c.children, cc)
+				return err
+			}
+		}
+		c.keys = append(c.keys, bytes.Clone(k))
+		c.values = append(c.values, bytes.Clone(v))
+		return nil
+	})
+	return c, err
+}
+
+func restoreBucket(b BackendBucket, c *bucketCopy) error {
+	for i, k := range c.keys {
+		if err := b.Put(k, c.values[i]); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.children {
+		nb, err := b.CreateBucket(child.name)
+		if err != nil {
+			return err
+		}
+		if err := restoreBucket(nb, child); err != nil {
+			return err
+		}
+	}
+	return b.SetSequence(c.seq)
+}
+
+// journalTx is a BackendTx journaling its writes for savepoints.
+type journalTx struct {
+	BackendTx
+	j *journal
+}
+
+func (t *journalTx) wrap(path [][]byte, b BackendBucket) BackendBucket {
+	if b == nil {
+		return nil
+	}
+	return &journalBucket{j: t.j, root: t.BackendTx, path: path, b: b, gen: t.j.gen}
+}
+
+func (t *journalTx) Bucket(name []byte) BackendBucket {
+	return t.wrap([][]byte{bytes.Clone(name)}, t.BackendTx.Bucket(name))
+}
+
+func (t *journalTx) CreateBucket(name []byte) (BackendBucket, error) {
+	b, err := t.BackendTx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if t.j.recording {
+		t.j.record(undoEntry{kind: undoCreate, root: t.BackendTx, key: bytes.Clone(name)})
+	}
+	return t.wrap([][]byte{bytes.Clone(name)}, b), nil
+}
+
+func (t *journalTx) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	if b := t.Bucket(name); b != nil {
+		return b, nil
+	}
+	return t.CreateBucket(name)
+}
+
+func (t *journalTx) DeleteBucket(name []byte) error {
+	var c *bucketCopy
+	if t.j.recording {
+		if b := t.BackendTx.Bucket(name); b != nil {
+			var err error
+			if c, err = snapshotBucket(name, b); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.BackendTx.DeleteBucket(name); err != nil {
+		return err
+	}
+	if c != nil {
+		t.j.record(undoEntry{kind: undoDelete, root: t.BackendTx, key: c.name, copy: c})
+	}
+	return nil
+}
+
+func (t *journalTx) ForEach(fn func(name []byte, b BackendBucket) error) error {
+	return t.BackendTx.ForEach(func(name []byte, b BackendBucket) error {
+		return fn(name, t.wrap([][]byte{bytes.Clone(name)}, b))
+	})
+}
+
+// WriteTo lets snapshot backups reach the journaled transaction.
+func (t *journalTx) WriteTo(w io.Writer) (int64, error) {
+	wt, ok := t.BackendTx.(io.WriterTo)
+	if !ok {
+		return 0, ErrBackendUnsupported("snapshot backups")
+	}
+	return wt.WriteTo(w)
+}
+
+// journalBucket is a BackendBucket journaling its writes for savepoints.
+type journalBucket struct {
+	j    *journal
+	root BackendTx
+	path [][]byte
+	b    BackendBucket
+	gen  int
+}
+
+func (b *journalBucket) bucket() BackendBucket {
+	if b.gen != b.j.gen {
+		if found := resolveBucket(b.root, b.path); found != nil {
+			b.b = found
+		}
+		b.gen = b.j.gen
+	}
+	return b.b
+}
+
+func (b *journalBucket) child(name []byte, c BackendBucket) BackendBucket {
+	if c == nil {
+		return nil
+	}
+	path := append(slices.Clip(b.path), bytes.Clone(name))
+	return &journalBucket{j: b.j, root: b.root, path: path, b: c, gen: b.j.gen}
+}
+
+func (b *journalBucket) Get(key []byte) []byte {
+	return b.bucket().Get(key)
+}
+
+func (b *journalBucket) Put(key, value []byte) error {
+	return b.write(key, func(raw BackendBucket) error { return raw.Put(key, value) })
+}
+
+func (b *journalBucket) Delete(key []byte) error {
+	return b.write(key, func(raw BackendBucket) error { return raw.Delete(key) })
+}
+
+func (b *journalBucket) write(key []byte, fn func(BackendBucket) error) error {
+	raw := b.bucket()
+	if !b.j.recording {
+		return fn(raw)
+	}
+	old, existed := lookup(raw, key)
+	e := undoEntry{kind: undoPut, root: b.root, path: b.path, key: bytes.Clone(key), value: bytes.Clone(old), existed: existed}
+	if err := fn(raw); err != nil {
+		return err
+	}
+	b.j.record(e)
+	return nil
+}
+
+// lookup reads key from b. Get cannot tell a missing key from an empty
+// value, which every index entry has, so a miss is confirmed with a cursor.
+func lookup(b BackendBucket, key []byte) ([]byte, bool) {
+	if v := b.Get(key); v != nil {
+		return v, true
+	}
+	k, _ := b.Cursor().Seek(key)
+	return nil, k != nil && bytes.Equal(k, key)
+}
+
+func (b *journalBucket) Cursor() BackendCursor {
+	return b.bucket().Cursor()
+}
+
+func (b *journalBucket) ForEach(fn func(k, v []byte) error) error {
+	return b.bucket().ForEach(fn)
+}
+
+func (b *journalBucket) ForEachBucket(fn func(name []byte) error) error {
+	return b.bucket().ForEachBucket(fn)
+}
+
+func (b *journalBucket) Bucket(name []byte) BackendBucket {
+	return b.child(name, b.bucket().Bucket(name))
+}
+
+func (b *journalBucket) CreateBucket(name []byte) (BackendBucket, error) {
+	c, err := b.bucket().CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if b.j.recording {
+		b.j.record(undoEntry{kind: undoCreate, root: b.root, path: b.path, key: bytes.Clone(name)})
+	}
+	return b.child(name, c), nil
+}
+
+func (b *journalBucket) CreateBucketIfNotExists(name []byte) (BackendBucket, error) {
+	if c := b.Bucket(name); c != nil {
+		return c, nil
+	}
+	return b.CreateBucket(name)
+}
+
+func (b *journalBucket) DeleteBucket(name []byte) error {
+	raw := b.bucket()
+	var c *bucketCopy
+	if b.j.recording {
+		if child := raw.Bucket(name); child != nil {
+			var err error
+			if c, err = snapshotBucket(name, child); err != nil {
+				return err
+			}
+		}
+	}
+	if err := raw.DeleteBucket(name); err != nil {
+		return err
+	}
+	if c != nil {
+		b.j.record(undoEntry{kind: undoDelete, root: b.root, path: b.path, key: c.name, copy: c})
+	}
+	return nil
+}
+
+func (b *journalBucket) Sequence() uint64 {
+	return b.bucket().Sequence()
+}
+
+func (b *journalBucket) SetSequence(v uint64) error {
+	raw := b.bucket()
+	old := raw.Sequence()
+	if err := raw.SetSequence(v); err != nil {
+		return err
+	}
+	b.recordSequence(old)
+	return nil
+}
+
+func (b *journalBucket) NextSequence() (uint64, error) {
+	raw := b.bucket()
+	old := raw.Sequence()
+	v, err := raw.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	b.recordSequence(old)
+	return v, nil
+}
+
+func (b *journalBucket) recordSequence(old uint64) {
+	if b.j.recording {
+		b.j.record(undoEntry{kind: undoSequence, root: b.root, path: b.path, seq: old})
+	}
+}
+
+// KeyCount and Bytes defer to the wrapped bucket, walking it when it cannot
+// report its size.
+func (b *journalBucket) KeyCount() int {
+	n, _ := bucketKeyCount(b.bucket())
+	return n
+}
+
+func (b *journalBucket) Bytes() int64 {
+	n, _ := bucketBytes(b.bucket())
+	return n
+}
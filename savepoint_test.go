@@ -0,0 +1,204 @@
+package thunder
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTx_SavepointRollbackTo(t *testing.T) {
+	db, err := OpenDB(&MsgpackMaUn, filepath.Join(t.TempDir(), "savepoint.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"name": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "1", "name": "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	sp, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "2", "name": "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("orders", map[string]ColumnSpec{"id": {}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.CreateIndex("by_name", []string{"name"}, false); err != nil {
+		t.Fatal(err)
+	}
+	inner, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DropRelation("users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(sp); err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	if err := tx.RollbackTo(inner); !errors.As(err, &te) || te.Code != ErrCodeSavepointReleased {
+		t.Fatalf("expected savepoint released, got %v", err)
+	}
+
+	names, err := tx.Relations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "users" {
+		t.Fatalf("expected only users after rollback, got %v", names)
+	}
+	// The index was rolled back too, so the relation is loaded again.
+	if users, err = tx.LoadPersistent("users"); err != nil {
+		t.Fatal(err)
+	}
+	seq, err := tx.Query("SELECT * FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 user, got %d", n)
+	}
+	if err := users.Insert(map[string]any{"id": "2", "name": "bea"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT name FROM users WHERE id = '2'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["name"] != "bea" {
+		t.Fatalf("expected bea, got %v", rows)
+	}
+}
+
+func TestTx_SavepointRestoresIndexEntries(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"name": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"id": "1", "name": "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	sp, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Index entries have empty values, which must still be put back.
+	if err := users.Delete(map[string]*keyRange{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(sp); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Release(sp); err != nil {
+		t.Fatal(err)
+	}
+	report, err := users.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 1 || !report.OK() {
+		t.Errorf("expected the row and its index entries restored, got %+v", report)
+	}
+	var te *ThunderError
+	if err := users.Insert(map[string]any{"id": "1", "name": "bob"}); !errors.As(err, &te) || te.Code != ErrCodeUniqueConstraint {
+		t.Errorf("expected the unique entry restored, got %v", err)
+	}
+}
+
+func TestTx_SavepointReloadsRelations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logs, err := tx.CreatePersistent("logs", map[string]ColumnSpec{"seq": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if logs, err = tx.LoadPersistent("logs"); err != nil {
+		t.Fatal(err)
+	}
+	sp, err := tx.Savepoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.SetCap(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.Insert(map[string]any{"seq": int64(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(sp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tx.touched["logs"]; ok {
+		t.Error("expected the rolled back write not to mark logs as touched")
+	}
+	// The cap was rolled back, so the same handle keeps every row.
+	for i := range 3 {
+		if err := logs.Insert(map[string]any{"seq": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := logs.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range rows {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 rows without the cap, got %d", n)
+	}
+}
@@ -0,0 +1,220 @@
+package thunder
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoder serializes the rows written by SelectTo.
+type Encoder interface {
+	// Begin is called once, before the first row, with the relation's
+	// columns in sorted order.
+	Begin(w io.Writer, columns []string) error
+	Encode(w io.Writer, row map[string]any) error
+	// End is called once after the last row.
+	End(w io.Writer) error
+}
+
+// RawEncoder is an Encoder that can also copy rows in the database's stored
+// encoding straight to the output. SelectTo uses it, without decoding rows,
+// when nothing about the stored rows has to be checked or transformed.
+type RawEncoder interface {
+	Encoder
+	// EncodesRaw reports whether rows stored with maUn can be written with
+	// EncodeRaw.
+	EncodesRaw(maUn MarshalUnmarshaler) bool
+	EncodeRaw(w io.Writer, raw []byte) error
+}
+
+// SelectTo writes the rows matching ranges to w with enc and returns how many
+// were written. Rows are streamed as they are read rather than collected
+// first. Output is buffered and flushed before SelectTo returns.
+func (pr *Persistent) SelectTo(w io.Writer, enc Encoder, ranges map[string]*keyRange) (int, error) {
+	ranges, err := pr.restrict(ranges)
+	if err != nil {
+		return 0, err
+	}
+	ranges = pr.coerceRanges(ranges)
+	plan, err := pr.plan(ranges, queryOptions{})
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(w)
+	columns := slices.Sorted(slices.Values(pr.columns))
+	if err := enc.Begin(bw, columns); err != nil {
+		return 0, err
+	}
+	n := 0
+	if raw, ok := pr.rawRows(enc, ranges, plan); ok {
+		for v, err := range raw {
+			if err != nil {
+				return n, err
+			}
+			if err := enc.(RawEncoder).EncodeRaw(bw, v); err != nil {
+				return n, err
+			}
+			n++
+		}
+	} else {
+		rows, err := pr.selectPlan(ranges, plan, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		for row, err := range rows {
+			if err != nil {
+				return n, err
+			}
+			if err := enc.Encode(bw, row); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	if err := enc.End(bw); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// rawRows returns the stored bytes of the matching rows when enc can write
+// them as they are: the rows need no decryption, validation or masking, and
+// the ranges are fully answered by the plan's index, or absent.
+func (pr *Persistent) rawRows(enc Encoder, ranges map[string]*keyRange, plan QueryPlan) (func(yield func([]byte, error) bool), bool) {
+	raw, ok := enc.(RawEncoder)
+	if !ok || !raw.EncodesRaw(pr.maUn) || pr.encrypted || pr.encoded || pr.typed || pr.data.recoded != nil || len(pr.maskedFor()) > 0 {
+		return nil, false
+	}
+	switch {
+	case len(ranges) == 0 && plan.Index == "":
+		return func(yield func([]byte, error) bool) {
+			c := pr.data.bucket.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if !yield(v, nil) {
+					return
+				}
+			}
+		}, true
+	case plan.Index != "" && len(ranges) == 1 && ranges[plan.Index] != nil && !pr.fields[plan.Index].MultiEntry:
+		ids, err := pr.indexes.get(plan.Index, ranges[plan.Index], plan.Descending)
+		if err != nil {
+			return nil, false
+		}
+		return func(yield func([]byte, error) bool) {
+			for id := range ids {
+				v := pr.data.bucket.Get(id[:])
+				if v == nil {
+					if !yield(nil, ErrCorruptedIndexEntry(plan.Index)) {
+						return
+					}
+					continue
+				}
+				if !yield(v, nil) {
+					return
+				}
+			}
+		}, true
+	}
+	return nil, false
+}
+
+// NDJSONEncoder writes one JSON object per line, like Export.
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) Begin(w io.Writer, columns []string) error { return nil }
+
+func (NDJSONEncoder) Encode(w io.Writer, row map[string]any) error {
+	return json.NewEncoder(w).Encode(row)
+}
+
+func (NDJSONEncoder) End(w io.Writer) error { return nil }
+
+func (NDJSONEncoder) EncodesRaw(maUn MarshalUnmarshaler) bool {
+	_, ok := maUn.(*jsonMarshalUnmarshaler)
+	return ok
+}
+
+func (NDJSONEncoder) EncodeRaw(w io.Writer, raw []byte) error {
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// MsgpackEncoder writes a stream of concatenated msgpack maps.
+type MsgpackEncoder struct{}
+
+func (MsgpackEncoder) Begin(w io.Writer, columns []string) error { return nil }
+
+func (MsgpackEncoder) Encode(w io.Writer, row map[string]any) error {
+	return msgpack.NewEncoder(w).Encode(row)
+}
+
+func (MsgpackEncoder) End(w io.Writer) error { return nil }
+
+func (MsgpackEncoder) EncodesRaw(maUn MarshalUnmarshaler) bool {
+	_, ok := maUn.(*msgpackMarshalUnmarshaler)
+	return ok
+}
+
+func (MsgpackEncoder) EncodeRaw(w io.Writer, raw []byte) error {
+	_, err := w.Write(raw)
+	return err
+}
+
+// CSVEncoder writes a header row of column names followed by one record per
+// row, in the textual form ImportCSV reads back.
+type CSVEncoder struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma   rune
+	w       *csv.Writer
+	columns []string
+	record  []string
+}
+
+func (e *CSVEncoder) Begin(w io.Writer, columns []string) error {
+	e.w = csv.NewWriter(w)
+	if e.Comma != 0 {
+		e.w.Comma = e.Comma
+	}
+	e.columns = columns
+	e.record = make([]string, len(columns))
+	return e.w.Write(columns)
+}
+
+func (e *CSVEncoder) Encode(w io.Writer, row map[string]any) error {
+	for i, col := range e.columns {
+		e.record[i] = csvField(row[col])
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *CSVEncoder) End(w io.Writer) error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func csvField(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,91 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestPersistent_SelectTo(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+		"name": {Indexed: true},
+		"age":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{
+		{"name": "ada", "age": int64(36)},
+		{"name": "bob", "age": int64(7)},
+		{"name": "cy, jr", "age": int64(51)},
+	} {
+		if err := p.Insert(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Whole-relation dumps in the stored encoding copy rows verbatim.
+	var packed bytes.Buffer
+	n, err := p.SelectTo(&packed, MsgpackEncoder{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := msgpack.NewDecoder(&packed)
+	var names []string
+	for range n {
+		var row map[string]any
+		if err := dec.Decode(&row); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, row["name"].(string))
+	}
+	if n != 3 || len(names) != 3 {
+		t.Fatalf("expected 3 msgpack rows, got %d: %v", n, names)
+	}
+
+	adults, err := ToKeyRanges(Ge("age", int64(18)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines bytes.Buffer
+	if n, err = p.SelectTo(&lines, NDJSONEncoder{}, adults); err != nil {
+		t.Fatal(err)
+	}
+	for line := range strings.Lines(lines.String()) {
+		var row map[string]any
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatal(err)
+		}
+		if row["age"].(float64) < 18 {
+			t.Fatalf("unexpected row %v", row)
+		}
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 NDJSON rows, got %d", n)
+	}
+
+	cy, err := ToKeyRanges(Eq("name", "cy, jr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table bytes.Buffer
+	if _, err := p.SelectTo(&table, &CSVEncoder{}, cy); err != nil {
+		t.Fatal(err)
+	}
+	if want := "age,name\n51,\"cy, jr\"\n"; table.String() != want {
+		t.Fatalf("expected CSV %q, got %q", want, table.String())
+	}
+}
@@ -0,0 +1,140 @@
+package thunder
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+const sequenceBucket = "__thunder_sequences"
+
+// Sequence is a named counter handing out increasing numbers, for invoice
+// numbers, offsets and the like. A sequence obtained from a Tx moves with the
+// transaction: numbers taken in a transaction that rolls back are handed out
+// again. One obtained from a DB runs each call in a transaction of its own.
+type Sequence struct {
+	db   *DB
+	tx   *Tx
+	name string
+}
+
+// Sequence returns the sequence called name. Sequences start at 1 and need
+// no creating.
+func (d *DB) Sequence(name string) *Sequence {
+	return &Sequence{db: d, name: name}
+}
+
+// Sequence returns the sequence called name, read and advanced within tx.
+func (tx *Tx) Sequence(name string) *Sequence {
+	return &Sequence{tx: tx, name: name}
+}
+
+// Name returns the name of the sequence.
+func (s *Sequence) Name() string {
+	return s.name
+}
+
+// Next advances the sequence and returns the number it was at.
+func (s *Sequence) Next() (uint64, error) {
+	var n uint64
+	err := s.run(true, func(tx *Tx) error {
+		bucket, err := tx.tx.CreateBucketIfNotExists([]byte(sequenceBucket))
+		if err != nil {
+			return err
+		}
+		n = sequenceValue(bucket, s.name)
+		if _, _, err := tx.changeSeq(); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(s.name), binary.BigEndian.AppendUint64(nil, n+1))
+	})
+	return n, err
+}
+
+// Peek returns the number Next would return, without advancing.
+func (s *Sequence) Peek() (uint64, error) {
+	var n uint64
+	err := s.run(false, func(tx *Tx) error {
+		n = sequenceValue(tx.tx.Bucket([]byte(sequenceBucket)), s.name)
+		return nil
+	})
+	return n, err
+}
+
+// SetStart makes n the next number Next returns.
+func (s *Sequence) SetStart(n uint64) error {
+	return s.run(true, func(tx *Tx) error {
+		bucket, err := tx.tx.CreateBucketIfNotExists([]byte(sequenceBucket))
+		if err != nil {
+			return err
+		}
+		if _, _, err := tx.changeSeq(); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(s.name), binary.BigEndian.AppendUint64(nil, n))
+	})
+}
+
+func (s *Sequence) run(writable bool, fn func(tx *Tx) error) error {
+	if s.tx != nil {
+		if writable {
+			if err := s.tx.checkWritable(); err != nil {
+				return err
+			}
+		}
+		return fn(s.tx)
+	}
+	if writable {
+		return s.db.update(fn)
+	}
+	return s.db.view(fn)
+}
+
+// sequenceValue returns the next number of the sequence name stored in
+// bucket, which may be nil.
+func sequenceValue(bucket BackendBucket, name string) uint64 {
+	if bucket == nil {
+		return 1
+	}
+	v := bucket.Get([]byte(name))
+	if len(v) != 8 {
+		return 1
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// backupSequences returns the next number of every sequence, for change
+// streams, which carry them in full.
+func (tx *Tx) backupSequences() (map[string][]byte, error) {
+	bucket := tx.tx.Bucket([]byte(sequenceBucket))
+	if bucket == nil {
+		return nil, nil
+	}
+	seqs := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		seqs[string(k)] = slices.Clone(v)
+		return nil
+	})
+	return seqs, err
+}
+
+// applySequences replaces every sequence with those of a change stream.
+func (tx *Tx) applySequences(seqs map[string][]byte) error {
+	if tx.tx.Bucket([]byte(sequenceBucket)) != nil {
+		if err := tx.tx.DeleteBucket([]byte(sequenceBucket)); err != nil {
+			return err
+		}
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+	bucket, err := tx.tx.CreateBucket([]byte(sequenceBucket))
+	if err != nil {
+		return err
+	}
+	for name, v := range seqs {
+		if err := bucket.Put([]byte(name), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package thunder
+
+import "testing"
+
+func TestDB_Sequence(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	invoices := db.Sequence("invoices")
+	if n, err := invoices.Peek(); err != nil || n != 1 {
+		t.Fatalf("expected a new sequence to start at 1, got %d, %v", n, err)
+	}
+	for want := uint64(1); want <= 3; want++ {
+		if n, err := invoices.Next(); err != nil || n != want {
+			t.Fatalf("expected %d, got %d, %v", want, n, err)
+		}
+	}
+	if err := invoices.SetStart(1000); err != nil {
+		t.Fatal(err)
+	}
+
+	// Numbers taken in a rolled back transaction are handed out again.
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tx.Sequence("invoices").Next(); err != nil || n != 1000 {
+		t.Fatalf("expected 1000, got %d, %v", n, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	err = db.update(func(tx *Tx) error {
+		seq := tx.Sequence("invoices")
+		n, err := seq.Next()
+		if err != nil {
+			return err
+		}
+		if n != 1000 {
+			t.Errorf("expected 1000 again after rollback, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := invoices.Peek(); err != nil || n != 1001 {
+		t.Fatalf("expected 1001 next, got %d, %v", n, err)
+	}
+	if n, err := db.Sequence("offsets").Next(); err != nil || n != 1 {
+		t.Fatalf("expected sequences to be independent, got %d, %v", n, err)
+	}
+}
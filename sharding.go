@@ -0,0 +1,222 @@
+package thunder
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"slices"
+	"sync"
+)
+
+// Sharded spreads one relation across several databases, usually separate
+// bolt files, by hashing a shard key column. Inserts touching different
+// shards commit in parallel, each under its own file's write lock. Writes are
+// atomic per shard only: an Insert spanning shards may partly fail.
+//
+// Constraints are checked per shard as well. A unique column other than the
+// shard key only keeps its values unique within each shard, since rows with
+// different shard keys may land in different shards.
+type Sharded struct {
+	shards   []*DB
+	relation string
+	key      string
+}
+
+// NewSharded creates relation with columns in every shard that does not
+// have it yet and returns a Sharded distributing its rows by the value of
+// key. The order of shards decides where rows live and must not change
+// between runs. It fails with ErrNoShards when shards is empty.
+func NewSharded(shards []*DB, relation, key string, columns map[string]ColumnSpec) (*Sharded, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShards()
+	}
+	if spec, ok := columns[key]; !ok || len(spec.ReferenceCols) > 0 {
+		return nil, ErrFieldNotFound(key)
+	}
+	for _, shard := range shards {
+		err := shard.update(func(tx *Tx) error {
+			names, err := tx.Relations()
+			if err != nil {
+				return err
+			}
+			if slices.Contains(names, relation) {
+				return nil
+			}
+			_, err = tx.CreatePersistent(relation, columns)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Sharded{shards: shards, relation: relation, key: key}, nil
+}
+
+// Name returns the name of the relation in each shard.
+func (s *Sharded) Name() string {
+	return s.relation
+}
+
+// Shards returns the underlying databases.
+func (s *Sharded) Shards() []*DB {
+	return s.shards
+}
+
+// Insert routes each row to the shard its key hashes to, inserting into
+// the shards in parallel. Rows are routed by their shard key once column
+// defaults and generated columns are filled in, and are inserted with those
+// values, so a BeforeInsert hook must not change the shard key.
+func (s *Sharded) Insert(rows ...map[string]any) error {
+	groups := make([][]map[string]any, len(s.shards))
+	err := s.shards[0].view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(s.relation)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			row, err := p.completeRow(row, false)
+			if err != nil {
+				return err
+			}
+			key, err := p.computeKey(row, s.key)
+			if err != nil {
+				return err
+			}
+			i := s.shardOf(key)
+			groups[i] = append(groups[i], row)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.each(func(i int, shard *DB) error {
+		if len(groups[i]) == 0 {
+			return nil
+		}
+		return shard.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(s.relation)
+			if err != nil {
+				return err
+			}
+			for _, row := range groups[i] {
+				if err := p.Insert(row); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Select returns the rows matching ranges. A range pinning the shard key to
+// one value reads only that value's shard; anything else reads every shard
+// in parallel. Rows come back grouped by shard.
+func (s *Sharded) Select(ranges map[string]*keyRange) ([]map[string]any, error) {
+	targets, err := s.route(ranges)
+	if err != nil {
+		return nil, err
+	}
+	results := make([][]map[string]any, len(s.shards))
+	err = s.each(func(i int, shard *DB) error {
+		if !targets[i] {
+			return nil
+		}
+		rows, err := shard.selectRows(s.relation, ranges, ConsistencyLatest)
+		results[i] = rows
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(results...), nil
+}
+
+// Delete removes the rows matching ranges from the shards that can hold
+// them.
+func (s *Sharded) Delete(ranges map[string]*keyRange) error {
+	targets, err := s.route(ranges)
+	if err != nil {
+		return err
+	}
+	return s.each(func(i int, shard *DB) error {
+		if !targets[i] {
+			return nil
+		}
+		return shard.update(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(s.relation)
+			if err != nil {
+				return err
+			}
+			return p.Delete(ranges)
+		})
+	})
+}
+
+// Count returns the number of rows across all shards.
+func (s *Sharded) Count() (int, error) {
+	counts := make([]int, len(s.shards))
+	err := s.each(func(i int, shard *DB) error {
+		return shard.view(func(tx *Tx) error {
+			p, err := tx.LoadPersistent(s.relation)
+			if err != nil {
+				return err
+			}
+			counts[i], err = p.Count()
+			return err
+		})
+	})
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total, err
+}
+
+// route reports which shards can hold rows matching ranges.
+func (s *Sharded) route(ranges map[string]*keyRange) ([]bool, error) {
+	targets := make([]bool, len(s.shards))
+	var point []byte
+	err := s.shards[0].view(func(tx *Tx) error {
+		p, err := tx.LoadPersistent(s.relation)
+		if err != nil {
+			return err
+		}
+		kr, ok := p.coerceRanges(ranges)[s.key]
+		if ok && kr != nil && !kr.elementwise() && kr.startKey != nil &&
+			kr.includeStart && kr.includeEnd && bytes.Equal(kr.startKey, kr.endKey) {
+			point = kr.startKey
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if point != nil {
+		targets[s.shardOf(point)] = true
+		return targets, nil
+	}
+	for i := range targets {
+		targets[i] = true
+	}
+	return targets, nil
+}
+
+func (s *Sharded) shardOf(key []byte) int {
+	return int(crc32.ChecksumIEEE(key) % uint32(len(s.shards)))
+}
+
+// each runs fn for every shard concurrently and joins their errors.
+func (s *Sharded) each(fn func(i int, shard *DB) error) error {
+	errs := make([]error, len(s.shards))
+	var wg sync.WaitGroup
+	for i, shard := range s.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
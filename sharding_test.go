@@ -0,0 +1,146 @@
+package thunder
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSharded(t *testing.T) {
+	dir := t.TempDir()
+	shards := make([]*DB, 3)
+	for i := range shards {
+		db, err := OpenDB(&MsgpackMaUn, filepath.Join(dir, fmt.Sprintf("shard%d.db", i)), 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		shards[i] = db
+	}
+	events, err := NewSharded(shards, "events", "user", map[string]ColumnSpec{
+		"user": {Indexed: true},
+		"seq":  {Type: TypeInt},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for i := range 30 {
+		rows = append(rows, map[string]any{"user": fmt.Sprintf("u%d", i%10), "seq": i})
+	}
+	if err := events.Insert(rows...); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := events.Count(); err != nil || n != 30 {
+		t.Fatalf("expected 30 rows, got %d, %v", n, err)
+	}
+	used := 0
+	for _, shard := range shards {
+		n, err := shard.Query("SELECT * FROM events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(n) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Fatalf("expected rows spread over several shards, used %d", used)
+	}
+
+	ranges, err := ToKeyRanges(Eq("user", "u3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := events.route(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hit := 0
+	for _, ok := range targets {
+		if ok {
+			hit++
+		}
+	}
+	if hit != 1 {
+		t.Fatalf("expected an equality on the shard key to read one shard, read %d", hit)
+	}
+	got, err := events.Select(ranges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events for u3, got %v", got)
+	}
+	ranges, err = ToKeyRanges(Ge("seq", 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = events.Select(ranges); err != nil || len(got) != 5 {
+		t.Fatalf("expected 5 late events, got %v, %v", got, err)
+	}
+	if err := events.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := events.Count(); err != nil || n != 25 {
+		t.Fatalf("expected 25 rows after delete, got %d, %v", n, err)
+	}
+
+	if _, err := NewSharded(shards, "events", "user", nil); err == nil {
+		t.Fatal("expected error for a shard key that is not a column")
+	}
+	// Reopening over the same shards finds the relation already there.
+	if _, err := NewSharded(shards, "events", "user", map[string]ColumnSpec{"user": {}}); err != nil {
+		t.Fatal(err)
+	}
+	var te *ThunderError
+	if _, err := NewSharded(nil, "events", "user", map[string]ColumnSpec{"user": {}}); !errors.As(err, &te) || te.Code != ErrCodeNoShards {
+		t.Fatalf("expected ErrNoShards, got %v", err)
+	}
+}
+
+func TestSharded_GeneratedKey(t *testing.T) {
+	dir := t.TempDir()
+	shards := make([]*DB, 3)
+	for i := range shards {
+		db, err := OpenDB(&MsgpackMaUn, filepath.Join(dir, fmt.Sprintf("shard%d.db", i)), 0600, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		db.SetGenerator("lower_user", func(row map[string]any) (any, error) {
+			return strings.ToLower(row["name"].(string)), nil
+		})
+		shards[i] = db
+	}
+	events, err := NewSharded(shards, "events", "user", map[string]ColumnSpec{
+		"user": {Indexed: true, Generated: "lower_user"},
+		"name": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for i := range 10 {
+		rows = append(rows, map[string]any{"name": fmt.Sprintf("U%d", i)})
+	}
+	if err := events.Insert(rows...); err != nil {
+		t.Fatal(err)
+	}
+	// Each row is found in the shard its generated key routes a lookup to.
+	for i := range 10 {
+		ranges, err := ToKeyRanges(Eq("user", fmt.Sprintf("u%d", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := events.Select(ranges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("expected u%d in its shard, got %v", i, got)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package thunder
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+)
+
+const constraintsBucket = "__thunder_constraints"
+
+// sharedUnique is a uniqueness constraint spanning several relations. Its
+// keys bucket maps every constrained key to the row owning it, encoded as the
+// 8 byte row id followed by the relation name.
+type sharedUnique struct {
+	name    string
+	columns []string
+	keys    BackendBucket
+}
+
+// CreateSharedUnique declares a uniqueness constraint named name across
+// several relations. members maps each relation to the columns forming its
+// key; all members must use the same number of columns. Existing rows are
+// checked and registered, so creation fails if they already conflict.
+func (tx *Tx) CreateSharedUnique(name string, members map[string][]string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	root, err := tx.tx.CreateBucketIfNotExists([]byte(constraintsBucket))
+	if err != nil {
+		return err
+	}
+	if root.Bucket([]byte(name)) != nil {
+		return ErrIndexAlreadyExists(name)
+	}
+	width := -1
+	for relation, columns := range members {
+		if width != -1 && len(columns) != width {
+			return ErrFieldCountMismatch(width, len(columns))
+		}
+		width = len(columns)
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		for _, col := range columns {
+			if !slices.Contains(p.columns, col) {
+				return ErrFieldNotFound(col)
+			}
+		}
+	}
+	bucket, err := root.CreateBucket([]byte(name))
+	if err != nil {
+		return err
+	}
+	membersBytes, err := tx.maUn.Marshal(members)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put([]byte("members"), membersBytes); err != nil {
+		return err
+	}
+	keys, err := bucket.CreateBucket([]byte("keys"))
+	if err != nil {
+		return err
+	}
+	return tx.registerSharedKeys(name, members, keys)
+}
+
+// registerSharedKeys claims the keys of every existing row of members in
+// keys, failing on the first conflict.
+func (tx *Tx) registerSharedKeys(name string, members map[string][]string, keys BackendBucket) error {
+	for _, relation := range slices.Sorted(maps.Keys(members)) {
+		p, err := tx.LoadPersistent(relation)
+		if err != nil {
+			return err
+		}
+		su := &sharedUnique{name: name, columns: members[relation], keys: keys}
+		err = p.data.bucket.ForEach(func(k, v []byte) error {
+			var value map[string]any
+			if err := p.data.unmarshal(k, v, &value); err != nil {
+				return err
+			}
+			e := entry{value: value}
+			copy(e.id[:], k)
+			if err := p.checkShared(su, value, nil); err != nil {
+				return err
+			}
+			return p.claimShared(su, e)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSharedUniques recomputes the keys of every cross-relation
+// uniqueness constraint from the rows of its members.
+func (tx *Tx) rebuildSharedUniques() error {
+	root := tx.tx.Bucket([]byte(constraintsBucket))
+	if root == nil {
+		return nil
+	}
+	var names [][]byte
+	if err := root.ForEachBucket(func(name []byte) error {
+		names = append(names, slices.Clone(name))
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, name := range names {
+		bucket := root.Bucket(name)
+		var members map[string][]string
+		if err := tx.maUn.Unmarshal(bucket.Get([]byte("members")), &members); err != nil {
+			return ErrCorruptedMetaDataEntry(constraintsBucket, string(name))
+		}
+		if err := bucket.DeleteBucket([]byte("keys")); err != nil {
+			return err
+		}
+		keys, err := bucket.CreateBucket([]byte("keys"))
+		if err != nil {
+			return err
+		}
+		if err := tx.registerSharedKeys(string(name), members, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropSharedUnique removes a cross-relation uniqueness constraint.
+func (tx *Tx) DropSharedUnique(name string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	root := tx.tx.Bucket([]byte(constraintsBucket))
+	if root == nil || root.Bucket([]byte(name)) == nil {
+		return ErrIndexNotFound(name)
+	}
+	return root.DeleteBucket([]byte(name))
+}
+
+// loadSharedUniques returns the cross-relation constraints relation takes part in.
+func (tx *Tx) loadSharedUniques(relation string) ([]*sharedUnique, error) {
+	root := tx.tx.Bucket([]byte(constraintsBucket))
+	if root == nil {
+		return nil, nil
+	}
+	var result []*sharedUnique
+	err := root.ForEachBucket(func(name []byte) error {
+		bucket := root.Bucket(name)
+		var members map[string][]string
+		if err := tx.maUn.Unmarshal(bucket.Get([]byte("members")), &members); err != nil {
+			return ErrCorruptedMetaDataEntry(constraintsBucket, string(name))
+		}
+		if columns, ok := members[relation]; ok {
+			result = append(result, &sharedUnique{
+				name:    string(name),
+				columns: columns,
+				keys:    bucket.Bucket([]byte("keys")),
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// forgetSharedKeys releases every key owned by rows of relation, and
+// optionally hands them over to newRelation when a relation is renamed.
+func (tx *Tx) forgetSharedKeys(relation, newRelation string) error {
+	root := tx.tx.Bucket([]byte(constraintsBucket))
+	if root == nil {
+		return nil
+	}
+	return root.ForEachBucket(func(name []byte) error {
+		bucket := root.Bucket(name)
+		var members map[string][]string
+		if err := tx.maUn.Unmarshal(bucket.Get([]byte("members")), &members); err != nil {
+			return ErrCorruptedMetaDataEntry(constraintsBucket, string(name))
+		}
+		columns, ok := members[relation]
+		if !ok {
+			return nil
+		}
+		keys := bucket.Bucket([]byte("keys"))
+		owned, err := ownedKeys(keys, relation)
+		if err != nil {
+			return err
+		}
+		for _, k := range owned {
+			if newRelation == "" {
+				err = keys.Delete(k)
+			} else {
+				err = keys.Put(k, append(slices.Clone(keys.Get(k)[:8]), newRelation...))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		delete(members, relation)
+		if newRelation != "" {
+			members[newRelation] = columns
+		}
+		membersBytes, err := tx.maUn.Marshal(members)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("members"), membersBytes)
+	})
+}
+
+func ownedKeys(keys BackendBucket, relation string) ([][]byte, error) {
+	var owned [][]byte
+	err := keys.ForEach(func(k, v []byte) error {
+		if string(v[8:]) == relation {
+			owned = append(owned, slices.Clone(k))
+		}
+		return nil
+	})
+	return owned, err
+}
+
+// releaseAllShared frees every shared key owned by the relation; used when
+// the relation is truncated.
+func (pr *Persistent) releaseAllShared() error {
+	for _, su := range pr.shared {
+		owned, err := ownedKeys(su.keys, pr.relation)
+		if err != nil {
+			return err
+		}
+		for _, k := range owned {
+			if err := su.keys.Delete(k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pr *Persistent) sharedKey(su *sharedUnique, value map[string]any) ([]byte, error) {
+	parts := make([]any, 0, len(su.columns))
+	for _, col := range su.columns {
+		v, ok := value[col]
+		if !ok {
+			return nil, ErrFieldNotFound(col)
+		}
+		parts = append(parts, v)
+	}
+	return ToKey(parts...)
+}
+
+// checkShared fails if the key of value is taken, unless by a row of the
+// relation for which held returns true.
+func (pr *Persistent) checkShared(su *sharedUnique, value map[string]any, held func(id [8]byte) bool) error {
+	key, err := pr.sharedKey(su, value)
+	if err != nil {
+		return err
+	}
+	owner := su.keys.Get(key)
+	if owner == nil || held != nil && string(owner[8:]) == pr.relation && held([8]byte(owner[:8])) {
+		return nil
+	}
+	return ErrUniqueConstraint(su.name, key)
+}
+
+func (pr *Persistent) claimShared(su *sharedUnique, e entry) error {
+	key, err := pr.sharedKey(su, e.value)
+	if err != nil {
+		return err
+	}
+	owner := append(slices.Clone(e.id[:]), pr.relation...)
+	return su.keys.Put(key, owner)
+}
+
+func (pr *Persistent) releaseShared(su *sharedUnique, e entry) error {
+	key, err := pr.sharedKey(su, e.value)
+	if err != nil {
+		return err
+	}
+	owner := su.keys.Get(key)
+	if owner == nil || !bytes.Equal(owner[:8], e.id[:]) || string(owner[8:]) != pr.relation {
+		return nil
+	}
+	return su.keys.Delete(key)
+}
@@ -0,0 +1,104 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTx_SharedUnique(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"username": {},
+		"email":    {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	invites, err := tx.CreatePersistent("pending_invites", map[string]ColumnSpec{
+		"handle": {},
+		"sent":   {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := invites.Insert(map[string]any{"handle": "carol", "sent": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateSharedUnique("usernames", map[string][]string{
+		"users":           {"username"},
+		"pending_invites": {"handle"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.LoadPersistent("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	invites, err = tx.LoadPersistent("pending_invites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	isViolation := func(err error) bool {
+		var thunderErr *ThunderError
+		return errors.As(err, &thunderErr) && thunderErr.Code == ErrCodeUniqueConstraint
+	}
+	if err := users.Insert(map[string]any{"username": "alice", "email": "a@x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := invites.Insert(map[string]any{"handle": "alice", "sent": false}); !isViolation(err) {
+		t.Fatalf("expected cross-relation violation, got %v", err)
+	}
+	if err := users.Insert(map[string]any{"username": "carol", "email": "c@x"}); !isViolation(err) {
+		t.Fatalf("expected violation against pre-existing invite, got %v", err)
+	}
+	// Deleting the invite frees the name for the users relation.
+	ranges, err := ToKeyRanges(Eq("handle", "carol"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := invites.Delete(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"username": "carol", "email": "c@x"}); err != nil {
+		t.Fatal(err)
+	}
+	// Renaming keeps ownership, dropping releases it.
+	if err := tx.RenameRelation("users", "accounts"); err != nil {
+		t.Fatal(err)
+	}
+	invites, err = tx.LoadPersistent("pending_invites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := invites.Insert(map[string]any{"handle": "alice", "sent": false}); !isViolation(err) {
+		t.Fatalf("expected violation after rename, got %v", err)
+	}
+	if err := tx.DropRelation("accounts"); err != nil {
+		t.Fatal(err)
+	}
+	invites, err = tx.LoadPersistent("pending_invites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := invites.Insert(map[string]any{"handle": "alice", "sent": false}); err != nil {
+		t.Fatalf("expected name to be free after drop, got %v", err)
+	}
+	if err := tx.DropSharedUnique("usernames"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+// Package testutil helps tests that use thunder: it opens throwaway
+// databases, loads fixture files into relations and compares query results,
+// against literal rows or golden files.
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/longlodw/thunder"
+	"gopkg.in/yaml.v3"
+)
+
+// UpdateEnv names the environment variable that makes AssertGolden rewrite
+// golden files instead of comparing against them.
+const UpdateEnv = "THUNDER_UPDATE_GOLDEN"
+
+// Open opens a database in a temporary file that is closed when the test
+// ends.
+func Open(t testing.TB) *thunder.DB {
+	t.Helper()
+	db, err := thunder.OpenDB(&thunder.MsgpackMaUn, filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// OpenMemory opens an in-memory database that is closed when the test ends.
+func OpenMemory(t testing.TB) *thunder.DB {
+	t.Helper()
+	db, err := thunder.OpenMemory(&thunder.MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// LoadFixtures inserts the rows of each fixture file into db, in one
+// transaction. A file maps relation names to lists of rows and is read as
+// YAML, or as JSON when its name ends in .json. Relations that do not exist
+// yet are created with a plain column for every key found in their rows;
+// create them beforehand for unique, typed or indexed columns.
+func LoadFixtures(t testing.TB, db *thunder.DB, paths ...string) {
+	t.Helper()
+	if err := loadFixtures(db, paths); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadFixtures(db *thunder.DB, paths []string) error {
+	tx, err := db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	existing, err := tx.Relations()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		fixtures, err := readFixtures(path)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(fixtures))
+		for name := range fixtures {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			rows := fixtures[name]
+			var p *thunder.Persistent
+			if slices.Contains(existing, name) {
+				p, err = tx.LoadPersistent(name)
+			} else {
+				p, err = tx.CreatePersistent(name, fixtureColumns(rows))
+				existing = append(existing, name)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			for i, row := range rows {
+				if err := p.Insert(row); err != nil {
+					return fmt.Errorf("%s: %s row %d: %w", path, name, i, err)
+				}
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func readFixtures(path string) (map[string][]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures map[string][]map[string]any
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &fixtures)
+	} else {
+		err = yaml.Unmarshal(data, &fixtures)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+func fixtureColumns(rows []map[string]any) map[string]thunder.ColumnSpec {
+	columns := make(map[string]thunder.ColumnSpec)
+	for _, row := range rows {
+		for name := range row {
+			columns[name] = thunder.ColumnSpec{}
+		}
+	}
+	return columns
+}
+
+// AssertRows fails the test unless got and want hold the same rows, in any
+// order. Values are compared by their JSON form, so an int64 read back
+// matches an int in want.
+func AssertRows(t testing.TB, got, want []map[string]any) {
+	t.Helper()
+	g, err := canonical(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := canonical(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Fatalf("rows differ\ngot:  %s\nwant: %s", g, w)
+	}
+}
+
+// AssertQuery runs query against db and compares its rows to want with
+// AssertRows.
+func AssertQuery(t testing.TB, db *thunder.DB, query string, want []map[string]any, args ...any) {
+	t.Helper()
+	got, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertRows(t, got, want)
+}
+
+// AssertGolden runs query against db and compares its rows, in any order,
+// to the JSON golden file at path. When the UpdateEnv environment variable
+// is set the file is written instead.
+func AssertGolden(t testing.TB, db *thunder.DB, path, query string, args ...any) {
+	t.Helper()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := canonical(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("rows differ from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// canonical renders rows as indented JSON, sorted so that row order does
+// not matter.
+func canonical(rows []map[string]any) ([]byte, error) {
+	encoded := make([]json.RawMessage, len(rows))
+	for i, row := range rows {
+		b, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = b
+	}
+	slices.SortFunc(encoded, func(a, b json.RawMessage) int { return bytes.Compare(a, b) })
+	out, err := json.MarshalIndent(encoded, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
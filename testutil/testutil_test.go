@@ -0,0 +1,63 @@
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/longlodw/thunder"
+)
+
+func TestFixturesAndAssertions(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.yaml")
+	if err := os.WriteFile(users, []byte(`
+users:
+  - {id: "1", name: ann, age: 31}
+  - {id: "2", name: bob, age: 27}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orders := filepath.Join(dir, "orders.json")
+	if err := os.WriteFile(orders, []byte(`{"orders": [{"id": "o1", "user": "1", "total": 12.5}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := OpenMemory(t)
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreatePersistent("orders", map[string]thunder.ColumnSpec{
+		"id":    {Unique: true},
+		"user":  {Indexed: true},
+		"total": {Type: thunder.TypeFloat},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	LoadFixtures(t, db, users, orders)
+
+	AssertQuery(t, db, "SELECT name, age FROM users WHERE age > ?", []map[string]any{
+		{"name": "ann", "age": 31},
+	}, 30)
+	AssertQuery(t, db, "SELECT * FROM orders", []map[string]any{
+		{"id": "o1", "user": "1", "total": 12.5},
+	})
+
+	golden := filepath.Join(dir, "testdata", "users.golden")
+	t.Setenv(UpdateEnv, "1")
+	AssertGolden(t, db, golden, "SELECT id, name FROM users")
+	t.Setenv(UpdateEnv, "")
+	AssertGolden(t, db, golden, "SELECT id, name FROM users")
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[\n  {\n    \"id\": \"1\",\n    \"name\": \"ann\"\n  },\n  {\n    \"id\": \"2\",\n    \"name\": \"bob\"\n  }\n]\n"
+	if string(data) != want {
+		t.Fatalf("unexpected golden file:\n%s", data)
+	}
+}
@@ -0,0 +1,16 @@
+package thunder
+
+import "time"
+
+// timeValue converts v to a time.Time. Codecs without a time type, such as
+// JSON, store timestamps as RFC 3339 strings, which are parsed back.
+func timeValue(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,84 @@
+package thunder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPersistent_TimeColumn(t *testing.T) {
+	for name, maUn := range map[string]MarshalUnmarshaler{"msgpack": &MsgpackMaUn, "json": &JsonMaUn, "cbor": &CborMaUn} {
+		t.Run(name, func(t *testing.T) {
+			db, err := OpenMemory(maUn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tx, err := db.Begin(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			events, err := tx.CreatePersistent("events", map[string]ColumnSpec{
+				"id": {Unique: true},
+				"at": {Indexed: true, Type: TypeTime},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			tokyo := time.FixedZone("JST", 9*60*60)
+			rows := []map[string]any{
+				{"id": "a", "at": base},
+				// Later than a, although its wall clock reads earlier.
+				{"id": "b", "at": base.Add(time.Hour).In(time.FixedZone("EST", -5*60*60))},
+				{"id": "c", "at": base.Add(2 * time.Hour).In(tokyo)},
+				{"id": "d", "at": base.Add(time.Nanosecond)},
+			}
+			for _, row := range rows {
+				if err := events.Insert(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := events.Insert(map[string]any{"id": "e", "at": "yesterday"}); err == nil {
+				t.Fatal("expected a type mismatch for a non-time value")
+			}
+
+			ids := func(ops ...Op) []string {
+				t.Helper()
+				ranges, err := ToKeyRanges(ops...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				seq, err := events.Select(ranges)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var out []string
+				for row, err := range seq {
+					if err != nil {
+						t.Fatal(err)
+					}
+					out = append(out, row["id"].(string))
+				}
+				return out
+			}
+			if got := ids(Gt("at", base), Lt("at", base.Add(2*time.Hour))); len(got) != 2 || got[0] != "d" || got[1] != "b" {
+				t.Fatalf("expected [d b] in time order, got %v", got)
+			}
+			if got := ids(Eq("at", base.Add(2*time.Hour))); len(got) != 1 || got[0] != "c" {
+				t.Fatalf("expected c for an equal instant in another zone, got %v", got)
+			}
+
+			ranges, err := ToKeyRanges(Eq("id", "c"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := events.Delete(ranges); err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(Ge("at", base.Add(time.Hour))); len(got) != 1 || got[0] != "b" {
+				t.Fatalf("expected only b after deleting c, got %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package thunder
+
+import (
+	"context"
+	"iter"
+	"strings"
+	"time"
+
+	"rsc.io/ordered"
+)
+
+// Tracer starts spans for the operations of a database. The otelthunder
+// package implements it on top of an OpenTelemetry TracerProvider.
+type Tracer interface {
+	// Start begins a span named op, such as "thunder.Select", over relation,
+	// as a child of any span carried by ctx.
+	Start(ctx context.Context, op, relation string) TraceSpan
+}
+
+// TraceSpan is a span started by a Tracer.
+type TraceSpan interface {
+	// SetPlan records how the operation reads the relation.
+	SetPlan(plan QueryPlan, ranges map[string]RangeSpec)
+	// End finishes the span with the number of rows yielded or written.
+	End(rows int, err error)
+}
+
+// Span names used by Persistent.
+const (
+	SpanSelect = "thunder.Select"
+	SpanInsert = "thunder.Insert"
+	SpanUpdate = "thunder.Update"
+	SpanDelete = "thunder.Delete"
+)
+
+// SetTracer registers the tracer used for the spans of Select, Insert,
+// Update and Delete, or removes it when t is nil. It should be set before the
+// database is used. Spans are children of the context the transaction was
+// begun with; see BeginContext.
+func (d *DB) SetTracer(t Tracer) {
+	d.tracer = t
+}
+
+// BeginContext is Begin with a context, which parents the spans of the
+// transaction's operations when a Tracer is set.
+func (d *DB) BeginContext(ctx context.Context, writable bool) (*Tx, error) {
+	tx, err := d.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	tx.ctx = ctx
+	return tx, nil
+}
+
+// Context returns the context the transaction was begun with.
+func (tx *Tx) Context() context.Context {
+	if tx.ctx == nil {
+		return context.Background()
+	}
+	return tx.ctx
+}
+
+// String describes the range with its keys decoded, as in
+// `>= ("a") and < ("c")`.
+func (rs RangeSpec) String() string {
+	key := func(k []byte) string {
+		s, err := ordered.DecodeFmt(k)
+		if err != nil {
+			return string(k)
+		}
+		return s
+	}
+	var parts []string
+	switch {
+	case rs.Start != nil && rs.End != nil && rs.IncludeStart && rs.IncludeEnd && string(rs.Start) == string(rs.End):
+		parts = append(parts, "= "+key(rs.Start))
+	default:
+		if rs.Start != nil {
+			op := "> "
+			if rs.IncludeStart {
+				op = ">= "
+			}
+			parts = append(parts, op+key(rs.Start))
+		}
+		if rs.End != nil {
+			op := "< "
+			if rs.IncludeEnd {
+				op = "<= "
+			}
+			parts = append(parts, op+key(rs.End))
+		}
+	}
+	for _, ex := range rs.Excludes {
+		parts = append(parts, "!= "+key(ex))
+	}
+	for _, k := range rs.All {
+		parts = append(parts, "contains "+key(k))
+	}
+	for _, group := range rs.AnyOf {
+		keys := make([]string, len(group))
+		for i, k := range group {
+			keys[i] = key(k)
+		}
+		parts = append(parts, "contains any of "+strings.Join(keys, ", "))
+	}
+	if len(parts) == 0 {
+		return "any"
+	}
+	return strings.Join(parts, " and ")
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetPlan(QueryPlan, map[string]RangeSpec) {}
+func (noopSpan) End(int, error)                          {}
+
+// startSpan starts a span for op, or returns a no-op span when tracing is
+// off.
+func (pr *Persistent) startSpan(op string) (TraceSpan, bool) {
+	if pr.ephemeral || pr.tx.db == nil || pr.tx.db.tracer == nil {
+		return noopSpan{}, false
+	}
+	return pr.tx.db.tracer.Start(pr.tx.Context(), op, pr.relation), true
+}
+
+// observeSelect wraps seq so that iterating it is recorded as a span and
+// timed for the slow-query log.
+func (pr *Persistent) observeSelect(ranges map[string]*keyRange, plan QueryPlan, seq iter.Seq2[map[string]any, error]) iter.Seq2[map[string]any, error] {
+	traced := !pr.ephemeral && pr.tx.db != nil && pr.tx.db.tracer != nil
+	if !traced && !pr.slowQueryLogged() {
+		return seq
+	}
+	return func(yield func(map[string]any, error) bool) {
+		span, _ := pr.startSpan(SpanSelect)
+		span.SetPlan(plan, rangeSpecs(ranges))
+		start := time.Now()
+		rows := 0
+		var firstErr error
+		defer func() {
+			span.End(rows, firstErr)
+			pr.logSlowQuery(SpanSelect, ranges, plan, time.Since(start), rows)
+		}()
+		for row, err := range seq {
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if err == nil {
+				rows++
+			}
+			if !yield(row, err) {
+				return
+			}
+		}
+	}
+}
+
+// observeWrite records fn, which writes the rows matching ranges, as a span
+// and times it for the slow-query log.
+func (pr *Persistent) observeWrite(op string, ranges map[string]*keyRange, fn func() (int, error)) (int, error) {
+	span, traced := pr.startSpan(op)
+	slow := ranges != nil && pr.slowQueryLogged()
+	if !traced && !slow {
+		return fn()
+	}
+	plan, planErr := pr.plan(ranges, queryOptions{})
+	if planErr == nil {
+		span.SetPlan(plan, rangeSpecs(ranges))
+	}
+	start := time.Now()
+	n, err := fn()
+	span.End(n, err)
+	if slow {
+		pr.logSlowQuery(op, ranges, plan, time.Since(start), n)
+	}
+	return n, err
+}
@@ -1,30 +1,57 @@
 package thunder
 
 import (
+	"context"
 	"errors"
-	"os"
-
-	"github.com/openkvlab/boltdb"
+	"iter"
+	"time"
 )
 
 type Tx struct {
-	tx           *boltdb.Tx
-	tempTx       *boltdb.Tx
-	tempDb       *boltdb.DB
-	tempFilePath string
-	maUn         MarshalUnmarshaler
+	tx     BackendTx
+	tempTx BackendTx
+	maUn   MarshalUnmarshaler
+	db     *DB
+
+	triggersSuppressed int
+	commitSeq          uint64
+	identity           string
+	keys               map[string][]byte
+	events             []ChangeEvent
+	touched            map[string]struct{}
+	loaded             []*Persistent
+	writable           bool
+	started            time.Time
+	finished           bool
+	ctx                context.Context
+	journal            *journal
+	savepoints         []*Savepoint
 }
 
 func (tx *Tx) Commit() error {
-	return tx.tx.Commit()
+	if err := tx.tx.Commit(); err != nil {
+		tx.finish(false)
+		return err
+	}
+	tx.finish(true)
+	if tx.writable {
+		tx.db.commits.notify()
+		tx.db.committed()
+	}
+	tx.db.feed.publish(tx.events)
+	tx.events = nil
+	if cache := tx.db.resultCache(); cache != nil && len(tx.touched) > 0 {
+		cache.invalidate(tx.touched)
+	}
+	tx.touched = nil
+	return nil
 }
 
 func (tx *Tx) Rollback() error {
+	tx.finish(false)
 	return errors.Join(
 		tx.tx.Rollback(),
 		tx.tempTx.Rollback(),
-		tx.tempDb.Close(),
-		os.Remove(tx.tempFilePath),
 	)
 }
 
@@ -36,7 +63,32 @@ func (tx *Tx) CreatePersistent(
 	relation string,
 	columnSpecs map[string]ColumnSpec,
 ) (*Persistent, error) {
-	return newPersistent(tx, relation, columnSpecs, false)
+	if err := tx.checkWritable(); err != nil {
+		return nil, err
+	}
+	existed := tx.tx.Bucket([]byte(relation)) != nil
+	p, err := newPersistent(tx, relation, columnSpecs, false)
+	if err != nil {
+		return nil, err
+	}
+	if existed {
+		// Its rows are kept, and with them their tracked changes.
+		tx.touch(relation)
+		return p, nil
+	}
+	if err := tx.trackRelationReset(relation); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkWritable fails with ErrReadOnly when the transaction is read-only,
+// before any work is done.
+func (tx *Tx) checkWritable() error {
+	if tx.writable {
+		return nil
+	}
+	return ErrReadOnly()
 }
 
 func (tx *Tx) LoadPersistent(
@@ -46,13 +98,206 @@ func (tx *Tx) LoadPersistent(
 }
 
 func (tx *Tx) DeletePersistent(relation string) error {
+	return tx.DropRelation(relation)
+}
+
+// DropRelation removes a relation together with its data and index buckets.
+func (tx *Tx) DropRelation(relation string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	tnx := tx.tx
+	if !isRelationBucket(tnx.Bucket([]byte(relation))) {
+		return ErrRelationNotFound(relation)
+	}
+	if err := tx.forgetSharedKeys(relation, ""); err != nil {
+		return err
+	}
+	if err := tx.forgetChanges(relation); err != nil {
+		return err
+	}
+	if err := tx.forgetAnalysis(relation); err != nil {
+		return err
+	}
+	return tnx.DeleteBucket([]byte(relation))
+}
+
+// RenameRelation moves a relation with all its data and indexes to a new name.
+func (tx *Tx) RenameRelation(oldName, newName string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
 	tnx := tx.tx
-	if err := tnx.DeleteBucket([]byte(relation)); err != nil {
+	src := tnx.Bucket([]byte(oldName))
+	if !isRelationBucket(src) {
+		return ErrRelationNotFound(oldName)
+	}
+	if tnx.Bucket([]byte(newName)) != nil {
+		return ErrRelationAlreadyExists(newName)
+	}
+	dst, err := tnx.CreateBucket([]byte(newName))
+	if err != nil {
 		return err
 	}
-	return nil
+	if err := copyBucket(dst, src); err != nil {
+		return err
+	}
+	if err := tx.forgetSharedKeys(oldName, newName); err != nil {
+		return err
+	}
+	if err := tx.forgetChanges(oldName); err != nil {
+		return err
+	}
+	if err := tx.forgetAnalysis(oldName); err != nil {
+		return err
+	}
+	if err := tx.trackRelationReset(newName); err != nil {
+		return err
+	}
+	return tnx.DeleteBucket([]byte(oldName))
+}
+
+// CopyRelation creates dstName as a copy of the relation src with its
+// schema, rows and indexes, copied bucket by bucket without re-encoding.
+// Statistics, history and change tracking start afresh for the copy.
+func (tx *Tx) CopyRelation(src, dstName string) error {
+	if err := tx.checkWritable(); err != nil {
+		return err
+	}
+	tnx := tx.tx
+	srcBucket := tnx.Bucket([]byte(src))
+	if !isRelationBucket(srcBucket) {
+		return ErrRelationNotFound(src)
+	}
+	if tnx.Bucket([]byte(dstName)) != nil {
+		return ErrRelationAlreadyExists(dstName)
+	}
+	dst, err := tnx.CreateBucket([]byte(dstName))
+	if err != nil {
+		return err
+	}
+	if err := copyBucket(dst, srcBucket); err != nil {
+		return err
+	}
+	// The copy would otherwise archive its versions into the history
+	// relation of src.
+	if dst.Bucket([]byte("versions")) != nil {
+		if err := dst.DeleteBucket([]byte("versions")); err != nil {
+			return err
+		}
+		if err := dst.Bucket([]byte("meta")).Delete([]byte("history")); err != nil {
+			return err
+		}
+	}
+	return tx.trackRelationReset(dstName)
 }
 
 func (tx *Tx) CreateRecursion(relation string, colColumnSpec map[string]ColumnSpec) (*Recursion, error) {
 	return newRecursive(tx, relation, colColumnSpec)
 }
+
+// Relations returns the names of all persistent relations stored in the database.
+func (tx *Tx) Relations() ([]string, error) {
+	names := make([]string, 0)
+	err := tx.tx.ForEach(func(name []byte, bucket BackendBucket) error {
+		if isRelationBucket(bucket) {
+			names = append(names, string(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// ForEachRelation loads every persistent relation and calls fn with it.
+// Iteration stops at the first error returned by fn.
+func (tx *Tx) ForEachRelation(fn func(*Persistent) error) error {
+	names, err := tx.Relations()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		p, err := tx.LoadPersistent(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RelationRow is a row yielded by SelectAcross together with the relation it came from.
+type RelationRow struct {
+	Relation string
+	Value    map[string]any
+}
+
+// SelectAcross runs Select on every relation that has all the columns referenced by ranges.
+// Relations missing any of those columns are skipped.
+func (tx *Tx) SelectAcross(ranges map[string]*keyRange) (iter.Seq2[RelationRow, error], error) {
+	names, err := tx.Relations()
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(RelationRow, error) bool) {
+		for _, name := range names {
+			p, err := tx.LoadPersistent(name)
+			if err != nil {
+				if !yield(RelationRow{}, err) {
+					return
+				}
+				continue
+			}
+			if !p.hasFields(ranges) {
+				continue
+			}
+			seq, err := p.Select(ranges)
+			if err != nil {
+				if !yield(RelationRow{}, err) {
+					return
+				}
+				continue
+			}
+			for value, err := range seq {
+				if err != nil {
+					if !yield(RelationRow{}, err) {
+						return
+					}
+					continue
+				}
+				if !yield(RelationRow{Relation: name, Value: value}, nil) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+func isRelationBucket(bucket BackendBucket) bool {
+	if bucket == nil {
+		return false
+	}
+	metaBucket := bucket.Bucket([]byte("meta"))
+	return metaBucket != nil && metaBucket.Get([]byte("columnSpecs")) != nil
+}
+
+func copyBucket(dst, src BackendBucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		srcChild := src.Bucket(k)
+		if srcChild == nil {
+			return dst.Put(k, v)
+		}
+		dstChild, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(dstChild, srcChild)
+	})
+}
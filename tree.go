@@ -0,0 +1,110 @@
+package thunder
+
+import "iter"
+
+// Tree treats a relation whose rows point to their parent row, through a
+// parent column holding the parent's id, as a forest.
+type Tree struct {
+	nodes  *Persistent
+	id     string
+	parent string
+}
+
+// Tree returns relation as a tree over its id and parent columns. Index
+// both columns for walks to be index lookups.
+func (tx *Tx) Tree(relation, id, parent string) (*Tree, error) {
+	nodes, err := tx.LoadPersistent(relation)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{id, parent} {
+		if spec, ok := nodes.fields[name]; !ok || len(spec.ReferenceCols) != 0 {
+			return nil, ErrFieldNotFound(name)
+		}
+	}
+	return &Tree{nodes: nodes, id: id, parent: parent}, nil
+}
+
+// Descendants yields the rows below root breadth first: its children at
+// depth 1, their children at depth 2 and so on. Rows already yielded are
+// skipped, so a cycle in the data ends the walk instead of looping.
+func (t *Tree) Descendants(root any) iter.Seq2[Visit, error] {
+	return func(yield func(Visit, error) bool) {
+		spec := t.nodes.fields[t.id]
+		seen := make(map[string]bool)
+		if key, err := spec.appendValue(nil, root); err == nil {
+			seen[string(key)] = true
+		}
+		level := []any{root}
+		for depth := 1; len(level) > 0; depth++ {
+			var next []any
+			for _, node := range level {
+				ranges, err := ToKeyRanges(Eq(t.parent, node))
+				if err != nil {
+					yield(Visit{}, err)
+					return
+				}
+				seq, err := t.nodes.Select(ranges)
+				if err != nil {
+					yield(Visit{}, err)
+					return
+				}
+				for row, err := range seq {
+					if err != nil {
+						yield(Visit{}, err)
+						return
+					}
+					key, err := t.nodes.computeKey(row, t.id)
+					if err != nil {
+						yield(Visit{}, err)
+						return
+					}
+					if seen[string(key)] {
+						continue
+					}
+					seen[string(key)] = true
+					if !yield(Visit{Node: row[t.id], Depth: depth, Row: row}, nil) {
+						return
+					}
+					next = append(next, row[t.id])
+				}
+			}
+			level = next
+		}
+	}
+}
+
+// Ancestors yields the rows above node, its parent at depth 1 first, up to
+// the root: the first row without a parent, or whose parent is missing. A
+// cycle in the data ends the walk at the first row seen twice.
+func (t *Tree) Ancestors(node any) iter.Seq2[Visit, error] {
+	return func(yield func(Visit, error) bool) {
+		spec := t.nodes.fields[t.id]
+		seen := make(map[string]bool)
+		for depth := 0; ; depth++ {
+			key, err := spec.appendValue(nil, node)
+			if err != nil {
+				yield(Visit{}, err)
+				return
+			}
+			if seen[string(key)] {
+				return
+			}
+			seen[string(key)] = true
+			row, err := t.nodes.First(Eq(t.id, node))
+			if err != nil {
+				yield(Visit{}, err)
+				return
+			}
+			if row == nil {
+				return
+			}
+			if depth > 0 && !yield(Visit{Node: node, Depth: depth, Row: row}, nil) {
+				return
+			}
+			if node = row[t.parent]; node == nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package thunder
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTx_Tree(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	dirs, err := tx.CreatePersistent("dirs", map[string]ColumnSpec{
+		"id":     {Unique: true},
+		"parent": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// root has an empty parent; root -> usr -> {bin, lib -> go}; loop1 and loop2 point at each other.
+	for _, d := range [][2]any{{"root", ""}, {"usr", "root"}, {"bin", "usr"}, {"lib", "usr"}, {"go", "lib"}, {"loop1", "loop2"}, {"loop2", "loop1"}} {
+		if err := dirs.Insert(map[string]any{"id": d[0], "parent": d[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, err := tx.Tree("dirs", "id", "parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	walk := func(seq func(func(Visit, error) bool)) string {
+		t.Helper()
+		var out string
+		for v, err := range seq {
+			if err != nil {
+				t.Fatal(err)
+			}
+			out += fmt.Sprintf("%v@%d ", v.Node, v.Depth)
+		}
+		return out
+	}
+	if got := walk(tree.Descendants("root")); got != "usr@1 bin@2 lib@2 go@3 " {
+		t.Fatalf("unexpected descendants %q", got)
+	}
+	if got := walk(tree.Ancestors("go")); got != "lib@1 usr@2 root@3 " {
+		t.Fatalf("unexpected ancestors %q", got)
+	}
+	if got := walk(tree.Ancestors("loop1")); got != "loop2@1 " {
+		t.Fatalf("expected the cycle to stop the walk, got %q", got)
+	}
+	if got := walk(tree.Descendants("loop1")); got != "loop2@1 " {
+		t.Fatalf("expected the cycle to stop the walk, got %q", got)
+	}
+	if _, err := tx.Tree("dirs", "id", "missing"); err == nil {
+		t.Fatal("expected error for an unknown column")
+	}
+}
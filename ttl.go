@@ -0,0 +1,88 @@
+package thunder
+
+import (
+	"slices"
+	"time"
+)
+
+// ttlPolicy expires rows whose Column, a Unix nanosecond timestamp, is older
+// than TTL.
+type ttlPolicy struct {
+	Column string
+	TTL    time.Duration
+}
+
+// SetTTL makes rows of the relation expire once the Unix nanosecond timestamp
+// held in column is more than ttl in the past, as seen by the database clock.
+// Expired rows are removed by SweepExpired. A zero ttl removes the policy.
+func (pr *Persistent) SetTTL(column string, ttl time.Duration) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	metaBucket := pr.bucket.Bucket([]byte("meta"))
+	if ttl <= 0 {
+		return metaBucket.Delete([]byte("ttl"))
+	}
+	if !slices.Contains(pr.columns, column) {
+		return ErrFieldNotFound(column)
+	}
+	policyBytes, err := pr.maUn.Marshal(ttlPolicy{Column: column, TTL: ttl})
+	if err != nil {
+		return err
+	}
+	return metaBucket.Put([]byte("ttl"), policyBytes)
+}
+
+// TTL returns the expiry column and duration set by SetTTL, if any.
+func (pr *Persistent) TTL() (string, time.Duration, error) {
+	policy, err := pr.ttlPolicy()
+	if err != nil || policy == nil {
+		return "", 0, err
+	}
+	return policy.Column, policy.TTL, nil
+}
+
+func (pr *Persistent) ttlPolicy() (*ttlPolicy, error) {
+	policyBytes := pr.bucket.Bucket([]byte("meta")).Get([]byte("ttl"))
+	if policyBytes == nil {
+		return nil, nil
+	}
+	policy := &ttlPolicy{}
+	if err := pr.maUn.Unmarshal(policyBytes, policy); err != nil {
+		return nil, ErrCorruptedMetaDataEntry(pr.relation, "ttl")
+	}
+	return policy, nil
+}
+
+// SweepExpired deletes the rows of the relation that have outlived its TTL
+// and returns how many were removed. Relations without a TTL are left alone.
+func (pr *Persistent) SweepExpired() (int, error) {
+	if err := pr.checkWritable(); err != nil {
+		return 0, err
+	}
+	policy, err := pr.ttlPolicy()
+	if err != nil || policy == nil {
+		return 0, err
+	}
+	cutoff := pr.tx.Now().Add(-policy.TTL).UnixNano()
+	ranges, err := ToKeyRanges(Lt(policy.Column, cutoff))
+	if err != nil {
+		return 0, err
+	}
+	return pr.deleteMatching(ranges, nil)
+}
+
+// SweepExpired removes expired rows from every relation with a TTL in a
+// single write transaction and returns the total number removed.
+func (d *DB) SweepExpired() (int, error) {
+	total := 0
+	err := d.ForEachRelation(func(p *Persistent) error {
+		n, err := p.SweepExpired()
+		total += n
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,76 @@
+package thunder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPersistent_TTLWithManualClock(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	clock := NewManualClock(time.Unix(1_000_000, 0))
+	db.SetClock(clock)
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	sessions, err := tx.CreatePersistent("sessions", map[string]ColumnSpec{
+		"token":   {Unique: true},
+		"created": {Indexed: true, DefaultNow: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.SetTTL("created", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.Insert(map[string]any{"token": "old"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Minute)
+	if err := sessions.Insert(map[string]any{"token": "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(45 * time.Minute)
+	n, err := db.SweepExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 expired row, got %d", n)
+	}
+
+	tx, err = db.Begin(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	sessions, err = tx.LoadPersistent("sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := sessions.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []any
+	for row, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, row["token"])
+		want := time.Unix(1_000_000, 0).Add(30 * time.Minute).UnixNano()
+		if created, _ := row["created"].(int64); created != want {
+			t.Fatalf("expected created %d from the clock, got %v", want, row["created"])
+		}
+	}
+	if len(tokens) != 1 || tokens[0] != "new" {
+		t.Fatalf("expected only the new session to remain, got %v", tokens)
+	}
+}
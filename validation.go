@@ -0,0 +1,124 @@
+package thunder
+
+// ValidationMode controls how schema violations found in stored rows are
+// reported while reading.
+type ValidationMode uint8
+
+const (
+	// ValidationStrict yields an error for every row that violates the schema.
+	ValidationStrict ValidationMode = iota
+	// ValidationWarn yields violating rows unchanged and reports each
+	// violation to the warning handler instead.
+	ValidationWarn
+)
+
+// Warning describes a schema violation that was tolerated because the
+// database runs in ValidationWarn mode. Code is the ThunderError code the
+// violation would have been reported with in strict mode.
+type Warning struct {
+	Relation string
+	Column   string
+	Code     int
+	Message  string
+}
+
+// SetValidationMode sets how schema violations in stored rows are handled on reads.
+// Writes are always validated strictly.
+func (d *DB) SetValidationMode(mode ValidationMode) {
+	d.validationMode = mode
+}
+
+// SetWarningHandler registers the function that receives tolerated schema
+// violations. Without a handler, warnings go to the Logger, if any, or are
+// dropped.
+func (d *DB) SetWarningHandler(handler func(Warning)) {
+	d.warningHandler = handler
+}
+
+func (d *DB) warn(w Warning) {
+	switch {
+	case d.warningHandler != nil:
+		d.warningHandler(w)
+	case d.logger != nil:
+		d.logger.Warn("thunder: "+w.Message, "relation", w.Relation, "column", w.Column, "code", w.Code)
+	}
+}
+
+// SetColumnType declares the type of an existing column without rewriting
+// stored rows, so a type constraint can be introduced on legacy data and
+// enforced on new writes right away.
+func (pr *Persistent) SetColumnType(column string, t ColumnType) error {
+	if err := pr.checkWritable(); err != nil {
+		return err
+	}
+	spec, ok := pr.fields[column]
+	if !ok || len(spec.ReferenceCols) > 0 {
+		return ErrFieldNotFound(column)
+	}
+	spec.Type = t
+	pr.fields[column] = spec
+	return pr.saveSpecs()
+}
+
+// validateRow checks the values of a row about to be written against the
+// declared column types.
+func (pr *Persistent) validateRow(value map[string]any) error {
+	if !pr.typed {
+		return nil
+	}
+	for _, col := range pr.columns {
+		spec := pr.fields[col]
+		if v, ok := value[col]; ok && !spec.Type.accepts(v) {
+			return ErrTypeMismatch(col, spec.Type, v)
+		}
+	}
+	return nil
+}
+
+// checkStoredRow validates a row read from storage, or only its columns when
+// set. It returns a non-nil error only in strict mode; in warn mode
+// violations go to the warning handler.
+func (pr *Persistent) checkStoredRow(value map[string]any, columns []string) error {
+	if !pr.typed {
+		return nil
+	}
+	if columns == nil {
+		columns = pr.columns
+	}
+	for _, col := range columns {
+		spec := pr.fields[col]
+		if spec.EncryptionKey != "" {
+			// Validated before encryption; the stored value may be redacted.
+			continue
+		}
+		v, ok := value[col]
+		var err error
+		switch {
+		case !ok:
+			err = ErrFieldNotFound(col)
+		case !spec.Type.accepts(v):
+			err = ErrTypeMismatch(col, spec.Type, v)
+		default:
+			continue
+		}
+		if pr.tx.db.validationMode == ValidationStrict {
+			return err
+		}
+		pr.tx.db.warn(Warning{
+			Relation: pr.relation,
+			Column:   col,
+			Code:     err.(*ThunderError).Code,
+			Message:  err.Error(),
+		})
+	}
+	return nil
+}
+
+func hasTypedColumns(columnSpecs map[string]ColumnSpec) bool {
+	for _, spec := range columnSpecs {
+		if spec.Type != TypeAny {
+			return true
+		}
+	}
+	return false
+}
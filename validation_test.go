@@ -0,0 +1,85 @@
+package thunder
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPersistent_SoftValidation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	p, err := tx.CreatePersistent("people", map[string]ColumnSpec{
+		"name": {Type: TypeString},
+		"age":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Insert(map[string]any{"name": "alice", "age": 30}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Insert(map[string]any{"name": "bob", "age": "thirty"}); err != nil {
+		t.Fatal(err)
+	}
+	// Introduce the constraint on the legacy data.
+	if err := p.SetColumnType("age", TypeInt); err != nil {
+		t.Fatal(err)
+	}
+	var thunderErr *ThunderError
+	if err := p.Insert(map[string]any{"name": "carol", "age": "forty"}); !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeTypeMismatch {
+		t.Fatalf("expected type mismatch on insert, got %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	readAll := func() (int, error) {
+		tx, err := db.Begin(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		p, err := tx.LoadPersistent("people")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seq, err := p.Select(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, err := range seq {
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		return count, nil
+	}
+
+	if _, err := readAll(); !errors.As(err, &thunderErr) || thunderErr.Code != ErrCodeTypeMismatch {
+		t.Fatalf("expected type mismatch in strict mode, got %v", err)
+	}
+
+	var warnings []Warning
+	db.SetValidationMode(ValidationWarn)
+	db.SetWarningHandler(func(w Warning) {
+		warnings = append(warnings, w)
+	})
+	count, err := readAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 rows in warn mode, got %d", count)
+	}
+	if len(warnings) != 1 || warnings[0].Column != "age" || warnings[0].Relation != "people" || warnings[0].Code != ErrCodeTypeMismatch {
+		t.Errorf("unexpected warnings: %+v", warnings)
+	}
+}
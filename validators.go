@@ -0,0 +1,125 @@
+package thunder
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Validator checks a value about to be written to a column and returns an
+// error describing why it is rejected.
+type Validator func(v any) error
+
+// Violation is a value rejected by a Validator.
+type Violation struct {
+	Column string
+	Value  any
+	Err    error
+}
+
+// ValidationError reports every violation of the validators of a relation
+// found in a row. It carries the ErrCodeValidationFailed ThunderError.
+type ValidationError struct {
+	ThunderError
+	Relation   string
+	Violations []Violation
+}
+
+func (e *ValidationError) Unwrap() error {
+	return &e.ThunderError
+}
+
+// SetValidators registers the validators run on column of relation on every
+// insert and update, replacing earlier ones. All validators of all columns
+// run, and a row with violations is rejected with a ValidationError listing
+// them. Pass no validators to remove them.
+func (d *DB) SetValidators(relation, column string, validators ...Validator) {
+	d.hooksMu.Lock()
+	defer d.hooksMu.Unlock()
+	if len(validators) == 0 {
+		delete(d.validators[relation], column)
+		return
+	}
+	if d.validators == nil {
+		d.validators = make(map[string]map[string][]Validator)
+	}
+	if d.validators[relation] == nil {
+		d.validators[relation] = make(map[string][]Validator)
+	}
+	d.validators[relation][column] = validators
+}
+
+// Match accepts strings matching re.
+func Match(re *regexp.Regexp) Validator {
+	return func(v any) error {
+		s, ok := v.(string)
+		if !ok || !re.MatchString(s) {
+			return fmt.Errorf("%v does not match %s", v, re)
+		}
+		return nil
+	}
+}
+
+// Between accepts values from min to max inclusive, in key order, so min and
+// max must be of the column's type. A nil bound is open.
+func Between(min, max any) Validator {
+	return func(v any) error {
+		key, err := ToKey(v)
+		if err != nil {
+			return err
+		}
+		for _, bound := range []struct {
+			value any
+			sign  int
+		}{{min, -1}, {max, 1}} {
+			if bound.value == nil {
+				continue
+			}
+			boundKey, err := ToKey(bound.value)
+			if err != nil {
+				return err
+			}
+			if bytes.Compare(key, boundKey) == bound.sign {
+				return fmt.Errorf("%v is not between %v and %v", v, min, max)
+			}
+		}
+		return nil
+	}
+}
+
+// OneOf accepts the listed values.
+func OneOf(values ...any) Validator {
+	return func(v any) error {
+		if !slices.Contains(values, v) {
+			return fmt.Errorf("%v is not one of %v", v, values)
+		}
+		return nil
+	}
+}
+
+// runValidators checks row against the validators registered for the
+// relation.
+func (pr *Persistent) runValidators(row map[string]any) error {
+	if pr.ephemeral || pr.tx.db == nil {
+		return nil
+	}
+	pr.tx.db.hooksMu.RLock()
+	validators := pr.tx.db.validators[pr.ruleRelation()]
+	pr.tx.db.hooksMu.RUnlock()
+	if len(validators) == 0 {
+		return nil
+	}
+	var violations []Violation
+	for _, col := range pr.columns {
+		for _, validate := range validators[col] {
+			if err := validate(row[col]); err != nil {
+				violations = append(violations, Violation{Column: col, Value: row[col], Err: err})
+			}
+		}
+	}
+	if violations == nil {
+		return nil
+	}
+	return ErrValidationFailed(pr.relation, violations)
+}
@@ -0,0 +1,100 @@
+package thunder
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"testing"
+)
+
+func TestPersistent_Validators(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetValidators("users", "email", Match(regexp.MustCompile(`^[^@]+@[^@]+$`)))
+	db.SetValidators("users", "age", Between(int64(0), int64(150)))
+	db.SetValidators("users", "role", OneOf("admin", "user"), func(v any) error {
+		if v == "root" {
+			return errors.New("root is reserved")
+		}
+		return nil
+	})
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"email": {Unique: true},
+		"age":   {Type: TypeInt},
+		"role":  {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Insert(map[string]any{"email": "a@b.c", "age": int64(30), "role": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	err = users.Insert(map[string]any{"email": "nope", "age": int64(200), "role": "root"})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	got := map[string]int{}
+	for _, v := range ve.Violations {
+		got[v.Column]++
+	}
+	if got["email"] != 1 || got["age"] != 1 || got["role"] != 2 {
+		t.Fatalf("expected every violation reported, got %v", ve.Violations)
+	}
+	var te *ThunderError
+	if !errors.As(err, &te) || te.Code != ErrCodeValidationFailed {
+		t.Fatalf("expected ErrCodeValidationFailed, got %v", err)
+	}
+
+	id, err := ToKeyRanges(Eq("email", "a@b.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Update(id, map[string]any{"age": int64(-1)}); !errors.As(err, &ve) {
+		t.Fatalf("expected updates to be validated, got %v", err)
+	}
+}
+
+func TestPersistent_ValidatorsOnUpdate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	db.SetValidators("invoices", "amount", func(v any) error {
+		if _, ok := v.(*big.Rat); !ok {
+			return fmt.Errorf("expected a decimal, got %T", v)
+		}
+		return nil
+	})
+	err := db.update(func(tx *Tx) error {
+		invoices, err := tx.CreatePersistent("invoices", map[string]ColumnSpec{
+			"id":     {Unique: true},
+			"amount": {Type: TypeDecimal},
+			"note":   {},
+		})
+		if err != nil {
+			return err
+		}
+		if err := invoices.Insert(map[string]any{"id": int64(1), "amount": big.NewRat(25, 2), "note": ""}); err != nil {
+			return err
+		}
+		// The validator sees the unchanged amount as it was written.
+		ranges, err := ToKeyRanges(Eq("id", int64(1)))
+		if err != nil {
+			return err
+		}
+		_, err = invoices.Update(ranges, map[string]any{"note": "paid"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
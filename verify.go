@@ -0,0 +1,177 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+)
+
+// IssueKind classifies an inconsistency found by Verify.
+type IssueKind uint8
+
+const (
+	// IssueDanglingEntry is an index entry whose row is gone or no longer
+	// has the entry's key.
+	IssueDanglingEntry IssueKind = iota
+	// IssueMissingEntry is a key of a row that its index lacks.
+	IssueMissingEntry
+	// IssueDuplicateKey is a key of a unique index held by several rows.
+	IssueDuplicateKey
+	// IssueCorruptEntry is an index entry or row that cannot be decoded.
+	IssueCorruptEntry
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueDanglingEntry:
+		return "dangling entry"
+	case IssueMissingEntry:
+		return "missing entry"
+	case IssueDuplicateKey:
+		return "duplicate key"
+	case IssueCorruptEntry:
+		return "corrupt entry"
+	default:
+		return "unknown"
+	}
+}
+
+// Issue is one inconsistency between the rows of a relation and an index.
+// Index is empty for a row that cannot be decoded.
+type Issue struct {
+	Kind  IssueKind
+	Index string
+	Key   []byte
+	ID    uint64
+}
+
+// VerifyReport is the result of Verify. Rows and Entries count the rows and
+// index entries checked.
+type VerifyReport struct {
+	Relation string
+	Rows     int
+	Entries  int
+	Issues   []Issue
+}
+
+// OK reports whether no issue was found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Verify cross-checks the rows of the relation with its indexes: every key of
+// every row must be in its index, every index entry must point to a row
+// holding its key, and keys of unique indexes must belong to one row. Indexes
+// still being built are skipped. Inconsistencies are reported rather than
+// returned as errors; Reindex repairs them.
+func (pr *Persistent) Verify() (*VerifyReport, error) {
+	report := &VerifyReport{Relation: pr.relation}
+	var names []string
+	for _, name := range pr.indexNames {
+		if _, building := pr.pending[name]; !building {
+			names = append(names, name)
+		}
+	}
+	queue := pr.bucket.Bucket([]byte("indexQueue"))
+	err := pr.data.bucket.ForEach(func(k, v []byte) error {
+		report.Rows++
+		var value map[string]any
+		if err := pr.data.unmarshal(k, v, &value); err != nil {
+			report.Issues = append(report.Issues, Issue{Kind: IssueCorruptEntry, ID: binary.BigEndian.Uint64(k)})
+			return nil
+		}
+		queued := queue != nil && queue.Get(k) != nil
+		for _, name := range names {
+			if queued && pr.fields[name].AsyncIndex {
+				continue
+			}
+			keys, err := pr.indexKeys(value, name)
+			if err != nil {
+				return err
+			}
+			c := pr.indexes.bucket.Bucket([]byte(name)).Cursor()
+			for _, key := range keys {
+				entry, err := ToKey(key, k)
+				if err != nil {
+					return err
+				}
+				if found, _ := c.Seek(entry); !bytes.Equal(found, entry) {
+					report.Issues = append(report.Issues, Issue{Kind: IssueMissingEntry, Index: name, Key: key, ID: binary.BigEndian.Uint64(k)})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := pr.verifyIndex(name, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// verifyIndex checks every entry of the index name against the rows.
+func (pr *Persistent) verifyIndex(name string, report *VerifyReport) error {
+	unique := pr.fields[name].Unique
+	var prev []byte
+	return pr.indexes.bucket.Bucket([]byte(name)).ForEach(func(k, _ []byte) error {
+		report.Entries++
+		key, id, ok := splitIndexEntry(k)
+		if !ok {
+			report.Issues = append(report.Issues, Issue{Kind: IssueCorruptEntry, Index: name, Key: bytes.Clone(k)})
+			return nil
+		}
+		if unique && len(key) > 0 && bytes.Equal(key, prev) {
+			report.Issues = append(report.Issues, Issue{Kind: IssueDuplicateKey, Index: name, Key: key, ID: binary.BigEndian.Uint64(id)})
+		}
+		prev = key
+		raw := pr.data.bucket.Get(id)
+		if raw == nil {
+			report.Issues = append(report.Issues, Issue{Kind: IssueDanglingEntry, Index: name, Key: key, ID: binary.BigEndian.Uint64(id)})
+			return nil
+		}
+		var value map[string]any
+		if err := pr.data.unmarshal(id, raw, &value); err != nil {
+			// Reported once, by the scan over the rows.
+			return nil
+		}
+		keys, err := pr.indexKeys(value, name)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(keys, func(k []byte) bool { return bytes.Equal(k, key) }) {
+			report.Issues = append(report.Issues, Issue{Kind: IssueDanglingEntry, Index: name, Key: key, ID: binary.BigEndian.Uint64(id)})
+		}
+		return nil
+	})
+}
+
+// splitIndexEntry decodes an index entry into the indexed key and row id.
+func splitIndexEntry(k []byte) ([]byte, []byte, bool) {
+	var parts []any
+	if err := orderedMa.Unmarshal(k, &parts); err != nil || len(parts) != 2 {
+		return nil, nil, false
+	}
+	key, ok := entryBytes(parts[0])
+	if !ok {
+		return nil, nil, false
+	}
+	id, ok := entryBytes(parts[1])
+	if !ok || len(id) != 8 {
+		return nil, nil, false
+	}
+	return key, id, true
+}
+
+func entryBytes(v any) ([]byte, bool) {
+	switch v := v.(type) {
+	case []byte:
+		return v, true
+	case string:
+		return []byte(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,83 @@
+package thunder
+
+import "testing"
+
+func TestPersistent_Verify(t *testing.T) {
+	db, err := OpenMemory(&MsgpackMaUn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	users, err := tx.CreatePersistent("users", map[string]ColumnSpec{
+		"id":   {Unique: true},
+		"name": {Indexed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		if err := users.Insert(map[string]any{"id": id, "name": "user" + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := users.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Rows != 3 || report.Entries != 6 {
+		t.Fatalf("expected a clean report over 3 rows and 6 entries, got %+v", report)
+	}
+
+	// Break the indexes behind the relation's back.
+	key := func(v any) []byte {
+		k, err := ToKey(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	id := func(n byte) []byte { return []byte{0, 0, 0, 0, 0, 0, 0, n} }
+	if err := users.indexes.delete("name", key("user1"), id(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.indexes.insert("name", key("ghost"), id(9)); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.indexes.insert("id", key("1"), id(2)); err != nil {
+		t.Fatal(err)
+	}
+	report, err = users.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[IssueKind]int{}
+	for _, issue := range report.Issues {
+		kinds[issue.Kind]++
+	}
+	// The extra id entry is both a duplicate and not a key of row 2.
+	if len(report.Issues) != 4 || kinds[IssueMissingEntry] != 1 || kinds[IssueDanglingEntry] != 2 || kinds[IssueDuplicateKey] != 1 {
+		t.Fatalf("unexpected issues %+v", report.Issues)
+	}
+
+	progress := map[string]int{}
+	if err := users.ReindexAll(func(index string, rows int) { progress[index] = rows }); err != nil {
+		t.Fatal(err)
+	}
+	if progress["id"] != 3 || progress["name"] != 3 {
+		t.Fatalf("expected 3 rows reported per index, got %v", progress)
+	}
+	if report, err = users.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.Entries != 6 {
+		t.Fatalf("expected a clean report after reindexing, got %+v", report)
+	}
+	if err := users.Reindex("missing", nil); err == nil {
+		t.Fatal("expected an unknown index to be rejected")
+	}
+}
@@ -0,0 +1,276 @@
+package thunder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ZeroCopyMaUn stores rows in a flat, length-prefixed layout and decodes
+// string and []byte values, and column names, as views of the stored bytes
+// rather than copies. Reads then allocate little more than the row map.
+//
+// The views are only valid while the transaction that read them is open and,
+// in a write transaction, only until its next write. Rows that must outlive
+// that have to be copied with CopyRow. Integers decode as int64 or uint64 and
+// floats as float64; values of other types, and anything that is not a row,
+// are stored as msgpack.
+var ZeroCopyMaUn = zeroCopyMarshalUnmarshaler{}
+
+type zeroCopyMarshalUnmarshaler struct{}
+
+// Layout tags. A row is zcRow, the number of columns and, per column, its
+// length-prefixed name, a value tag and the value.
+const (
+	zcRow byte = iota + 1
+	zcMsgpack
+)
+
+const (
+	zcNil byte = iota
+	zcFalse
+	zcTrue
+	zcInt
+	zcUint
+	zcFloat
+	zcString
+	zcBytes
+	zcOther
+)
+
+var errZeroCopyCorrupt = errors.New("thunder: corrupted zero-copy row")
+
+func (z *zeroCopyMarshalUnmarshaler) Marshal(v any) ([]byte, error) {
+	row, ok := v.(map[string]any)
+	if !ok {
+		raw, err := msgpack.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{zcMsgpack}, raw...), nil
+	}
+	buf := []byte{zcRow}
+	buf = binary.AppendUvarint(buf, uint64(len(row)))
+	for _, col := range slices.Sorted(maps.Keys(row)) {
+		buf = appendZeroCopyBytes(buf, []byte(col))
+		var err error
+		if buf, err = appendZeroCopyValue(buf, row[col]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendZeroCopyBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendZeroCopyValue(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, zcNil), nil
+	case bool:
+		if v {
+			return append(buf, zcTrue), nil
+		}
+		return append(buf, zcFalse), nil
+	case int:
+		return binary.BigEndian.AppendUint64(append(buf, zcInt), uint64(v)), nil
+	case int8:
+		return binary.BigEndian.AppendUint64(append(buf, zcInt), uint64(v)), nil
+	case int16:
+		return binary.BigEndian.AppendUint64(append(buf, zcInt), uint64(v)), nil
+	case int32:
+		return binary.BigEndian.AppendUint64(append(buf, zcInt), uint64(v)), nil
+	case int64:
+		return binary.BigEndian.AppendUint64(append(buf, zcInt), uint64(v)), nil
+	case uint:
+		return binary.BigEndian.AppendUint64(append(buf, zcUint), uint64(v)), nil
+	case uint8:
+		return binary.BigEndian.AppendUint64(append(buf, zcUint), uint64(v)), nil
+	case uint16:
+		return binary.BigEndian.AppendUint64(append(buf, zcUint), uint64(v)), nil
+	case uint32:
+		return binary.BigEndian.AppendUint64(append(buf, zcUint), uint64(v)), nil
+	case uint64:
+		return binary.BigEndian.AppendUint64(append(buf, zcUint), v), nil
+	case float32:
+		return binary.BigEndian.AppendUint64(append(buf, zcFloat), math.Float64bits(float64(v))), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, zcFloat), math.Float64bits(v)), nil
+	case string:
+		return appendZeroCopyBytes(append(buf, zcString), []byte(v)), nil
+	case []byte:
+		return appendZeroCopyBytes(append(buf, zcBytes), v), nil
+	}
+	raw, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendZeroCopyBytes(append(buf, zcOther), raw), nil
+}
+
+func (z *zeroCopyMarshalUnmarshaler) Unmarshal(data []byte, v any) error {
+	if len(data) == 0 {
+		return errZeroCopyCorrupt
+	}
+	if data[0] == zcMsgpack {
+		return msgpack.Unmarshal(data[1:], v)
+	}
+	if data[0] != zcRow {
+		return errZeroCopyCorrupt
+	}
+	if row, ok := v.(*map[string]any); ok {
+		return decodeZeroCopyRow(data[1:], nil, row)
+	}
+	// A row read into something else, such as a struct: go through msgpack.
+	var row map[string]any
+	if err := decodeZeroCopyRow(data[1:], nil, &row); err != nil {
+		return err
+	}
+	raw, err := msgpack.Marshal(CopyRow(row))
+	if err != nil {
+		return err
+	}
+	return msgpack.Unmarshal(raw, v)
+}
+
+// UnmarshalColumns skips the values of the columns not asked for.
+func (z *zeroCopyMarshalUnmarshaler) UnmarshalColumns(data []byte, columns []string, v *map[string]any) error {
+	if len(data) == 0 || data[0] != zcRow {
+		return z.Unmarshal(data, v)
+	}
+	return decodeZeroCopyRow(data[1:], columns, v)
+}
+
+func decodeZeroCopyRow(data []byte, columns []string, v *map[string]any) error {
+	n, data, err := zeroCopyUvarint(data)
+	if err != nil {
+		return err
+	}
+	if *v == nil {
+		*v = make(map[string]any, n)
+	}
+	row := *v
+	for range n {
+		var key []byte
+		if key, data, err = zeroCopyBytes(data); err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return errZeroCopyCorrupt
+		}
+		tag := data[0]
+		data = data[1:]
+		skip := columns != nil && !slices.Contains(columns, unsafeString(key))
+		var value any
+		switch tag {
+		case zcNil:
+		case zcFalse:
+			value = false
+		case zcTrue:
+			value = true
+		case zcInt, zcUint, zcFloat:
+			if len(data) < 8 {
+				return errZeroCopyCorrupt
+			}
+			bits := binary.BigEndian.Uint64(data)
+			data = data[8:]
+			switch tag {
+			case zcInt:
+				value = int64(bits)
+			case zcUint:
+				value = bits
+			default:
+				value = math.Float64frombits(bits)
+			}
+		case zcString, zcBytes, zcOther:
+			var b []byte
+			if b, data, err = zeroCopyBytes(data); err != nil {
+				return err
+			}
+			switch {
+			case skip:
+			case tag == zcString:
+				value = unsafeString(b)
+			case tag == zcBytes:
+				value = b
+			default:
+				if err := msgpack.Unmarshal(b, &value); err != nil {
+					return err
+				}
+			}
+		default:
+			return errZeroCopyCorrupt
+		}
+		if !skip {
+			row[unsafeString(key)] = value
+		}
+	}
+	return nil
+}
+
+func zeroCopyUvarint(data []byte) (uint64, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return 0, nil, errZeroCopyCorrupt
+	}
+	return n, data[size:], nil
+}
+
+func zeroCopyBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := zeroCopyUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(data)) < n {
+		return nil, nil, errZeroCopyCorrupt
+	}
+	// Cap the view so appending to it cannot overwrite what follows.
+	return data[:n:n], data[n:], nil
+}
+
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// CopyRow returns a copy of row that owns its column names and its string and
+// []byte values, so that it stays valid after the transaction it was read in.
+// Rows decoded by other codecs already own their memory; copying them is
+// harmless.
+func CopyRow(row map[string]any) map[string]any {
+	if row == nil {
+		return nil
+	}
+	out := make(map[string]any, len(row))
+	for col, v := range row {
+		switch b := v.(type) {
+		case string:
+			v = strings.Clone(b)
+		case []byte:
+			v = bytes.Clone(b)
+		}
+		out[strings.Clone(col)] = v
+	}
+	return out
+}
+
+// ownedRow returns row, copied with CopyRow if it may reference the stored
+// bytes of a transaction.
+func ownedRow(maUn MarshalUnmarshaler, row map[string]any) map[string]any {
+	if _, ok := maUn.(*zeroCopyMarshalUnmarshaler); ok {
+		return CopyRow(row)
+	}
+	return row
+}
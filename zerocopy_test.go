@@ -0,0 +1,72 @@
+package thunder
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestZeroCopyMaUn(t *testing.T) {
+	db, cleanup := setupTestDBWithMaUn(t, &ZeroCopyMaUn)
+	defer cleanup()
+
+	stamp := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var kept map[string]any
+	err := db.update(func(tx *Tx) error {
+		p, err := tx.CreatePersistent("files", map[string]ColumnSpec{
+			"path": {Unique: true},
+			"size": {Indexed: true},
+			"data": {},
+			"tags": {},
+			"seen": {},
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range []map[string]any{
+			{"path": "/a", "size": int64(3), "data": []byte("abc"), "tags": []any{"x"}, "seen": stamp},
+			{"path": "/b", "size": int64(5), "data": []byte{}, "tags": nil, "seen": stamp},
+		} {
+			if err := p.Insert(row); err != nil {
+				return err
+			}
+		}
+		ranges, err := ToKeyRanges(Eq("path", "/a"))
+		if err != nil {
+			return err
+		}
+		seq, err := p.Select(ranges)
+		if err != nil {
+			return err
+		}
+		for row, err := range seq {
+			if err != nil {
+				return err
+			}
+			kept = CopyRow(row)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept["path"] != "/a" || kept["size"] != int64(3) || !bytes.Equal(kept["data"].([]byte), []byte("abc")) {
+		t.Fatalf("unexpected copied row %v", kept)
+	}
+	if seen, ok := kept["seen"].(time.Time); !ok || !seen.Equal(stamp) {
+		t.Fatalf("expected the time to round-trip, got %#v", kept["seen"])
+	}
+
+	// Rows returned by DB.Select stay valid after its transaction.
+	ranges, err := ToKeyRanges(Ge("size", int64(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Select("files", ranges, ConsistencyLatest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["path"] != "/b" || rows[0]["data"] == nil || len(rows[0]["data"].([]byte)) != 0 {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}